@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+	"github.com/yiqi-017/blockchain/wallet"
+)
+
+// maxBumpAttempts 提高手续费后交易体积可能略有变化（签名长度），最多重建的次数
+const maxBumpAttempts = 4
+
+// bumpFee 以更高手续费重建并重新签名钱包发出的池内交易，替换原交易及其后代，返回新交易和手续费
+// 保留原交易的输入和收款输出，从找零中扣除新增手续费；找零不足时追加钱包的可用输出
+// newFee 为 0 时取满足替换规则的最低手续费
+func bumpFee(store *storage.FileStorage, walletPath string, scheme crypto.Scheme, passphrase, txid string, newFee int64, opts txOptions) (*core.Transaction, int64, error) {
+	pool, err := store.LoadTxPool()
+	if err != nil {
+		return nil, 0, err
+	}
+	orig, ok := pool.Get(txid)
+	if !ok {
+		return nil, 0, fmt.Errorf("交易池中没有交易 %s", txid)
+	}
+	if !core.SignalsReplacement(orig) {
+		return nil, 0, fmt.Errorf("交易 %s 未选择加入替换（发送时需指定 -rbf）", txid)
+	}
+
+	sw, err := loadSigningWallet(walletPath, scheme, passphrase)
+	if err != nil {
+		return nil, 0, err
+	}
+	owners := sw.owners()
+	blocks, err := loadAllBlocks(store)
+	if err != nil {
+		return nil, 0, err
+	}
+	utxos := core.BuildUTXOSet(blocks)
+
+	// 原交易的输入必须全部属于本钱包
+	var coins []core.UTXO
+	for i, in := range orig.Inputs {
+		out, ok := pool.SpentOutput(in, utxos)
+		if !ok {
+			return nil, 0, fmt.Errorf("input %d of %s not found", i, txid)
+		}
+		if _, ok := owners[out.ScriptPubKey]; !ok {
+			return nil, 0, fmt.Errorf("input %d of %s does not belong to this wallet", i, txid)
+		}
+		coins = append(coins, core.UTXO{TxID: in.TxID, Index: in.Vout, Output: out})
+	}
+	origFee, err := pool.TxFee(orig, utxos)
+	if err != nil {
+		return nil, 0, err
+	}
+	var evictedFees int64
+	for _, key := range pool.Descendants([]string{txid}) {
+		tx, _ := pool.Get(key)
+		f, err := pool.TxFee(tx, utxos)
+		if err != nil {
+			return nil, 0, err
+		}
+		evictedFees += f
+	}
+
+	// 原交易最后一个发往钱包地址的输出视为找零，其余为收款输出
+	recipients := append([]core.TxOutput(nil), orig.Outputs...)
+	changeAddr := ""
+	if n := len(recipients); n > 0 {
+		if _, ok := owners[recipients[n-1].ScriptPubKey]; ok {
+			changeAddr = recipients[n-1].ScriptPubKey
+			recipients = recipients[:n-1]
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, 0, fmt.Errorf("交易 %s 没有收款输出", txid)
+	}
+	change := func() (string, error) {
+		if changeAddr == "" {
+			k, err := sw.nextChange()
+			if err != nil {
+				return "", err
+			}
+			changeAddr = crypto.PublicKeyHex(k.PublicKey)
+		}
+		return changeAddr, nil
+	}
+
+	tracker, spendHeight, err := openWalletTracker(store, walletPath, opts.params)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 追加输入的候选：钱包可用输出，金额从大到小
+	var extra []core.UTXO
+	for _, u := range tracker.SpendableCoins(spendHeight) {
+		if _, ok := owners[u.Output.ScriptPubKey]; ok {
+			extra = append(extra, u)
+		}
+	}
+	sort.SliceStable(extra, func(i, j int) bool { return extra[i].Output.Value > extra[j].Output.Value })
+
+	fee := newFee
+	if fee <= evictedFees {
+		fee = evictedFees + 1
+	}
+	origSize := core.TxSize(orig)
+	for attempt := 0; attempt < maxBumpAttempts; attempt++ {
+		tx, paid, err := buildReplacement(coins, extra, recipients, change, orig, fee, opts.fees.DustLimit, owners)
+		if err != nil {
+			return nil, 0, err
+		}
+		size := core.TxSize(tx)
+		if paid*int64(origSize) <= origFee*int64(size) {
+			// 手续费率未超过原交易，按新体积提高到刚好超过
+			fee = origFee*int64(size)/int64(origSize) + 1
+			continue
+		}
+		evicted, err := pool.Submit(tx, utxos)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := sw.save(); err != nil {
+			return nil, 0, err
+		}
+		if err := store.SaveTxPool(pool); err != nil {
+			return nil, 0, err
+		}
+		tracker.SyncPending(pool.Pending())
+		if err := storage.SaveWalletState(storage.WalletStatePath(walletPath), tracker.Snapshot()); err != nil {
+			return nil, 0, err
+		}
+		log.Printf("交易 %s 已被替换：新 id=%x，手续费 %d -> %d，驱逐 %d 笔", txid, tx.ID, origFee, paid, len(evicted))
+		return tx, paid, nil
+	}
+	return nil, 0, fmt.Errorf("无法构造手续费率高于原交易的替换交易")
+}
+
+// buildReplacement 以 coins 为必选输入、按需追加 extra，构造支付 recipients 和 fee 的签名交易，返回交易和实付手续费
+// 找零低于粉尘阈值时并入手续费
+func buildReplacement(coins, extra []core.UTXO, recipients []core.TxOutput, change func() (string, error), orig *core.Transaction, fee, dust int64, owners map[string]*crypto.Wallet) (*core.Transaction, int64, error) {
+	value, err := sumOutputs(recipients)
+	if err != nil {
+		return nil, 0, err
+	}
+	inputs := append([]core.UTXO(nil), coins...)
+	total := int64(0)
+	for _, u := range inputs {
+		total += u.Output.Value
+	}
+	for _, u := range extra {
+		if total >= value+fee {
+			break
+		}
+		inputs = append(inputs, u)
+		total += u.Output.Value
+	}
+	if total < value+fee {
+		return nil, 0, fmt.Errorf("余额不足以支付手续费 %d", fee)
+	}
+
+	outputs := append([]core.TxOutput(nil), recipients...)
+	if rest := total - value - fee; rest > 0 && rest >= dust {
+		addr, err := change()
+		if err != nil {
+			return nil, 0, err
+		}
+		outputs = append(outputs, core.TxOutput{Value: rest, ScriptPubKey: addr})
+	}
+	p, err := wallet.NewPartialTx(inputs, outputs, orig.LockTime, orig.Inputs[0].Sequence, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := p.Sign(func(in wallet.PartialInput) (*crypto.Wallet, error) {
+		return owners[in.Address], nil
+	}); err != nil {
+		return nil, 0, err
+	}
+	tx, err := p.Finalize()
+	if err != nil {
+		return nil, 0, err
+	}
+	return tx, p.Fee(), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestBumpFee -rbf 发出的交易可用 bumpfee 提高手续费替换；未选择加入的交易不能替换
+func TestBumpFee(t *testing.T) {
+	base := t.TempDir()
+	walletPath := filepath.Join(base, "r1", "wallet.json")
+	key, err := storage.LoadOrCreateWallet(walletPath, "")
+	if err != nil {
+		t.Fatalf("load wallet: %v", err)
+	}
+	addr := crypto.PublicKeyHex(key.PublicKey)
+	common := []string{"-node", "r1", "-data", base, "-difficulty", "4", "-coinbase-maturity", "1", "-wallet", walletPath, "-no-encrypt"}
+	run := func(extra ...string) error {
+		return Run(append(append([]string{}, common...), extra...))
+	}
+	for _, args := range [][]string{
+		{"-mode", "init"},
+		{"-mode", "mine", "-miner", addr},
+		{"-mode", "mine", "-miner", addr},
+		{"-mode", "mine", "-miner", "bob"},
+	} {
+		if err := run(args...); err != nil {
+			t.Fatalf("run %v: %v", args, err)
+		}
+	}
+	store, err := storage.NewFileStorage(base, "r1")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	onlyTx := func() *core.Transaction {
+		t.Helper()
+		pool, err := store.LoadTxPool()
+		if err != nil {
+			t.Fatalf("load pool: %v", err)
+		}
+		txs := pool.Pending()
+		if len(txs) != 1 {
+			t.Fatalf("expect one pool tx, got %d", len(txs))
+		}
+		return txs[0]
+	}
+
+	if err := run("-mode", "tx", "-to", "alice", "-value", "10", "-fee-base", "1"); err != nil {
+		t.Fatalf("tx: %v", err)
+	}
+	final := onlyTx()
+	if err := run("-mode", "bumpfee", "-txid", fmt.Sprintf("%x", final.ID)); err == nil {
+		t.Fatalf("bumpfee on a non-replaceable tx should fail")
+	}
+	if err := run("-mode", "mine", "-miner", "bob"); err != nil {
+		t.Fatalf("mine: %v", err)
+	}
+
+	if err := run("-mode", "tx", "-to", "alice", "-value", "10", "-fee-base", "1", "-rbf"); err != nil {
+		t.Fatalf("rbf tx: %v", err)
+	}
+	orig := onlyTx()
+	if !core.SignalsReplacement(orig) {
+		t.Fatalf("-rbf tx should signal replaceability")
+	}
+	if err := run("-mode", "bumpfee", "-txid", fmt.Sprintf("%x", orig.ID), "-new-fee", "4"); err != nil {
+		t.Fatalf("bumpfee: %v", err)
+	}
+	bumped := onlyTx()
+	if fmt.Sprintf("%x", bumped.ID) == fmt.Sprintf("%x", orig.ID) {
+		t.Fatalf("original tx should be replaced")
+	}
+	if bumped.Outputs[0].ScriptPubKey != "alice" || bumped.Outputs[0].Value != 10 {
+		t.Fatalf("payment output should be preserved: %+v", bumped.Outputs)
+	}
+	if got := bumped.Outputs[1].Value; got != orig.Outputs[1].Value-3 {
+		t.Fatalf("change should shrink by the extra fee: %d -> %d", orig.Outputs[1].Value, got)
+	}
+}
@@ -12,7 +12,7 @@ import (
 func TestCLIFlagFlow(t *testing.T) {
 	base := t.TempDir()
 	walletPath := filepath.Join(base, "cli1", "wallet.json")
-	w, err := storage.LoadOrCreateWallet(walletPath)
+	w, err := storage.LoadOrCreateWallet(walletPath, "")
 	if err != nil {
 		t.Fatalf("load wallet: %v", err)
 	}
@@ -29,7 +29,7 @@ func TestCLIFlagFlow(t *testing.T) {
 		t.Fatalf("run init: %v", err)
 	}
 
-	// 先挖一个块获取余额
+	// 先挖一个块获取余额（下方 tx/mine 将成熟深度调为 1，便于立即花费）
 	if err := Run([]string{
 		"-mode", "mine",
 		"-node", "cli1",
@@ -48,6 +48,8 @@ func TestCLIFlagFlow(t *testing.T) {
 		"-to", "alice",
 		"-value", "5",
 		"-wallet", walletPath,
+		"-coinbase-maturity", "1",
+		"-no-encrypt",
 	}); err != nil {
 		t.Fatalf("run tx: %v", err)
 	}
@@ -59,6 +61,7 @@ func TestCLIFlagFlow(t *testing.T) {
 		"-data", base,
 		"-miner", minerAddr,
 		"-difficulty", "4",
+		"-coinbase-maturity", "1",
 	}); err != nil {
 		t.Fatalf("run mine: %v", err)
 	}
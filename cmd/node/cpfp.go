@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+	"github.com/yiqi-017/blockchain/wallet"
+)
+
+// childPaysForParent 花费池内未确认交易 txid 中属于本钱包的输出，扣除 fee 后转回钱包找零地址
+// 子交易须与父交易一同打包，矿工按父子包的合计手续费率挑选，从而加速手续费过低的转入
+// 返回子交易及加入后父子（含更早的池内祖先）包的汇总
+func childPaysForParent(store *storage.FileStorage, walletPath string, scheme crypto.Scheme, passphrase, txid string, fee int64, opts txOptions) (*core.Transaction, core.TxPackage, error) {
+	pool, err := store.LoadTxPool()
+	if err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	parent, ok := pool.Get(txid)
+	if !ok {
+		return nil, core.TxPackage{}, fmt.Errorf("交易池中没有交易 %s", txid)
+	}
+	sw, err := loadSigningWallet(walletPath, scheme, passphrase)
+	if err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	owners := sw.owners()
+	blocks, err := loadAllBlocks(store)
+	if err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	utxos := core.BuildUTXOSet(blocks)
+	before, err := pool.AncestorPackage(txid, utxos)
+	if err != nil {
+		return nil, core.TxPackage{}, err
+	}
+
+	// 父交易中发往本钱包且尚未被池内交易花费的输出
+	parentID := core.ComputeTxID(parent)
+	spent := make(map[int]struct{})
+	for _, tx := range pool.Pending() {
+		for _, in := range tx.Inputs {
+			if bytes.Equal(in.TxID, parentID) {
+				spent[in.Vout] = struct{}{}
+			}
+		}
+	}
+	var coins []core.UTXO
+	var total int64
+	for i, out := range parent.Outputs {
+		if _, ok := owners[out.ScriptPubKey]; !ok {
+			continue
+		}
+		if _, ok := spent[i]; ok {
+			continue
+		}
+		coins = append(coins, core.UTXO{TxID: parentID, Index: i, Output: out})
+		total += out.Value
+	}
+	if len(coins) == 0 {
+		return nil, core.TxPackage{}, fmt.Errorf("交易 %s 没有属于本钱包的未花费输出", txid)
+	}
+	rest := total - fee
+	if rest <= 0 || rest < opts.fees.DustLimit {
+		return nil, core.TxPackage{}, fmt.Errorf("钱包输出合计 %d 不足以支付子交易手续费 %d", total, fee)
+	}
+
+	changeKey, err := sw.nextChange()
+	if err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	outputs := []core.TxOutput{{Value: rest, ScriptPubKey: crypto.PublicKeyHex(changeKey.PublicKey)}}
+	p, err := wallet.NewPartialTx(coins, outputs, 0, opts.sequence, nil)
+	if err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	if _, err := p.Sign(func(in wallet.PartialInput) (*crypto.Wallet, error) {
+		return owners[in.Address], nil
+	}); err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	child, err := p.Finalize()
+	if err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	if _, err := pool.Submit(child, utxos); err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	after, err := pool.AncestorPackage(crypto.HexEncode(child.ID), utxos)
+	if err != nil {
+		return nil, core.TxPackage{}, err
+	}
+
+	if err := sw.save(); err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	if err := store.SaveTxPool(pool); err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	tracker, _, err := openWalletTracker(store, walletPath, opts.params)
+	if err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	tracker.SyncPending(pool.Pending())
+	if err := storage.SaveWalletState(storage.WalletStatePath(walletPath), tracker.Snapshot()); err != nil {
+		return nil, core.TxPackage{}, err
+	}
+	log.Printf("子交易 %x 为 %s 付费：包手续费 %d/%dB -> %d/%dB", child.ID, txid, before.Fee, before.Size, after.Fee, after.Size)
+	return child, after, nil
+}
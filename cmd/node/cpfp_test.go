@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestCPFP 收款方花费未确认转入并支付高手续费；矿工一并打包父子交易并领取全部手续费
+func TestCPFP(t *testing.T) {
+	base := t.TempDir()
+	payerPath := filepath.Join(base, "c1", "payer.json")
+	payee := filepath.Join(base, "c1", "payee.json")
+	payer, err := storage.LoadOrCreateWallet(payerPath, "")
+	if err != nil {
+		t.Fatalf("payer wallet: %v", err)
+	}
+	payeeKey, err := storage.LoadOrCreateWallet(payee, "")
+	if err != nil {
+		t.Fatalf("payee wallet: %v", err)
+	}
+	payerAddr := crypto.PublicKeyHex(payer.PublicKey)
+	common := []string{"-node", "c1", "-data", base, "-difficulty", "4", "-coinbase-maturity", "1", "-no-encrypt"}
+	run := func(extra ...string) error {
+		return Run(append(append([]string{}, common...), extra...))
+	}
+	for _, args := range [][]string{
+		{"-mode", "init"},
+		{"-mode", "mine", "-miner", payerAddr},
+		{"-mode", "mine", "-miner", "bob"},
+		{"-mode", "tx", "-wallet", payerPath, "-to", crypto.PublicKeyHex(payeeKey.PublicKey), "-value", "20", "-fee-base", "1"},
+	} {
+		if err := run(args...); err != nil {
+			t.Fatalf("run %v: %v", args, err)
+		}
+	}
+	store, err := storage.NewFileStorage(base, "c1")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	pool, err := store.LoadTxPool()
+	if err != nil {
+		t.Fatalf("load pool: %v", err)
+	}
+	pending := pool.Pending()
+	if len(pending) != 1 {
+		t.Fatalf("expect one pool tx, got %d", len(pending))
+	}
+	parentID := fmt.Sprintf("%x", pending[0].ID)
+
+	if err := run("-mode", "cpfp", "-wallet", payee, "-txid", parentID); err == nil {
+		t.Fatalf("cpfp without -new-fee should fail")
+	}
+	if err := run("-mode", "cpfp", "-wallet", payerPath, "-txid", "00"); err == nil {
+		t.Fatalf("cpfp on an unknown tx should fail")
+	}
+	if err := run("-mode", "cpfp", "-wallet", payee, "-txid", parentID, "-new-fee", "5"); err != nil {
+		t.Fatalf("cpfp: %v", err)
+	}
+	if pool, err = store.LoadTxPool(); err != nil {
+		t.Fatalf("load pool: %v", err)
+	}
+	if pool.Size() != 2 {
+		t.Fatalf("expect parent and child in pool, got %d", pool.Size())
+	}
+	blocks, err := loadAllBlocks(store)
+	if err != nil {
+		t.Fatalf("load blocks: %v", err)
+	}
+	for _, key := range pool.Descendants([]string{parentID}) {
+		if key == parentID {
+			continue
+		}
+		pkg, err := pool.AncestorPackage(key, core.BuildUTXOSet(blocks))
+		if err != nil || pkg.Count != 2 || pkg.Fee != 6 {
+			t.Fatalf("package of child: %+v, %v", pkg, err)
+		}
+	}
+
+	if err := run("-mode", "mine", "-miner", "bob"); err != nil {
+		t.Fatalf("mine: %v", err)
+	}
+	tip, err := loadTip(store)
+	if err != nil {
+		t.Fatalf("tip: %v", err)
+	}
+	if len(tip.Transactions) != 3 {
+		t.Fatalf("block should include parent and child, got %d txs", len(tip.Transactions))
+	}
+	if got := tip.Transactions[0].Outputs[0].Value; got != core.BlockSubsidy+6 {
+		t.Fatalf("coinbase should collect fees: %d", got)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestHDWalletFlow newwallet -> mine -> tx（找零到新地址）-> recover 恢复相同计数
+func TestHDWalletFlow(t *testing.T) {
+	base := t.TempDir()
+	walletPath := filepath.Join(base, "hd1", "wallet.json")
+	common := []string{"-node", "hd1", "-data", base, "-difficulty", "4", "-coinbase-maturity", "1", "-wallet", walletPath, "-no-encrypt"}
+	run := func(extra ...string) {
+		t.Helper()
+		if err := Run(append(append([]string{}, common...), extra...)); err != nil {
+			t.Fatalf("run %v: %v", extra, err)
+		}
+	}
+
+	run("-mode", "init")
+	run("-mode", "newwallet")
+	addr, err := storage.WalletAddress(walletPath)
+	if err != nil {
+		t.Fatalf("wallet address: %v", err)
+	}
+	run("-mode", "mine", "-miner", addr)
+	run("-mode", "mine", "-miner", "bob")
+
+	run("-mode", "tx", "-to", "alice", "-value", "5")
+	hd, err := storage.LoadHDWallet(walletPath, "")
+	if err != nil {
+		t.Fatalf("load hd wallet: %v", err)
+	}
+	if hd.NextChange != 1 {
+		t.Fatalf("expected one change address allocated, got %d", hd.NextChange)
+	}
+	changeKey, _ := hd.DeriveKey(true, 0)
+	changeAddr := crypto.PublicKeyHex(changeKey.PublicKey)
+	if changeAddr == addr {
+		t.Fatalf("change should go to a fresh address")
+	}
+	run("-mode", "mine", "-miner", "bob")
+
+	// 第二笔交易花费找零输出，再分配新的找零地址
+	run("-mode", "tx", "-to", "alice", "-value", "40")
+	run("-mode", "mine", "-miner", "bob")
+
+	recovered := filepath.Join(base, "hd1", "recovered.json")
+	if err := Run(append(append([]string{}, common...), "-wallet", recovered, "-mode", "recover", "-mnemonic", hd.Mnemonic)); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	got, err := storage.LoadHDWallet(recovered, "")
+	if err != nil {
+		t.Fatalf("load recovered: %v", err)
+	}
+	if got.NextReceive != 1 || got.NextChange != 2 {
+		t.Fatalf("recovered counters receive=%d change=%d, want 1/2", got.NextReceive, got.NextChange)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/network"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// loadHeaderChain 以硬编码创世区块头为锚点，重新校验并加载本地保存的区块头链
+func loadHeaderChain(store *storage.FileStorage, minDifficulty uint32) (*core.HeaderChain, error) {
+	genesis := core.GenesisBlock().Header
+	chain, err := core.NewHeaderChain(genesis, minDifficulty)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := store.LoadHeaders()
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) == 0 {
+		return chain, nil
+	}
+	if !bytes.Equal(core.HashBlockHeader(&headers[0]), core.HashBlockHeader(&genesis)) {
+		return nil, fmt.Errorf("saved headers do not start with the genesis block")
+	}
+	for _, h := range headers[1:] {
+		if err := chain.Append(h); err != nil {
+			return nil, fmt.Errorf("saved headers invalid: %w", err)
+		}
+	}
+	return chain, nil
+}
+
+// runLight 轻节点：从全节点同步并校验区块头，以紧凑过滤器在本地匹配钱包地址，只下载命中的区块，打印余额
+// 只保存区块头
+func runLight(store *storage.FileStorage, walletPath, peer string, params core.ChainParams) error {
+	addresses, err := storage.WalletAddresses(walletPath)
+	if err != nil {
+		return err
+	}
+	chain, err := loadHeaderChain(store, params.MinDifficulty)
+	if err != nil {
+		return err
+	}
+	client := network.NewLightClient(peer, chain)
+	client.Params = &params
+	added, err := client.SyncHeaders()
+	if err != nil {
+		return fmt.Errorf("sync headers: %w", err)
+	}
+	if err := store.SaveHeaders(client.Chain.Headers()); err != nil {
+		return err
+	}
+	fmt.Printf("区块头高度：%d（新增 %d）\n", client.Chain.Height(), added)
+
+	w, fetched, err := client.ScanWallet(addresses)
+	if err != nil {
+		return fmt.Errorf("scan wallet: %w", err)
+	}
+	spendHeight := client.Chain.Height() + 1
+	b := w.Balance(spendHeight)
+	fmt.Printf("地址数：%d，下载区块：%d\n", len(addresses), fetched)
+	fmt.Printf("已确认：%d（未成熟 %d）\n", b.Confirmed, b.Immature)
+	fmt.Printf("可用：%d\n", b.Spendable)
+	balances := w.AddressBalances(spendHeight)
+	for _, a := range w.Addresses() {
+		if ab := balances[a]; ab.Confirmed != 0 {
+			fmt.Printf("  %s  已确认 %d\n", a, ab.Confirmed)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/network"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestLightMode 轻节点从全节点同步区块头并保存，重新加载时逐个复核；难度下限高于链上区块时拒绝同步
+func TestLightMode(t *testing.T) {
+	base := t.TempDir()
+	payerPath := filepath.Join(base, "full", "payer.json")
+	payeePath := filepath.Join(base, "phone", "payee.json")
+	payer, err := storage.LoadOrCreateWallet(payerPath, "")
+	if err != nil {
+		t.Fatalf("payer wallet: %v", err)
+	}
+	payee, err := storage.LoadOrCreateWallet(payeePath, "")
+	if err != nil {
+		t.Fatalf("payee wallet: %v", err)
+	}
+	payeeAddr := crypto.PublicKeyHex(payee.PublicKey)
+	full := []string{"-node", "full", "-data", base, "-difficulty", "4", "-coinbase-maturity", "1", "-no-encrypt"}
+	for _, args := range [][]string{
+		{"-mode", "init"},
+		{"-mode", "mine", "-miner", crypto.PublicKeyHex(payer.PublicKey)},
+		{"-mode", "mine", "-miner", "bob"},
+		{"-mode", "tx", "-wallet", payerPath, "-to", payeeAddr, "-value", "20", "-fee-base", "1"},
+		{"-mode", "mine", "-miner", "bob"},
+	} {
+		if err := Run(append(append([]string{}, full...), args...)); err != nil {
+			t.Fatalf("run %v: %v", args, err)
+		}
+	}
+	fullStore, err := storage.NewFileStorage(base, "full")
+	if err != nil {
+		t.Fatalf("full store: %v", err)
+	}
+	srv := httptest.NewServer((&network.NodeServer{NodeID: "full", Store: fullStore}).Handler())
+	defer srv.Close()
+
+	light := []string{"-node", "phone", "-data", base, "-mode", "light", "-wallet", payeePath, "-node-url", srv.URL}
+	if err := Run(append(append([]string{}, light...), "-min-difficulty", "5")); err == nil {
+		t.Fatalf("headers below the minimum difficulty should be rejected")
+	}
+	if err := Run(append(append([]string{}, light...), "-min-difficulty", "4")); err != nil {
+		t.Fatalf("light sync: %v", err)
+	}
+	phone, err := storage.NewFileStorage(base, "phone")
+	if err != nil {
+		t.Fatalf("phone store: %v", err)
+	}
+	chain, err := loadHeaderChain(phone, 4)
+	if err != nil {
+		t.Fatalf("reload headers: %v", err)
+	}
+	if chain.Height() != 3 {
+		t.Fatalf("expect header height 3, got %d", chain.Height())
+	}
+	if _, err := loadHeaderChain(phone, 5); err == nil {
+		t.Fatalf("saved headers should be rechecked against the minimum difficulty")
+	}
+
+	client := network.NewLightClient(srv.URL, chain)
+	w, fetched, err := client.ScanWallet([]string{payeeAddr})
+	if err != nil {
+		t.Fatalf("scan wallet: %v", err)
+	}
+	if b := w.Balance(chain.Height() + 1); fetched != 1 || b.Confirmed != 20 {
+		t.Fatalf("payee: fetched=%d balance=%+v", fetched, b)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -13,6 +15,7 @@ import (
 	"github.com/yiqi-017/blockchain/crypto"
 	"github.com/yiqi-017/blockchain/network"
 	"github.com/yiqi-017/blockchain/storage"
+	"github.com/yiqi-017/blockchain/wallet"
 )
 
 // 简易 CLI：支持初始化链、提交交易、单次挖矿、启动 HTTP 服务并同步
@@ -20,8 +23,24 @@ import (
 //
 //	go run ./cmd/node -mode init -node node1
 //	go run ./cmd/node -mode tx   -node node1 -to alice -value 12
+//	go run ./cmd/node -mode newwallet -node node1
+//	go run ./cmd/node -mode recover -node node1 -mnemonic "word1 word2 ..."
+//	go run ./cmd/node -mode tx -node node1 -to alice -value 12 -rbf
+//	go run ./cmd/node -mode bumpfee -node node1 -txid <txid> -new-fee 3
+//	go run ./cmd/node -mode balance -node node1
+//	go run ./cmd/node -mode gettx -node node1 -txid <txid>
+//	go run ./cmd/node -mode light -node laptop -wallet watch.json -node-url http://127.0.0.1:8080
+//	go run ./cmd/node -mode import -node node1 -wallet watch.json -watch <pubkey-hex> -xpub <account_pub>
+//	go run ./cmd/node -mode pay -node node1 -pay-to alice=5,bob=7 -pay-file payouts.csv
+//	go run ./cmd/node -mode create -node node1 -to alice -value 12 -psbt tx.psbt.json
+//	go run ./cmd/node -mode create -node node1 -wallet watch.json -from <account_pub> -to alice -value 12
+//	go run ./cmd/node -mode sign -wallet cold.json -psbt tx.psbt.json
+//	go run ./cmd/node -mode broadcast -psbt tx.psbt.json -node-url http://127.0.0.1:8080
 //	go run ./cmd/node -mode mine -node node1 -miner bob -difficulty 12
 //	go run ./cmd/node -mode serve -node node1 -addr :8080 -peers http://127.0.0.1:8081,http://127.0.0.1:8082
+//	go run ./cmd/node -mode serve -node node3 -addr :8082 -seeds http://127.0.0.1:8080 -advertise http://127.0.0.1:8082
+//	go run ./cmd/node -mode serve -node node1 -addr :8080 -ban-duration 1h
+//	go run ./cmd/node -mode serve -node node2 -addr :8081 -p2p-addr :9081 -p2p-peers 127.0.0.1:9080
 func main() {
 	if err := Run(os.Args[1:]); err != nil {
 		log.Fatal(err)
@@ -32,51 +51,273 @@ func main() {
 func Run(args []string) error {
 	fs := flag.NewFlagSet("node", flag.ContinueOnError)
 
-	mode := fs.String("mode", "init", "init | tx | mine | serve")
+	mode := fs.String("mode", "init", "init | tx | pay | bumpfee | cpfp | create | sign | broadcast | mine | serve | newwallet | newaddr | recover | import | balance | history | gettx | light")
 	nodeID := fs.String("node", "node1", "节点标识，用于隔离数据目录")
 	dataDir := fs.String("data", "./data", "数据目录")
 	miner := fs.String("miner", "miner", "挖矿奖励接收者（coinbase 输出脚本）")
+	coinbaseTag := fs.String("coinbase-tag", "", "写入 coinbase 的矿工标签（mode=mine，可选）")
 	to := fs.String("to", "", "交易接收者脚本（用于 mode=tx）")
-	walletPath := fs.String("wallet", "", "钱包文件路径（mode=tx 使用，默认 data/<node>/wallet.json）")
+	walletPath := fs.String("wallet", "", "钱包文件路径（mode=tx/newwallet/newaddr/recover 使用，默认 data/<node>/wallet.json）")
+	mnemonic := fs.String("mnemonic", "", "从助记词恢复 HD 钱包（mode=recover）")
+	schemeName := fs.String("scheme", "p256", "新建钱包的签名方案：p256 | secp256k1 | ed25519（已有钱包沿用文件中的方案）")
+	passphraseFile := fs.String("passphrase-file", "", "钱包口令文件（未指定时依次读取环境变量 "+storage.WalletPassphraseEnv+"、终端输入）")
+	noEncrypt := fs.Bool("no-encrypt", false, "未提供钱包口令时以明文保存私钥（不推荐；默认缺少口令即报错）")
 	value := fs.Int64("value", 10, "交易金额（用于 mode=tx）")
+	var payTo recipientList
+	fs.Var(&payTo, "pay-to", "批量收款 addr=amount，逗号分隔，可重复指定（mode=pay）")
+	payFile := fs.String("pay-file", "", "批量收款文件：.csv（address,amount 每行一条）或 .json（[{\"address\":..,\"amount\":..}]）（mode=pay）")
+	var watchAddrs, xpubs stringList
+	fs.Var(&watchAddrs, "watch", "导入只读钱包的地址（公钥 hex），逗号分隔，可重复指定（mode=import）")
+	watchFile := fs.String("watch-file", "", "地址文件，每行一个地址（mode=import）")
+	fs.Var(&xpubs, "xpub", "导入只读钱包的 HD 账户扩展公钥（钱包文件中的 account_pub）（mode=import）")
+	from := fs.String("from", "", "只读钱包的资金来源：已导入的账户扩展公钥或地址，输入与找零都限于该账户（mode=create，只读钱包必填）")
+	psbtPath := fs.String("psbt", "tx.psbt.json", "部分签名交易文件（mode=create 写出，sign 读写，broadcast 读取）")
+	nodeURL := fs.String("node-url", "http://127.0.0.1:8080", "广播目标节点（mode=broadcast）；轻节点连接的全节点（mode=light）")
+	minDifficulty := fs.Uint("min-difficulty", 12, "共识要求的最低区块难度，同步与接收区块（mode=serve）及轻节点校验区块头（mode=light）时低于它的一律拒绝")
+	lockTime := fs.Uint64("locktime", 0, "交易绝对时间锁：<500000000 为区块高度，否则为 Unix 秒（mode=tx）")
+	sequence := fs.Uint64("sequence", uint64(core.SequenceFinal-1), "输入 sequence（BIP68 相对时间锁编码，默认不启用相对锁）（mode=tx）")
+	rbf := fs.Bool("rbf", false, "允许之后以更高手续费替换该交易（输入 sequence 设为 0xfffffffd）（mode=tx/pay/create）")
+	bumpTxID := fs.String("txid", "", "交易 ID：要提高手续费的池内交易（mode=bumpfee/cpfp）或要查询的交易（mode=gettx）")
+	newFee := fs.Int64("new-fee", 0, "替换交易的手续费，0 表示满足替换规则的最低值（mode=bumpfee）；子交易的手续费（mode=cpfp，必填）")
+	coinSelect := fs.String("coin-select", "bnb", "选币策略：bnb | largest | smallest | random（mode=tx）")
+	coinSelectSeed := fs.Int64("coin-select-seed", 0, "random 选币的随机种子，0 表示按时间取种子（mode=tx）")
+	feeBase := fs.Int64("fee-base", 0, "每笔交易固定手续费（mode=tx）")
+	feePerInput := fs.Int64("fee-per-input", 0, "每个输入的手续费（mode=tx）")
+	feePerOutput := fs.Int64("fee-per-output", 0, "每个输出的手续费（mode=tx）")
+	dustLimit := fs.Int64("dust-limit", 0, "找零低于该值时并入手续费（mode=tx）")
 	difficulty := fs.Uint("difficulty", 12, "POW 难度（前导零位数）")
+	blockMaxBytes := fs.Int("block-max-bytes", 1000000, "区块内交易（不含 coinbase）的最大字节数，0 表示不限制（mode=mine）")
 	addr := fs.String("addr", ":8080", "HTTP 监听地址（mode=serve）")
 	peersStr := fs.String("peers", "", "逗号分隔的 peer 列表（mode=serve）")
 	syncInterval := fs.Duration("sync-interval", 5*time.Second, "与 peers 同步间隔（mode=serve）")
+	seedsStr := fs.String("seeds", "", "逗号分隔的种子节点，启动时加入地址簿以发现更多 peer（mode=serve）")
+	targetPeers := fs.Int("target-peers", 8, "从地址簿维持的外连 peer 数（含 -peers，mode=serve）")
+	advertise := fs.String("advertise", "", "本节点供其他节点连接的地址，如 http://1.2.3.4:8080，交换地址时告知 peer（mode=serve）")
+	p2pAddr := fs.String("p2p-addr", "", "TCP 线路协议监听地址，如 :9080；为空时只在 -p2p-peers 非空时主动连接（mode=serve）")
+	p2pPeersStr := fs.String("p2p-peers", "", "逗号分隔的线路协议 peer 地址 host:port，保持长连接（mode=serve）")
+	banDuration := fs.Duration("ban-duration", network.DefaultBanDuration, "不当行为分值达到阈值的 peer 的封禁时长（mode=serve）")
+	maturity := fs.Uint64("coinbase-maturity", core.DefaultCoinbaseMaturity, "coinbase 输出可花费前需经过的区块数（全网需一致）")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
 	rand.Seed(time.Now().UnixNano())
+	params := core.ChainParams{CoinbaseMaturity: *maturity, MinDifficulty: uint32(*minDifficulty)}
 
 	store, err := storage.NewFileStorage(*dataDir, *nodeID)
 	if err != nil {
 		return fmt.Errorf("init storage failed: %w", err)
 	}
 
+	if *walletPath == "" {
+		*walletPath = defaultWalletPath(*dataDir, *nodeID)
+	}
+
 	switch *mode {
 	case "init":
 		if err := initChain(store, *miner, uint32(*difficulty)); err != nil {
 			return fmt.Errorf("init chain failed: %w", err)
 		}
-	case "tx":
-		if *to == "" {
-			return fmt.Errorf("mode=tx 需要指定 -to")
+	case "tx", "pay", "create":
+		var recipients []core.TxOutput
+		if *mode == "tx" || *mode == "create" && *to != "" {
+			if *to == "" {
+				return fmt.Errorf("mode=tx 需要指定 -to")
+			}
+			recipients = []core.TxOutput{{Value: *value, ScriptPubKey: *to}}
+		} else {
+			recipients = append(recipients, payTo...)
+			if *payFile != "" {
+				fromFile, err := loadPayoutFile(*payFile)
+				if err != nil {
+					return err
+				}
+				recipients = append(recipients, fromFile...)
+			}
+			if len(recipients) == 0 {
+				return fmt.Errorf("mode=%s 需要 -pay-to 或 -pay-file", *mode)
+			}
+		}
+		total, err := sumOutputs(recipients)
+		if err != nil {
+			return err
+		}
+		if *sequence > uint64(core.SequenceFinal) {
+			return fmt.Errorf("sequence 超出 uint32 范围")
+		}
+		if *rbf && *sequence > uint64(core.SequenceMaxReplaceable) {
+			*sequence = uint64(core.SequenceMaxReplaceable)
+		}
+		scheme, err := crypto.ParseScheme(*schemeName)
+		if err != nil {
+			return err
+		}
+		seed := *coinSelectSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		selector, err := wallet.ParseCoinSelector(*coinSelect, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			return err
 		}
-		if *walletPath == "" {
-			*walletPath = defaultWalletPath(*dataDir, *nodeID)
+		fees := wallet.FeePolicy{BaseFee: *feeBase, FeePerInput: *feePerInput, FeePerOutput: *feePerOutput, DustLimit: *dustLimit}
+		if fees.BaseFee < 0 || fees.FeePerInput < 0 || fees.FeePerOutput < 0 || fees.DustLimit < 0 {
+			return fmt.Errorf("手续费与粉尘阈值不能为负")
 		}
-		if err := submitTx(store, *walletPath, *to, *value); err != nil {
+		opts := txOptions{lockTime: *lockTime, sequence: uint32(*sequence), selector: selector, fees: fees, params: params}
+		if *mode == "create" {
+			// 只读：不读取口令，不写交易池
+			if _, err := createPartialTx(store, *walletPath, *psbtPath, *from, recipients, opts); err != nil {
+				return fmt.Errorf("create failed: %w", err)
+			}
+			return nil
+		}
+		passphrase, err := resolvePassphrase(*passphraseFile, *noEncrypt)
+		if err != nil {
+			return err
+		}
+		tx, fee, err := submitTx(store, *walletPath, scheme, passphrase, recipients, opts)
+		if err != nil {
 			return fmt.Errorf("submit tx failed: %w", err)
 		}
+		if *mode == "pay" {
+			fmt.Printf("txid: %x\n", tx.ID)
+			fmt.Printf("收款方：%d，合计：%d，手续费：%d\n", len(recipients), total, fee)
+		}
+	case "bumpfee":
+		if *bumpTxID == "" {
+			return fmt.Errorf("mode=bumpfee 需要指定 -txid")
+		}
+		if *newFee < 0 || *dustLimit < 0 {
+			return fmt.Errorf("手续费与粉尘阈值不能为负")
+		}
+		scheme, err := crypto.ParseScheme(*schemeName)
+		if err != nil {
+			return err
+		}
+		passphrase, err := resolvePassphrase(*passphraseFile, *noEncrypt)
+		if err != nil {
+			return err
+		}
+		opts := txOptions{fees: wallet.FeePolicy{DustLimit: *dustLimit}, params: params}
+		tx, fee, err := bumpFee(store, *walletPath, scheme, passphrase, strings.ToLower(*bumpTxID), *newFee, opts)
+		if err != nil {
+			return fmt.Errorf("bumpfee failed: %w", err)
+		}
+		fmt.Printf("txid: %x\n", tx.ID)
+		fmt.Printf("手续费：%d\n", fee)
+	case "cpfp":
+		if *bumpTxID == "" {
+			return fmt.Errorf("mode=cpfp 需要指定 -txid")
+		}
+		if *newFee <= 0 || *dustLimit < 0 {
+			return fmt.Errorf("mode=cpfp 需要正的 -new-fee，粉尘阈值不能为负")
+		}
+		scheme, err := crypto.ParseScheme(*schemeName)
+		if err != nil {
+			return err
+		}
+		passphrase, err := resolvePassphrase(*passphraseFile, *noEncrypt)
+		if err != nil {
+			return err
+		}
+		opts := txOptions{sequence: core.SequenceFinal - 1, fees: wallet.FeePolicy{DustLimit: *dustLimit}, params: params}
+		if *rbf {
+			opts.sequence = core.SequenceMaxReplaceable
+		}
+		tx, pkg, err := childPaysForParent(store, *walletPath, scheme, passphrase, strings.ToLower(*bumpTxID), *newFee, opts)
+		if err != nil {
+			return fmt.Errorf("cpfp failed: %w", err)
+		}
+		fmt.Printf("txid: %x\n", tx.ID)
+		fmt.Printf("包：%d 笔交易，手续费 %d，%d 字节\n", pkg.Count, pkg.Fee, pkg.Size)
+	case "sign":
+		passphrase, err := resolvePassphrase(*passphraseFile, *noEncrypt)
+		if err != nil {
+			return err
+		}
+		p, n, err := signPartialTx(*walletPath, *psbtPath, passphrase)
+		if err != nil {
+			return fmt.Errorf("sign failed: %w", err)
+		}
+		fmt.Printf("已签名 %d 个输入，", n)
+		if p.Complete() {
+			fmt.Println("交易已完整签名，可执行 -mode broadcast")
+		} else {
+			fmt.Println("仍有输入待其他钱包签名")
+		}
+	case "broadcast":
+		tx, err := broadcastPartialTx(*psbtPath, *nodeURL)
+		if err != nil {
+			return fmt.Errorf("broadcast failed: %w", err)
+		}
+		fmt.Printf("txid: %x\n", tx.ID)
+	case "newwallet", "newaddr", "recover":
+		passphrase, err := resolvePassphrase(*passphraseFile, *noEncrypt)
+		if err != nil {
+			return err
+		}
+		switch *mode {
+		case "newwallet":
+			err = createHDWallet(*walletPath, passphrase)
+		case "newaddr":
+			err = newReceiveAddress(*walletPath, passphrase)
+		default:
+			if *mnemonic == "" {
+				return fmt.Errorf("mode=recover 需要指定 -mnemonic")
+			}
+			err = recoverHDWallet(store, *walletPath, *mnemonic, passphrase)
+		}
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", *mode, err)
+		}
+	case "import":
+		addresses := append([]string(nil), watchAddrs...)
+		if *watchFile != "" {
+			fromFile, err := loadAddressFile(*watchFile)
+			if err != nil {
+				return err
+			}
+			addresses = append(addresses, fromFile...)
+		}
+		if err := importWatch(store, *walletPath, addresses, xpubs); err != nil {
+			return fmt.Errorf("import failed: %w", err)
+		}
+	case "balance":
+		if err := showBalance(store, *walletPath, params); err != nil {
+			return fmt.Errorf("balance failed: %w", err)
+		}
+	case "history":
+		if err := showHistory(store, *walletPath, params); err != nil {
+			return fmt.Errorf("history failed: %w", err)
+		}
+	case "light":
+		if err := runLight(store, *walletPath, *nodeURL, params); err != nil {
+			return fmt.Errorf("light failed: %w", err)
+		}
+	case "gettx":
+		if *bumpTxID == "" {
+			return fmt.Errorf("mode=gettx 需要指定 -txid")
+		}
+		if err := showTx(store, *bumpTxID); err != nil {
+			return fmt.Errorf("gettx failed: %w", err)
+		}
 	case "mine":
-		if err := mineOnce(store, *miner, uint32(*difficulty)); err != nil {
+		if err := mineOnce(store, *miner, *coinbaseTag, uint32(*difficulty), *blockMaxBytes, params); err != nil {
 			return fmt.Errorf("mine failed: %w", err)
 		}
 	case "serve":
-		peers := parsePeers(*peersStr)
-		if err := serveNode(*nodeID, store, *addr, peers, *syncInterval); err != nil {
+		opts := serveOptions{
+			seeds:       parsePeers(*seedsStr),
+			target:      *targetPeers,
+			advertise:   *advertise,
+			banDuration: *banDuration,
+			p2pAddr:     *p2pAddr,
+			p2pPeers:    parsePeers(*p2pPeersStr),
+			params:      params,
+		}
+		if err := serveNode(*nodeID, store, *addr, parsePeers(*peersStr), *syncInterval, opts); err != nil {
 			return fmt.Errorf("serve failed: %w", err)
 		}
 	default:
@@ -113,45 +354,152 @@ func initChain(store *storage.FileStorage, miner string, difficulty uint32) erro
 	return nil
 }
 
-// submitTx 创建一笔签名交易并写入交易池
-func submitTx(store *storage.FileStorage, walletPath string, to string, value int64) error {
+// txOptions 构造交易的可选参数
+type txOptions struct {
+	lockTime uint64
+	sequence uint32
+	selector wallet.CoinSelector
+	fees     wallet.FeePolicy
+	params   core.ChainParams
+}
+
+// signingWallet 发交易所需的钱包私钥
+// HD 钱包：使用全部已分配地址的资金，找零发往新分配的找零地址；单密钥钱包找零回到原地址
+type signingWallet struct {
+	path       string
+	passphrase string
+	hd         *crypto.HDWallet
+	keys       []*crypto.Wallet
+	usedChange bool
+}
+
+// resolvePassphrase 获取钱包口令；未获得口令时提示以 -no-encrypt 显式允许明文保存
+func resolvePassphrase(file string, noEncrypt bool) (string, error) {
+	pass, err := storage.ResolvePassphrase(file, noEncrypt)
+	if errors.Is(err, storage.ErrPassphraseRequired) {
+		return "", fmt.Errorf("%w；确需以明文保存钱包请加 -no-encrypt", err)
+	}
+	return pass, err
+}
+
+// loadSigningWallet 加载钱包私钥；单密钥钱包不存在时按 scheme 新建
+func loadSigningWallet(walletPath string, scheme crypto.Scheme, passphrase string) (*signingWallet, error) {
+	isHD, err := storage.IsHDWallet(walletPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("load wallet failed: %w", err)
+	}
+	sw := &signingWallet{path: walletPath, passphrase: passphrase}
+	if isHD {
+		if sw.hd, err = storage.LoadHDWallet(walletPath, passphrase); err != nil {
+			return nil, fmt.Errorf("load wallet failed: %w", err)
+		}
+		if sw.keys, err = sw.hd.Keys(); err != nil {
+			return nil, err
+		}
+	} else {
+		key, err := storage.LoadOrCreateWalletWithScheme(walletPath, scheme, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("load wallet failed: %w", err)
+		}
+		sw.keys = []*crypto.Wallet{key}
+	}
+	if passphrase == "" {
+		log.Printf("警告：未提供钱包口令，%s 中的私钥以明文保存", walletPath)
+	}
+	return sw, nil
+}
+
+// nextChange 返回找零密钥：HD 钱包分配新的找零地址
+func (sw *signingWallet) nextChange() (*crypto.Wallet, error) {
+	if sw.hd == nil {
+		return sw.keys[0], nil
+	}
+	sw.usedChange = true
+	return sw.hd.NextChangeKey()
+}
+
+// owners 返回地址到私钥的映射
+func (sw *signingWallet) owners() map[string]*crypto.Wallet {
+	out := make(map[string]*crypto.Wallet, len(sw.keys))
+	for _, k := range sw.keys {
+		out[crypto.PublicKeyHex(k.PublicKey)] = k
+	}
+	return out
+}
+
+// save 分配过新的找零地址时持久化 HD 钱包计数
+func (sw *signingWallet) save() error {
+	if !sw.usedChange {
+		return nil
+	}
+	if err := storage.SaveHDWallet(sw.path, sw.hd, sw.passphrase); err != nil {
+		return fmt.Errorf("save wallet failed: %w", err)
+	}
+	sw.usedChange = false
+	return nil
+}
+
+// submitTx 创建一笔向 recipients 付款的签名交易并写入交易池，返回交易和手续费
+func submitTx(store *storage.FileStorage, walletPath string, scheme crypto.Scheme, passphrase string, recipients []core.TxOutput, opts txOptions) (*core.Transaction, int64, error) {
+	value, err := sumOutputs(recipients)
+	if err != nil {
+		return nil, 0, err
+	}
 	tip, err := loadTip(store)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 	if tip == nil {
-		return fmt.Errorf("链不存在，请先执行 -mode init")
+		return nil, 0, fmt.Errorf("链不存在，请先执行 -mode init")
 	}
 
-	wallet, err := storage.LoadOrCreateWallet(walletPath)
+	sw, err := loadSigningWallet(walletPath, scheme, passphrase)
 	if err != nil {
-		return fmt.Errorf("load wallet failed: %w", err)
+		return nil, 0, err
 	}
-
-	tx, err := buildSignedTx(store, wallet, to, value)
+	tracker, spendHeight, err := openWalletTracker(store, walletPath, opts.params)
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+	tx, fee, err := buildSignedTx(tracker, spendHeight, sw.keys, sw.nextChange, recipients, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 找零地址被使用时才持久化分配计数，先于入池保存，避免地址复用
+	if err := sw.save(); err != nil {
+		return nil, 0, err
 	}
 
-	txID := core.ComputeTxID(tx)
-	tx.ID = txID
+	txID := tx.ID
 
 	pool, err := store.LoadTxPool()
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+	blocks, err := loadAllBlocks(store)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := pool.Submit(tx, core.BuildUTXOSet(blocks)); err != nil {
+		return nil, 0, err
 	}
-	pool.Add(fmt.Sprintf("%x", txID), tx)
 
 	if err := store.SaveTxPool(pool); err != nil {
-		return err
+		return nil, 0, err
+	}
+	// 锁定新交易占用的输出，避免下一笔交易重复选用
+	tracker.SyncPending(pool.Pending())
+	if err := storage.SaveWalletState(storage.WalletStatePath(walletPath), tracker.Snapshot()); err != nil {
+		return nil, 0, err
 	}
 
-	log.Printf("交易已加入池：id=%x, to=%s, value=%d, 池大小=%d", txID, to, value, pool.Size())
-	return nil
+	log.Printf("交易已加入池：id=%x, outputs=%d, value=%d, fee=%d, inputs=%d, locktime=%d, 池大小=%d", txID, len(recipients), value, fee, len(tx.Inputs), opts.lockTime, pool.Size())
+	return tx, fee, nil
 }
 
-// mineOnce 取出交易池交易 + coinbase，挖一个区块并持久化
-func mineOnce(store *storage.FileStorage, miner string, difficulty uint32) error {
+// mineOnce 按区块模板取出可打包交易 + coinbase，挖一个区块并持久化
+// 模板按祖先包手续费率挑选不超过 maxBytes 字节的交易，coinbase 领取出块补贴加手续费
+func mineOnce(store *storage.FileStorage, miner string, tag string, difficulty uint32, maxBytes int, params core.ChainParams) error {
 	tip, err := loadTip(store)
 	if err != nil {
 		return err
@@ -162,24 +510,45 @@ func mineOnce(store *storage.FileStorage, miner string, difficulty uint32) error
 		return err
 	}
 
-	pending := pool.Pending()
-	coinbase := core.NewCoinbaseTx(miner, 50)
+	blocks, err := loadAllBlocks(store)
+	if err != nil {
+		return err
+	}
+	var height uint64
+	if tip != nil {
+		height = tip.Header.Height + 1
+	}
+	// 时间锁未到期或暂不可用的交易留在池中，等待后续区块
+	utxos := core.BuildUTXOSet(blocks)
+	tmpl := core.BuildTemplate(pool.Pending(), utxos, height, core.MedianTimePast(blocks), maxBytes, params)
+	selected := tmpl.Transactions
+	// coinbase 承诺本块高度，保证不同区块的 coinbase ID 唯一
+	coinbase := core.NewCoinbaseTxWithExtra(miner, core.BlockSubsidy+tmpl.Fees, height, []byte(tag))
 
 	var baseTxs []*core.Transaction
 	baseTxs = append(baseTxs, coinbase)
-	baseTxs = append(baseTxs, pending...)
+	baseTxs = append(baseTxs, selected...)
 
 	block := core.MineBlock(tip, baseTxs, difficulty)
 
-	if err := store.SaveBlock(block); err != nil {
+	if err := store.ConnectBlock(block, storage.SpentOutputs(block, utxos)); err != nil {
 		return err
 	}
-	// 挖出后清空交易池
-	if err := store.SaveTxPool(core.NewTxPool()); err != nil {
+	// 挖出后仅移除已打包的交易
+	included := make(map[string]struct{}, len(selected))
+	for _, tx := range selected {
+		included[crypto.HexEncode(core.ComputeTxID(tx))] = struct{}{}
+	}
+	for id, tx := range pool.Snapshot() {
+		if _, ok := included[crypto.HexEncode(core.ComputeTxID(tx))]; ok {
+			pool.Remove(id)
+		}
+	}
+	if err := store.SaveTxPool(pool); err != nil {
 		return err
 	}
 
-	log.Printf("出块成功：高度=%d，哈希=%x，包含交易=%d（含 coinbase）", block.Header.Height, core.HashBlockHeader(&block.Header), len(baseTxs))
+	log.Printf("出块成功：高度=%d，哈希=%x，包含交易=%d（含 coinbase），手续费=%d，池中剩余=%d", block.Header.Height, core.HashBlockHeader(&block.Header), len(baseTxs), tmpl.Fees, pool.Size())
 	return nil
 }
 
@@ -196,26 +565,83 @@ func loadTip(store *storage.FileStorage) (*core.Block, error) {
 	return store.LoadBlock(last)
 }
 
+// serveOptions peer 发现、封禁与线路协议参数
+type serveOptions struct {
+	seeds       []string
+	target      int
+	advertise   string
+	banDuration time.Duration
+	p2pAddr     string
+	p2pPeers    []string
+	params      core.ChainParams
+}
+
 // serveNode 启动 HTTP 服务并定期从 peers 同步区块和交易池
-func serveNode(nodeID string, store *storage.FileStorage, addr string, peers []string, interval time.Duration) error {
+// 地址簿与封禁列表保存在节点数据目录，种子节点加入地址簿，同步前从地址簿补足外连 peer 并与新 peer 交换地址
+func serveNode(nodeID string, store *storage.FileStorage, addr string, peers []string, interval time.Duration, opts serveOptions) error {
+	for i, p := range peers {
+		if norm, err := network.NormalizePeerURL(p); err == nil {
+			peers[i] = norm
+		}
+	}
+	records, err := store.LoadPeers()
+	if err != nil {
+		return fmt.Errorf("load peer address book: %w", err)
+	}
+	book := network.NewAddrBook(opts.advertise, records)
+	book.Add(opts.seeds, "seed")
+	book.Add(peers, "config")
+
+	bans, err := network.NewBanManager(store, opts.banDuration)
+	if err != nil {
+		return fmt.Errorf("load bans: %w", err)
+	}
+
+	manager := network.NewPeerManager(peers)
+	manager.Params = &opts.params
+	manager.Bans = bans
 	server := &network.NodeServer{
-		NodeID: nodeID,
-		Store:  store,
-		Addr:   addr,
-		Peers:  peers,
+		NodeID:      nodeID,
+		Store:       store,
+		Addr:        addr,
+		Peers:       peers,
+		Progress:    &network.SyncProgress{},
+		PeerManager: manager,
+		AddrBook:    book,
+		Bans:        bans,
+		Params:      &opts.params,
+	}
+	// 线路协议：TCP 长连接推送区块与交易，HTTP 仍作为客户端 RPC 与同步兜底
+	if opts.p2pAddr != "" || len(opts.p2pPeers) > 0 {
+		server.Wire = network.NewWireNode(server)
+		if opts.p2pAddr != "" {
+			l, err := server.Wire.Listen(opts.p2pAddr)
+			if err != nil {
+				return fmt.Errorf("listen p2p: %w", err)
+			}
+			log.Printf("P2P wire protocol listening on %s", l.Addr())
+		}
+	}
+
+	// 线路协议维护循环独立运行：连接慢或补块耗时不拖住 HTTP 同步，反之亦然
+	if server.Wire != nil {
+		go func() {
+			for {
+				server.Wire.Maintain(opts.p2pPeers)
+				time.Sleep(interval)
+			}
+		}()
 	}
 
-	// 后台同步循环
+	// 后台同步循环：维护外连并交换地址，从最优 peer 头优先下载区块，合并各 peer 的交易池；失败的 peer 指数退避
 	go func() {
 		for {
-			for _, peer := range peers {
-				syncer := network.NewSyncer(peer)
-				if err := syncer.SyncBlocks(store); err != nil {
-					log.Printf("[sync][%s] sync blocks err: %v", peer, err)
-				}
-				if err := syncer.SyncTxPool(store); err != nil {
-					log.Printf("[sync][%s] sync txpool err: %v", peer, err)
-				}
+			manager.Maintain(book, opts.target, opts.advertise)
+			if err := manager.Sync(server); err != nil {
+				log.Printf("[sync] %v", err)
+			}
+			if err := store.SavePeers(book.Records()); err != nil {
+				log.Printf("[sync] save peer address book: %v", err)
 			}
 			time.Sleep(interval)
 		}
@@ -243,70 +669,270 @@ func defaultWalletPath(baseDir, nodeID string) string {
 	return fmt.Sprintf("%s/%s/wallet.json", strings.TrimRight(baseDir, "/"), nodeID)
 }
 
-// buildSignedTx 简单 UTXO 选择（全链扫描），签名并返回交易
-func buildSignedTx(store *storage.FileStorage, wallet *crypto.Wallet, to string, value int64) (*core.Transaction, error) {
-	if value <= 0 {
-		return nil, fmt.Errorf("value must be positive")
+// buildPartialTx 按选币策略从 owned 地址的可用输出中选币，向 recipients 付款，最多一个找零输出
+// 仅在需要找零时调用 change 分配找零地址；path 为输入地址返回 HD 派生路径（可为 nil）
+func buildPartialTx(tracker *wallet.Wallet, spendHeight uint64, owned func(address string) bool, change func() (string, error), path func(address string) string, recipients []core.TxOutput, opts txOptions) (*wallet.PartialTx, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients")
 	}
-	blocks, err := loadAllBlocks(store)
+	for _, r := range recipients {
+		if r.Value <= 0 {
+			return nil, fmt.Errorf("value must be positive")
+		}
+		if r.ScriptPubKey == "" {
+			return nil, fmt.Errorf("recipient address is empty")
+		}
+	}
+	value, err := sumOutputs(recipients)
 	if err != nil {
 		return nil, err
 	}
-	utxoSet := core.BuildUTXOSet(blocks)
-	fromAddr := crypto.PublicKeyHex(wallet.PublicKey)
-
-	// 收集属于 from 的 UTXO
-	var selected []core.UTXO
-	var total int64
-	for _, list := range utxoSet {
-		for _, u := range list {
-			if u.Output.ScriptPubKey == fromAddr {
-				selected = append(selected, u)
-				total += u.Output.Value
-				if total >= value {
-					break
-				}
-			}
+
+	// 候选：已成熟、未被待确认交易占用且属于 owned 的输出
+	var candidates []core.UTXO
+	for _, u := range tracker.SpendableCoins(spendHeight) {
+		if owned(u.Output.ScriptPubKey) {
+			candidates = append(candidates, u)
+		}
+	}
+	sel, err := wallet.SelectCoins(opts.selector, candidates, wallet.Target{Value: value, Outputs: len(recipients), Policy: opts.fees})
+	if errors.Is(err, wallet.ErrInsufficientFunds) {
+		return nil, fmt.Errorf("余额不足，需 %d（不含手续费）实有 %d", value, tracker.Balance(spendHeight).Spendable)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := append([]core.TxOutput(nil), recipients...)
+	if sel.Change > 0 {
+		addr, err := change()
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, core.TxOutput{Value: sel.Change, ScriptPubKey: addr})
+	}
+	return wallet.NewPartialTx(sel.Coins, outputs, opts.lockTime, opts.sequence, path)
+}
+
+// buildSignedTx 构造交易并由 keys 中的所属密钥签名各输入，返回交易和手续费
+// 仅在需要找零时调用 change 取得找零密钥
+func buildSignedTx(tracker *wallet.Wallet, spendHeight uint64, keys []*crypto.Wallet, change func() (*crypto.Wallet, error), recipients []core.TxOutput, opts txOptions) (*core.Transaction, int64, error) {
+	owners := (&signingWallet{keys: keys}).owners()
+	owned := func(address string) bool {
+		_, ok := owners[address]
+		return ok
+	}
+	changeAddr := func() (string, error) {
+		k, err := change()
+		if err != nil {
+			return "", err
 		}
-		if total >= value {
-			break
+		return crypto.PublicKeyHex(k.PublicKey), nil
+	}
+	p, err := buildPartialTx(tracker, spendHeight, owned, changeAddr, nil, recipients, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := p.Sign(func(in wallet.PartialInput) (*crypto.Wallet, error) {
+		return owners[in.Address], nil
+	}); err != nil {
+		return nil, 0, err
+	}
+	tx, err := p.Finalize()
+	if err != nil {
+		return nil, 0, err
+	}
+	return tx, p.Fee(), nil
+}
+
+// openWalletTracker 加载钱包跟踪状态并与本地链、交易池同步（增量连接新区块，重组时回退），同步后保存
+// 返回下一个区块高度，用于判断 coinbase 成熟度
+func openWalletTracker(store *storage.FileStorage, walletPath string, params core.ChainParams) (*wallet.Wallet, uint64, error) {
+	isWatch, err := storage.IsWatchOnlyWallet(walletPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("load wallet failed: %w", err)
+	}
+	// 只读钱包随扩展公钥地址被使用扩展缺口，否则预留地址用尽后的转入无法发现
+	if isWatch {
+		if _, err := extendWatchGap(store, walletPath); err != nil {
+			return nil, 0, err
 		}
 	}
-	if total < value {
-		return nil, fmt.Errorf("余额不足，需 %d 实有 %d", value, total)
+	addresses, err := storage.WalletAddresses(walletPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("load wallet failed: %w", err)
+	}
+	statePath := storage.WalletStatePath(walletPath)
+	st, err := storage.LoadWalletState(statePath)
+	if err != nil {
+		return nil, 0, err
 	}
+	tracker := wallet.New(addresses)
+	tracker.CoinbaseMaturity = params.CoinbaseMaturity
+	tracker.LoadSnapshot(st)
 
-	var inputs []core.TxInput
-	for _, u := range selected {
-		inputs = append(inputs, core.TxInput{
-			TxID:   u.TxID,
-			Vout:   u.Index,
-			PubKey: wallet.PublicKey,
-		})
+	blocks, err := loadAllBlocks(store)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := tracker.SyncChain(blocks); err != nil {
+		return nil, 0, err
 	}
-	outputs := []core.TxOutput{
-		{Value: value, ScriptPubKey: to},
+	pool, err := store.LoadTxPool()
+	if err != nil {
+		return nil, 0, err
 	}
-	change := total - value
-	if change > 0 {
-		outputs = append(outputs, core.TxOutput{Value: change, ScriptPubKey: fromAddr})
+	tracker.SyncPending(pool.Pending())
+	if err := storage.SaveWalletState(statePath, tracker.Snapshot()); err != nil {
+		return nil, 0, err
 	}
+	return tracker, uint64(len(blocks)), nil
+}
 
-	tx := &core.Transaction{
-		Inputs:     inputs,
-		Outputs:    outputs,
-		IsCoinbase: false,
+// showBalance 打印钱包余额（已确认 / 待确认 / 锁定 / 未成熟 / 可用）
+func showBalance(store *storage.FileStorage, walletPath string, params core.ChainParams) error {
+	tracker, spendHeight, err := openWalletTracker(store, walletPath, params)
+	if err != nil {
+		return err
 	}
-	signHash := core.TxSigningHash(tx)
-	for i := range tx.Inputs {
-		sig, err := wallet.Sign(signHash)
-		if err != nil {
-			return nil, err
+	b := tracker.Balance(spendHeight)
+	fmt.Printf("地址数：%d\n", len(tracker.Addresses()))
+	fmt.Printf("已确认：%d（未成熟 %d，待确认交易占用 %d）\n", b.Confirmed, b.Immature, b.Locked)
+	fmt.Printf("待确认转入：%d\n", b.Pending)
+	fmt.Printf("可用：%d\n", b.Spendable)
+
+	// 只读钱包通常跟踪多方地址，逐个列出有资金的地址
+	watch, err := storage.IsWatchOnlyWallet(walletPath)
+	if err != nil || !watch {
+		return err
+	}
+	balances := tracker.AddressBalances(spendHeight)
+	for _, a := range tracker.Addresses() {
+		if ab := balances[a]; ab.Confirmed != 0 || ab.Pending != 0 {
+			fmt.Printf("  %s  已确认 %d  待确认转入 %d\n", a, ab.Confirmed, ab.Pending)
 		}
-		tx.Inputs[i].Signature = sig
 	}
-	tx.ID = core.ComputeTxID(tx)
-	return tx, nil
+	return nil
+}
+
+// showHistory 打印钱包交易历史，待确认在前
+func showHistory(store *storage.FileStorage, walletPath string, params core.ChainParams) error {
+	tracker, _, err := openWalletTracker(store, walletPath, params)
+	if err != nil {
+		return err
+	}
+	entries := tracker.History()
+	if len(entries) == 0 {
+		fmt.Println("暂无交易")
+		return nil
+	}
+	for _, e := range entries {
+		status := "未确认"
+		if e.Confirmed {
+			status = fmt.Sprintf("高度 %d", e.Height)
+		}
+		kind := "转账"
+		if e.Coinbase {
+			kind = "挖矿"
+		}
+		fmt.Printf("%s  %-10s %s  %+d（转入 %d，转出 %d）\n", e.TxID, status, kind, e.Net(), e.Received, e.Sent)
+	}
+	return nil
+}
+
+// showTx 打印交易的确认状态与内容；链上与交易池中都没有时返回错误
+func showTx(store *storage.FileStorage, txid string) error {
+	st, err := network.LookupTx(store, txid)
+	if err != nil {
+		return err
+	}
+	switch st.Status {
+	case network.TxStatusConfirmed:
+		fmt.Printf("%s  已确认：高度 %d 第 %d 笔，确认数 %d\n", st.TxID, *st.BlockHeight, *st.BlockIndex, st.Confirmations)
+	case network.TxStatusPending:
+		fmt.Printf("%s  未确认（交易池中）\n", st.TxID)
+	default:
+		return fmt.Errorf("交易 %s 不在链上也不在交易池中", st.TxID)
+	}
+	data, err := json.MarshalIndent(st.Transaction, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// createHDWallet 生成助记词并创建 HD 钱包，打印助记词和首个收款地址
+func createHDWallet(walletPath string, passphrase string) error {
+	if _, err := os.Stat(walletPath); err == nil {
+		return fmt.Errorf("钱包文件已存在：%s", walletPath)
+	}
+	hd, err := crypto.GenerateHDWallet()
+	if err != nil {
+		return err
+	}
+	w, err := hd.NextReceiveKey()
+	if err != nil {
+		return err
+	}
+	if err := storage.SaveHDWallet(walletPath, hd, passphrase); err != nil {
+		return err
+	}
+	fmt.Printf("助记词（请离线备份，恢复钱包只需此助记词）：\n%s\n", hd.Mnemonic)
+	fmt.Printf("收款地址：%s\n", crypto.PublicKeyHex(w.PublicKey))
+	return nil
+}
+
+// newReceiveAddress 为 HD 钱包分配并打印一个新的收款地址
+func newReceiveAddress(walletPath string, passphrase string) error {
+	hd, err := storage.LoadHDWallet(walletPath, passphrase)
+	if err != nil {
+		return err
+	}
+	w, err := hd.NextReceiveKey()
+	if err != nil {
+		return err
+	}
+	if err := storage.SaveHDWallet(walletPath, hd, passphrase); err != nil {
+		return err
+	}
+	fmt.Println(crypto.PublicKeyHex(w.PublicKey))
+	return nil
+}
+
+// recoverHDWallet 由助记词恢复 HD 钱包：扫描链上和交易池中出现过的地址，恢复分配计数
+func recoverHDWallet(store *storage.FileStorage, walletPath, mnemonic, passphrase string) error {
+	if _, err := os.Stat(walletPath); err == nil {
+		return fmt.Errorf("钱包文件已存在：%s", walletPath)
+	}
+	hd, err := crypto.NewHDWallet(mnemonic)
+	if err != nil {
+		return err
+	}
+	// 旧的跟踪状态可能只覆盖部分地址，删除后下次查询从创世重新扫描
+	if err := os.Remove(storage.WalletStatePath(walletPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	used, err := usedAddresses(store)
+	if err != nil {
+		return err
+	}
+	if err := hd.Rescan(func(address string) bool {
+		_, ok := used[address]
+		return ok
+	}); err != nil {
+		return err
+	}
+	if hd.NextReceive == 0 {
+		if _, err := hd.NextReceiveKey(); err != nil {
+			return err
+		}
+	}
+	if err := storage.SaveHDWallet(walletPath, hd, passphrase); err != nil {
+		return err
+	}
+	log.Printf("HD 钱包已恢复：收款地址 %d 个，找零地址 %d 个", hd.NextReceive, hd.NextChange)
+	return nil
 }
 
 // loadAllBlocks 按高度顺序加载全链
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/network"
+	"github.com/yiqi-017/blockchain/storage"
+	"github.com/yiqi-017/blockchain/wallet"
+)
+
+// 离线签名流程：
+//  1. 联网的只读节点 create：按钱包地址选币，写出未签名的部分签名交易文件（无需口令），附上各输入的前序交易
+//  2. 离线机器 sign：用前序交易核对输入金额后，仅凭钱包文件中的私钥签名，文件写回原处
+//  3. 联网节点 broadcast：校验签名后 POST 到节点的 /tx
+
+// createPartialTx 以只读方式构造未签名交易并写入 psbtPath
+// HD 钱包由账户扩展公钥分配找零地址，单密钥钱包找零回到原地址；
+// 只读钱包可能跟踪多方的地址，须由 from 指定一个已导入的账户扩展公钥或地址，输入与找零都限于该账户
+func createPartialTx(store *storage.FileStorage, walletPath, psbtPath, from string, recipients []core.TxOutput, opts txOptions) (*wallet.PartialTx, error) {
+	tip, err := loadTip(store)
+	if err != nil {
+		return nil, err
+	}
+	if tip == nil {
+		return nil, fmt.Errorf("链不存在，请先执行 -mode init")
+	}
+	isHD, err := storage.IsHDWallet(walletPath)
+	if err != nil {
+		return nil, fmt.Errorf("load wallet failed: %w", err)
+	}
+	isWatch, err := storage.IsWatchOnlyWallet(walletPath)
+	if err != nil {
+		return nil, fmt.Errorf("load wallet failed: %w", err)
+	}
+	if from != "" && !isWatch {
+		return nil, errors.New("-from 仅用于只读钱包")
+	}
+	tracker, spendHeight, err := openWalletTracker(store, walletPath, opts.params)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		owned  = tracker.Owns
+		change func() (string, error)
+		path   func(string) string
+	)
+	switch {
+	case isHD:
+		paths, err := storage.HDAddressPaths(walletPath)
+		if err != nil {
+			return nil, err
+		}
+		path = func(address string) string { return paths[address] }
+		change = func() (string, error) {
+			addr, _, err := storage.ReserveHDChangeAddress(walletPath)
+			return addr, err
+		}
+	case isWatch:
+		if owned, path, change, err = watchSource(store, walletPath, from); err != nil {
+			return nil, err
+		}
+	default:
+		addr, err := storage.WalletAddress(walletPath)
+		if err != nil {
+			return nil, err
+		}
+		change = func() (string, error) { return addr, nil }
+	}
+
+	p, err := buildPartialTx(tracker, spendHeight, owned, change, path, recipients, opts)
+	if err != nil {
+		return nil, err
+	}
+	// 附上前序交易，离线签名方据此核对输入金额
+	for i, in := range p.Tx.Inputs {
+		prev, _, ok, err := store.LookupTx(crypto.HexEncode(in.TxID))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("前序交易 %x 不在链上", in.TxID)
+		}
+		p.Inputs[i].PrevTx = prev
+	}
+	if err := storage.SavePartialTx(psbtPath, p); err != nil {
+		return nil, err
+	}
+	log.Printf("未签名交易已写入 %s：inputs=%d, outputs=%d, fee=%d", psbtPath, len(p.Inputs), len(p.Tx.Outputs), p.Fee())
+	return p, nil
+}
+
+// signPartialTx 用钱包私钥为部分签名交易中属于本钱包的输入签名并写回文件，返回本次签名的输入个数
+// 不访问链数据，可在从不运行 serve 的离线机器上执行
+func signPartialTx(walletPath, psbtPath, passphrase string) (*wallet.PartialTx, int, error) {
+	p, err := storage.LoadPartialTx(psbtPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	isHD, err := storage.IsHDWallet(walletPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("load wallet failed: %w", err)
+	}
+
+	var key func(in wallet.PartialInput) (*crypto.Wallet, error)
+	if isHD {
+		hd, err := storage.LoadHDWallet(walletPath, passphrase)
+		if err != nil {
+			return nil, 0, fmt.Errorf("load wallet failed: %w", err)
+		}
+		key = func(in wallet.PartialInput) (*crypto.Wallet, error) {
+			if in.Path == "" {
+				return nil, nil
+			}
+			k, err := hd.KeyAtPath(in.Path)
+			if err != nil {
+				return nil, err
+			}
+			// 路径派生出的地址不符说明输入属于其他钱包
+			if crypto.PublicKeyHex(k.PublicKey) != in.Address {
+				return nil, nil
+			}
+			return k, nil
+		}
+	} else {
+		k, err := storage.LoadWallet(walletPath, passphrase)
+		if err != nil {
+			return nil, 0, fmt.Errorf("load wallet failed: %w", err)
+		}
+		key = func(in wallet.PartialInput) (*crypto.Wallet, error) {
+			if crypto.PublicKeyHex(k.PublicKey) != in.Address {
+				return nil, nil
+			}
+			return k, nil
+		}
+	}
+
+	// 输入金额由创建方填写，核对前序交易后才能据此显示手续费
+	if err := p.VerifyInputs(); err != nil {
+		return nil, 0, fmt.Errorf("核对输入失败: %w", err)
+	}
+	if err := printPartialTx(p); err != nil {
+		return nil, 0, err
+	}
+	n, err := p.Sign(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n == 0 {
+		return nil, 0, errors.New("没有属于该钱包且未签名的输入")
+	}
+	if err := storage.SavePartialTx(psbtPath, p); err != nil {
+		return nil, 0, err
+	}
+	return p, n, nil
+}
+
+// watchSource 解析只读钱包 create 的资金来源 from：已导入的账户扩展公钥或地址
+// 扩展公钥：只花费其派生的已跟踪地址，找零发往其找零分支上首个未使用的地址；地址：只花费该地址，找零回到原地址
+func watchSource(store *storage.FileStorage, walletPath, from string) (owned func(string) bool, path func(string) string, change func() (string, error), err error) {
+	if from == "" {
+		return nil, nil, nil, errors.New("只读钱包可能跟踪多方的地址，create 需用 -from 指定一个已导入的账户扩展公钥或地址")
+	}
+	if account, err := crypto.ParseExtendedPublicKey(from); err == nil {
+		paths, err := storage.WatchAddressPaths(walletPath, account)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		owned = func(address string) bool {
+			_, ok := paths[address]
+			return ok
+		}
+		path = func(address string) string { return paths[address] }
+		change = func() (string, error) { return watchChangeAddress(store, walletPath, account) }
+		return owned, path, change, nil
+	}
+	addresses, err := storage.WalletAddresses(walletPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	addr := strings.ToLower(strings.TrimSpace(from))
+	for _, a := range addresses {
+		if a == addr {
+			owned = func(address string) bool { return address == addr }
+			change = func() (string, error) { return addr, nil }
+			return owned, nil, change, nil
+		}
+	}
+	return nil, nil, nil, fmt.Errorf("-from %s 不是该只读钱包导入的扩展公钥或地址", from)
+}
+
+// watchChangeAddress 返回账户扩展公钥找零分支上首个未在链上或交易池出现过的地址，必要时将其加入跟踪集合
+func watchChangeAddress(store *storage.FileStorage, walletPath string, account *crypto.ExtendedPublicKey) (string, error) {
+	used, err := usedAddresses(store)
+	if err != nil {
+		return "", err
+	}
+	for i := uint32(0); ; i++ {
+		addr, err := crypto.DeriveHDAddress(account, true, i)
+		if errors.Is(err, crypto.ErrInvalidChildKey) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, ok := used[addr]; ok {
+			continue
+		}
+		if _, err := storage.ImportWatchAddresses(walletPath, []string{addr}, nil); err != nil {
+			return "", err
+		}
+		return addr, nil
+	}
+}
+
+// broadcastPartialTx 校验全部签名后将交易提交到 nodeURL 的 /tx
+func broadcastPartialTx(psbtPath, nodeURL string) (*core.Transaction, error) {
+	p, err := storage.LoadPartialTx(psbtPath)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := p.Finalize()
+	if err != nil {
+		return nil, err
+	}
+	held, err := network.NewSyncer(nodeURL).SubmitTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	if held {
+		log.Printf("交易时间锁未到期，已暂存于 %s 的交易池", nodeURL)
+	}
+	return tx, nil
+}
+
+// printPartialTx 打印待签名交易的输出与手续费，供离线签名前核对
+func printPartialTx(p *wallet.PartialTx) error {
+	value, err := sumOutputs(p.Tx.Outputs)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("输入：%d 个，合计 %d\n", len(p.Inputs), p.Fee()+value)
+	for _, out := range p.Tx.Outputs {
+		fmt.Printf("  -> %s  %d\n", out.ScriptPubKey, out.Value)
+	}
+	fmt.Printf("手续费：%d\n", p.Fee())
+	return nil
+}
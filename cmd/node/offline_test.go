@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+	"github.com/yiqi-017/blockchain/wallet"
+)
+
+// TestOfflineSigningFlow create（无口令）-> sign（仅钱包文件）-> broadcast 到节点 /tx
+func TestOfflineSigningFlow(t *testing.T) {
+	base := t.TempDir()
+	walletPath := filepath.Join(base, "cold", "wallet.json")
+	passFile := filepath.Join(base, "pass.txt")
+	if err := os.WriteFile(passFile, []byte("cold storage\n"), 0o600); err != nil {
+		t.Fatalf("write passphrase: %v", err)
+	}
+	psbt := filepath.Join(base, "tx.psbt.json")
+	common := []string{"-node", "hot", "-data", base, "-difficulty", "4", "-coinbase-maturity", "1", "-wallet", walletPath}
+	run := func(extra ...string) {
+		t.Helper()
+		if err := Run(append(append([]string{}, common...), extra...)); err != nil {
+			t.Fatalf("run %v: %v", extra, err)
+		}
+	}
+
+	run("-mode", "init")
+	run("-mode", "newwallet", "-passphrase-file", passFile)
+	addr, err := storage.WalletAddress(walletPath)
+	if err != nil {
+		t.Fatalf("wallet address: %v", err)
+	}
+	run("-mode", "mine", "-miner", addr)
+	run("-mode", "mine", "-miner", "bob")
+
+	// 只读节点不持有口令
+	run("-mode", "create", "-to", "alice", "-value", "12", "-fee-base", "1", "-psbt", psbt)
+	p, err := storage.LoadPartialTx(psbt)
+	if err != nil {
+		t.Fatalf("load psbt: %v", err)
+	}
+	if p.Complete() || p.Fee() != 1 || len(p.Inputs) != 1 || p.Inputs[0].Path != crypto.HDKeyPath(false, 0) {
+		t.Fatalf("unexpected partial tx: fee=%d inputs=%+v", p.Fee(), p.Inputs)
+	}
+	hd, err := storage.LoadHDWallet(walletPath, "cold storage")
+	if err != nil {
+		t.Fatalf("load hd wallet: %v", err)
+	}
+	changeKey, _ := hd.DeriveKey(true, 0)
+	if hd.NextChange != 1 || p.Tx.Outputs[1].ScriptPubKey != crypto.PublicKeyHex(changeKey.PublicKey) {
+		t.Fatalf("change should go to the first change address reserved without the passphrase")
+	}
+
+	if err := Run(append(append([]string{}, common...), "-mode", "broadcast", "-psbt", psbt)); err == nil {
+		t.Fatalf("broadcast of an unsigned tx should fail")
+	}
+	if err := Run(append(append([]string{}, common...), "-mode", "sign", "-psbt", psbt)); err == nil {
+		t.Fatalf("sign without passphrase should fail")
+	}
+	run("-mode", "sign", "-psbt", psbt, "-passphrase-file", passFile)
+
+	store, err := storage.NewFileStorage(base, "hot")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tx core.Transaction
+		if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		blocks, _ := loadAllBlocks(store)
+		if err := core.ValidateTransaction(&tx, core.BuildUTXOSet(blocks), uint64(len(blocks)), core.MedianTimePast(blocks), core.ChainParams{CoinbaseMaturity: 1}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pool, _ := store.LoadTxPool()
+		pool.Add(fmt.Sprintf("%x", tx.ID), &tx)
+		_ = store.SaveTxPool(pool)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	run("-mode", "broadcast", "-psbt", psbt, "-node-url", srv.URL)
+	pool, err := store.LoadTxPool()
+	if err != nil {
+		t.Fatalf("load pool: %v", err)
+	}
+	if pool.Size() != 1 {
+		t.Fatalf("expect broadcast tx in pool, got %d", pool.Size())
+	}
+}
+
+// TestOfflineSigningWatchOnly 只读钱包（导入冷钱包扩展公钥与其他团队地址）按 -from 限定账户 create，冷钱包 sign；
+// 未指定 -from 时拒绝猜测；篡改输入金额后签名被拒绝
+func TestOfflineSigningWatchOnly(t *testing.T) {
+	base := t.TempDir()
+	coldPath := filepath.Join(base, "cold", "wallet.json")
+	watchPath := filepath.Join(base, "hot", "watch.json")
+	psbt := filepath.Join(base, "tx.psbt.json")
+	common := []string{"-node", "hot", "-data", base, "-difficulty", "4", "-coinbase-maturity", "1", "-no-encrypt"}
+	run := func(extra ...string) {
+		t.Helper()
+		if err := Run(append(append([]string{}, common...), extra...)); err != nil {
+			t.Fatalf("run %v: %v", extra, err)
+		}
+	}
+
+	run("-mode", "init")
+	run("-mode", "newwallet", "-wallet", coldPath)
+	hd, err := storage.LoadHDWallet(coldPath, "")
+	if err != nil {
+		t.Fatalf("load hd wallet: %v", err)
+	}
+	team, err := crypto.GenerateWallet()
+	if err != nil {
+		t.Fatalf("team wallet: %v", err)
+	}
+	teamAddr := crypto.PublicKeyHex(team.PublicKey)
+	receive, _ := hd.DeriveKey(false, 1)
+	run("-mode", "mine", "-miner", teamAddr)
+	run("-mode", "mine", "-miner", crypto.PublicKeyHex(receive.PublicKey))
+	run("-mode", "mine", "-miner", "bob")
+	xpub := hd.AccountPublicKey().String()
+	run("-mode", "import", "-wallet", watchPath, "-xpub", xpub, "-watch", teamAddr)
+
+	create := func(extra ...string) error {
+		args := append([]string{"-mode", "create", "-wallet", watchPath, "-to", "alice", "-fee-base", "1", "-psbt", psbt}, extra...)
+		return Run(append(append([]string{}, common...), args...))
+	}
+	if err := create("-value", "12"); err == nil {
+		t.Fatalf("create from a watch-only wallet without -from should fail")
+	}
+	if err := create("-value", "12", "-from", "bob"); err == nil {
+		t.Fatalf("-from an address the wallet does not track should fail")
+	}
+	// 只读钱包合计 100，但冷钱包账户只有 50，不得借用其他团队的输出
+	if err := create("-value", "60", "-from", xpub); err == nil {
+		t.Fatalf("create should only spend the -from account")
+	}
+	if err := create("-value", "12", "-from", teamAddr); err != nil {
+		t.Fatalf("create from a single address: %v", err)
+	}
+	p, err := storage.LoadPartialTx(psbt)
+	if err != nil {
+		t.Fatalf("load psbt: %v", err)
+	}
+	if len(p.Inputs) != 1 || p.Inputs[0].Address != teamAddr || p.Tx.Outputs[1].ScriptPubKey != teamAddr {
+		t.Fatalf("single address source should spend and return change to itself: %+v", p.Tx.Outputs)
+	}
+
+	if err := create("-value", "12", "-from", xpub); err != nil {
+		t.Fatalf("create from account: %v", err)
+	}
+	p, err = storage.LoadPartialTx(psbt)
+	if err != nil {
+		t.Fatalf("load psbt: %v", err)
+	}
+	if len(p.Inputs) != 1 || p.Inputs[0].Path != crypto.HDKeyPath(false, 1) || p.Inputs[0].PrevTx == nil {
+		t.Fatalf("unexpected inputs: %+v", p.Inputs)
+	}
+	changeKey, _ := hd.DeriveKey(true, 0)
+	if len(p.Tx.Outputs) != 2 || p.Tx.Outputs[1].ScriptPubKey != crypto.PublicKeyHex(changeKey.PublicKey) {
+		t.Fatalf("change should go to the account's first unused change address: %+v", p.Tx.Outputs)
+	}
+
+	// 创建方虚报输入金额以掩盖手续费，离线签名方核对前序交易后拒绝
+	forged := *p
+	forged.Inputs = append([]wallet.PartialInput(nil), p.Inputs...)
+	forged.Inputs[0].Value += 1000
+	if err := storage.SavePartialTx(psbt, &forged); err != nil {
+		t.Fatalf("save forged psbt: %v", err)
+	}
+	if err := Run(append(append([]string{}, common...), "-mode", "sign", "-wallet", coldPath, "-psbt", psbt)); err == nil {
+		t.Fatalf("sign should reject an input value that does not match the previous tx")
+	}
+
+	if err := storage.SavePartialTx(psbt, p); err != nil {
+		t.Fatalf("save psbt: %v", err)
+	}
+	run("-mode", "sign", "-wallet", coldPath, "-psbt", psbt)
+	signed, err := storage.LoadPartialTx(psbt)
+	if err != nil {
+		t.Fatalf("load signed psbt: %v", err)
+	}
+	if _, err := signed.Finalize(); err != nil {
+		t.Fatalf("finalize: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestParsePayouts addr=amount 参数、CSV（含表头/注释）与 JSON 收款文件
+func TestParsePayouts(t *testing.T) {
+	var l recipientList
+	if err := l.Set("alice=5, bob=7"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := l.Set("carol=1"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if l.String() != "alice=5,bob=7,carol=1" {
+		t.Fatalf("unexpected list: %s", l.String())
+	}
+	for _, bad := range []string{"alice", "alice=0", "=3", "alice=x"} {
+		var b recipientList
+		if err := b.Set(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+
+	csvOut, err := parsePayoutCSV(strings.NewReader("address,amount\n# 工资\ndave,3\neve, 4\n"))
+	if err != nil || len(csvOut) != 2 || csvOut[1].ScriptPubKey != "eve" || csvOut[1].Value != 4 {
+		t.Fatalf("csv: %+v, %v", csvOut, err)
+	}
+	jsonOut, err := parsePayoutJSON(strings.NewReader(`[{"address":"frank","amount":6}]`))
+	if err != nil || len(jsonOut) != 1 || jsonOut[0].Value != 6 {
+		t.Fatalf("json: %+v, %v", jsonOut, err)
+	}
+	if _, err := parsePayoutJSON(strings.NewReader(`[{"address":"frank","amount":-1}]`)); err == nil {
+		t.Fatalf("negative amount should fail")
+	}
+
+	// 合计溢出 int64 或含负金额时报错，而不是回绕成小额
+	var huge recipientList
+	if err := huge.Set(fmt.Sprintf("alice=%d,bob=2", int64(math.MaxInt64))); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, err := sumOutputs(huge); err == nil {
+		t.Fatalf("overflowing total should fail")
+	}
+	if _, err := sumOutputs([]core.TxOutput{{Value: 3, ScriptPubKey: "a"}, {Value: -1, ScriptPubKey: "b"}}); err == nil {
+		t.Fatalf("negative output should fail")
+	}
+	if total, err := sumOutputs(l); err != nil || total != 13 {
+		t.Fatalf("sum: %d, %v", total, err)
+	}
+	if err := Run([]string{"-node", "p0", "-data", t.TempDir(), "-mode", "pay", "-pay-to", huge.String(), "-no-encrypt"}); err == nil || !strings.Contains(err.Error(), "溢出") {
+		t.Fatalf("pay with overflowing total should fail before touching the wallet, got %v", err)
+	}
+}
+
+// TestPayModeBatch 多个收款方合并为一笔交易，仅一个找零输出
+func TestPayModeBatch(t *testing.T) {
+	base := t.TempDir()
+	walletPath := filepath.Join(base, "p1", "wallet.json")
+	key, err := storage.LoadOrCreateWallet(walletPath, "")
+	if err != nil {
+		t.Fatalf("load wallet: %v", err)
+	}
+	addr := crypto.PublicKeyHex(key.PublicKey)
+	payFile := filepath.Join(base, "payouts.csv")
+	if err := os.WriteFile(payFile, []byte("address,amount\ndave,3\neve,4\n"), 0o644); err != nil {
+		t.Fatalf("write payouts: %v", err)
+	}
+
+	common := []string{"-node", "p1", "-data", base, "-difficulty", "4", "-coinbase-maturity", "1", "-wallet", walletPath, "-no-encrypt"}
+	for _, args := range [][]string{
+		{"-mode", "init"},
+		{"-mode", "mine", "-miner", addr},
+		{"-mode", "mine", "-miner", "bob"},
+		{"-mode", "pay", "-pay-to", "alice=5,carol=6", "-pay-file", payFile, "-fee-per-output", "1"},
+	} {
+		if err := Run(append(append([]string{}, common...), args...)); err != nil {
+			t.Fatalf("run %v: %v", args, err)
+		}
+	}
+
+	store, err := storage.NewFileStorage(base, "p1")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	pool, err := store.LoadTxPool()
+	if err != nil {
+		t.Fatalf("load pool: %v", err)
+	}
+	txs := pool.Pending()
+	if len(txs) != 1 {
+		t.Fatalf("expect a single batch tx, got %d", len(txs))
+	}
+	tx := txs[0]
+	if len(tx.Inputs) != 1 || len(tx.Outputs) != 5 {
+		t.Fatalf("expect 1 input and 4 payouts + 1 change, got %d/%d", len(tx.Inputs), len(tx.Outputs))
+	}
+	// 50 - (5+6+3+4) - 5 个输出手续费 = 27
+	if change := tx.Outputs[4]; change.ScriptPubKey != addr || change.Value != 27 {
+		t.Fatalf("unexpected change output: %+v", change)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yiqi-017/blockchain/core"
+)
+
+// recipientList 实现 flag.Value，收集 addr=amount 形式的收款方，可重复指定或逗号分隔
+type recipientList []core.TxOutput
+
+func (l *recipientList) String() string {
+	if l == nil {
+		return ""
+	}
+	parts := make([]string, len(*l))
+	for i, r := range *l {
+		parts[i] = fmt.Sprintf("%s=%d", r.ScriptPubKey, r.Value)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *recipientList) Set(raw string) error {
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		addr, amount, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("收款方格式应为 addr=amount：%q", item)
+		}
+		out, err := parseRecipient(addr, amount)
+		if err != nil {
+			return err
+		}
+		*l = append(*l, out)
+	}
+	return nil
+}
+
+// payoutEntry JSON 收款文件的条目
+type payoutEntry struct {
+	Address string `json:"address"`
+	Amount  int64  `json:"amount"`
+}
+
+// loadPayoutFile 按扩展名读取 CSV 或 JSON 收款文件
+func loadPayoutFile(path string) ([]core.TxOutput, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parsePayoutJSON(f)
+	case ".csv", ".txt":
+		return parsePayoutCSV(f)
+	default:
+		return nil, fmt.Errorf("不支持的收款文件格式：%s（需 .csv 或 .json）", path)
+	}
+}
+
+// parsePayoutJSON 解析 [{"address":..,"amount":..}] 数组
+func parsePayoutJSON(r io.Reader) ([]core.TxOutput, error) {
+	var entries []payoutEntry
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("解析 JSON 收款文件失败：%w", err)
+	}
+	out := make([]core.TxOutput, 0, len(entries))
+	for i, e := range entries {
+		if e.Address == "" || e.Amount <= 0 {
+			return nil, fmt.Errorf("第 %d 条收款无效：address 不能为空且 amount 需为正", i+1)
+		}
+		out = append(out, core.TxOutput{Value: e.Amount, ScriptPubKey: e.Address})
+	}
+	return out, nil
+}
+
+// parsePayoutCSV 解析 address,amount 两列；允许表头行和 # 注释行
+func parsePayoutCSV(r io.Reader) ([]core.TxOutput, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var out []core.TxOutput
+	for line := 1; ; line++ {
+		rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 CSV 收款文件失败：%w", err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[1]), "amount") {
+			continue // 表头
+		}
+		o, err := parseRecipient(rec[0], rec[1])
+		if err != nil {
+			return nil, fmt.Errorf("CSV 第 %d 行：%w", line, err)
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+func parseRecipient(addr, amount string) (core.TxOutput, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return core.TxOutput{}, errors.New("收款地址不能为空")
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(amount), 10, 64)
+	if err != nil || v <= 0 {
+		return core.TxOutput{}, fmt.Errorf("收款金额无效：%q", amount)
+	}
+	return core.TxOutput{Value: v, ScriptPubKey: addr}, nil
+}
+
+// sumOutputs 计算输出金额合计；金额为负或合计超出 int64 时报错
+func sumOutputs(outputs []core.TxOutput) (int64, error) {
+	var total int64
+	for _, o := range outputs {
+		if o.Value < 0 {
+			return 0, fmt.Errorf("输出金额不能为负：%s=%d", o.ScriptPubKey, o.Value)
+		}
+		var err error
+		if total, err = core.AddValue(total, o.Value); err != nil {
+			return 0, fmt.Errorf("输出金额合计溢出: %w", err)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestWalletBalanceAndHistoryModes 连续两笔未确认交易不会重复选用同一输出，balance/history 可运行
+func TestWalletBalanceAndHistoryModes(t *testing.T) {
+	base := t.TempDir()
+	walletPath := filepath.Join(base, "w1", "wallet.json")
+	key, err := storage.LoadOrCreateWallet(walletPath, "")
+	if err != nil {
+		t.Fatalf("load wallet: %v", err)
+	}
+	addr := crypto.PublicKeyHex(key.PublicKey)
+	common := []string{"-node", "w1", "-data", base, "-difficulty", "4", "-coinbase-maturity", "1", "-wallet", walletPath, "-no-encrypt"}
+	run := func(extra ...string) error {
+		return Run(append(append([]string{}, common...), extra...))
+	}
+
+	for _, args := range [][]string{
+		{"-mode", "init"},
+		{"-mode", "mine", "-miner", addr},
+		{"-mode", "mine", "-miner", addr},
+		{"-mode", "mine", "-miner", "bob"},
+		{"-mode", "tx", "-to", "alice", "-value", "50"},
+		{"-mode", "tx", "-to", "alice", "-value", "50"},
+	} {
+		if err := run(args...); err != nil {
+			t.Fatalf("run %v: %v", args, err)
+		}
+	}
+	// 两个 coinbase 输出都已被占用，第三笔应余额不足
+	if err := run("-mode", "tx", "-to", "alice", "-value", "1"); err == nil {
+		t.Fatalf("expected insufficient funds while coins are locked")
+	}
+
+	store, err := storage.NewFileStorage(base, "w1")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	tracker, spendHeight, err := openWalletTracker(store, walletPath, core.DefaultChainParams())
+	if err != nil {
+		t.Fatalf("open tracker: %v", err)
+	}
+	if b := tracker.Balance(spendHeight); b.Confirmed != 100 || b.Locked != 100 || b.Spendable != 0 {
+		t.Fatalf("unexpected balance: %+v", b)
+	}
+
+	for _, mode := range []string{"balance", "history"} {
+		if err := run("-mode", mode); err != nil {
+			t.Fatalf("run %s: %v", mode, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// stringList 实现 flag.Value，收集可重复指定或逗号分隔的字符串
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(raw string) error {
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// loadAddressFile 读取地址文件：每行一个地址，忽略空行与 # 注释
+func loadAddressFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out, sc.Err()
+}
+
+// importWatch 将地址与账户扩展公钥导入只读钱包
+// 扩展公钥按缺口限制扫描链上已使用的地址，并在两条分支各预留 HDGapLimit 个未使用地址以发现后续转入；
+// 之后每次打开钱包由 extendWatchGap 随地址被使用继续扩展
+func importWatch(store *storage.FileStorage, walletPath string, addresses, accountPubs []string) error {
+	if len(addresses) == 0 && len(accountPubs) == 0 {
+		return errors.New("没有要导入的地址")
+	}
+	if len(accountPubs) > 0 {
+		used, err := usedAddresses(store)
+		if err != nil {
+			return err
+		}
+		for _, xpub := range accountPubs {
+			account, err := crypto.ParseExtendedPublicKey(xpub)
+			if err != nil {
+				return fmt.Errorf("invalid account public key: %w", err)
+			}
+			derived, err := watchAccountAddresses(account, used)
+			if err != nil {
+				return err
+			}
+			addresses = append(addresses, derived...)
+		}
+	}
+
+	added, err := storage.ImportWatchAddresses(walletPath, addresses, accountPubs)
+	if err != nil {
+		return err
+	}
+	if err := dropWalletState(walletPath, added); err != nil {
+		return err
+	}
+	all, err := storage.WalletAddresses(walletPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("新增 %d 个地址，共跟踪 %d 个\n", added, len(all))
+	return nil
+}
+
+// extendWatchGap 重新扫描只读钱包导入的扩展公钥，使两条分支在最后一个已使用地址之后始终跟踪 HDGapLimit 个地址
+// 返回新增地址个数
+func extendWatchGap(store *storage.FileStorage, walletPath string) (int, error) {
+	accounts, err := storage.WatchAccountPubs(walletPath)
+	if err != nil || len(accounts) == 0 {
+		return 0, err
+	}
+	used, err := usedAddresses(store)
+	if err != nil {
+		return 0, err
+	}
+	var addresses []string
+	for _, account := range accounts {
+		derived, err := watchAccountAddresses(account, used)
+		if err != nil {
+			return 0, err
+		}
+		addresses = append(addresses, derived...)
+	}
+	added, err := storage.ImportWatchAddresses(walletPath, addresses, nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := dropWalletState(walletPath, added); err != nil {
+		return 0, err
+	}
+	if added > 0 {
+		log.Printf("只读钱包已扩展缺口，新增跟踪 %d 个地址", added)
+	}
+	return added, nil
+}
+
+// watchAccountAddresses 按缺口限制扫描账户扩展公钥，返回两条分支直到最后一个已使用地址之后 HDGapLimit 个的全部地址
+func watchAccountAddresses(account *crypto.ExtendedPublicKey, used map[string]struct{}) ([]string, error) {
+	receive, change, err := crypto.ScanHDAccount(account, func(address string) bool {
+		_, ok := used[address]
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, b := range []struct {
+		change bool
+		n      uint32
+	}{{false, receive + crypto.HDGapLimit}, {true, change + crypto.HDGapLimit}} {
+		derived, err := crypto.HDAccountAddresses(account, b.change, b.n)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, derived...)
+	}
+	return out, nil
+}
+
+// dropWalletState 新导入的地址可能早已有链上记录，有新增地址时丢弃跟踪状态，下次查询从创世重新扫描
+func dropWalletState(walletPath string, added int) error {
+	if added == 0 {
+		return nil
+	}
+	if err := os.Remove(storage.WalletStatePath(walletPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// usedAddresses 收集链上与交易池中作为输出出现过的地址
+func usedAddresses(store *storage.FileStorage) (map[string]struct{}, error) {
+	blocks, err := loadAllBlocks(store)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := store.LoadTxPool()
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]struct{})
+	record := func(tx *core.Transaction) {
+		for _, out := range tx.Outputs {
+			used[out.ScriptPubKey] = struct{}{}
+		}
+	}
+	for _, b := range blocks {
+		for _, tx := range b.Transactions {
+			record(tx)
+		}
+	}
+	for _, tx := range pool.Pending() {
+		record(tx)
+	}
+	return used, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestWatchOnlyImport 导入单个地址与 HD 账户扩展公钥，一次查询整组余额；地址被使用后缺口随之扩展；只读钱包不能签名
+func TestWatchOnlyImport(t *testing.T) {
+	base := t.TempDir()
+	keyPath := filepath.Join(base, "w1", "team.json")
+	hdPath := filepath.Join(base, "w1", "cold.json")
+	watchPath := filepath.Join(base, "w1", "watch.json")
+	common := []string{"-node", "w1", "-data", base, "-difficulty", "4", "-coinbase-maturity", "1", "-no-encrypt"}
+	run := func(extra ...string) error {
+		return Run(append(append([]string{}, common...), extra...))
+	}
+	must := func(extra ...string) {
+		t.Helper()
+		if err := run(extra...); err != nil {
+			t.Fatalf("run %v: %v", extra, err)
+		}
+	}
+
+	key, err := storage.LoadOrCreateWallet(keyPath, "")
+	if err != nil {
+		t.Fatalf("key wallet: %v", err)
+	}
+	teamAddr := crypto.PublicKeyHex(key.PublicKey)
+	must("-mode", "init")
+	must("-mode", "newwallet", "-wallet", hdPath)
+	hd, err := storage.LoadHDWallet(hdPath, "")
+	if err != nil {
+		t.Fatalf("load hd: %v", err)
+	}
+	// 冷钱包第 3 个收款地址收到挖矿奖励，扫描需越过未使用的前两个地址
+	third, _ := hd.DeriveKey(false, 2)
+	must("-mode", "mine", "-miner", teamAddr)
+	must("-mode", "mine", "-miner", crypto.PublicKeyHex(third.PublicKey))
+	must("-mode", "mine", "-miner", "bob")
+
+	if err := run("-mode", "import", "-wallet", watchPath, "-watch", "not-a-key"); err == nil {
+		t.Fatalf("importing a non-key address should fail")
+	}
+	if err := run("-mode", "import", "-wallet", keyPath, "-watch", teamAddr); err == nil {
+		t.Fatalf("importing into a key wallet should fail")
+	}
+	must("-mode", "import", "-wallet", watchPath, "-watch", teamAddr, "-xpub", hd.AccountPublicKey().String())
+	must("-mode", "balance", "-wallet", watchPath)
+
+	store, err := storage.NewFileStorage(base, "w1")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	tracker, spendHeight, err := openWalletTracker(store, watchPath, core.DefaultChainParams())
+	if err != nil {
+		t.Fatalf("open tracker: %v", err)
+	}
+	if got := tracker.Balance(spendHeight).Confirmed; got != 100 {
+		t.Fatalf("watch-only total = %d, want 100", got)
+	}
+	if n := len(tracker.Addresses()); n != 1+(3+crypto.HDGapLimit)+crypto.HDGapLimit {
+		t.Fatalf("unexpected watched address count %d", n)
+	}
+
+	// 最后一个预留收款地址被使用后，再次打开钱包即扩展缺口，发现更远处地址的转入
+	last, _ := hd.DeriveKey(false, 2+crypto.HDGapLimit)
+	must("-mode", "mine", "-miner", crypto.PublicKeyHex(last.PublicKey))
+	must("-mode", "balance", "-wallet", watchPath)
+	beyond, _ := hd.DeriveKey(false, 2+2*crypto.HDGapLimit)
+	must("-mode", "mine", "-miner", crypto.PublicKeyHex(beyond.PublicKey))
+	tracker, spendHeight, err = openWalletTracker(store, watchPath, core.DefaultChainParams())
+	if err != nil {
+		t.Fatalf("open tracker: %v", err)
+	}
+	if got := tracker.Balance(spendHeight).Confirmed; got != 200 {
+		t.Fatalf("watch-only total after gap extension = %d, want 200", got)
+	}
+	if n := len(tracker.Addresses()); n != 1+(3+2*crypto.HDGapLimit+crypto.HDGapLimit)+crypto.HDGapLimit {
+		t.Fatalf("unexpected watched address count after gap extension %d", n)
+	}
+
+	err = run("-mode", "tx", "-wallet", watchPath, "-to", "alice", "-value", "5")
+	if !errors.Is(err, storage.ErrWatchOnly) {
+		t.Fatalf("tx from watch-only wallet should fail with ErrWatchOnly, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sort"
+
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// BIP158 基本过滤器参数：Golomb-Rice 余数位数 P 与误报率倒数 M（约 1/784931）
+const (
+	FilterP = 19
+	FilterM = 784931
+)
+
+// ErrMalformedFilter 过滤器编码损坏
+var ErrMalformedFilter = errors.New("malformed block filter")
+
+// FilterScriptElement 输出脚本（地址）对应的过滤器元素
+func FilterScriptElement(script string) []byte {
+	return []byte(script)
+}
+
+// FilterOutpointElement 被花费输出对应的过滤器元素：交易 ID || 输出序号（4 字节小端）
+func FilterOutpointElement(txid []byte, vout int) []byte {
+	e := make([]byte, len(txid)+4)
+	copy(e, txid)
+	binary.LittleEndian.PutUint32(e[len(txid):], uint32(vout))
+	return e
+}
+
+// BlockFilterElements 区块过滤器覆盖的元素：全部输出脚本与非 coinbase 交易花费的输出（已去重）
+func BlockFilterElements(block *Block) [][]byte {
+	seen := make(map[string]struct{})
+	var out [][]byte
+	add := func(e []byte) {
+		if len(e) == 0 {
+			return
+		}
+		if _, ok := seen[string(e)]; ok {
+			return
+		}
+		seen[string(e)] = struct{}{}
+		out = append(out, e)
+	}
+	for _, tx := range block.Transactions {
+		if !tx.IsCoinbase {
+			for _, in := range tx.Inputs {
+				add(FilterOutpointElement(in.TxID, in.Vout))
+			}
+		}
+		for _, o := range tx.Outputs {
+			add(FilterScriptElement(o.ScriptPubKey))
+		}
+	}
+	return out
+}
+
+// BuildBlockFilter 为区块构造 Golomb 编码集合过滤器，SipHash 密钥取区块哈希前 16 字节
+func BuildBlockFilter(block *Block) []byte {
+	k0, k1 := filterKey(HashBlockHeader(&block.Header))
+	return buildGCS(k0, k1, BlockFilterElements(block))
+}
+
+// FilterMatchAny 判断过滤器是否可能包含 items 中任一元素（可能误报，不会漏报）
+// blockHash 须为构造该过滤器的区块哈希
+func FilterMatchAny(filter, blockHash []byte, items [][]byte) (bool, error) {
+	n, set, err := decodeGCS(filter)
+	if err != nil {
+		return false, err
+	}
+	if n == 0 || len(items) == 0 {
+		return false, nil
+	}
+	k0, k1 := filterKey(blockHash)
+	queries := make([]uint64, len(items))
+	for i, item := range items {
+		queries[i] = hashToRange(k0, k1, item, uint64(n)*FilterM)
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i] < queries[j] })
+	i := 0
+	for _, q := range queries {
+		for i < len(set) && set[i] < q {
+			i++
+		}
+		if i == len(set) {
+			return false, nil
+		}
+		if set[i] == q {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FilterHeader 过滤器头链：double-SHA256(double-SHA256(filter) || prevHeader)，创世区块的前一过滤器头为 32 字节 0
+func FilterHeader(filter, prevHeader []byte) []byte {
+	if len(prevHeader) == 0 {
+		prevHeader = make([]byte, 32)
+	}
+	data := append(crypto.DoubleHash256(filter), prevHeader...)
+	return crypto.DoubleHash256(data)
+}
+
+func filterKey(blockHash []byte) (uint64, uint64) {
+	var key [16]byte
+	copy(key[:], blockHash)
+	return binary.LittleEndian.Uint64(key[:8]), binary.LittleEndian.Uint64(key[8:])
+}
+
+// hashToRange 将 SipHash 结果均匀映射到 [0, f)
+func hashToRange(k0, k1 uint64, item []byte, f uint64) uint64 {
+	hi, _ := bits.Mul64(crypto.SipHash24(k0, k1, item), f)
+	return hi
+}
+
+// buildGCS 编码：元素数（uvarint）后接排序哈希值差分的 Golomb-Rice 编码
+func buildGCS(k0, k1 uint64, items [][]byte) []byte {
+	n := uint64(len(items))
+	values := make([]uint64, len(items))
+	for i, item := range items {
+		values[i] = hashToRange(k0, k1, item, n*FilterM)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	out := binary.AppendUvarint(nil, n)
+	w := &bitWriter{}
+	var last uint64
+	for _, v := range values {
+		delta := v - last
+		last = v
+		for q := delta >> FilterP; q > 0; q-- {
+			w.writeBit(1)
+		}
+		w.writeBit(0)
+		w.writeBits(delta, FilterP)
+	}
+	return append(out, w.bytes...)
+}
+
+// decodeGCS 解码过滤器，返回元素数与升序哈希值
+func decodeGCS(filter []byte) (uint32, []uint64, error) {
+	n, size := binary.Uvarint(filter)
+	if size <= 0 || n > uint64(^uint32(0)) {
+		return 0, nil, ErrMalformedFilter
+	}
+	// 每个元素至少占 P+1 位，元素数超出剩余位数的过滤器必然损坏；不按对端声明的 N 预分配
+	if n*(FilterP+1) > 8*uint64(len(filter)) {
+		return 0, nil, ErrMalformedFilter
+	}
+	r := &bitReader{data: filter[size:]}
+	var values []uint64
+	var last uint64
+	for i := uint64(0); i < n; i++ {
+		var q uint64
+		for {
+			b, ok := r.readBit()
+			if !ok {
+				return 0, nil, ErrMalformedFilter
+			}
+			if b == 0 {
+				break
+			}
+			q++
+		}
+		rem, ok := r.readBits(FilterP)
+		if !ok {
+			return 0, nil, ErrMalformedFilter
+		}
+		last += q<<FilterP | rem
+		values = append(values, last)
+	}
+	return uint32(n), values, nil
+}
+
+// bitWriter 高位在前的位流写入
+type bitWriter struct {
+	bytes []byte
+	used  uint // 最后一个字节已写入的位数
+}
+
+func (w *bitWriter) writeBit(b uint64) {
+	if w.used == 0 || w.used == 8 {
+		w.bytes = append(w.bytes, 0)
+		w.used = 0
+	}
+	if b != 0 {
+		w.bytes[len(w.bytes)-1] |= 0x80 >> w.used
+	}
+	w.used++
+}
+
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	for i := n; i > 0; i-- {
+		w.writeBit(v >> (i - 1) & 1)
+	}
+}
+
+// bitReader 高位在前的位流读取
+type bitReader struct {
+	data []byte
+	pos  uint
+}
+
+func (r *bitReader) readBit() (uint64, bool) {
+	if r.pos >= uint(len(r.data))*8 {
+		return 0, false
+	}
+	b := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+	r.pos++
+	return uint64(b), true
+}
+
+func (r *bitReader) readBits(n uint) (uint64, bool) {
+	var v uint64
+	for i := uint(0); i < n; i++ {
+		b, ok := r.readBit()
+		if !ok {
+			return 0, false
+		}
+		v = v<<1 | b
+	}
+	return v, true
+}
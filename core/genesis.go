@@ -11,13 +11,13 @@ const (
 	genesisMiner      = "miner"
 	genesisTimestamp  = int64(1766922950)
 	genesisDifficulty = uint32(12)
-	genesisNonce      = uint64(1966)
-	genesisMerkleB64  = "lnzZx6skOjSgtVsnI1Pz9PxxUTOUNt7owZ2vb/jAOsQ="
+	genesisNonce      = uint64(57)
+	genesisMerkleB64  = "/NvGGWvhbA6dTzBqOYMQf2UIvu2nLhmQnLscwPLtx4k="
 )
 
 // GenesisBlock 返回硬编码的创世块（哈希稳定，不再依赖 time.Now）
 func GenesisBlock() *Block {
-	tx := NewCoinbaseTx(genesisMiner, 50)
+	tx := NewCoinbaseTx(genesisMiner, BlockSubsidy, 0)
 	txs := []*Transaction{tx}
 
 	merkle := ComputeMerkleRoot(txs)
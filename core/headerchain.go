@@ -0,0 +1,96 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// HeaderChain 轻节点维护的区块头链：只保存区块头，逐个校验链接与工作量证明
+// 区块难度由出块者填写，轻节点以 minDifficulty 拒绝低于本地要求的区块头
+type HeaderChain struct {
+	headers       []BlockHeader
+	minDifficulty uint32
+}
+
+// NewHeaderChain 以可信的创世区块头为锚点创建区块头链
+func NewHeaderChain(genesis BlockHeader, minDifficulty uint32) (*HeaderChain, error) {
+	if genesis.Height != 0 || len(genesis.PrevHash) != 0 {
+		return nil, errors.New("genesis header must have height 0 and empty prev hash")
+	}
+	return &HeaderChain{headers: []BlockHeader{genesis}, minDifficulty: minDifficulty}, nil
+}
+
+// Height 返回已验证的最高区块高度
+func (c *HeaderChain) Height() uint64 {
+	return c.headers[len(c.headers)-1].Height
+}
+
+// Work 返回自创世起的累计工作量，分叉链以此而非高度比较
+func (c *HeaderChain) Work() *big.Int {
+	return HeadersWork(c.headers)
+}
+
+// Header 按高度返回已验证的区块头
+func (c *HeaderChain) Header(height uint64) (BlockHeader, bool) {
+	if height >= uint64(len(c.headers)) {
+		return BlockHeader{}, false
+	}
+	return c.headers[height], true
+}
+
+// Headers 返回全部已验证区块头的副本
+func (c *HeaderChain) Headers() []BlockHeader {
+	return append([]BlockHeader(nil), c.headers...)
+}
+
+// Clone 复制区块头链，便于在副本上尝试切换分叉
+func (c *HeaderChain) Clone() *HeaderChain {
+	return &HeaderChain{headers: c.Headers(), minDifficulty: c.minDifficulty}
+}
+
+// Truncate 丢弃高于 height 的区块头（切换到分叉链前回退到共同祖先），创世区块头始终保留
+func (c *HeaderChain) Truncate(height uint64) {
+	if height+1 < uint64(len(c.headers)) {
+		c.headers = c.headers[:height+1]
+	}
+}
+
+// Append 校验并追加紧接链尾的区块头：高度连续、前哈希匹配、时间戳递增、难度不低于下限且满足工作量证明
+func (c *HeaderChain) Append(h BlockHeader) error {
+	tip := c.headers[len(c.headers)-1]
+	if h.Height != tip.Height+1 {
+		return fmt.Errorf("header height %d does not follow tip %d", h.Height, tip.Height)
+	}
+	if !bytes.Equal(h.PrevHash, HashBlockHeader(&tip)) {
+		return fmt.Errorf("header %d prev hash mismatch", h.Height)
+	}
+	if h.Timestamp <= tip.Timestamp {
+		return fmt.Errorf("header %d timestamp not increasing", h.Height)
+	}
+	if h.Difficulty < c.minDifficulty {
+		return fmt.Errorf("header %d difficulty %d below minimum %d", h.Height, h.Difficulty, c.minDifficulty)
+	}
+	if !ValidateHeaderPOW(&h) {
+		return fmt.Errorf("header %d pow invalid", h.Height)
+	}
+	c.headers = append(c.headers, h)
+	return nil
+}
+
+// VerifyTx 以已验证的区块头校验交易包含证明，返回确认数
+// header 为证明提供方声称的区块头，须与链上同高度区块头一致
+func (c *HeaderChain) VerifyTx(header BlockHeader, proof *MerkleProof) (uint64, error) {
+	trusted, ok := c.Header(header.Height)
+	if !ok {
+		return 0, fmt.Errorf("header at height %d not yet verified", header.Height)
+	}
+	if !bytes.Equal(HashBlockHeader(&trusted), HashBlockHeader(&header)) {
+		return 0, fmt.Errorf("header at height %d is not on the verified chain", header.Height)
+	}
+	if err := VerifyMerkleProof(proof, trusted.MerkleRoot); err != nil {
+		return 0, err
+	}
+	return c.Height() - header.Height + 1, nil
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// 时间锁相关常量，语义参照比特币 nLockTime / BIP68
+const (
+	// LockTimeThreshold 以下的 LockTime 视为区块高度，以上视为 Unix 时间戳
+	LockTimeThreshold = uint64(500000000)
+
+	// SequenceFinal 表示输入不参与任何时间锁（所有输入均为该值时 LockTime 也失效）
+	SequenceFinal = uint32(0xffffffff)
+	// SequenceLockTimeDisableFlag 置位时该输入不启用相对时间锁
+	SequenceLockTimeDisableFlag = uint32(1 << 31)
+	// SequenceLockTimeTypeFlag 置位时相对锁按时间计（单位 512 秒），否则按区块数计
+	SequenceLockTimeTypeFlag = uint32(1 << 22)
+	// SequenceLockTimeMask 取出相对锁数值的掩码
+	SequenceLockTimeMask = uint32(0x0000ffff)
+	// SequenceLockTimeGranularity 时间型相对锁的粒度（2^9 = 512 秒）
+	SequenceLockTimeGranularity = 9
+
+	// medianTimeSpan 计算中位时间使用的区块数
+	medianTimeSpan = 11
+)
+
+// ErrTxNotFinal 表示交易的绝对或相对时间锁尚未满足（交易本身有效，可暂存等待）
+var ErrTxNotFinal = errors.New("transaction timelock not satisfied")
+
+// SequenceLock 汇总交易所有输入的相对时间锁：
+// 区块高度需大于 MinHeight 且前序中位时间需大于 MinTime，-1 表示无约束
+type SequenceLock struct {
+	MinHeight int64
+	MinTime   int64
+}
+
+// MedianTimePast 返回区块列表末尾最多 11 个区块时间戳的中位数；空列表返回 0
+func MedianTimePast(blocks []*Block) int64 {
+	if len(blocks) == 0 {
+		return 0
+	}
+	start := len(blocks) - medianTimeSpan
+	if start < 0 {
+		start = 0
+	}
+	times := make([]int64, 0, len(blocks)-start)
+	for _, b := range blocks[start:] {
+		times = append(times, b.Header.Timestamp)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2]
+}
+
+// IsFinalTx 判断交易在高度 height、中位时间 medianTime 的区块中是否满足绝对时间锁
+func IsFinalTx(tx *Transaction, height uint64, medianTime int64) bool {
+	if tx == nil {
+		return false
+	}
+	if tx.LockTime == 0 {
+		return true
+	}
+	cutoff := height
+	if tx.LockTime >= LockTimeThreshold {
+		if medianTime < 0 {
+			medianTime = 0
+		}
+		cutoff = uint64(medianTime)
+	}
+	if tx.LockTime < cutoff {
+		return true
+	}
+	// 所有输入均放弃时间锁时，LockTime 不生效
+	for _, in := range tx.Inputs {
+		if in.Sequence != SequenceFinal {
+			return false
+		}
+	}
+	return true
+}
+
+// CalcSequenceLock 依据各输入引用 UTXO 的高度/时间计算交易的相对时间锁
+func CalcSequenceLock(tx *Transaction, utxos map[string][]UTXO) (SequenceLock, error) {
+	lock := SequenceLock{MinHeight: -1, MinTime: -1}
+	if tx == nil || tx.IsCoinbase {
+		return lock, nil
+	}
+	for _, in := range tx.Inputs {
+		if in.Sequence&SequenceLockTimeDisableFlag != 0 {
+			continue
+		}
+		utxo, ok := findUTXO(in.TxID, in.Vout, utxos)
+		if !ok {
+			return lock, ErrMissingInput
+		}
+		value := int64(in.Sequence & SequenceLockTimeMask)
+		if in.Sequence&SequenceLockTimeTypeFlag != 0 {
+			// 减 1 是为了沿用“大于”语义：锁定值本身所在时间即可满足
+			minTime := utxo.Time + (value << SequenceLockTimeGranularity) - 1
+			if minTime > lock.MinTime {
+				lock.MinTime = minTime
+			}
+		} else {
+			minHeight := int64(utxo.Height) + value - 1
+			if minHeight > lock.MinHeight {
+				lock.MinHeight = minHeight
+			}
+		}
+	}
+	return lock, nil
+}
+
+// Satisfied 判断相对时间锁在高度 height、中位时间 medianTime 的区块中是否已解除
+func (l SequenceLock) Satisfied(height uint64, medianTime int64) bool {
+	return l.MinHeight < int64(height) && l.MinTime < medianTime
+}
+
+// CheckTxLocks 校验交易的绝对与相对时间锁，未满足时返回包装 ErrTxNotFinal 的错误
+func CheckTxLocks(tx *Transaction, utxos map[string][]UTXO, height uint64, medianTime int64) error {
+	if tx == nil || tx.IsCoinbase {
+		return nil
+	}
+	if !IsFinalTx(tx, height, medianTime) {
+		return fmt.Errorf("%w: locktime %d", ErrTxNotFinal, tx.LockTime)
+	}
+	lock, err := CalcSequenceLock(tx, utxos)
+	if err != nil {
+		return err
+	}
+	if !lock.Satisfied(height, medianTime) {
+		return fmt.Errorf("%w: sequence lock until height>%d time>%d", ErrTxNotFinal, lock.MinHeight, lock.MinTime)
+	}
+	return nil
+}
@@ -1,11 +1,30 @@
 package core
 
-import "crypto/sha256"
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMerkleMutated 表示交易列表含重复叶子，与另一交易列表共享 Merkle 根
+	ErrMerkleMutated = errors.New("merkle tree mutated by duplicate leaves")
+	// ErrDuplicateTx 表示区块内出现重复交易
+	ErrDuplicateTx = errors.New("duplicate transaction in block")
+)
 
 // ComputeMerkleRoot 依据交易列表计算 Merkle 根；若为空返回 nil
 func ComputeMerkleRoot(txs []*Transaction) []byte {
+	root, _ := computeMerkleRoot(txs)
+	return root
+}
+
+// computeMerkleRoot 计算 Merkle 根，并报告树是否可被重复叶子变形（CVE-2012-2459）：
+// 单数层复制尾节点，因此 [a,b,c] 与 [a,b,c,c] 根相同；任一层出现相邻两个节点哈希相同即视为变形
+func computeMerkleRoot(txs []*Transaction) ([]byte, bool) {
 	if len(txs) == 0 {
-		return nil
+		return nil, false
 	}
 
 	// 初始化叶子节点哈希
@@ -14,6 +33,7 @@ func ComputeMerkleRoot(txs []*Transaction) []byte {
 		hashes[i] = txDigest(tx)
 	}
 
+	mutated := false
 	// 自底向上两两哈希，单数时复制最后一个
 	for len(hashes) > 1 {
 		nextLevel := make([][]byte, 0, (len(hashes)+1)/2)
@@ -22,6 +42,9 @@ func ComputeMerkleRoot(txs []*Transaction) []byte {
 			var right []byte
 			if i+1 < len(hashes) {
 				right = hashes[i+1]
+				if bytes.Equal(left, right) {
+					mutated = true
+				}
 			} else {
 				right = left // 复制尾节点
 			}
@@ -30,7 +53,42 @@ func ComputeMerkleRoot(txs []*Transaction) []byte {
 		hashes = nextLevel
 	}
 
-	return hashes[0]
+	return hashes[0], mutated
+}
+
+// CheckBlockMerkle 校验区块交易与 Merkle 根：交易 ID 与内容一致、无重复交易、
+// 树未被重复叶子变形、根与区块头一致
+// 变形返回 ErrMerkleMutated：同一区块头可能对应合法的交易列表，不能据此判定区块头无效
+func CheckBlockMerkle(block *Block) error {
+	if block == nil || len(block.Transactions) == 0 {
+		return errors.New("block has no transactions")
+	}
+	ids := make([][]byte, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		if tx == nil {
+			return fmt.Errorf("transaction %d is nil", i)
+		}
+		// 叶子取交易携带的 ID，必须与内容一致
+		ids[i] = ComputeTxID(tx)
+		if len(tx.ID) > 0 && !bytes.Equal(tx.ID, ids[i]) {
+			return fmt.Errorf("transaction %d id does not match its contents", i)
+		}
+	}
+	root, mutated := computeMerkleRoot(block.Transactions)
+	if mutated {
+		return ErrMerkleMutated
+	}
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		if _, dup := seen[string(id)]; dup {
+			return fmt.Errorf("%w: %x", ErrDuplicateTx, id)
+		}
+		seen[string(id)] = struct{}{}
+	}
+	if !bytes.Equal(root, block.Header.MerkleRoot) {
+		return errors.New("invalid merkle root")
+	}
+	return nil
 }
 
 // txDigest 提供交易的基础哈希；若已有 ID 则直接使用
@@ -47,7 +105,10 @@ func txDigest(tx *Transaction) []byte {
 
 // hashPair 将左右子哈希拼接后再做一次 SHA-256
 func hashPair(left, right []byte) []byte {
-	combined := append(left, right...)
+	// 新建切片拼接，避免 append 写入 left 的底层数组
+	combined := make([]byte, 0, len(left)+len(right))
+	combined = append(combined, left...)
+	combined = append(combined, right...)
 	sum := sha256.Sum256(combined)
 	return sum[:]
 }
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// MerkleProof 交易在区块中的 Merkle 包含证明
+// Branch 为自叶向根每层的兄弟哈希；Index 的第 i 位为 1 表示第 i 层当前节点位于右侧
+type MerkleProof struct {
+	TxID    []byte   `json:"txid"`
+	Index   int      `json:"index"`
+	TxCount int      `json:"tx_count"`
+	Branch  [][]byte `json:"branch"`
+}
+
+// BuildMerkleProof 为 txs 中第 index 笔交易生成包含证明，与 ComputeMerkleRoot 的构造一致
+func BuildMerkleProof(txs []*Transaction, index int) (*MerkleProof, error) {
+	if index < 0 || index >= len(txs) {
+		return nil, fmt.Errorf("tx index %d out of range [0,%d)", index, len(txs))
+	}
+	level := make([][]byte, len(txs))
+	for i, tx := range txs {
+		level[i] = txDigest(tx)
+	}
+	proof := &MerkleProof{TxID: level[index], Index: index, TxCount: len(txs)}
+	pos := index
+	for len(level) > 1 {
+		sibling := pos ^ 1
+		if sibling >= len(level) {
+			sibling = pos // 单数层复制尾节点
+		}
+		proof.Branch = append(proof.Branch, level[sibling])
+
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, hashPair(level[i], right))
+		}
+		level = next
+		pos /= 2
+	}
+	return proof, nil
+}
+
+// merkleDepth 返回 n 个叶子的树高（层数，不含根）
+func merkleDepth(n int) int {
+	depth := 0
+	for n > 1 {
+		n = (n + 1) / 2
+		depth++
+	}
+	return depth
+}
+
+// VerifyMerkleProof 校验证明能否由 TxID 沿 Branch 推出 root
+// 同时检查 Index 在 TxCount 范围内、分支长度与树高一致，避免以内部节点冒充叶子
+func VerifyMerkleProof(proof *MerkleProof, root []byte) error {
+	if proof == nil || len(proof.TxID) == 0 {
+		return errors.New("empty merkle proof")
+	}
+	if proof.TxCount <= 0 || proof.Index < 0 || proof.Index >= proof.TxCount {
+		return fmt.Errorf("tx index %d out of range for %d transactions", proof.Index, proof.TxCount)
+	}
+	if len(proof.Branch) != merkleDepth(proof.TxCount) {
+		return fmt.Errorf("branch length %d does not match tree depth %d", len(proof.Branch), merkleDepth(proof.TxCount))
+	}
+	cur := proof.TxID
+	pos := proof.Index
+	width := proof.TxCount
+	for _, sibling := range proof.Branch {
+		// 单数层最后一个节点的兄弟必须是其自身；其余位置兄弟与自身相同说明树被重复叶子变形
+		last := pos%2 == 0 && pos == width-1
+		if last != bytes.Equal(sibling, cur) {
+			return errors.New("invalid sibling in merkle branch")
+		}
+		if pos%2 == 1 {
+			cur = hashPair(sibling, cur)
+		} else {
+			cur = hashPair(cur, sibling)
+		}
+		pos /= 2
+		width = (width + 1) / 2
+	}
+	if !bytes.Equal(cur, root) {
+		return errors.New("merkle root mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// MaxPackageTxs 池内交易的祖先包、后代包（均含自身）最多包含的交易数，限制打包与替换时的计算量
+const MaxPackageTxs = 25
+
+// ErrPackageLimit 表示交易加入后祖先包或后代包超过 MaxPackageTxs
+var ErrPackageLimit = errors.New("transaction package too large")
+
+// TxPackage 一组相互依赖的池内交易的汇总：交易数、手续费合计、字节数合计
+type TxPackage struct {
+	Count int   `json:"count"`
+	Fee   int64 `json:"fee"`
+	Size  int   `json:"size"`
+}
+
+// FeeRateHigher 判断包手续费率是否严格高于 o
+func (pkg TxPackage) FeeRateHigher(o TxPackage) bool {
+	return feeRateHigher(pkg.Fee, pkg.Size, o.Fee, o.Size)
+}
+
+// Ancestors 返回池内交易 key 的全部池内祖先（被其直接或间接花费输出的池内交易）的键，按键排序，不含自身
+func (p *TxPool) Ancestors(key string) []string {
+	tx, ok := p.pool[key]
+	if !ok {
+		return nil
+	}
+	return p.ancestorsOf(tx, p.keysByTxID())
+}
+
+func (p *TxPool) ancestorsOf(tx *Transaction, byID map[string]string) []string {
+	seen := make(map[string]struct{})
+	queue := []*Transaction{tx}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, in := range cur.Inputs {
+			key, ok := byID[crypto.HexEncode(in.TxID)]
+			if !ok {
+				continue
+			}
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+			queue = append(queue, p.pool[key])
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for key := range seen {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AncestorPackage 汇总交易 key 及其池内祖先：打包 key 前必须先打包这些交易
+func (p *TxPool) AncestorPackage(key string, utxos map[string][]UTXO) (TxPackage, error) {
+	if _, ok := p.pool[key]; !ok {
+		return TxPackage{}, fmt.Errorf("transaction %s not in pool", key)
+	}
+	return p.summarize(append(p.Ancestors(key), key), utxos)
+}
+
+// DescendantPackage 汇总交易 key 及其池内后代：替换或移除 key 时这些交易一并失效
+func (p *TxPool) DescendantPackage(key string, utxos map[string][]UTXO) (TxPackage, error) {
+	if _, ok := p.pool[key]; !ok {
+		return TxPackage{}, fmt.Errorf("transaction %s not in pool", key)
+	}
+	return p.summarize(p.Descendants([]string{key}), utxos)
+}
+
+func (p *TxPool) summarize(keys []string, utxos map[string][]UTXO) (TxPackage, error) {
+	var pkg TxPackage
+	byID := p.keysByTxID()
+	for _, key := range keys {
+		tx := p.pool[key]
+		fee, err := p.txFee(tx, utxos, byID)
+		if err != nil {
+			return TxPackage{}, err
+		}
+		pkg.Count++
+		pkg.Fee += fee
+		pkg.Size += TxSize(tx)
+	}
+	return pkg, nil
+}
+
+// checkPackageLimits 检查 tx 加入后（不计 exclude 中将被驱逐的交易）其祖先包与各祖先的后代包是否超限
+// byID 为当前池的交易 ID 到池键映射，不会被修改
+func (p *TxPool) checkPackageLimits(tx *Transaction, exclude []string, byID map[string]string) error {
+	if len(exclude) > 0 {
+		kept := make(map[string]string, len(byID))
+		for id, key := range byID {
+			kept[id] = key
+		}
+		for _, key := range exclude {
+			if old, ok := p.pool[key]; ok {
+				delete(kept, crypto.HexEncode(ComputeTxID(old)))
+			}
+		}
+		byID = kept
+	}
+	ancestors := p.ancestorsOf(tx, byID)
+	if len(ancestors)+1 > MaxPackageTxs {
+		return fmt.Errorf("%w: %d ancestors (max %d including itself)", ErrPackageLimit, len(ancestors), MaxPackageTxs)
+	}
+	excluded := make(map[string]struct{}, len(exclude))
+	for _, key := range exclude {
+		excluded[key] = struct{}{}
+	}
+	children := p.childrenByParent()
+	for _, a := range ancestors {
+		n := 0
+		for _, d := range p.descendantsOf([]string{a}, children) {
+			if _, ok := excluded[d]; !ok {
+				n++
+			}
+		}
+		if n+1 > MaxPackageTxs {
+			return fmt.Errorf("%w: ancestor %s would have %d descendants (max %d including itself)", ErrPackageLimit, a, n, MaxPackageTxs)
+		}
+	}
+	return nil
+}
+
+// UTXOView 返回已确认 UTXO 集加上池内交易输出的视图，用于校验花费未确认输出的子交易
+// 池内交易花费的输出不从视图中移除，双花由 Submit 的冲突检查处理；池内输出按将被打包的 height/medianTime 记录
+func (p *TxPool) UTXOView(utxos map[string][]UTXO, height uint64, medianTime int64) map[string][]UTXO {
+	view := CloneUTXOSet(utxos)
+	for _, key := range p.sortedIDs() {
+		tx := p.pool[key]
+		if tx.IsCoinbase {
+			continue
+		}
+		txID := ComputeTxID(tx)
+		idHex := crypto.HexEncode(txID)
+		if _, ok := view[idHex]; ok {
+			continue
+		}
+		for i, out := range tx.Outputs {
+			view[idHex] = append(view[idHex], UTXO{TxID: txID, Index: i, Output: out, Height: height, Time: medianTime})
+		}
+	}
+	return view
+}
+
+// AddToUTXOView 把新入池交易的输出并入 UTXOView 返回的视图，逐笔入池时无需每次重建视图
+func AddToUTXOView(view map[string][]UTXO, tx *Transaction, height uint64, medianTime int64) {
+	if tx.IsCoinbase {
+		return
+	}
+	txID := ComputeTxID(tx)
+	idHex := crypto.HexEncode(txID)
+	if _, ok := view[idHex]; ok {
+		return
+	}
+	for i, out := range tx.Outputs {
+		view[idHex] = append(view[idHex], UTXO{TxID: txID, Index: i, Output: out, Height: height, Time: medianTime})
+	}
+}
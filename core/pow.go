@@ -24,11 +24,36 @@ func ValidateBlockPOW(block *Block) bool {
 	if block == nil {
 		return false
 	}
-	target := targetFromDifficulty(block.Header.Difficulty)
-	hashInt := new(big.Int).SetBytes(HashBlockHeader(&block.Header))
+	return ValidateHeaderPOW(&block.Header)
+}
+
+// ValidateHeaderPOW 校验单个区块头的哈希是否满足其难度目标（轻节点只持有区块头）
+func ValidateHeaderPOW(header *BlockHeader) bool {
+	if header == nil {
+		return false
+	}
+	target := targetFromDifficulty(header.Difficulty)
+	hashInt := new(big.Int).SetBytes(HashBlockHeader(header))
 	return hashInt.Cmp(target) <= 0
 }
 
+// BlockWork 难度为 difficulty 的区块的工作量，即期望哈希次数 2^difficulty（难度上限 256 位）
+func BlockWork(difficulty uint32) *big.Int {
+	if difficulty > 256 {
+		difficulty = 256
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(difficulty))
+}
+
+// HeadersWork 返回一段区块头的累计工作量，各区块头工作量按难度计算
+func HeadersWork(headers []BlockHeader) *big.Int {
+	work := new(big.Int)
+	for i := range headers {
+		work.Add(work, BlockWork(headers[i].Difficulty))
+	}
+	return work
+}
+
 // MineBlock 依据给定难度寻找满足目标的 Nonce；用于单节点模拟
 // 若 prev 为 nil，视作创世块
 func MineBlock(prev *Block, txs []*Transaction, difficulty uint32) *Block {
@@ -51,6 +76,10 @@ func MineBlock(prev *Block, txs []*Transaction, difficulty uint32) *Block {
 	for nonce := uint64(0); ; nonce++ {
 		block.Header.Nonce = nonce
 		block.Header.Timestamp = time.Now().Unix()
+		// 区块时间戳须严格递增：同一秒内连续出块时取前块时间 + 1
+		if prev != nil && block.Header.Timestamp <= prev.Header.Timestamp {
+			block.Header.Timestamp = prev.Header.Timestamp + 1
+		}
 		hashInt := new(big.Int).SetBytes(HashBlockHeader(&block.Header))
 		if hashInt.Cmp(target) <= 0 {
 			return block
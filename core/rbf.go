@@ -0,0 +1,238 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// SequenceMaxReplaceable 输入 sequence 不超过该值即表示交易允许被替换（BIP125 选择加入）
+// 该值置有 SequenceLockTimeDisableFlag，不会启用相对时间锁
+const SequenceMaxReplaceable = SequenceFinal - 2
+
+// maxReplacementEvictions 一次替换最多驱逐的交易数（含后代），防止廉价地清空交易池
+const maxReplacementEvictions = 100
+
+var (
+	// ErrTxConflict 表示交易与池中不允许替换的交易花费了相同输出
+	ErrTxConflict = errors.New("transaction conflicts with a non-replaceable pool transaction")
+	// ErrReplacementFee 表示替换交易的手续费或手续费率不足
+	ErrReplacementFee = errors.New("replacement fee too low")
+)
+
+// SignalsReplacement 判断交易是否选择加入替换：任一输入的 sequence 不超过 SequenceMaxReplaceable
+func SignalsReplacement(tx *Transaction) bool {
+	if tx == nil || tx.IsCoinbase {
+		return false
+	}
+	for _, in := range tx.Inputs {
+		if in.Sequence <= SequenceMaxReplaceable {
+			return true
+		}
+	}
+	return false
+}
+
+// feeRateHigher 判断 fee1/size1 是否严格高于 fee2/size2（交叉相乘避免浮点）
+func feeRateHigher(fee1 int64, size1 int, fee2 int64, size2 int) bool {
+	return fee1*int64(size2) > fee2*int64(size1)
+}
+
+// Submit 按替换策略将交易放入池（以交易 ID hex 为键），返回被驱逐交易的池内键
+// 不与池中交易冲突时直接加入；冲突时所有直接冲突的交易都须选择加入替换，
+// 且新交易的手续费须严格高于被驱逐交易（直接冲突及其后代）手续费之和、
+// 手续费率须严格高于每笔直接冲突交易，满足时驱逐这些交易后加入
+// 加入后祖先包或任一祖先的后代包超过 MaxPackageTxs 时返回 ErrPackageLimit
+// utxos 为已确认 UTXO 集，用于计算手续费；父交易在池中的输入按池内输出计算
+func (p *TxPool) Submit(tx *Transaction, utxos map[string][]UTXO) ([]string, error) {
+	if tx == nil {
+		return nil, errors.New("tx is nil")
+	}
+	id := crypto.HexEncode(ComputeTxID(tx))
+	// 交易 ID 到池键的映射在本次提交中只建一次，池内容在驱逐前不变
+	byID := p.keysByTxID()
+	if key, ok := byID[id]; ok {
+		p.pool[key] = tx
+		return nil, nil
+	}
+	conflicts := p.Conflicts(tx)
+	if len(conflicts) == 0 {
+		if err := p.checkPackageLimits(tx, nil, byID); err != nil {
+			return nil, err
+		}
+		p.pool[id] = tx
+		return nil, nil
+	}
+
+	for _, cid := range conflicts {
+		if !SignalsReplacement(p.pool[cid]) {
+			return nil, fmt.Errorf("%w: %s", ErrTxConflict, cid)
+		}
+	}
+	evicted := p.Descendants(conflicts)
+	if len(evicted) > maxReplacementEvictions {
+		return nil, fmt.Errorf("replacement would evict %d transactions (max %d)", len(evicted), maxReplacementEvictions)
+	}
+	for _, eid := range evicted {
+		evictedID := crypto.HexEncode(ComputeTxID(p.pool[eid]))
+		for _, in := range tx.Inputs {
+			if crypto.HexEncode(in.TxID) == evictedID {
+				return nil, errors.New("replacement spends an output of a transaction it replaces")
+			}
+		}
+	}
+
+	fee, err := p.txFee(tx, utxos, byID)
+	if err != nil {
+		return nil, err
+	}
+	size := TxSize(tx)
+	var evictedFees int64
+	for _, eid := range evicted {
+		f, err := p.txFee(p.pool[eid], utxos, byID)
+		if err != nil {
+			return nil, err
+		}
+		evictedFees += f
+	}
+	if fee <= evictedFees {
+		return nil, fmt.Errorf("%w: fee %d must exceed %d paid by replaced transactions", ErrReplacementFee, fee, evictedFees)
+	}
+	for _, cid := range conflicts {
+		old := p.pool[cid]
+		oldFee, err := p.txFee(old, utxos, byID)
+		if err != nil {
+			return nil, err
+		}
+		if !feeRateHigher(fee, size, oldFee, TxSize(old)) {
+			return nil, fmt.Errorf("%w: fee rate %d/%dB must exceed %d/%dB of %s", ErrReplacementFee, fee, size, oldFee, TxSize(old), cid)
+		}
+	}
+	if err := p.checkPackageLimits(tx, evicted, byID); err != nil {
+		return nil, err
+	}
+
+	p.RemoveMany(evicted)
+	p.pool[id] = tx
+	return evicted, nil
+}
+
+// Conflicts 返回与 tx 花费相同输出的池内交易键（不含 tx 自身）
+func (p *TxPool) Conflicts(tx *Transaction) []string {
+	spent := make(map[string]struct{}, len(tx.Inputs))
+	for _, in := range tx.Inputs {
+		spent[outpointKey(in.TxID, in.Vout)] = struct{}{}
+	}
+	self := crypto.HexEncode(ComputeTxID(tx))
+	var out []string
+	for _, key := range p.sortedIDs() {
+		other := p.pool[key]
+		if crypto.HexEncode(ComputeTxID(other)) == self {
+			continue
+		}
+		for _, in := range other.Inputs {
+			if _, ok := spent[outpointKey(in.TxID, in.Vout)]; ok {
+				out = append(out, key)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Descendants 返回 keys 及其在池内的全部后代（花费其输出的交易，递归），按发现顺序去重
+func (p *TxPool) Descendants(keys []string) []string {
+	return p.descendantsOf(keys, p.childrenByParent())
+}
+
+// childrenByParent 返回父交易 ID hex 到花费其输出的池内交易键的映射
+func (p *TxPool) childrenByParent() map[string][]string {
+	children := make(map[string][]string)
+	for _, key := range p.sortedIDs() {
+		for _, in := range p.pool[key].Inputs {
+			parent := crypto.HexEncode(in.TxID)
+			if n := len(children[parent]); n == 0 || children[parent][n-1] != key {
+				children[parent] = append(children[parent], key)
+			}
+		}
+	}
+	return children
+}
+
+func (p *TxPool) descendantsOf(keys []string, children map[string][]string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	var out []string
+	queue := append([]string(nil), keys...)
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		tx, ok := p.pool[key]
+		if _, dup := seen[key]; dup || !ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, key)
+		queue = append(queue, children[crypto.HexEncode(ComputeTxID(tx))]...)
+	}
+	return out
+}
+
+// TxFee 计算交易手续费（输入合计 - 输出合计）；输入先在已确认 UTXO 中查找，再在池内交易输出中查找
+func (p *TxPool) TxFee(tx *Transaction, utxos map[string][]UTXO) (int64, error) {
+	return p.txFee(tx, utxos, p.keysByTxID())
+}
+
+func (p *TxPool) txFee(tx *Transaction, utxos map[string][]UTXO, byID map[string]string) (int64, error) {
+	if tx == nil || tx.IsCoinbase {
+		return 0, nil
+	}
+	var fee int64
+	for _, in := range tx.Inputs {
+		out, ok := p.spentOutput(in, utxos, byID)
+		if !ok {
+			return 0, fmt.Errorf("input %x:%d not found", in.TxID, in.Vout)
+		}
+		fee += out.Value
+	}
+	for _, out := range tx.Outputs {
+		fee -= out.Value
+	}
+	return fee, nil
+}
+
+// SpentOutput 查找输入引用的输出：先查已确认 UTXO，再查池内交易的输出
+func (p *TxPool) SpentOutput(in TxInput, utxos map[string][]UTXO) (TxOutput, bool) {
+	if u, ok := findUTXO(in.TxID, in.Vout, utxos); ok {
+		return u.Output, true
+	}
+	return p.spentOutput(in, nil, p.keysByTxID())
+}
+
+// spentOutput 同 SpentOutput，池内交易经调用方预先建好的 byID 查找
+func (p *TxPool) spentOutput(in TxInput, utxos map[string][]UTXO, byID map[string]string) (TxOutput, bool) {
+	if u, ok := findUTXO(in.TxID, in.Vout, utxos); ok {
+		return u.Output, true
+	}
+	key, ok := byID[crypto.HexEncode(in.TxID)]
+	if !ok {
+		return TxOutput{}, false
+	}
+	if parent := p.pool[key]; in.Vout >= 0 && in.Vout < len(parent.Outputs) {
+		return parent.Outputs[in.Vout], true
+	}
+	return TxOutput{}, false
+}
+
+// keysByTxID 返回交易 ID hex 到池内键的映射（池键通常即交易 ID，但不作假设）
+func (p *TxPool) keysByTxID() map[string]string {
+	out := make(map[string]string, len(p.pool))
+	for key, tx := range p.pool {
+		out[crypto.HexEncode(ComputeTxID(tx))] = key
+	}
+	return out
+}
+
+func outpointKey(txid []byte, vout int) string {
+	return fmt.Sprintf("%x:%d", txid, vout)
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// BlockTemplate 区块模板：按打包顺序排列的交易（不含 coinbase）及其手续费、字节数合计
+type BlockTemplate struct {
+	Transactions []*Transaction
+	Fees         int64
+	Size         int
+}
+
+// SelectTransactions 从候选交易中挑选可打包进高度 height 区块的交易，不限制区块大小
+func SelectTransactions(candidates []*Transaction, utxos map[string][]UTXO, height uint64, medianTime int64, params ChainParams) []*Transaction {
+	return BuildTemplate(candidates, utxos, height, medianTime, 0, params).Transactions
+}
+
+// BuildTemplate 按祖先包手续费率从高到低挑选交易（子交易可为父交易付费，CPFP）：
+// 每笔候选交易与其尚未入选的池内祖先组成一个包，包手续费率 = 包内手续费之和 / 包内字节数之和，
+// 每轮放入包手续费率最高且能装下的包。每笔交易在工作 UTXO 集上校验（含时间锁），包内任一笔失败时回滚该包，
+// 未满足时间锁或无效的交易及其后代留在池中。maxBytes 为 0 表示不限制交易总字节数
+func BuildTemplate(candidates []*Transaction, utxos map[string][]UTXO, height uint64, medianTime int64, maxBytes int, params ChainParams) *BlockTemplate {
+	working := CloneUTXOSet(utxos)
+
+	type entry struct {
+		tx      *Transaction
+		id      string
+		fee     int64
+		size    int
+		parents []string // 候选集中被本交易花费的父交易
+	}
+	entries := make(map[string]*entry, len(candidates))
+	var order []string
+	for _, tx := range candidates {
+		if tx == nil || tx.IsCoinbase {
+			continue
+		}
+		id := crypto.HexEncode(ComputeTxID(tx))
+		if _, dup := entries[id]; dup {
+			continue
+		}
+		entries[id] = &entry{tx: tx, id: id, size: TxSize(tx)}
+		order = append(order, id)
+	}
+	// 按 ID 排序，手续费率相同时模板确定
+	sort.Strings(order)
+
+	// 手续费：输入先查已确认 UTXO，再查候选交易的输出；找不到输入的交易不可能有效，直接排除
+	for _, id := range order {
+		e := entries[id]
+		ok := true
+		seen := make(map[string]struct{})
+		for _, in := range e.tx.Inputs {
+			if u, found := findUTXO(in.TxID, in.Vout, utxos); found {
+				e.fee += u.Output.Value
+				continue
+			}
+			parentID := crypto.HexEncode(in.TxID)
+			parent, found := entries[parentID]
+			if !found || in.Vout < 0 || in.Vout >= len(parent.tx.Outputs) {
+				ok = false
+				break
+			}
+			e.fee += parent.tx.Outputs[in.Vout].Value
+			if _, dup := seen[parentID]; !dup {
+				seen[parentID] = struct{}{}
+				e.parents = append(e.parents, parentID)
+			}
+		}
+		if !ok {
+			delete(entries, id)
+			continue
+		}
+		for _, out := range e.tx.Outputs {
+			e.fee -= out.Value
+		}
+	}
+
+	tmpl := &BlockTemplate{}
+	selected := make(map[string]bool)
+	failed := make(map[string]bool)
+	// ancestry 将 id 及其未入选祖先按依赖顺序（祖先在前）追加到 out；祖先缺失或已失败时返回 false
+	var ancestry func(id string, visiting map[string]bool, out *[]string) bool
+	ancestry = func(id string, visiting map[string]bool, out *[]string) bool {
+		if selected[id] || visiting[id] {
+			return true
+		}
+		e, ok := entries[id]
+		if !ok || failed[id] {
+			return false
+		}
+		visiting[id] = true
+		for _, p := range e.parents {
+			if !ancestry(p, visiting, out) {
+				return false
+			}
+		}
+		*out = append(*out, id)
+		return true
+	}
+
+	for {
+		var (
+			best     []string
+			bestFee  int64
+			bestSize int
+		)
+		for _, id := range order {
+			if selected[id] || failed[id] || entries[id] == nil {
+				continue
+			}
+			var pkg []string
+			if !ancestry(id, map[string]bool{}, &pkg) {
+				failed[id] = true
+				continue
+			}
+			var fee int64
+			size := 0
+			for _, pid := range pkg {
+				fee += entries[pid].fee
+				size += entries[pid].size
+			}
+			if maxBytes > 0 && tmpl.Size+size > maxBytes {
+				continue
+			}
+			if best == nil || feeRateHigher(fee, size, bestFee, bestSize) {
+				best, bestFee, bestSize = pkg, fee, size
+			}
+		}
+		if best == nil {
+			break
+		}
+
+		// 依次校验包内交易并应用到工作集，全部有效才放入；否则按撤销记录回滚，失败的交易及其后代不再考虑
+		undo := utxoUndo{}
+		ok := true
+		for _, id := range best {
+			if err := ValidateTransaction(entries[id].tx, working, height, medianTime, params); err != nil {
+				failed[id] = true
+				ok = false
+				break
+			}
+			undo.apply(working, entries[id].tx, height, medianTime)
+		}
+		if !ok {
+			undo.restore(working)
+			continue
+		}
+		for _, id := range best {
+			selected[id] = true
+			tmpl.Transactions = append(tmpl.Transactions, entries[id].tx)
+			tmpl.Fees += entries[id].fee
+			tmpl.Size += entries[id].size
+		}
+	}
+	return tmpl
+}
+
+// utxoUndo 记录试算时被改动的 UTXO 条目的原值（nil 表示原本不存在），失败时据此回滚，
+// 避免为每个候选包复制整个 UTXO 集
+type utxoUndo map[string][]UTXO
+
+// apply 保存 tx 将改动的条目后把 tx 应用到 utxos
+func (u utxoUndo) apply(utxos map[string][]UTXO, tx *Transaction, height uint64, medianTime int64) {
+	keys := []string{crypto.HexEncode(ComputeTxID(tx))}
+	for _, in := range tx.Inputs {
+		keys = append(keys, crypto.HexEncode(in.TxID))
+	}
+	for _, key := range keys {
+		if _, saved := u[key]; saved {
+			continue
+		}
+		// removeUTXO 会原地改写切片，须保存副本
+		u[key] = append([]UTXO(nil), utxos[key]...)
+	}
+	ApplyTxToUTXO(utxos, tx, height, medianTime)
+}
+
+// restore 把改动过的条目恢复为 apply 前的值
+func (u utxoUndo) restore(utxos map[string][]UTXO) {
+	for key, list := range u {
+		if len(list) == 0 {
+			delete(utxos, key)
+		} else {
+			utxos[key] = list
+		}
+	}
+}
@@ -4,14 +4,21 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
 )
 
+// maxCoinbaseDataSize 限制 coinbase 附加数据长度（高度承诺 + 额外数据）
+const maxCoinbaseDataSize = 100
+
 // TxInput 表示交易输入，引用前一交易的输出
 type TxInput struct {
 	TxID      []byte // 被引用的交易 ID
 	Vout      int    // 被引用的输出索引
 	Signature []byte // 交易签名
 	PubKey    []byte // 发送者公钥（用于验签和地址匹配）
+	Sequence  uint32 // 相对时间锁（BIP68 语义），SequenceFinal 表示不启用任何时间锁
 }
 
 // TxOutput 表示交易输出
@@ -26,20 +33,120 @@ type Transaction struct {
 	Inputs     []TxInput  // 输入列表
 	Outputs    []TxOutput // 输出列表
 	IsCoinbase bool       // 是否为 coinbase 交易
+	LockTime   uint64     // 绝对时间锁：小于 LockTimeThreshold 视为区块高度，否则为 Unix 秒
+	// CoinbaseData 仅 coinbase 使用：区块高度承诺（uvarint）+ 额外数据（extra nonce / 矿工标签）
+	CoinbaseData []byte
+}
+
+// NewCoinbaseTx 创建一笔承诺区块高度的 coinbase 交易，保证不同高度的 coinbase ID 不同
+func NewCoinbaseTx(to string, reward int64, height uint64) *Transaction {
+	return NewCoinbaseTxWithExtra(to, reward, height, nil)
 }
 
-// NewCoinbaseTx 创建一笔简单的 coinbase 交易
-func NewCoinbaseTx(to string, reward int64) *Transaction {
+// NewCoinbaseTxWithExtra 在高度承诺后追加额外数据（如 extra nonce、矿工标签）
+func NewCoinbaseTxWithExtra(to string, reward int64, height uint64, extra []byte) *Transaction {
 	output := TxOutput{
 		Value:        reward,
 		ScriptPubKey: to,
 	}
 	return &Transaction{
-		ID:         nil, // 可后续计算哈希
-		Inputs:     nil, // coinbase 无输入
-		Outputs:    []TxOutput{output},
-		IsCoinbase: true,
+		ID:           nil, // 可后续计算哈希
+		Inputs:       nil, // coinbase 无输入
+		Outputs:      []TxOutput{output},
+		IsCoinbase:   true,
+		CoinbaseData: EncodeCoinbaseData(height, extra),
+	}
+}
+
+// EncodeCoinbaseData 编码 coinbase 附加数据：uvarint(height) || extra
+func EncodeCoinbaseData(height uint64, extra []byte) []byte {
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(extra))
+	n := binary.PutUvarint(buf, height)
+	return append(buf[:n], extra...)
+}
+
+// CoinbaseHeight 解析 coinbase 承诺的区块高度
+func CoinbaseHeight(tx *Transaction) (uint64, error) {
+	if tx == nil || !tx.IsCoinbase {
+		return 0, errors.New("not a coinbase transaction")
+	}
+	height, n := binary.Uvarint(tx.CoinbaseData)
+	if n <= 0 {
+		return 0, errors.New("coinbase missing height commitment")
+	}
+	return height, nil
+}
+
+// BlockSubsidy 每个区块的出块补贴；矿工还可领取区块内交易的手续费
+const BlockSubsidy = int64(50)
+
+// ErrValueOverflow 金额合计超出 int64 范围
+var ErrValueOverflow = errors.New("value sum overflows int64")
+
+// AddValue 累加两个非负金额，结果超出 int64 时返回 ErrValueOverflow，避免回绕成小额或负数
+func AddValue(total, v int64) (int64, error) {
+	if v > math.MaxInt64-total {
+		return 0, ErrValueOverflow
+	}
+	return total + v, nil
+}
+
+// CheckCoinbaseValue 校验 coinbase 输出合计不超过出块补贴加区块内交易手续费 fees
+func CheckCoinbaseValue(block *Block, fees int64) error {
+	if block == nil || len(block.Transactions) == 0 || block.Transactions[0] == nil {
+		return errors.New("block has no coinbase")
+	}
+	if fees < 0 {
+		return errors.New("negative block fees")
+	}
+	var total int64
+	for _, out := range block.Transactions[0].Outputs {
+		if out.Value < 0 {
+			return errors.New("negative coinbase output")
+		}
+		var err error
+		if total, err = AddValue(total, out.Value); err != nil {
+			return fmt.Errorf("coinbase outputs: %w", err)
+		}
 	}
+	limit, err := AddValue(BlockSubsidy, fees)
+	if err != nil {
+		return fmt.Errorf("subsidy plus fees: %w", err)
+	}
+	if total > limit {
+		return fmt.Errorf("coinbase pays %d, exceeds subsidy %d plus fees %d", total, BlockSubsidy, fees)
+	}
+	return nil
+}
+
+// ValidateCoinbase 校验区块的 coinbase：首笔且唯一、无输入、承诺高度与区块头一致
+func ValidateCoinbase(block *Block) error {
+	if block == nil || len(block.Transactions) == 0 {
+		return errors.New("block has no transactions")
+	}
+	coinbase := block.Transactions[0]
+	if coinbase == nil || !coinbase.IsCoinbase {
+		return errors.New("first transaction is not coinbase")
+	}
+	for _, tx := range block.Transactions[1:] {
+		if tx != nil && tx.IsCoinbase {
+			return errors.New("multiple coinbase transactions")
+		}
+	}
+	if len(coinbase.Inputs) != 0 {
+		return errors.New("coinbase must not have inputs")
+	}
+	if len(coinbase.CoinbaseData) > maxCoinbaseDataSize {
+		return errors.New("coinbase data too large")
+	}
+	height, err := CoinbaseHeight(coinbase)
+	if err != nil {
+		return err
+	}
+	if height != block.Header.Height {
+		return fmt.Errorf("coinbase height %d does not match block height %d", height, block.Header.Height)
+	}
+	return nil
 }
 
 // TxSigningHash 返回用于签名/验签的交易摘要（不包含 Signature 字段）
@@ -57,7 +164,21 @@ func txDigestWithSig(tx *Transaction, includeSig bool) []byte {
 	if tx == nil {
 		return nil
 	}
+	sum := sha256.Sum256(serializeTx(tx, includeSig))
+	sum2 := sha256.Sum256(sum[:])
+	return sum2[:]
+}
+
+// TxSize 返回交易序列化（含签名）后的字节数，用于计算手续费率
+func TxSize(tx *Transaction) int {
+	if tx == nil {
+		return 0
+	}
+	return len(serializeTx(tx, true))
+}
 
+// serializeTx 交易的规范序列化，ID 与签名摘要均基于此
+func serializeTx(tx *Transaction, includeSig bool) []byte {
 	var buf bytes.Buffer
 	if tx.IsCoinbase {
 		buf.WriteByte(1)
@@ -68,6 +189,7 @@ func txDigestWithSig(tx *Transaction, includeSig bool) []byte {
 	for _, in := range tx.Inputs {
 		writeVarBytes(&buf, in.TxID)
 		writeInt64(&buf, int64(in.Vout))
+		writeInt64(&buf, int64(in.Sequence))
 		if includeSig {
 			writeVarBytes(&buf, in.Signature)
 		}
@@ -77,9 +199,9 @@ func txDigestWithSig(tx *Transaction, includeSig bool) []byte {
 		writeInt64(&buf, out.Value)
 		buf.WriteString(out.ScriptPubKey)
 	}
-	sum := sha256.Sum256(buf.Bytes())
-	sum2 := sha256.Sum256(sum[:])
-	return sum2[:]
+	writeInt64(&buf, int64(tx.LockTime))
+	writeVarBytes(&buf, tx.CoinbaseData)
+	return buf.Bytes()
 }
 
 func writeInt64(buf *bytes.Buffer, v int64) {
@@ -1,5 +1,7 @@
 package core
 
+import "sort"
+
 // TxPool 用于暂存待打包的交易
 type TxPool struct {
 	pool map[string]*Transaction
@@ -12,7 +14,7 @@ func NewTxPool() *TxPool {
 	}
 }
 
-// Add 将交易放入池，重复 ID 会覆盖旧交易
+// Add 将交易放入池，重复 ID 会覆盖旧交易；不检查冲突，接收外部交易应使用 Submit
 func (p *TxPool) Add(id string, tx *Transaction) {
 	p.pool[id] = tx
 }
@@ -66,3 +68,19 @@ func (p *TxPool) RemoveMany(ids []string) {
 		delete(p.pool, id)
 	}
 }
+
+// Get 按 ID 取出池内交易
+func (p *TxPool) Get(id string) (*Transaction, bool) {
+	tx, ok := p.pool[id]
+	return tx, ok
+}
+
+// sortedIDs 返回按 ID 排序的池内交易 ID，保证遍历结果确定
+func (p *TxPool) sortedIDs() []string {
+	ids := make([]string, 0, len(p.pool))
+	for id := range p.pool {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -3,15 +3,47 @@ package core
 import (
 	"bytes"
 	"errors"
+	"fmt"
 
 	"github.com/yiqi-017/blockchain/crypto"
 )
 
+// DefaultCoinbaseMaturity coinbase 输出可被花费前需经过的区块数
+const DefaultCoinbaseMaturity = uint64(100)
+
+// ChainParams 全网须一致的共识参数，由调用方显式传入校验函数
+type ChainParams struct {
+	CoinbaseMaturity uint64 // coinbase 输出可被花费前需经过的区块数
+	MinDifficulty    uint32 // 区块难度下限，低于它的区块头与区块一律拒绝；0 表示不限制
+}
+
+// DefaultChainParams 返回默认共识参数
+func DefaultChainParams() ChainParams {
+	return ChainParams{CoinbaseMaturity: DefaultCoinbaseMaturity}
+}
+
+// ErrImmatureCoinbase 表示交易花费了尚未成熟的 coinbase 输出
+var ErrImmatureCoinbase = errors.New("spend of immature coinbase")
+
+// ErrMissingInput 交易引用的输出不存在或已被花费（也可能只是本地尚未收到父交易）
+var ErrMissingInput = errors.New("referenced output not found or spent")
+
 // UTXO 表示未花费输出
 type UTXO struct {
-	TxID   []byte
-	Index  int
-	Output TxOutput
+	TxID     []byte
+	Index    int
+	Output   TxOutput
+	Height   uint64 // 输出所在区块高度（相对时间锁、成熟度使用）
+	Time     int64  // 所在区块前序的中位时间（时间型相对锁使用）
+	Coinbase bool   // 是否来自 coinbase 交易
+}
+
+// Mature 判断该输出能否被高度 spendHeight 的区块花费，maturity 为 coinbase 成熟深度（非 coinbase 恒为 true）
+func (u UTXO) Mature(spendHeight, maturity uint64) bool {
+	if !u.Coinbase {
+		return true
+	}
+	return spendHeight >= u.Height && spendHeight-u.Height >= maturity
 }
 
 // BuildUTXOSet 从区块列表构建 UTXO 集合（全链扫描）
@@ -19,60 +51,98 @@ type UTXO struct {
 func BuildUTXOSet(blocks []*Block) map[string][]UTXO {
 	utxos := make(map[string][]UTXO)
 
-	for _, block := range blocks {
+	for i, block := range blocks {
+		// 与 BIP68 一致：输出时间取所在区块之前的中位时间（创世取自身）
+		prevEnd := i
+		if prevEnd == 0 {
+			prevEnd = 1
+		}
+		medianTime := MedianTimePast(blocks[:prevEnd])
 		for txIdx, tx := range block.Transactions {
-			txID := ComputeTxID(tx)
-			txIDHex := crypto.HexEncode(txID)
-
-			// 先处理支出：从 UTXO 集中移除已引用输出
-			if !tx.IsCoinbase {
-				for _, in := range tx.Inputs {
-					removeUTXO(utxos, in.TxID, in.Vout)
-				}
-			}
-
-			// 添加新输出
-			for outIdx, out := range tx.Outputs {
-				utxos[txIDHex] = append(utxos[txIDHex], UTXO{
-					TxID:   txID,
-					Index:  outIdx,
-					Output: out,
-				})
-			}
-
+			ApplyTxToUTXO(utxos, tx, block.Header.Height, medianTime)
 			// 确保交易哈希写回，便于后续引用
-			block.Transactions[txIdx].ID = txID
+			block.Transactions[txIdx].ID = ComputeTxID(tx)
 		}
 	}
 
 	return utxos
 }
 
-// ValidateTransaction 校验单笔交易：存在性、余额守恒、验签、未花费
-func ValidateTransaction(tx *Transaction, utxos map[string][]UTXO) error {
+// ApplyTxToUTXO 将交易的花费与新增输出应用到 UTXO 集（height/medianTime 为所在区块信息）
+func ApplyTxToUTXO(utxos map[string][]UTXO, tx *Transaction, height uint64, medianTime int64) {
+	if tx == nil {
+		return
+	}
+	txID := ComputeTxID(tx)
+	txIDHex := crypto.HexEncode(txID)
+
+	// 先处理支出：从 UTXO 集中移除已引用输出
+	if !tx.IsCoinbase {
+		for _, in := range tx.Inputs {
+			removeUTXO(utxos, in.TxID, in.Vout)
+		}
+	}
+
+	// 添加新输出
+	for outIdx, out := range tx.Outputs {
+		utxos[txIDHex] = append(utxos[txIDHex], UTXO{
+			TxID:     txID,
+			Index:    outIdx,
+			Output:   out,
+			Height:   height,
+			Time:     medianTime,
+			Coinbase: tx.IsCoinbase,
+		})
+	}
+}
+
+// CloneUTXOSet 复制 UTXO 集，便于在副本上试算而不影响原集合
+func CloneUTXOSet(utxos map[string][]UTXO) map[string][]UTXO {
+	out := make(map[string][]UTXO, len(utxos))
+	for k, list := range utxos {
+		out[k] = append([]UTXO(nil), list...)
+	}
+	return out
+}
+
+// ValidateTransaction 校验单笔交易：存在性、余额守恒、验签、未花费、coinbase 成熟度、时间锁
+// height/medianTime 为交易所在（或将被打包进）区块的高度及其前序中位时间，params 为共识参数
+func ValidateTransaction(tx *Transaction, utxos map[string][]UTXO, height uint64, medianTime int64, params ChainParams) error {
 	if tx == nil {
 		return errors.New("tx is nil")
 	}
 	if tx.IsCoinbase {
 		return nil
 	}
+	if len(tx.CoinbaseData) != 0 {
+		return errors.New("coinbase data on non-coinbase tx")
+	}
 
 	signHash := TxSigningHash(tx)
 	var inputSum int64
 	for _, in := range tx.Inputs {
 		utxo, ok := findUTXO(in.TxID, in.Vout, utxos)
 		if !ok {
-			return errors.New("referenced output not found or spent")
+			return ErrMissingInput
+		}
+		if !utxo.Mature(height, params.CoinbaseMaturity) {
+			return fmt.Errorf("%w: output at height %d, spend height %d, maturity %d", ErrImmatureCoinbase, utxo.Height, height, params.CoinbaseMaturity)
 		}
 		// 校验公钥匹配锁定脚本
 		if utxo.Output.ScriptPubKey != crypto.PublicKeyHex(in.PubKey) {
 			return errors.New("pubkey does not match script")
 		}
-		// 验签
-		if !crypto.Verify(in.PubKey, signHash, in.Signature) {
-			return errors.New("signature invalid")
+		// 按公钥携带的签名方案分派验签
+		verifier, err := crypto.ParsePublicKey(in.PubKey)
+		if err != nil {
+			return fmt.Errorf("invalid pubkey: %w", err)
+		}
+		if !verifier.Verify(signHash, in.Signature) {
+			return fmt.Errorf("signature invalid (%s)", verifier.Scheme())
+		}
+		if inputSum, err = AddValue(inputSum, utxo.Output.Value); err != nil {
+			return fmt.Errorf("inputs: %w", err)
 		}
-		inputSum += utxo.Output.Value
 	}
 
 	var outputSum int64
@@ -80,13 +150,45 @@ func ValidateTransaction(tx *Transaction, utxos map[string][]UTXO) error {
 		if out.Value < 0 {
 			return errors.New("negative output")
 		}
-		outputSum += out.Value
+		var err error
+		if outputSum, err = AddValue(outputSum, out.Value); err != nil {
+			return fmt.Errorf("outputs: %w", err)
+		}
 	}
 
 	if inputSum < outputSum {
 		return errors.New("inputs not enough")
 	}
-	return nil
+	// 时间锁放在最后：返回 ErrTxNotFinal 时交易其余部分均已有效
+	return CheckTxLocks(tx, utxos, height, medianTime)
+}
+
+// TxFee 计算交易在 utxos 上的手续费（输入合计 - 输出合计），coinbase 为 0
+func TxFee(tx *Transaction, utxos map[string][]UTXO) (int64, error) {
+	if tx == nil || tx.IsCoinbase {
+		return 0, nil
+	}
+	var in, out int64
+	for _, input := range tx.Inputs {
+		utxo, ok := findUTXO(input.TxID, input.Vout, utxos)
+		if !ok {
+			return 0, ErrMissingInput
+		}
+		var err error
+		if in, err = AddValue(in, utxo.Output.Value); err != nil {
+			return 0, err
+		}
+	}
+	for _, o := range tx.Outputs {
+		if o.Value < 0 {
+			return 0, errors.New("negative output")
+		}
+		var err error
+		if out, err = AddValue(out, o.Value); err != nil {
+			return 0, err
+		}
+	}
+	return in - out, nil
 }
 
 func findUTXO(txid []byte, index int, utxos map[string][]UTXO) (UTXO, bool) {
@@ -0,0 +1,230 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// HardenedKeyStart 硬化派生的起始索引（2^31），路径中以 ' 或 h 标记
+const HardenedKeyStart = uint32(0x80000000)
+
+// bip32MasterSecret 主密钥 HMAC 的固定密钥
+var bip32MasterSecret = []byte("Bitcoin seed")
+
+// ErrInvalidChildKey 派生结果超出曲线阶或为零（概率约 2^-127），按 BIP32 应跳过该索引
+var ErrInvalidChildKey = errors.New("derived key is invalid, skip this index")
+
+// ExtendedKey BIP32 扩展私钥（secp256k1）：私钥标量 + 链码
+type ExtendedKey struct {
+	key       []byte // 32 字节私钥
+	chainCode []byte // 32 字节链码
+	Depth     uint8
+	Index     uint32
+}
+
+// NewMasterKey 由种子（16~64 字节）生成主扩展私钥
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("seed length must be between 16 and 64 bytes")
+	}
+	mac := hmac.New(sha512.New, bip32MasterSecret)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	k := new(big.Int).SetBytes(sum[:32])
+	if k.Sign() == 0 || k.Cmp(secp256k1().Params().N) >= 0 {
+		return nil, errors.New("invalid master key, use another seed")
+	}
+	return &ExtendedKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// Child 派生第 index 个子私钥；index >= HardenedKeyStart 为硬化派生
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if k.Depth == 255 {
+		return nil, errors.New("derivation depth exceeds 255")
+	}
+	// 硬化：0x00 || 私钥 || index；普通：压缩公钥 || index
+	var data []byte
+	if index >= HardenedKeyStart {
+		data = append([]byte{0x00}, k.key...)
+	} else {
+		data = k.compressedPublicKey()
+	}
+	var idx [4]byte
+	binary.BigEndian.PutUint32(idx[:], index)
+	data = append(data, idx[:]...)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := secp256k1().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, ErrInvalidChildKey
+	}
+	// 子私钥 = (IL + k_par) mod n
+	child := il.Add(il, new(big.Int).SetBytes(k.key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, ErrInvalidChildKey
+	}
+	return &ExtendedKey{
+		key:       child.FillBytes(make([]byte, PrivateKeySize)),
+		chainCode: sum[32:],
+		Depth:     k.Depth + 1,
+		Index:     index,
+	}, nil
+}
+
+// DerivePath 按路径（如 m/44'/0'/0'/0/3）逐级派生
+func (k *ExtendedKey) DerivePath(path string) (*ExtendedKey, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	cur := k
+	for _, i := range indexes {
+		if cur, err = cur.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return cur, nil
+}
+
+// PrivateKey 返回 32 字节私钥副本
+func (k *ExtendedKey) PrivateKey() []byte {
+	return append([]byte(nil), k.key...)
+}
+
+// ChainCode 返回 32 字节链码副本
+func (k *ExtendedKey) ChainCode() []byte {
+	return append([]byte(nil), k.chainCode...)
+}
+
+// Wallet 将扩展私钥转换为 secp256k1 钱包
+func (k *ExtendedKey) Wallet() (*Wallet, error) {
+	signer, err := NewSigner(SchemeSecp256k1, k.key)
+	if err != nil {
+		return nil, err
+	}
+	return NewWallet(signer), nil
+}
+
+func (k *ExtendedKey) compressedPublicKey() []byte {
+	c := secp256k1()
+	x, y := c.ScalarBaseMult(k.key)
+	return elliptic.MarshalCompressed(c, x, y)
+}
+
+// ParseDerivationPath 解析 m/a/b'/c 形式的派生路径
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path must start with m: %q", path)
+	}
+	out := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		hardened := strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h")
+		if hardened {
+			p = p[:len(p)-1]
+		}
+		v, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || uint32(v) >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid path component %q", p)
+		}
+		idx := uint32(v)
+		if hardened {
+			idx += HardenedKeyStart
+		}
+		out = append(out, idx)
+	}
+	return out, nil
+}
+
+// ExtendedPublicKey BIP32 扩展公钥：只能做普通（非硬化）派生，只读钱包据此生成地址而无需私钥
+type ExtendedPublicKey struct {
+	x, y      *big.Int
+	chainCode []byte
+	Depth     uint8
+	Index     uint32
+}
+
+// extendedPublicKeySize 序列化长度：32 字节链码 + 33 字节压缩公钥
+const extendedPublicKeySize = 32 + CompressedPubKeySize
+
+// Public 返回对应的扩展公钥
+func (k *ExtendedKey) Public() *ExtendedPublicKey {
+	x, y := secp256k1().ScalarBaseMult(k.key)
+	return &ExtendedPublicKey{x: x, y: y, chainCode: k.ChainCode(), Depth: k.Depth, Index: k.Index}
+}
+
+// Child 普通派生第 index 个子公钥：子公钥 = IL*G + K_par
+func (k *ExtendedPublicKey) Child(index uint32) (*ExtendedPublicKey, error) {
+	if index >= HardenedKeyStart {
+		return nil, errors.New("cannot derive hardened child from public key")
+	}
+	if k.Depth == 255 {
+		return nil, errors.New("derivation depth exceeds 255")
+	}
+	c := secp256k1()
+	data := elliptic.MarshalCompressed(c, k.x, k.y)
+	var idx [4]byte
+	binary.BigEndian.PutUint32(idx[:], index)
+	data = append(data, idx[:]...)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(c.Params().N) >= 0 {
+		return nil, ErrInvalidChildKey
+	}
+	ix, iy := c.ScalarBaseMult(sum[:32])
+	x, y := c.Add(ix, iy, k.x, k.y)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, ErrInvalidChildKey
+	}
+	return &ExtendedPublicKey{x: x, y: y, chainCode: sum[32:], Depth: k.Depth + 1, Index: index}, nil
+}
+
+// PublicKey 返回钱包地址使用的公钥编码（secp256k1 方案前缀 + 压缩公钥）
+func (k *ExtendedPublicKey) PublicKey() []byte {
+	return append([]byte{byte(SchemeSecp256k1)}, elliptic.MarshalCompressed(secp256k1(), k.x, k.y)...)
+}
+
+// String 序列化为 hex(链码 || 压缩公钥)；不含 BIP32 的版本号和父指纹，仅用于本项目钱包文件
+func (k *ExtendedPublicKey) String() string {
+	b := append(k.ChainCode(), elliptic.MarshalCompressed(secp256k1(), k.x, k.y)...)
+	return HexEncode(b)
+}
+
+// ChainCode 返回 32 字节链码副本
+func (k *ExtendedPublicKey) ChainCode() []byte {
+	return append([]byte(nil), k.chainCode...)
+}
+
+// ParseExtendedPublicKey 解析 String 的输出
+func ParseExtendedPublicKey(s string) (*ExtendedPublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != extendedPublicKeySize {
+		return nil, errors.New("invalid extended public key length")
+	}
+	x, y := secp256k1Decompress(b[32:])
+	if x == nil {
+		return nil, errors.New("invalid extended public key point")
+	}
+	return &ExtendedPublicKey{x: x, y: y, chainCode: append([]byte(nil), b[:32]...)}, nil
+}
@@ -0,0 +1,122 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 种子派生参数：PBKDF2-HMAC-SHA512，2048 轮，输出 64 字节种子
+const (
+	mnemonicSeedIter = 2048
+	mnemonicSeedSize = 64
+)
+
+// ErrInvalidMnemonic 表示助记词不在词表中或校验和不匹配
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+var (
+	bip39IndexOnce sync.Once
+	bip39Index     map[string]int
+)
+
+// NewMnemonic 生成指定熵位数（128~256，32 的倍数）的 BIP39 助记词
+func NewMnemonic(entropyBits int) (string, error) {
+	if entropyBits < 128 || entropyBits > 256 || entropyBits%32 != 0 {
+		return "", errors.New("entropy bits must be a multiple of 32 in [128, 256]")
+	}
+	entropy := make([]byte, entropyBits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic 将熵编码为助记词：熵 || SHA-256 前 ENT/32 位，按 11 位分组查表
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	entBits := len(entropy) * 8
+	if entBits < 128 || entBits > 256 || entBits%32 != 0 {
+		return "", errors.New("entropy length must be 16..32 bytes in steps of 4")
+	}
+	csBits := entBits / 32
+	sum := sha256.Sum256(entropy)
+
+	b := new(big.Int).SetBytes(entropy)
+	b.Lsh(b, uint(csBits))
+	b.Or(b, big.NewInt(int64(sum[0]>>(8-csBits))))
+
+	n := (entBits + csBits) / 11
+	words := make([]string, n)
+	mask := big.NewInt(2047)
+	idx := new(big.Int)
+	for i := n - 1; i >= 0; i-- {
+		idx.And(b, mask)
+		words[i] = bip39English[idx.Int64()]
+		b.Rsh(b, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy 校验助记词并还原熵
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, ErrInvalidMnemonic
+	}
+	index := bip39WordIndex()
+	b := new(big.Int)
+	for _, w := range words {
+		i, ok := index[w]
+		if !ok {
+			return nil, ErrInvalidMnemonic
+		}
+		b.Lsh(b, 11)
+		b.Or(b, big.NewInt(int64(i)))
+	}
+
+	totalBits := len(words) * 11
+	csBits := totalBits / 33
+	entBits := totalBits - csBits
+	checksum := new(big.Int).And(b, big.NewInt(int64(1)<<csBits-1)).Int64()
+	entropy := b.Rsh(b, uint(csBits)).FillBytes(make([]byte, entBits/8))
+
+	sum := sha256.Sum256(entropy)
+	if int64(sum[0]>>(8-csBits)) != checksum {
+		return nil, ErrInvalidMnemonic
+	}
+	return entropy, nil
+}
+
+// ValidateMnemonic 判断助记词是否合法（词表 + 校验和）
+func ValidateMnemonic(mnemonic string) bool {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err == nil
+}
+
+// MnemonicToSeed 由助记词和可选口令（BIP39 “第 25 个词”）派生 64 字节种子
+// 与 BIP39 一致不校验助记词本身；英文词表均为 ASCII，未实现 NFKD 规范化，非 ASCII 口令需调用方自行规范化
+func MnemonicToSeed(mnemonic, passphrase string) []byte {
+	password := strings.Join(strings.Fields(mnemonic), " ")
+	salt := "mnemonic" + passphrase
+	return PBKDF2([]byte(password), []byte(salt), mnemonicSeedIter, mnemonicSeedSize, sha512.New)
+}
+
+// NormalizeMnemonic 统一助记词的大小写和空白，便于用户输入
+func NormalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(strings.Map(unicode.ToLower, mnemonic)), " ")
+}
+
+func bip39WordIndex() map[string]int {
+	bip39IndexOnce.Do(func() {
+		bip39Index = make(map[string]int, len(bip39English))
+		for i, w := range bip39English {
+			bip39Index[w] = i
+		}
+	})
+	return bip39Index
+}
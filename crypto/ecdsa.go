@@ -0,0 +1,216 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// ecdsaCurve 描述一个 ECDSA 方案使用的曲线参数
+type ecdsaCurve struct {
+	scheme Scheme
+	curve  elliptic.Curve
+	// halfOrder 为 N/2，规范签名要求 s 不超过该值（low-S）
+	halfOrder *big.Int
+}
+
+var (
+	p256Params      = newECDSACurve(SchemeP256, elliptic.P256())
+	secp256k1Params = newECDSACurve(SchemeSecp256k1, secp256k1())
+)
+
+// ecdsaSignature 为 ASN.1 DER 编码的签名结构
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+func newECDSACurve(scheme Scheme, c elliptic.Curve) *ecdsaCurve {
+	return &ecdsaCurve{
+		scheme:    scheme,
+		curve:     c,
+		halfOrder: new(big.Int).Rsh(c.Params().N, 1),
+	}
+}
+
+// ecdsaSigner 为 P-256 / secp256k1 共用的 ECDSA 签名器
+type ecdsaSigner struct {
+	c    *ecdsaCurve
+	d    *big.Int
+	x, y *big.Int
+}
+
+// generateECDSASigner 随机生成 [1, N-1] 内的私钥标量
+func generateECDSASigner(c *ecdsaCurve) (Signer, error) {
+	n := c.curve.Params().N
+	for {
+		b := make([]byte, PrivateKeySize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		k := new(big.Int).SetBytes(b)
+		if k.Sign() > 0 && k.Cmp(n) < 0 {
+			return newECDSASigner(c, b)
+		}
+	}
+}
+
+// newECDSASigner 由私钥字节恢复签名器；兼容旧版丢失前导零的短编码
+func newECDSASigner(c *ecdsaCurve, priv []byte) (Signer, error) {
+	if len(priv) == 0 || len(priv) > PrivateKeySize {
+		return nil, errors.New("invalid private key length")
+	}
+	d := new(big.Int).SetBytes(priv)
+	if d.Sign() == 0 || d.Cmp(c.curve.Params().N) >= 0 {
+		return nil, errors.New("invalid private key scalar")
+	}
+	x, y := c.curve.ScalarBaseMult(d.FillBytes(make([]byte, PrivateKeySize)))
+	return &ecdsaSigner{c: c, d: d, x: x, y: y}, nil
+}
+
+func (s *ecdsaSigner) Scheme() Scheme { return s.c.scheme }
+
+// PublicKey P-256 沿用 64 字节 X||Y 旧格式（地址不变），其余方案为“方案前缀 + 压缩公钥”
+func (s *ecdsaSigner) PublicKey() []byte {
+	if s.c.scheme == SchemeP256 {
+		return serializePubKey(s.x, s.y)
+	}
+	return append([]byte{byte(s.c.scheme)}, elliptic.MarshalCompressed(s.c.curve, s.x, s.y)...)
+}
+
+func (s *ecdsaSigner) PrivateKey() []byte {
+	return s.d.FillBytes(make([]byte, PrivateKeySize))
+}
+
+// Sign 对数据进行 SHA-256 后签名（ASN.1 DER 编码）
+// 随机数 k 按 RFC 6979 确定性生成，同一私钥和消息总得到相同签名；s 归一化为 low-S
+func (s *ecdsaSigner) Sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	n := s.c.curve.Params().N
+
+	nonce := newRFC6979Nonce(n, s.d, digest[:])
+	z := hashToInt(digest[:], n)
+	for {
+		k := nonce.next()
+		x, _ := s.c.curve.ScalarBaseMult(k.FillBytes(make([]byte, PrivateKeySize)))
+		r := new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+		// sig = k^-1 (z + r*d) mod n
+		sig := new(big.Int).Mul(r, s.d)
+		sig.Add(sig, z)
+		sig.Mul(sig, new(big.Int).ModInverse(k, n))
+		sig.Mod(sig, n)
+		if sig.Sign() == 0 {
+			continue
+		}
+		if sig.Cmp(s.c.halfOrder) > 0 {
+			sig.Sub(n, sig)
+		}
+		return asn1.Marshal(ecdsaSignature{R: r, S: sig})
+	}
+}
+
+// ecdsaVerifier 为 P-256 / secp256k1 共用的 ECDSA 验签器
+type ecdsaVerifier struct {
+	c    *ecdsaCurve
+	x, y *big.Int
+}
+
+// parseECDSAPublicKey 解析 64 字节 X||Y 或 33 字节压缩公钥
+func parseECDSAPublicKey(c *ecdsaCurve, b []byte) (Verifier, error) {
+	var x, y *big.Int
+	switch len(b) {
+	case 2 * pubKeySize:
+		x = new(big.Int).SetBytes(b[:pubKeySize])
+		y = new(big.Int).SetBytes(b[pubKeySize:])
+	case CompressedPubKeySize:
+		x, y = unmarshalCompressed(c, b)
+		if x == nil {
+			return nil, errors.New("invalid compressed public key")
+		}
+	default:
+		return nil, errors.New("invalid public key length")
+	}
+	if !c.curve.IsOnCurve(x, y) {
+		return nil, errors.New("public key not on curve")
+	}
+	return &ecdsaVerifier{c: c, x: x, y: y}, nil
+}
+
+func (v *ecdsaVerifier) Scheme() Scheme { return v.c.scheme }
+
+// Verify 仅接受规范签名：严格 DER 编码、r/s 位于 [1, N-1] 且 s 为 low-S，防止签名延展
+func (v *ecdsaVerifier) Verify(data []byte, sig []byte) bool {
+	r, s, ok := parseCanonicalSig(v.c, sig)
+	if !ok {
+		return false
+	}
+	digest := sha256.Sum256(data)
+	n := v.c.curve.Params().N
+
+	// u1 = z/s, u2 = r/s，校验 (u1*G + u2*Q).x ≡ r (mod n)
+	w := new(big.Int).ModInverse(s, n)
+	u1 := new(big.Int).Mul(hashToInt(digest[:], n), w)
+	u1.Mod(u1, n)
+	u2 := new(big.Int).Mul(r, w)
+	u2.Mod(u2, n)
+
+	x1, y1 := v.c.curve.ScalarBaseMult(u1.Bytes())
+	x2, y2 := v.c.curve.ScalarMult(v.x, v.y, u2.Bytes())
+	x, y := v.c.curve.Add(x1, y1, x2, y2)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return false
+	}
+	return x.Mod(x, n).Cmp(r) == 0
+}
+
+// parseCanonicalSig 解析 DER 签名并检查规范性
+func parseCanonicalSig(c *ecdsaCurve, sig []byte) (*big.Int, *big.Int, bool) {
+	var parsed ecdsaSignature
+	rest, err := asn1.Unmarshal(sig, &parsed)
+	if err != nil || len(rest) != 0 || parsed.R == nil || parsed.S == nil {
+		return nil, nil, false
+	}
+	// 重新编码需与原字节一致，拒绝非最短长度、多余前导零等非严格 DER
+	again, err := asn1.Marshal(parsed)
+	if err != nil || !bytes.Equal(again, sig) {
+		return nil, nil, false
+	}
+	n := c.curve.Params().N
+	if parsed.R.Sign() <= 0 || parsed.R.Cmp(n) >= 0 {
+		return nil, nil, false
+	}
+	if parsed.S.Sign() <= 0 || parsed.S.Cmp(c.halfOrder) > 0 {
+		return nil, nil, false
+	}
+	return parsed.R, parsed.S, true
+}
+
+// hashToInt 取摘要最左侧 bitlen(N) 位作为整数（ECDSA 与 RFC 6979 的 bits2int）
+func hashToInt(hash []byte, n *big.Int) *big.Int {
+	x := new(big.Int).SetBytes(hash)
+	if excess := len(hash)*8 - n.BitLen(); excess > 0 {
+		x.Rsh(x, uint(excess))
+	}
+	return x
+}
+
+// unmarshalCompressed 解压 33 字节公钥；secp256k1 的 a=0，不能使用标准库的 a=-3 公式
+func unmarshalCompressed(c *ecdsaCurve, b []byte) (*big.Int, *big.Int) {
+	if c.scheme == SchemeSecp256k1 {
+		return secp256k1Decompress(b)
+	}
+	return elliptic.UnmarshalCompressed(c.curve, b)
+}
+
+// serializePubKey 将公钥序列化为非压缩 64 字节
+func serializePubKey(x, y *big.Int) []byte {
+	xBytes := x.FillBytes(make([]byte, pubKeySize))
+	yBytes := y.FillBytes(make([]byte, pubKeySize))
+	return append(xBytes, yBytes...)
+}
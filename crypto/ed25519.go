@@ -0,0 +1,63 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+)
+
+// ed25519Signer 以 32 字节种子作为私钥的 Ed25519 签名器（签名本身即确定性）
+type ed25519Signer struct {
+	priv ed25519.PrivateKey
+}
+
+func generateEd25519Signer() (Signer, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &ed25519Signer{priv: priv}, nil
+}
+
+func newEd25519Signer(seed []byte) (Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("invalid ed25519 seed length")
+	}
+	return &ed25519Signer{priv: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+func (s *ed25519Signer) Scheme() Scheme { return SchemeEd25519 }
+
+// PublicKey 返回“方案前缀 + 32 字节公钥”
+func (s *ed25519Signer) PublicKey() []byte {
+	pub := s.priv.Public().(ed25519.PublicKey)
+	return append([]byte{byte(SchemeEd25519)}, pub...)
+}
+
+func (s *ed25519Signer) PrivateKey() []byte {
+	return append([]byte(nil), s.priv.Seed()...)
+}
+
+func (s *ed25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(s.priv, data), nil
+}
+
+type ed25519Verifier struct {
+	pub ed25519.PublicKey
+}
+
+func parseEd25519PublicKey(b []byte) (Verifier, error) {
+	if len(b) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key length")
+	}
+	return &ed25519Verifier{pub: append(ed25519.PublicKey(nil), b...)}, nil
+}
+
+func (v *ed25519Verifier) Scheme() Scheme { return SchemeEd25519 }
+
+func (v *ed25519Verifier) Verify(data []byte, sig []byte) bool {
+	if len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(v.pub, data, sig)
+}
@@ -0,0 +1,123 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// 口令加密默认参数：scrypt(N=2^15, r=8, p=1) 派生 256 位密钥，AES-256-GCM 加密
+const (
+	DefaultScryptN = 1 << 15
+	DefaultScryptR = 8
+	DefaultScryptP = 1
+
+	// 解密时接受的 scrypt 参数上限：参数来自钱包文件，不加限制时篡改的文件可让派生耗尽内存或 CPU
+	MaxScryptN      = 1 << 20
+	MaxScryptR      = 32
+	MaxScryptP      = 16
+	maxScryptMemory = 256 << 20 // 128*N*r 字节
+
+	kdfScrypt    = "scrypt"
+	cipherAESGCM = "aes-256-gcm"
+	saltSize     = 16
+	aesKeySize   = 32
+)
+
+// ErrWrongPassphrase 表示口令错误或密文被篡改（GCM 认证失败）
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted ciphertext")
+
+// EncryptedBox 保存口令加密后的密文及派生参数，字段均为可直接 JSON 持久化的形式
+type EncryptedBox struct {
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	SaltHex    string `json:"salt"`
+	Cipher     string `json:"cipher"`
+	NonceHex   string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// EncryptWithPassphrase 使用口令派生密钥并以 AES-GCM 加密明文
+func EncryptWithPassphrase(plaintext []byte, passphrase string) (*EncryptedBox, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	box := &EncryptedBox{
+		KDF:     kdfScrypt,
+		N:       DefaultScryptN,
+		R:       DefaultScryptR,
+		P:       DefaultScryptP,
+		SaltHex: hex.EncodeToString(salt),
+		Cipher:  cipherAESGCM,
+	}
+	aead, err := box.aead(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	box.NonceHex = hex.EncodeToString(nonce)
+	box.Ciphertext = hex.EncodeToString(aead.Seal(nil, nonce, plaintext, nil))
+	return box, nil
+}
+
+// DecryptWithPassphrase 按 box 中记录的参数解密；口令错误返回 ErrWrongPassphrase
+func DecryptWithPassphrase(box *EncryptedBox, passphrase string) ([]byte, error) {
+	if box == nil {
+		return nil, errors.New("encrypted box is nil")
+	}
+	if box.KDF != kdfScrypt || box.Cipher != cipherAESGCM {
+		return nil, fmt.Errorf("unsupported encryption %s/%s", box.KDF, box.Cipher)
+	}
+	salt, err := hex.DecodeString(box.SaltHex)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(box.NonceHex)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := hex.DecodeString(box.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := box.aead(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce length")
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// aead 由口令与 box 的 scrypt 参数构造 AES-GCM 实例，参数超出上限时拒绝派生
+func (box *EncryptedBox) aead(passphrase string, salt []byte) (cipher.AEAD, error) {
+	if box.N > MaxScryptN || box.R > MaxScryptR || box.P > MaxScryptP || box.R > 0 && box.N > maxScryptMemory/128/box.R {
+		return nil, fmt.Errorf("scrypt parameters too large: N=%d r=%d p=%d", box.N, box.R, box.P)
+	}
+	key, err := Scrypt([]byte(passphrase), salt, box.N, box.R, box.P, aesKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,238 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// HDAccountPath HD 钱包账户路径（BIP44 风格），其下 /0 为收款分支、/1 为找零分支
+const HDAccountPath = "m/44'/0'/0'"
+
+// HDGapLimit 恢复时连续未使用地址数达到该值即停止扫描
+const HDGapLimit = 20
+
+// HD 钱包的两条派生分支
+const (
+	hdReceiveBranch = 0
+	hdChangeBranch  = 1
+)
+
+// HDWallet 由助记词派生的分层确定性钱包，记录两条分支已分配的地址数
+type HDWallet struct {
+	Mnemonic    string
+	NextReceive uint32 // 下一个未分配的收款地址索引
+	NextChange  uint32 // 下一个未分配的找零地址索引
+
+	account  *ExtendedKey
+	branches [2]*ExtendedKey
+}
+
+// NewHDWallet 由助记词恢复 HD 钱包（仅派生账户密钥，不分配地址）
+func NewHDWallet(mnemonic string) (*HDWallet, error) {
+	mnemonic = NormalizeMnemonic(mnemonic)
+	if !ValidateMnemonic(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+	master, err := NewMasterKey(MnemonicToSeed(mnemonic, ""))
+	if err != nil {
+		return nil, err
+	}
+	account, err := master.DerivePath(HDAccountPath)
+	if err != nil {
+		return nil, err
+	}
+	h := &HDWallet{Mnemonic: mnemonic, account: account}
+	for i := range h.branches {
+		if h.branches[i], err = account.Child(uint32(i)); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// GenerateHDWallet 生成新助记词（128 位熵，12 个词）并创建 HD 钱包
+func GenerateHDWallet() (*HDWallet, error) {
+	mnemonic, err := NewMnemonic(128)
+	if err != nil {
+		return nil, err
+	}
+	return NewHDWallet(mnemonic)
+}
+
+// DeriveKey 派生指定分支和索引的密钥，不改变分配计数
+func (h *HDWallet) DeriveKey(change bool, index uint32) (*Wallet, error) {
+	if index >= HardenedKeyStart {
+		return nil, errors.New("address index out of range")
+	}
+	branch := h.branches[hdReceiveBranch]
+	if change {
+		branch = h.branches[hdChangeBranch]
+	}
+	k, err := branch.Child(index)
+	if err != nil {
+		return nil, err
+	}
+	return k.Wallet()
+}
+
+// NextReceiveKey 分配一个新的收款地址
+func (h *HDWallet) NextReceiveKey() (*Wallet, error) {
+	return h.nextKey(false, &h.NextReceive)
+}
+
+// NextChangeKey 分配一个新的找零地址，每笔交易使用不同找零地址以避免地址复用
+func (h *HDWallet) NextChangeKey() (*Wallet, error) {
+	return h.nextKey(true, &h.NextChange)
+}
+
+// nextKey 分配下一个索引；遇到无效索引按 BIP32 跳过
+func (h *HDWallet) nextKey(change bool, next *uint32) (*Wallet, error) {
+	for {
+		w, err := h.DeriveKey(change, *next)
+		*next++
+		if errors.Is(err, ErrInvalidChildKey) {
+			continue
+		}
+		return w, err
+	}
+}
+
+// Keys 返回已分配的全部收款和找零密钥
+func (h *HDWallet) Keys() ([]*Wallet, error) {
+	var out []*Wallet
+	for _, b := range []struct {
+		change bool
+		n      uint32
+	}{{false, h.NextReceive}, {true, h.NextChange}} {
+		for i := uint32(0); i < b.n; i++ {
+			w, err := h.DeriveKey(b.change, i)
+			if errors.Is(err, ErrInvalidChildKey) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+
+// Rescan 按缺口限制扫描两条分支，将分配计数推进到最后一个已使用地址之后
+// used 判断地址（公钥 hex）是否在链上出现过
+func (h *HDWallet) Rescan(used func(address string) bool) error {
+	receive, change, err := ScanHDAccount(h.AccountPublicKey(), used)
+	if err != nil {
+		return err
+	}
+	if receive > h.NextReceive {
+		h.NextReceive = receive
+	}
+	if change > h.NextChange {
+		h.NextChange = change
+	}
+	return nil
+}
+
+// ScanHDAccount 仅凭账户扩展公钥按缺口限制扫描两条分支，返回各分支最后一个已使用地址之后的索引
+func ScanHDAccount(account *ExtendedPublicKey, used func(address string) bool) (receive, change uint32, err error) {
+	for _, b := range []struct {
+		change bool
+		next   *uint32
+	}{{false, &receive}, {true, &change}} {
+		gap := 0
+		for i := uint32(0); gap < HDGapLimit; i++ {
+			addr, err := DeriveHDAddress(account, b.change, i)
+			if errors.Is(err, ErrInvalidChildKey) {
+				continue
+			}
+			if err != nil {
+				return 0, 0, err
+			}
+			if used(addr) {
+				gap = 0
+				*b.next = i + 1
+			} else {
+				gap++
+			}
+		}
+	}
+	return receive, change, nil
+}
+
+// HDAccountAddresses 由账户扩展公钥派生一条分支上索引 [0, n) 的地址，跳过无效索引
+func HDAccountAddresses(account *ExtendedPublicKey, change bool, n uint32) ([]string, error) {
+	out := make([]string, 0, n)
+	for i := uint32(0); i < n; i++ {
+		addr, err := DeriveHDAddress(account, change, i)
+		if errors.Is(err, ErrInvalidChildKey) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, addr)
+	}
+	return out, nil
+}
+
+// AccountPublicKey 返回账户扩展公钥，只读钱包凭此派生地址
+func (h *HDWallet) AccountPublicKey() *ExtendedPublicKey {
+	return h.account.Public()
+}
+
+// HDKeyPath 返回指定分支和索引的完整派生路径
+func HDKeyPath(change bool, index uint32) string {
+	branch := hdReceiveBranch
+	if change {
+		branch = hdChangeBranch
+	}
+	return fmt.Sprintf("%s/%d/%d", HDAccountPath, branch, index)
+}
+
+// KeyAtPath 按 HDKeyPath 形式的路径派生密钥，只接受本账户下的收款/找零路径
+func (h *HDWallet) KeyAtPath(path string) (*Wallet, error) {
+	change, index, err := parseHDKeyPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return h.DeriveKey(change, index)
+}
+
+// DeriveHDAddress 由账户扩展公钥派生地址（公钥 hex），无需私钥
+func DeriveHDAddress(account *ExtendedPublicKey, change bool, index uint32) (string, error) {
+	branch := uint32(hdReceiveBranch)
+	if change {
+		branch = hdChangeBranch
+	}
+	b, err := account.Child(branch)
+	if err != nil {
+		return "", err
+	}
+	k, err := b.Child(index)
+	if err != nil {
+		return "", err
+	}
+	return PublicKeyHex(k.PublicKey()), nil
+}
+
+func parseHDKeyPath(path string) (bool, uint32, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return false, 0, err
+	}
+	account, _ := ParseDerivationPath(HDAccountPath)
+	if len(indexes) != len(account)+2 {
+		return false, 0, fmt.Errorf("path %q is not an address path of this account", path)
+	}
+	for i := range account {
+		if indexes[i] != account[i] {
+			return false, 0, fmt.Errorf("path %q is not under account %s", path, HDAccountPath)
+		}
+	}
+	branch, index := indexes[len(account)], indexes[len(account)+1]
+	if branch != hdReceiveBranch && branch != hdChangeBranch || index >= HardenedKeyStart {
+		return false, 0, fmt.Errorf("invalid address path %q", path)
+	}
+	return branch == hdChangeBranch, index, nil
+}
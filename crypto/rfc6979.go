@@ -0,0 +1,83 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"math/big"
+)
+
+// rfc6979Nonce 按 RFC 6979 §3.2 以 HMAC-SHA256 为 DRBG 生成确定性 ECDSA 随机数 k
+// 每次调用 next 返回下一个候选 k（签名得到 r 或 s 为 0 时需继续取下一个）
+type rfc6979Nonce struct {
+	q    *big.Int
+	qlen int
+	k    []byte
+	v    []byte
+}
+
+// newRFC6979Nonce 依据私钥 x 与消息摘要 hash 初始化生成器
+func newRFC6979Nonce(q, x *big.Int, hash []byte) *rfc6979Nonce {
+	g := &rfc6979Nonce{
+		q:    q,
+		qlen: q.BitLen(),
+		k:    make([]byte, sha256.Size),
+		v:    make([]byte, sha256.Size),
+	}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	xOctets := g.int2octets(x)
+	hOctets := g.bits2octets(hash)
+
+	g.k = g.mac(g.k, g.v, []byte{0x00}, xOctets, hOctets)
+	g.v = g.mac(g.k, g.v)
+	g.k = g.mac(g.k, g.v, []byte{0x01}, xOctets, hOctets)
+	g.v = g.mac(g.k, g.v)
+	return g
+}
+
+// next 返回下一个位于 [1, q-1] 的候选 k
+func (g *rfc6979Nonce) next() *big.Int {
+	rlen := (g.qlen + 7) / 8
+	for {
+		var t []byte
+		for len(t) < rlen {
+			g.v = g.mac(g.k, g.v)
+			t = append(t, g.v...)
+		}
+		k := g.bits2int(t)
+		// 无论本次是否可用，都推进状态，保证下次调用返回不同候选
+		g.k = g.mac(g.k, g.v, []byte{0x00})
+		g.v = g.mac(g.k, g.v)
+		if k.Sign() > 0 && k.Cmp(g.q) < 0 {
+			return k
+		}
+	}
+}
+
+// bits2int 取输入最左侧 qlen 位转为整数
+func (g *rfc6979Nonce) bits2int(b []byte) *big.Int {
+	return hashToInt(b, g.q)
+}
+
+// int2octets 将整数编码为定长 rlen 字节（大端）
+func (g *rfc6979Nonce) int2octets(x *big.Int) []byte {
+	return x.FillBytes(make([]byte, (g.qlen+7)/8))
+}
+
+// bits2octets 对摘要做 bits2int 后模 q，再编码为定长字节
+func (g *rfc6979Nonce) bits2octets(b []byte) []byte {
+	z := g.bits2int(b)
+	if z.Cmp(g.q) >= 0 {
+		z.Sub(z, g.q)
+	}
+	return g.int2octets(z)
+}
+
+func (g *rfc6979Nonce) mac(key []byte, parts ...[]byte) []byte {
+	h := hmac.New(sha256.New, key)
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Scheme 标识签名方案，编码在公钥首字节中随地址一起出现
+// 取值从 0x10 开始，避免与 SEC1 公钥前缀 0x02/0x03/0x04 混淆
+type Scheme byte
+
+const (
+	SchemeP256      Scheme = 0x10 // ECDSA over NIST P-256（默认，兼容无前缀的旧公钥）
+	SchemeSecp256k1 Scheme = 0x11 // ECDSA over secp256k1
+	SchemeEd25519   Scheme = 0x12 // Ed25519
+)
+
+// Signer 持有私钥并产生签名
+type Signer interface {
+	Scheme() Scheme
+	// PublicKey 返回用于地址与交易输入的公钥编码
+	PublicKey() []byte
+	// PrivateKey 返回私钥的定长字节编码（便于持久化）
+	PrivateKey() []byte
+	Sign(data []byte) ([]byte, error)
+}
+
+// Verifier 持有公钥并校验签名
+type Verifier interface {
+	Scheme() Scheme
+	Verify(data []byte, sig []byte) bool
+}
+
+// String 返回方案名称，用于 CLI 参数与钱包文件
+func (s Scheme) String() string {
+	switch s {
+	case SchemeP256:
+		return "p256"
+	case SchemeSecp256k1:
+		return "secp256k1"
+	case SchemeEd25519:
+		return "ed25519"
+	default:
+		return fmt.Sprintf("scheme(0x%02x)", byte(s))
+	}
+}
+
+// ParseScheme 将名称解析为签名方案，空字符串视为默认的 P-256
+func ParseScheme(name string) (Scheme, error) {
+	switch name {
+	case "", "p256":
+		return SchemeP256, nil
+	case "secp256k1":
+		return SchemeSecp256k1, nil
+	case "ed25519":
+		return SchemeEd25519, nil
+	default:
+		return 0, fmt.Errorf("unknown signature scheme: %s", name)
+	}
+}
+
+// GenerateSigner 为指定方案生成新私钥
+func GenerateSigner(scheme Scheme) (Signer, error) {
+	switch scheme {
+	case SchemeP256:
+		return generateECDSASigner(p256Params)
+	case SchemeSecp256k1:
+		return generateECDSASigner(secp256k1Params)
+	case SchemeEd25519:
+		return generateEd25519Signer()
+	default:
+		return nil, fmt.Errorf("unsupported signature scheme %s", scheme)
+	}
+}
+
+// NewSigner 由私钥字节恢复指定方案的签名器
+func NewSigner(scheme Scheme, priv []byte) (Signer, error) {
+	switch scheme {
+	case SchemeP256:
+		return newECDSASigner(p256Params, priv)
+	case SchemeSecp256k1:
+		return newECDSASigner(secp256k1Params, priv)
+	case SchemeEd25519:
+		return newEd25519Signer(priv)
+	default:
+		return nil, fmt.Errorf("unsupported signature scheme %s", scheme)
+	}
+}
+
+// ParsePublicKey 按编码识别签名方案并返回对应的验签器
+// 无前缀的 64 字节（X||Y）或 33 字节压缩公钥视为 P-256；其余以首字节方案标识分派
+func ParsePublicKey(pubKey []byte) (Verifier, error) {
+	if len(pubKey) == 0 {
+		return nil, errors.New("empty public key")
+	}
+	if isLegacyP256Key(pubKey) {
+		return parseECDSAPublicKey(p256Params, pubKey)
+	}
+	scheme, body := Scheme(pubKey[0]), pubKey[1:]
+	switch scheme {
+	case SchemeP256:
+		return parseECDSAPublicKey(p256Params, body)
+	case SchemeSecp256k1:
+		return parseECDSAPublicKey(secp256k1Params, body)
+	case SchemeEd25519:
+		return parseEd25519PublicKey(body)
+	default:
+		return nil, fmt.Errorf("unsupported signature scheme 0x%02x", byte(scheme))
+	}
+}
+
+// PublicKeyScheme 返回公钥所属的签名方案
+func PublicKeyScheme(pubKey []byte) (Scheme, error) {
+	v, err := ParsePublicKey(pubKey)
+	if err != nil {
+		return 0, err
+	}
+	return v.Scheme(), nil
+}
+
+// Verify 使用公钥验证签名，按公钥携带的方案分派
+func Verify(pubKey []byte, data []byte, sig []byte) bool {
+	v, err := ParsePublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+	return v.Verify(data, sig)
+}
+
+// isLegacyP256Key 判断是否为不带方案前缀的旧版 P-256 公钥
+func isLegacyP256Key(b []byte) bool {
+	if len(b) == 2*pubKeySize {
+		return true
+	}
+	return len(b) == CompressedPubKeySize && (b[0] == 0x02 || b[0] == 0x03)
+}
@@ -0,0 +1,234 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"math/big"
+	"sync"
+)
+
+// secp256k1Curve 纯 Go 实现的 secp256k1（y² = x³ + 7），满足 elliptic.Curve 接口
+// 标准库 CurveParams 的通用算法假定 a = -3，不适用于 a = 0 的 secp256k1，因此自行实现雅可比坐标运算
+// 注意：运算基于 big.Int，非常量时间，仅适用于本项目的模拟场景
+type secp256k1Curve struct {
+	params *elliptic.CurveParams
+}
+
+var (
+	secp256k1Once     sync.Once
+	secp256k1Instance *secp256k1Curve
+)
+
+// secp256k1 返回曲线单例
+func secp256k1() elliptic.Curve {
+	secp256k1Once.Do(func() {
+		p := &elliptic.CurveParams{Name: "secp256k1", BitSize: 256}
+		p.P, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+		p.N, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+		p.B = big.NewInt(7)
+		p.Gx, _ = new(big.Int).SetString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", 16)
+		p.Gy, _ = new(big.Int).SetString("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8", 16)
+		secp256k1Instance = &secp256k1Curve{params: p}
+	})
+	return secp256k1Instance
+}
+
+func (c *secp256k1Curve) Params() *elliptic.CurveParams { return c.params }
+
+// IsOnCurve 校验 y² ≡ x³ + 7 (mod p)
+func (c *secp256k1Curve) IsOnCurve(x, y *big.Int) bool {
+	p := c.params.P
+	if x.Sign() < 0 || x.Cmp(p) >= 0 || y.Sign() < 0 || y.Cmp(p) >= 0 {
+		return false
+	}
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, p)
+	return y2.Cmp(c.rhs(x)) == 0
+}
+
+// rhs 计算 x³ + 7 (mod p)
+func (c *secp256k1Curve) rhs(x *big.Int) *big.Int {
+	x3 := new(big.Int).Mul(x, x)
+	x3.Mul(x3, x)
+	x3.Add(x3, c.params.B)
+	return x3.Mod(x3, c.params.P)
+}
+
+func (c *secp256k1Curve) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	z1 := zForAffine(x1, y1)
+	z2 := zForAffine(x2, y2)
+	return c.toAffine(c.addJacobian(x1, y1, z1, x2, y2, z2))
+}
+
+func (c *secp256k1Curve) Double(x1, y1 *big.Int) (*big.Int, *big.Int) {
+	return c.toAffine(c.doubleJacobian(x1, y1, zForAffine(x1, y1)))
+}
+
+// ScalarMult 自高位向低位的倍点-加法
+func (c *secp256k1Curve) ScalarMult(bx, by *big.Int, k []byte) (*big.Int, *big.Int) {
+	bz := zForAffine(bx, by)
+	x, y, z := new(big.Int), new(big.Int), new(big.Int)
+	for _, b := range k {
+		for bit := 0; bit < 8; bit++ {
+			x, y, z = c.doubleJacobian(x, y, z)
+			if b&0x80 == 0x80 {
+				x, y, z = c.addJacobian(bx, by, bz, x, y, z)
+			}
+			b <<= 1
+		}
+	}
+	return c.toAffine(x, y, z)
+}
+
+func (c *secp256k1Curve) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
+	return c.ScalarMult(c.params.Gx, c.params.Gy, k)
+}
+
+// zForAffine 仿射坐标转雅可比坐标的 Z；(0,0) 表示无穷远点
+func zForAffine(x, y *big.Int) *big.Int {
+	z := new(big.Int)
+	if x.Sign() != 0 || y.Sign() != 0 {
+		z.SetInt64(1)
+	}
+	return z
+}
+
+func (c *secp256k1Curve) toAffine(x, y, z *big.Int) (*big.Int, *big.Int) {
+	if z.Sign() == 0 {
+		return new(big.Int), new(big.Int)
+	}
+	p := c.params.P
+	zinv := new(big.Int).ModInverse(z, p)
+	zinv2 := new(big.Int).Mul(zinv, zinv)
+	xOut := new(big.Int).Mul(x, zinv2)
+	xOut.Mod(xOut, p)
+	zinv2.Mul(zinv2, zinv)
+	yOut := new(big.Int).Mul(y, zinv2)
+	yOut.Mod(yOut, p)
+	return xOut, yOut
+}
+
+// addJacobian 雅可比坐标点加（add-2007-bl）
+func (c *secp256k1Curve) addJacobian(x1, y1, z1, x2, y2, z2 *big.Int) (*big.Int, *big.Int, *big.Int) {
+	p := c.params.P
+	if z1.Sign() == 0 {
+		return new(big.Int).Set(x2), new(big.Int).Set(y2), new(big.Int).Set(z2)
+	}
+	if z2.Sign() == 0 {
+		return new(big.Int).Set(x1), new(big.Int).Set(y1), new(big.Int).Set(z1)
+	}
+
+	z1z1 := new(big.Int).Mul(z1, z1)
+	z1z1.Mod(z1z1, p)
+	z2z2 := new(big.Int).Mul(z2, z2)
+	z2z2.Mod(z2z2, p)
+
+	u1 := new(big.Int).Mul(x1, z2z2)
+	u1.Mod(u1, p)
+	u2 := new(big.Int).Mul(x2, z1z1)
+	u2.Mod(u2, p)
+	s1 := new(big.Int).Mul(y1, z2)
+	s1.Mul(s1, z2z2)
+	s1.Mod(s1, p)
+	s2 := new(big.Int).Mul(y2, z1)
+	s2.Mul(s2, z1z1)
+	s2.Mod(s2, p)
+
+	h := new(big.Int).Sub(u2, u1)
+	h.Mod(h, p)
+	r := new(big.Int).Sub(s2, s1)
+	r.Mod(r, p)
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return c.doubleJacobian(x1, y1, z1)
+		}
+		return new(big.Int), new(big.Int), new(big.Int)
+	}
+	r.Lsh(r, 1)
+
+	i := new(big.Int).Lsh(h, 1)
+	i.Mul(i, i)
+	j := new(big.Int).Mul(h, i)
+	v := new(big.Int).Mul(u1, i)
+
+	x3 := new(big.Int).Mul(r, r)
+	x3.Sub(x3, j)
+	x3.Sub(x3, v)
+	x3.Sub(x3, v)
+	x3.Mod(x3, p)
+
+	y3 := new(big.Int).Sub(v, x3)
+	y3.Mul(y3, r)
+	s1j := new(big.Int).Mul(s1, j)
+	s1j.Lsh(s1j, 1)
+	y3.Sub(y3, s1j)
+	y3.Mod(y3, p)
+
+	z3 := new(big.Int).Add(z1, z2)
+	z3.Mul(z3, z3)
+	z3.Sub(z3, z1z1)
+	z3.Sub(z3, z2z2)
+	z3.Mul(z3, h)
+	z3.Mod(z3, p)
+	return x3, y3, z3
+}
+
+// doubleJacobian 雅可比坐标倍点（dbl-2009-l，适用于 a = 0）
+func (c *secp256k1Curve) doubleJacobian(x, y, z *big.Int) (*big.Int, *big.Int, *big.Int) {
+	p := c.params.P
+	if z.Sign() == 0 || y.Sign() == 0 {
+		return new(big.Int), new(big.Int), new(big.Int)
+	}
+	a := new(big.Int).Mul(x, x)
+	a.Mod(a, p)
+	b := new(big.Int).Mul(y, y)
+	b.Mod(b, p)
+	cc := new(big.Int).Mul(b, b)
+	cc.Mod(cc, p)
+
+	d := new(big.Int).Add(x, b)
+	d.Mul(d, d)
+	d.Sub(d, a)
+	d.Sub(d, cc)
+	d.Lsh(d, 1)
+	d.Mod(d, p)
+
+	e := new(big.Int).Mul(a, big.NewInt(3))
+	f := new(big.Int).Mul(e, e)
+
+	x3 := new(big.Int).Sub(f, new(big.Int).Lsh(d, 1))
+	x3.Mod(x3, p)
+
+	y3 := new(big.Int).Sub(d, x3)
+	y3.Mul(y3, e)
+	y3.Sub(y3, new(big.Int).Lsh(cc, 3))
+	y3.Mod(y3, p)
+
+	z3 := new(big.Int).Mul(y, z)
+	z3.Lsh(z3, 1)
+	z3.Mod(z3, p)
+	return x3, y3, z3
+}
+
+// secp256k1Decompress 由 33 字节压缩公钥恢复 (x, y)；p ≡ 3 (mod 4)，平方根为 a^((p+1)/4)
+func secp256k1Decompress(b []byte) (*big.Int, *big.Int) {
+	c := secp256k1().(*secp256k1Curve)
+	if len(b) != CompressedPubKeySize || (b[0] != 0x02 && b[0] != 0x03) {
+		return nil, nil
+	}
+	p := c.params.P
+	x := new(big.Int).SetBytes(b[1:])
+	if x.Cmp(p) >= 0 {
+		return nil, nil
+	}
+	y := new(big.Int).ModSqrt(c.rhs(x), p)
+	if y == nil {
+		return nil, nil
+	}
+	if byte(y.Bit(0)) != b[0]&1 {
+		y.Sub(p, y)
+	}
+	if !c.IsOnCurve(x, y) {
+		return nil, nil
+	}
+	return x, y
+}
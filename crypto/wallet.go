@@ -1,13 +1,9 @@
 package crypto
 
 import (
-	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
-	"math/big"
 )
 
 var (
@@ -15,72 +11,90 @@ var (
 	pubKeySize = curve.Params().BitSize / 8 // 256-bit => 32 bytes
 )
 
-// Wallet 封装 ECDSA 密钥对
+// PrivateKeySize 私钥标量的定长编码字节数
+const PrivateKeySize = 32
+
+// CompressedPubKeySize 压缩公钥长度：前缀(0x02/0x03) + X
+const CompressedPubKeySize = 33
+
+// Wallet 封装一个签名方案的密钥对
 type Wallet struct {
-	PrivateKey *ecdsa.PrivateKey
-	PublicKey  []byte // 非压缩形式：X||Y
+	Signer    Signer // 具体签名方案实现
+	PublicKey []byte // 地址使用的公钥编码：P-256 为 64 字节 X||Y，其余方案带方案前缀
+}
+
+// NewWallet 由签名器构造钱包
+func NewWallet(signer Signer) *Wallet {
+	return &Wallet{Signer: signer, PublicKey: signer.PublicKey()}
 }
 
-// GenerateWallet 生成新的 ECDSA 密钥对
+// GenerateWallet 生成新的 P-256 密钥对
 func GenerateWallet() (*Wallet, error) {
-	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	return GenerateWalletWithScheme(SchemeP256)
+}
+
+// GenerateWalletWithScheme 生成指定签名方案的密钥对
+func GenerateWalletWithScheme(scheme Scheme) (*Wallet, error) {
+	signer, err := GenerateSigner(scheme)
 	if err != nil {
 		return nil, err
 	}
-	return &Wallet{
-		PrivateKey: priv,
-		PublicKey:  serializePubKey(&priv.PublicKey),
-	}, nil
+	return NewWallet(signer), nil
 }
 
-// FromPrivateHex 通过十六进制私钥恢复钱包
+// FromPrivateHex 通过十六进制私钥恢复 P-256 钱包
+// 标准编码为 32 字节定长；兼容旧版丢失前导零的短编码
 func FromPrivateHex(privHex string) (*Wallet, error) {
+	return FromPrivateHexWithScheme(SchemeP256, privHex)
+}
+
+// FromPrivateHexWithScheme 通过十六进制私钥恢复指定签名方案的钱包
+func FromPrivateHexWithScheme(scheme Scheme, privHex string) (*Wallet, error) {
 	b, err := hex.DecodeString(privHex)
 	if err != nil {
 		return nil, err
 	}
-	k := new(big.Int).SetBytes(b)
-	if k.Sign() == 0 || k.Cmp(curve.Params().N) >= 0 {
-		return nil, errors.New("invalid private key scalar")
+	signer, err := NewSigner(scheme, b)
+	if err != nil {
+		return nil, err
 	}
-	priv := new(ecdsa.PrivateKey)
-	priv.PublicKey.Curve = curve
-	priv.D = k
-	priv.PublicKey.X, priv.PublicKey.Y = curve.ScalarBaseMult(b)
-	return &Wallet{
-		PrivateKey: priv,
-		PublicKey:  serializePubKey(&priv.PublicKey),
-	}, nil
+	return NewWallet(signer), nil
 }
 
-// PrivateKeyHex 返回私钥的十六进制编码（便于持久化）
+// PrivateKeyHex 返回私钥的定长十六进制编码（便于持久化）
 func PrivateKeyHex(w *Wallet) (string, error) {
-	if w == nil || w.PrivateKey == nil {
+	if w == nil || w.Signer == nil {
 		return "", errors.New("wallet is nil")
 	}
-	return hex.EncodeToString(w.PrivateKey.D.Bytes()), nil
+	return hex.EncodeToString(w.Signer.PrivateKey()), nil
 }
 
-// Sign 对数据进行 SHA-256 后签名（ASN.1 编码）
-func (w *Wallet) Sign(data []byte) ([]byte, error) {
-	if w == nil || w.PrivateKey == nil {
-		return nil, errors.New("wallet private key is nil")
+// Scheme 返回钱包的签名方案
+func (w *Wallet) Scheme() Scheme {
+	if w == nil || w.Signer == nil {
+		return 0
 	}
-	digest := sha256.Sum256(data)
-	return ecdsa.SignASN1(rand.Reader, w.PrivateKey, digest[:])
+	return w.Signer.Scheme()
 }
 
-// Verify 使用公钥验证签名
-func Verify(pubKey []byte, data []byte, sig []byte) bool {
-	if len(pubKey) != 2*pubKeySize {
-		return false
+// CompressedPublicKey 返回紧凑公钥：P-256 为 33 字节压缩形式，其余方案的公钥本身已是紧凑编码
+func (w *Wallet) CompressedPublicKey() []byte {
+	if w == nil || w.Signer == nil {
+		return nil
+	}
+	compressed, err := CompressPublicKey(w.PublicKey)
+	if err != nil {
+		return nil
 	}
-	pub := deserializePubKey(pubKey)
-	if pub == nil {
-		return false
+	return compressed
+}
+
+// Sign 使用钱包的签名方案对数据签名
+func (w *Wallet) Sign(data []byte) ([]byte, error) {
+	if w == nil || w.Signer == nil {
+		return nil, errors.New("wallet private key is nil")
 	}
-	digest := sha256.Sum256(data)
-	return ecdsa.VerifyASN1(pub, digest[:], sig)
+	return w.Signer.Sign(data)
 }
 
 // PublicKeyHex 将公钥编码为十六进制字符串
@@ -88,25 +102,15 @@ func PublicKeyHex(pubKey []byte) string {
 	return hex.EncodeToString(pubKey)
 }
 
-// serializePubKey 将公钥序列化为非压缩 64 字节
-func serializePubKey(pub *ecdsa.PublicKey) []byte {
-	if pub == nil {
-		return nil
-	}
-	xBytes := pub.X.FillBytes(make([]byte, pubKeySize))
-	yBytes := pub.Y.FillBytes(make([]byte, pubKeySize))
-	return append(xBytes, yBytes...)
-}
-
-// deserializePubKey 从非压缩 64 字节还原公钥
-func deserializePubKey(b []byte) *ecdsa.PublicKey {
-	if len(b) != 2*pubKeySize {
-		return nil
+// CompressPublicKey 将 64 字节 P-256 非压缩公钥转换为 33 字节压缩形式；已是紧凑编码的公钥原样返回
+func CompressPublicKey(pubKey []byte) ([]byte, error) {
+	v, err := ParsePublicKey(pubKey)
+	if err != nil {
+		return nil, err
 	}
-	x := new(big.Int).SetBytes(b[:pubKeySize])
-	y := new(big.Int).SetBytes(b[pubKeySize:])
-	if !curve.IsOnCurve(x, y) {
-		return nil
+	if len(pubKey) != 2*pubKeySize {
+		return append([]byte(nil), pubKey...), nil
 	}
-	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	ev := v.(*ecdsaVerifier)
+	return elliptic.MarshalCompressed(curve, ev.x, ev.y), nil
 }
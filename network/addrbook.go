@@ -0,0 +1,312 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+const (
+	// maxAddrBookSize 地址簿容量上限
+	maxAddrBookSize = 1000
+	// maxAddrPerMessage 单条 /getaddr、/addr 消息的地址数上限
+	maxAddrPerMessage = 1000
+	// peerDropFailures 非手动配置的 peer 连续失败该次数后断开，地址簿中同样视为不可用
+	peerDropFailures = 5
+)
+
+// NormalizePeerURL 校验并规范化 peer 地址：须为带主机的 http(s) URL，去掉末尾斜杠，不允许路径、查询参数
+func NormalizePeerURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("peer url %q must use http or https", raw)
+	}
+	if u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" || strings.Trim(u.Path, "/") != "" {
+		return "", fmt.Errorf("invalid peer url %q", raw)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// AddrBook 已知 peer 地址簿：记录每个地址的来源、最近连通时间与连续失败次数，供地址交换与选择外连 peer
+type AddrBook struct {
+	mu      sync.Mutex
+	entries map[string]*storage.PeerRecord
+	self    string
+	// now 注入便于测试
+	now func() time.Time
+}
+
+// NewAddrBook 以持久化的条目创建地址簿；self 为本节点对外地址（可为空），不会被加入
+func NewAddrBook(self string, records []storage.PeerRecord) *AddrBook {
+	if norm, err := NormalizePeerURL(self); err == nil {
+		self = norm
+	}
+	b := &AddrBook{entries: make(map[string]*storage.PeerRecord), self: self, now: time.Now}
+	for _, r := range records {
+		norm, err := NormalizePeerURL(r.URL)
+		if err != nil || norm == self || len(b.entries) >= maxAddrBookSize {
+			continue
+		}
+		r.URL = norm
+		rec := r
+		b.entries[norm] = &rec
+	}
+	return b
+}
+
+// Add 加入新地址，忽略无效地址、本节点与已知地址；地址簿已满时替换一个不可用的条目，返回新加入数量
+func (b *AddrBook) Add(urls []string, source string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	added := 0
+	for _, raw := range urls {
+		norm, err := NormalizePeerURL(raw)
+		if err != nil || norm == b.self {
+			continue
+		}
+		if _, ok := b.entries[norm]; ok {
+			continue
+		}
+		if len(b.entries) >= maxAddrBookSize && !b.evictLocked() {
+			break
+		}
+		b.entries[norm] = &storage.PeerRecord{URL: norm, Source: source}
+		added++
+	}
+	return added
+}
+
+// evictLocked 移除一个连续失败达到上限的条目，没有可移除的返回 false
+func (b *AddrBook) evictLocked() bool {
+	victim := ""
+	for u, e := range b.entries {
+		if e.Failures >= peerDropFailures && (victim == "" || u < victim) {
+			victim = u
+		}
+	}
+	if victim == "" {
+		return false
+	}
+	delete(b.entries, victim)
+	return true
+}
+
+// MarkGood 记录地址连通，清除失败计数（未知地址会被加入）
+func (b *AddrBook) MarkGood(raw string) {
+	b.mark(raw, func(e *storage.PeerRecord) {
+		e.LastSeen = b.now().Unix()
+		e.Failures = 0
+	})
+}
+
+// MarkFailed 记录地址连接失败
+func (b *AddrBook) MarkFailed(raw string) {
+	b.mark(raw, func(e *storage.PeerRecord) { e.Failures++ })
+}
+
+func (b *AddrBook) mark(raw string, fn func(*storage.PeerRecord)) {
+	norm, err := NormalizePeerURL(raw)
+	if err != nil || norm == b.self {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e, ok := b.entries[norm]
+	if !ok {
+		if len(b.entries) >= maxAddrBookSize && !b.evictLocked() {
+			return
+		}
+		e = &storage.PeerRecord{URL: norm}
+		b.entries[norm] = e
+	}
+	fn(e)
+}
+
+// Addresses 返回最多 limit 个可分享的地址：排除失败达到上限的，最近连通的优先
+func (b *AddrBook) Addresses(limit int) []string {
+	return b.pick(limit, nil)
+}
+
+// Candidates 返回最多 n 个可发起外连的地址，跳过 exclude 中已连接的
+func (b *AddrBook) Candidates(n int, exclude map[string]bool) []string {
+	return b.pick(n, exclude)
+}
+
+func (b *AddrBook) pick(limit int, exclude map[string]bool) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var list []*storage.PeerRecord
+	for u, e := range b.entries {
+		if e.Failures >= peerDropFailures || exclude[u] {
+			continue
+		}
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Failures != list[j].Failures {
+			return list[i].Failures < list[j].Failures
+		}
+		if list[i].LastSeen != list[j].LastSeen {
+			return list[i].LastSeen > list[j].LastSeen
+		}
+		return list[i].URL < list[j].URL
+	})
+	if limit >= 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	out := make([]string, 0, len(list))
+	for _, e := range list {
+		out = append(out, e.URL)
+	}
+	return out
+}
+
+// Records 返回全部条目（按地址排序）用于持久化
+func (b *AddrBook) Records() []storage.PeerRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]storage.PeerRecord, 0, len(b.entries))
+	for _, e := range b.entries {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].URL < out[j].URL })
+	return out
+}
+
+// handleGetAddr GET /getaddr 返回本节点已知的可用 peer 地址
+func (s *NodeServer) handleGetAddr(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp := AddrMessage{Addrs: []string{}}
+	if s.AddrBook != nil {
+		resp.Addrs = s.AddrBook.Addresses(maxAddrPerMessage)
+	} else {
+		resp.Addrs = append(resp.Addrs, s.peerURLs()...)
+	}
+	writeJSON(w, resp)
+}
+
+// handleAddr POST /addr 接收对端分享的地址（含其自身对外地址），加入地址簿
+func (s *NodeServer) handleAddr(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.AddrBook == nil {
+		http.Error(w, "address book disabled", http.StatusServiceUnavailable)
+		return
+	}
+	var msg AddrMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTxMessageBytes)).Decode(&msg); err != nil {
+		s.rejectBody(w, r, err)
+		return
+	}
+	if len(msg.Addrs) > maxAddrPerMessage {
+		s.misbehaving(r, scoreOversized, "oversized addr message")
+		http.Error(w, fmt.Sprintf("too many addresses (max %d)", maxAddrPerMessage), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, AddrAckResponse{Added: s.AddrBook.Add(msg.Addrs, "addr")})
+}
+
+// peerURLs 当前 peer 列表：有 peer 管理器时取其连接，否则取启动参数
+func (s *NodeServer) peerURLs() []string {
+	if s.PeerManager != nil {
+		return s.PeerManager.URLs()
+	}
+	return s.Peers
+}
+
+// FetchAddrs 向 peer 请求其已知地址
+func (s *Syncer) FetchAddrs() ([]string, error) {
+	resp, err := s.get("/getaddr")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var msg AddrMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, err
+	}
+	return msg.Addrs, nil
+}
+
+// SendAddrs 把地址分享给 peer
+func (s *Syncer) SendAddrs(addrs []string) error {
+	body, err := json.Marshal(AddrMessage{Addrs: addrs})
+	if err != nil {
+		return err
+	}
+	resp, err := s.Client.Post(s.Peer+"/addr", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("POST %s/addr status %d: %s", s.Peer, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Maintain 维护外连：断开连续失败的非配置 peer 并记入地址簿，从地址簿补足到 target 个连接，
+// 与新连接的 peer 交换一次地址（取回其地址簿，并告知本节点对外地址 advertise，可为空）
+func (m *PeerManager) Maintain(book *AddrBook, target int, advertise string) {
+	// 本轮断开的地址不会立即被重新选中
+	skip := make(map[string]bool)
+	for _, st := range m.Peers() {
+		switch {
+		case m.banned(st.URL) && !m.pinned(st.URL):
+			m.Remove(st.URL)
+			skip[st.URL] = true
+		case st.Failures >= peerDropFailures && !m.pinned(st.URL):
+			m.Remove(st.URL)
+			book.MarkFailed(st.URL)
+			skip[st.URL] = true
+		case st.Failures == 0 && st.LastSeen > 0:
+			book.MarkGood(st.URL)
+		}
+	}
+
+	if need := target - len(m.URLs()); need > 0 {
+		for _, u := range m.URLs() {
+			skip[u] = true
+		}
+		for _, u := range book.Candidates(need, skip) {
+			if !m.banned(u) {
+				m.Add(u)
+			}
+		}
+	}
+
+	for _, s := range m.Available() {
+		if !m.beginExchange(s.Peer) {
+			continue
+		}
+		addrs, err := s.FetchAddrs()
+		if err == nil && advertise != "" {
+			err = s.SendAddrs([]string{advertise})
+		}
+		if err != nil {
+			m.endExchange(s.Peer, false)
+			m.RecordFailure(s.Peer, err)
+			continue
+		}
+		m.endExchange(s.Peer, true)
+		book.Add(addrs, s.Peer)
+	}
+}
@@ -0,0 +1,111 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestAddrBook 地址规范化、去重、排除本节点；失败达到上限的地址不再分享，地址簿可持久化
+func TestAddrBook(t *testing.T) {
+	for raw, want := range map[string]string{
+		"http://127.0.0.1:8080/": "http://127.0.0.1:8080",
+		" https://node.example ": "https://node.example",
+		"ftp://127.0.0.1":        "",
+		"http://127.0.0.1/x":     "",
+		"127.0.0.1:8080":         "",
+	} {
+		got, err := NormalizePeerURL(raw)
+		if want == "" && err == nil || want != "" && got != want {
+			t.Fatalf("normalize %q: got %q err %v", raw, got, err)
+		}
+	}
+
+	book := NewAddrBook("http://me:1/", nil)
+	if n := book.Add([]string{"http://a:1", "http://a:1/", "http://me:1", "bad", "http://b:1", "http://c:1"}, "seed"); n != 3 {
+		t.Fatalf("expect 3 new addresses, got %d", n)
+	}
+	book.MarkGood("http://c:1")
+	for i := 0; i < peerDropFailures; i++ {
+		book.MarkFailed("http://b:1")
+	}
+	if got := book.Addresses(10); fmt.Sprint(got) != "[http://c:1 http://a:1]" {
+		t.Fatalf("addresses: %v", got)
+	}
+	if got := book.Candidates(5, map[string]bool{"http://c:1": true}); fmt.Sprint(got) != "[http://a:1]" {
+		t.Fatalf("candidates: %v", got)
+	}
+
+	store := mustStore(t, t.TempDir(), "book")
+	if err := store.SavePeers(book.Records()); err != nil {
+		t.Fatalf("save peers: %v", err)
+	}
+	records, err := store.LoadPeers()
+	if err != nil {
+		t.Fatalf("load peers: %v", err)
+	}
+	reloaded := NewAddrBook("http://me:1", records)
+	if fmt.Sprint(reloaded.Records()) != fmt.Sprint(book.Records()) {
+		t.Fatalf("reloaded book differs: %v vs %v", reloaded.Records(), book.Records())
+	}
+}
+
+// TestPeerDiscovery 新节点只配置一个 peer：交换地址后得知第三个节点并自动连上，同时把自身地址告知对端；
+// 连续失败的已发现 peer 被断开，手动配置的保留
+func TestPeerDiscovery(t *testing.T) {
+	newNode := func(name string) (*NodeServer, *httptest.Server) {
+		ns := &NodeServer{NodeID: name, Store: mustStore(t, t.TempDir(), name)}
+		srv := httptest.NewServer(ns.Handler())
+		t.Cleanup(func() { srv.Close() })
+		ns.AddrBook = NewAddrBook(srv.URL, nil)
+		return ns, srv
+	}
+	a, srvA := newNode("a")
+	_, srvC := newNode("c")
+	a.AddrBook.Add([]string{srvC.URL}, "test")
+
+	self := "http://127.0.0.1:1"
+	book := NewAddrBook(self, nil)
+	book.Add([]string{srvA.URL}, "seed")
+	m := NewPeerManager([]string{srvA.URL})
+	m.Maintain(book, 3, self)
+	if got := book.Addresses(10); len(got) != 2 {
+		t.Fatalf("expect to learn node c, got %v", got)
+	}
+	if got := a.AddrBook.Addresses(10); len(got) != 2 || got[0] != self && got[1] != self {
+		t.Fatalf("node a should learn our address, got %v", got)
+	}
+	m.Maintain(book, 3, self)
+	if got := m.URLs(); fmt.Sprint(got) != fmt.Sprint([]string{srvA.URL, srvC.URL}) {
+		t.Fatalf("expect outbound to a and c, got %v", got)
+	}
+
+	// 对 c 连续失败达到上限后断开；配置的 a 即使失败也保留
+	for i := 0; i < peerDropFailures; i++ {
+		m.RecordFailure(srvC.URL, errors.New("down"))
+		m.RecordFailure(srvA.URL, errors.New("down"))
+	}
+	m.Maintain(book, 3, self)
+	if got := m.URLs(); fmt.Sprint(got) != fmt.Sprint([]string{srvA.URL}) {
+		t.Fatalf("failing discovered peer should be dropped, got %v", got)
+	}
+
+	// /addr 拒绝超量地址
+	many := make([]string, maxAddrPerMessage+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("http://10.0.%d.%d:8080", i/256, i%256)
+	}
+	body, _ := json.Marshal(AddrMessage{Addrs: many})
+	resp, err := http.Post(srvA.URL+"/addr", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("post addr: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("oversized addr message: status %d", resp.StatusCode)
+	}
+}
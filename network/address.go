@@ -0,0 +1,118 @@
+package network
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+const (
+	// defaultAddressPageSize /address/{addr}/txs 未指定 limit 时的每页条数
+	defaultAddressPageSize = 50
+	// maxAddressPageSize 每页条数上限
+	maxAddressPageSize = 500
+)
+
+// handleAddress 地址索引查询
+// GET /address/{addr}/txs?cursor=&limit=  按链上顺序分页返回地址相关交易，cursor 取上一页的 next_cursor
+// GET /address/{addr}/utxos               返回地址的未花费输出及合计
+func (s *NodeServer) handleAddress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/address/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "expect /address/{addr}/txs or /address/{addr}/utxos", http.StatusNotFound)
+		return
+	}
+	addr := parts[0]
+	height, err := latestHeight(s.Store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch parts[1] {
+	case "txs":
+		limit := defaultAddressPageSize
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			if n > maxAddressPageSize {
+				n = maxAddressPageSize
+			}
+			limit = n
+		}
+		entries, err := s.Store.AddressTxs(addr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		start := 0
+		if raw := r.URL.Query().Get("cursor"); raw != "" {
+			h, idx, err := parseAddressCursor(raw)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			// 从游标之后的第一条开始，新区块追加在末尾，翻页结果稳定
+			start = sort.Search(len(entries), func(i int) bool {
+				return entries[i].Height > h || entries[i].Height == h && entries[i].Index > idx
+			})
+		}
+		end := start + limit
+		if end > len(entries) {
+			end = len(entries)
+		}
+		resp := AddressTxsResponse{Address: addr, Height: height, Txs: entries[start:end]}
+		if resp.Txs == nil {
+			resp.Txs = []storage.AddressTx{}
+		}
+		if end < len(entries) {
+			last := entries[end-1]
+			resp.NextCursor = fmt.Sprintf("%d-%d", last.Height, last.Index)
+		}
+		writeJSON(w, resp)
+	case "utxos":
+		utxos, err := s.Store.AddressUTXOs(addr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := AddressUTXOsResponse{Address: addr, Height: height, UTXOs: utxos}
+		if resp.UTXOs == nil {
+			resp.UTXOs = []storage.AddressUTXO{}
+		}
+		for _, u := range utxos {
+			resp.Total += u.Value
+		}
+		writeJSON(w, resp)
+	default:
+		http.Error(w, "unknown address resource", http.StatusNotFound)
+	}
+}
+
+// parseAddressCursor 解析分页游标 "<height>-<index>"
+func parseAddressCursor(raw string) (uint64, int, error) {
+	hs, is, ok := strings.Cut(raw, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid cursor")
+	}
+	h, err := strconv.ParseUint(hs, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cursor")
+	}
+	idx, err := strconv.Atoi(is)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cursor")
+	}
+	return h, idx, nil
+}
@@ -0,0 +1,105 @@
+package network
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestAddressIndex 地址索引记录收款与花费，/address/{addr}/txs 分页、/address/{addr}/utxos 返回未花费输出
+func TestAddressIndex(t *testing.T) {
+	store := mustStore(t, t.TempDir(), "addr")
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 0)}, 0)
+	// 跳过签名校验直接写盘：addr1 -> addr2 30，找零 20
+	tx1 := &core.Transaction{
+		Inputs:  []core.TxInput{{TxID: core.ComputeTxID(genesis.Transactions[0]), Vout: 0}},
+		Outputs: []core.TxOutput{{Value: 30, ScriptPubKey: "addr2"}, {Value: 20, ScriptPubKey: "addr1"}},
+	}
+	// 同块内 addr2 -> addr3 10，找零 20
+	tx2 := &core.Transaction{
+		Inputs:  []core.TxInput{{TxID: core.ComputeTxID(tx1), Vout: 0}},
+		Outputs: []core.TxOutput{{Value: 10, ScriptPubKey: "addr3"}, {Value: 20, ScriptPubKey: "addr2"}},
+	}
+	block1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 1), tx1, tx2}, 0)
+	if err := store.SaveBlock(genesis); err != nil {
+		t.Fatalf("save block: %v", err)
+	}
+	// 被花费输出由调用方的 UTXO 集解析，不读取已有索引
+	if err := store.ConnectBlock(block1, storage.SpentOutputs(block1, core.BuildUTXOSet([]*core.Block{genesis}))); err != nil {
+		t.Fatalf("connect block: %v", err)
+	}
+
+	ns := &NodeServer{NodeID: "addr", Store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/address/", ns.handleAddress)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() { srv.Close() })
+	get := func(path string, out any) int {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	// addr1：创世 coinbase、区块 1 coinbase、tx1（花费 50 收回找零 20），按链上顺序
+	var page AddressTxsResponse
+	if code := get("/address/addr1/txs?limit=2", &page); code != http.StatusOK {
+		t.Fatalf("txs status %d", code)
+	}
+	if len(page.Txs) != 2 || page.NextCursor == "" || !page.Txs[0].Coinbase || page.Txs[1].Height != 1 || page.Txs[1].Index != 0 {
+		t.Fatalf("first page: %+v", page)
+	}
+	var rest AddressTxsResponse
+	if code := get("/address/addr1/txs?limit=2&cursor="+page.NextCursor, &rest); code != http.StatusOK {
+		t.Fatalf("txs status %d", code)
+	}
+	if len(rest.Txs) != 1 || rest.NextCursor != "" {
+		t.Fatalf("second page: %+v", rest)
+	}
+	if e := rest.Txs[0]; e.TxID != crypto.HexEncode(core.ComputeTxID(tx1)) || e.Sent != 50 || e.Received != 20 || len(e.Spends) != 1 {
+		t.Fatalf("spending entry: %+v", e)
+	}
+
+	var utxos AddressUTXOsResponse
+	if code := get("/address/addr2/utxos", &utxos); code != http.StatusOK {
+		t.Fatalf("utxos status %d", code)
+	}
+	if len(utxos.UTXOs) != 1 || utxos.Total != 20 || utxos.UTXOs[0].TxID != crypto.HexEncode(core.ComputeTxID(tx2)) || utxos.UTXOs[0].Vout != 1 {
+		t.Fatalf("addr2 utxos: %+v", utxos)
+	}
+	if code := get("/address/addr1/utxos", &utxos); code != http.StatusOK || utxos.Total != 70 {
+		t.Fatalf("addr1 utxos: %d %+v", code, utxos)
+	}
+	if code := get("/address/addr1/txs?cursor=bad", &page); code != http.StatusBadRequest {
+		t.Fatalf("bad cursor should be 400, got %d", code)
+	}
+	if code := get("/address/addr1/other", &page); code != http.StatusNotFound {
+		t.Fatalf("unknown resource should be 404, got %d", code)
+	}
+
+	// 重组覆盖区块 1 后，其中的交易从索引移除
+	fork := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("addr9", 50, 1)}, 0)
+	if err := store.SaveBlock(fork); err != nil {
+		t.Fatalf("save fork: %v", err)
+	}
+	var none AddressTxsResponse
+	if code := get("/address/addr3/txs", &none); code != http.StatusOK || len(none.Txs) != 0 {
+		t.Fatalf("addr3 after reorg: %d %+v", code, none)
+	}
+	if code := get("/address/addr1/utxos", &utxos); code != http.StatusOK || utxos.Total != 50 {
+		t.Fatalf("addr1 after reorg: %+v", utxos)
+	}
+}
@@ -1,9 +1,13 @@
 package network
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/yiqi-017/blockchain/core"
@@ -15,7 +19,7 @@ func TestBalanceHandler(t *testing.T) {
 
 	// 创世：addr1 获得 50
 	addr1 := "addr1"
-	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx(addr1, 50)}, 0)
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx(addr1, 50, 0)}, 0)
 	if err := store.SaveBlock(genesis); err != nil {
 		t.Fatalf("save genesis: %v", err)
 	}
@@ -52,3 +56,111 @@ func TestBalanceHandler(t *testing.T) {
 		t.Fatalf("expect balance 20, got %d", out.Balance)
 	}
 }
+
+// TestBalancesHandler 一次查询多个地址：逐地址余额、待确认转入与合计；由地址索引作答，请求体与地址个数有上限
+func TestBalancesHandler(t *testing.T) {
+	base := t.TempDir()
+	store := mustStore(t, base, "bals")
+
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 0)}, 0)
+	if err := store.SaveBlock(genesis); err != nil {
+		t.Fatalf("save genesis: %v", err)
+	}
+	tx := &core.Transaction{
+		Inputs:  []core.TxInput{{TxID: core.ComputeTxID(genesis.Transactions[0]), Vout: 0}},
+		Outputs: []core.TxOutput{{Value: 30, ScriptPubKey: "addr2"}, {Value: 20, ScriptPubKey: "addr1"}},
+	}
+	block1 := core.MineBlock(genesis, []*core.Transaction{tx}, 0)
+	if err := store.SaveBlock(block1); err != nil {
+		t.Fatalf("save block1: %v", err)
+	}
+	// 待确认：addr2 -> addr3 30
+	pending := &core.Transaction{
+		Inputs:  []core.TxInput{{TxID: core.ComputeTxID(tx), Vout: 0}},
+		Outputs: []core.TxOutput{{Value: 30, ScriptPubKey: "addr3"}},
+	}
+	pool := core.NewTxPool()
+	pool.Add("p", pending)
+	if err := store.SaveTxPool(pool); err != nil {
+		t.Fatalf("save pool: %v", err)
+	}
+
+	ns := &NodeServer{NodeID: "bals", Store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/balances", ns.handleBalances)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() { srv.Close() })
+
+	resp, err := http.Get(srv.URL + "/balances?addr=addr1,addr2&addr=addr3")
+	if err != nil {
+		t.Fatalf("get balances: %v", err)
+	}
+	defer resp.Body.Close()
+	var out BalancesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Height != 1 || len(out.Addresses) != 3 {
+		t.Fatalf("unexpected response: %+v", out)
+	}
+	want := map[string][2]int64{"addr1": {20, 0}, "addr2": {30, 0}, "addr3": {0, 30}}
+	for _, a := range out.Addresses {
+		if w := want[a.Address]; a.Confirmed != w[0] || a.Pending != w[1] {
+			t.Fatalf("%s: confirmed=%d pending=%d, want %v", a.Address, a.Confirmed, a.Pending, w)
+		}
+	}
+	if out.Total.Confirmed != 50 || out.Total.Locked != 30 || out.Total.Pending != 30 {
+		t.Fatalf("unexpected total: %+v", out.Total)
+	}
+
+	// 已确认部分取自地址索引：区块文件内容不再被读取
+	if err := os.WriteFile(filepath.Join(base, "bals", "blocks", "1.json"), []byte("garbage"), 0o644); err != nil {
+		t.Fatalf("scribble block: %v", err)
+	}
+	body, _ := json.Marshal(BalancesRequest{Addresses: []string{"addr1", "addr1"}})
+	post, err := http.Post(srv.URL+"/balances", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("post balances: %v", err)
+	}
+	var single BalancesResponse
+	if err := json.NewDecoder(post.Body).Decode(&single); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	post.Body.Close()
+	if len(single.Addresses) != 1 || single.Total.Confirmed != 20 || single.Total.Spendable != 20 {
+		t.Fatalf("unexpected POST response: %+v", single)
+	}
+
+	// 请求体与地址个数均有上限
+	many := make([]string, maxBalanceAddresses+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("a%d", i)
+	}
+	body, _ = json.Marshal(BalancesRequest{Addresses: many})
+	tooMany, err := http.Post(srv.URL+"/balances", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	tooMany.Body.Close()
+	if tooMany.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for too many addresses, got %d", tooMany.StatusCode)
+	}
+	huge := append([]byte(`{"addresses":["`), bytes.Repeat([]byte("a"), maxTxMessageBytes)...)
+	oversized, err := http.Post(srv.URL+"/balances", "application/json", bytes.NewReader(append(huge, `"]}`...)))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	oversized.Body.Close()
+	if oversized.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for oversized body, got %d", oversized.StatusCode)
+	}
+
+	missing, err := http.Get(srv.URL + "/balances")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without addr, got %d", missing.StatusCode)
+	}
+}
@@ -0,0 +1,278 @@
+package network
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+const (
+	// banThreshold 累计不当行为分值达到该值即封禁
+	banThreshold = 100
+	// DefaultBanDuration 默认封禁时长
+	DefaultBanDuration = 24 * time.Hour
+
+	// maxBlockMessageBytes POST /block、/txpool 请求体上限
+	maxBlockMessageBytes = 4 << 20
+	// maxTxMessageBytes POST /tx、/addr 请求体上限
+	maxTxMessageBytes = 1 << 20
+)
+
+// 不当行为分值
+const (
+	scoreInvalidPOW    = 100 // 区块工作量证明无效
+	scoreBadMerkle     = 100 // 区块交易列表与 Merkle 根不符、变形或重复交易
+	scoreInvalidTxIn   = 100 // 区块内含无效交易
+	scoreInvalidBlock  = 20  // 其他无效区块（时间戳不递增、coinbase 等）
+	scoreInvalidTx     = 10  // 提交的无效交易（缺少输入的不计分）
+	scoreOversized     = 20  // 超出大小上限的消息
+	scoreBadMessage    = 10  // 无法解析的消息
+	scorePoolOverwrite = 20  // 远程节点试图覆盖交易池
+)
+
+// BanManager 按 peer 地址（IP）累计不当行为分值，达到阈值后临时封禁；封禁列表持久化，重启后仍生效
+type BanManager struct {
+	mu       sync.Mutex
+	scores   map[string]int
+	bans     map[string]storage.BanRecord
+	duration time.Duration
+	store    *storage.FileStorage // 可为 nil，此时不持久化
+	// now 注入便于测试
+	now func() time.Time
+}
+
+// NewBanManager 读取已保存的封禁列表（丢弃已过期的），duration<=0 时取默认封禁时长
+func NewBanManager(store *storage.FileStorage, duration time.Duration) (*BanManager, error) {
+	if duration <= 0 {
+		duration = DefaultBanDuration
+	}
+	m := &BanManager{
+		scores:   make(map[string]int),
+		bans:     make(map[string]storage.BanRecord),
+		duration: duration,
+		store:    store,
+		now:      time.Now,
+	}
+	if store == nil {
+		return m, nil
+	}
+	records, err := store.LoadBans()
+	if err != nil {
+		return nil, err
+	}
+	now := m.now().Unix()
+	for _, r := range records {
+		if r.Until > now {
+			m.bans[r.Addr] = r
+		}
+	}
+	return m, nil
+}
+
+// Misbehaving 为 addr 累加分值，达到阈值时封禁并清零分值；返回是否因此被封禁
+func (m *BanManager) Misbehaving(addr string, score int, reason string) bool {
+	if m == nil || addr == "" || score <= 0 {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scores[addr] += score
+	if m.scores[addr] < banThreshold {
+		return false
+	}
+	delete(m.scores, addr)
+	m.bans[addr] = storage.BanRecord{Addr: addr, Until: m.now().Add(m.duration).Unix(), Reason: reason}
+	log.Printf("[ban] %s 因 %s 被封禁至 %s", addr, reason, time.Unix(m.bans[addr].Until, 0).Format(time.RFC3339))
+	m.saveLocked()
+	return true
+}
+
+// IsBanned 判断 addr 是否处于封禁期，过期的封禁随之解除
+func (m *BanManager) IsBanned(addr string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.bans[addr]
+	if !ok {
+		return false
+	}
+	if r.Until > m.now().Unix() {
+		return true
+	}
+	delete(m.bans, addr)
+	m.saveLocked()
+	return false
+}
+
+// Unban 解除封禁并清零分值，返回原先是否被封禁
+func (m *BanManager) Unban(addr string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.scores, addr)
+	if _, ok := m.bans[addr]; !ok {
+		return false
+	}
+	delete(m.bans, addr)
+	m.saveLocked()
+	return true
+}
+
+// Bans 返回未过期的封禁（按地址排序）与各地址当前分值
+func (m *BanManager) Bans() ([]storage.BanRecord, map[string]int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now().Unix()
+	bans := make([]storage.BanRecord, 0, len(m.bans))
+	for _, r := range m.bans {
+		if r.Until > now {
+			bans = append(bans, r)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Addr < bans[j].Addr })
+	scores := make(map[string]int, len(m.scores))
+	for a, s := range m.scores {
+		scores[a] = s
+	}
+	return bans, scores
+}
+
+func (m *BanManager) saveLocked() {
+	if m.store == nil {
+		return
+	}
+	records := make([]storage.BanRecord, 0, len(m.bans))
+	for _, r := range m.bans {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Addr < records[j].Addr })
+	if err := m.store.SaveBans(records); err != nil {
+		log.Printf("[ban] save bans: %v", err)
+	}
+}
+
+// blockMisbehavior 按区块校验错误给出分值；只为可证明无效的区块计分，
+// 与本地链冲突（分叉）、时间戳超前（可能是时钟偏差）与本地读写错误不算不当行为
+func blockMisbehavior(err error) (int, string) {
+	switch {
+	case errors.Is(err, errInvalidPOW):
+		return scoreInvalidPOW, "invalid pow"
+	case errors.Is(err, errBadMerkle):
+		return scoreBadMerkle, "bad merkle"
+	case errors.Is(err, errInvalidTx):
+		return scoreInvalidTxIn, "invalid tx in block"
+	case errors.Is(err, errInvalidBlock):
+		return scoreInvalidBlock, "invalid block"
+	default:
+		return 0, ""
+	}
+}
+
+// txMisbehavior 按交易校验错误给出分值；引用的输出缺失（尚未收到父交易或刚被区块花费）
+// 与 coinbase 未成熟（双方链尖不同）可能来自诚实转发，不计分
+func txMisbehavior(err error) (int, string) {
+	switch {
+	case !errors.Is(err, errInvalidTx):
+		return 0, ""
+	case errors.Is(err, core.ErrMissingInput), errors.Is(err, core.ErrImmatureCoinbase):
+		return 0, ""
+	default:
+		return scoreInvalidTx, "invalid tx"
+	}
+}
+
+// remoteAddr 请求来源 IP
+func remoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// peerHost peer URL 的主机部分，与请求来源 IP 一样作为封禁键
+func peerHost(peerURL string) string {
+	u, err := url.Parse(peerURL)
+	if err != nil || u.Hostname() == "" {
+		return peerURL
+	}
+	return u.Hostname()
+}
+
+// isLoopback 请求是否来自本机
+func isLoopback(r *http.Request) bool {
+	ip := net.ParseIP(remoteAddr(r))
+	return ip != nil && ip.IsLoopback()
+}
+
+// misbehaving 记录请求来源的不当行为
+func (s *NodeServer) misbehaving(r *http.Request, score int, reason string) {
+	s.Bans.Misbehaving(remoteAddr(r), score, reason)
+}
+
+// rejectBody 处理请求体解析错误：超出大小上限返回 413，否则 400，并记录相应分值
+func (s *NodeServer) rejectBody(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.misbehaving(r, scoreOversized, "oversized message")
+		http.Error(w, "message too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	s.misbehaving(r, scoreBadMessage, "bad message")
+	http.Error(w, "bad json", http.StatusBadRequest)
+}
+
+// withBans 拒绝被封禁地址的请求；管理接口仅限本机访问，不受封禁影响
+func (s *NodeServer) withBans(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Bans.IsBanned(remoteAddr(r)) && !isLoopbackAdmin(r) {
+			http.Error(w, "banned", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isLoopbackAdmin(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/admin/") && isLoopback(r)
+}
+
+// handleAdminBans 本机管理接口：GET /admin/bans 列出封禁与分值；DELETE /admin/bans?addr=IP 解除封禁
+func (s *NodeServer) handleAdminBans(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r) {
+		http.Error(w, "admin endpoint is loopback only", http.StatusForbidden)
+		return
+	}
+	if s.Bans == nil {
+		http.Error(w, "ban manager disabled", http.StatusServiceUnavailable)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		bans, scores := s.Bans.Bans()
+		writeJSON(w, BansResponse{Bans: bans, Scores: scores})
+	case http.MethodDelete:
+		addr := r.URL.Query().Get("addr")
+		if addr == "" {
+			http.Error(w, "missing addr", http.StatusBadRequest)
+			return
+		}
+		if !s.Bans.Unban(addr) {
+			http.Error(w, "not banned", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,173 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yiqi-017/blockchain/core"
+)
+
+// TestBanManager 分值累计到阈值即封禁，封禁列表重启后仍生效，到期自动解除
+func TestBanManager(t *testing.T) {
+	store := mustStore(t, t.TempDir(), "bans")
+	now := time.Now()
+	bans, err := NewBanManager(store, time.Hour)
+	if err != nil {
+		t.Fatalf("new ban manager: %v", err)
+	}
+	bans.now = func() time.Time { return now }
+
+	if bans.Misbehaving("10.0.0.1", 60, "test") || bans.IsBanned("10.0.0.1") {
+		t.Fatalf("score below threshold must not ban")
+	}
+	if !bans.Misbehaving("10.0.0.1", 40, "test") || !bans.IsBanned("10.0.0.1") {
+		t.Fatalf("score reaching threshold must ban")
+	}
+	bans.Misbehaving("10.0.0.2", 30, "test")
+
+	reloaded, err := NewBanManager(store, time.Hour)
+	if err != nil {
+		t.Fatalf("reload ban manager: %v", err)
+	}
+	reloaded.now = func() time.Time { return now.Add(30 * time.Minute) }
+	list, _ := reloaded.Bans()
+	if len(list) != 1 || list[0].Addr != "10.0.0.1" || !reloaded.IsBanned("10.0.0.1") {
+		t.Fatalf("ban not persisted: %+v", list)
+	}
+	reloaded.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if reloaded.IsBanned("10.0.0.1") {
+		t.Fatalf("ban should expire")
+	}
+	if records, err := store.LoadBans(); err != nil || len(records) != 0 {
+		t.Fatalf("expired ban should be removed from disk: %v %v", records, err)
+	}
+}
+
+// TestMisbehaviorBan 无效工作量证明立即封禁；远程覆盖交易池、超大消息累计计分；分叉区块不计分；
+// 管理接口仅限本机，可列出并解除封禁
+func TestMisbehaviorBan(t *testing.T) {
+	chain := buildChain(nil, 2, "alice")
+	store := mustStore(t, t.TempDir(), "node")
+	for _, b := range chain {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+	bans, err := NewBanManager(store, time.Hour)
+	if err != nil {
+		t.Fatalf("new ban manager: %v", err)
+	}
+	h := (&NodeServer{NodeID: "node", Store: store, Bans: bans}).Handler()
+	do := func(method, path, remote string, body []byte) int {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.RemoteAddr = remote + ":40000"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	postBlock := func(remote string, b *core.Block) int {
+		body, _ := json.Marshal(BlockResponse{Block: b})
+		return do(http.MethodPost, "/block", remote, body)
+	}
+
+	bad := buildChain(chain, 1, "mallory")[2]
+	bad.Header.Difficulty = 200
+	if code := postBlock("10.0.0.1", bad); code != http.StatusBadRequest {
+		t.Fatalf("invalid pow block: status %d", code)
+	}
+	if code := do(http.MethodGet, "/status", "10.0.0.1", nil); code != http.StatusForbidden {
+		t.Fatalf("banned peer should be rejected, got %d", code)
+	}
+
+	fork := buildChain(chain[:1], 1, "bob")[1]
+	if code := postBlock("10.0.0.3", fork); code != http.StatusBadRequest {
+		t.Fatalf("fork block: status %d", code)
+	}
+	if code := do(http.MethodPost, "/txpool", "10.0.0.2", []byte(`{"entries":{}}`)); code != http.StatusForbidden {
+		t.Fatalf("remote txpool overwrite: status %d", code)
+	}
+	huge := []byte(`{"id":"` + strings.Repeat("a", maxTxMessageBytes) + `"}`)
+	if code := do(http.MethodPost, "/tx", "10.0.0.2", huge); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized tx: status %d", code)
+	}
+	if code := do(http.MethodPost, "/txpool", "127.0.0.1", []byte(`{"entries":{}}`)); code != http.StatusNoContent {
+		t.Fatalf("local txpool overwrite: status %d", code)
+	}
+
+	if code := do(http.MethodGet, "/admin/bans", "10.0.0.2", nil); code != http.StatusForbidden {
+		t.Fatalf("remote admin access: status %d", code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/admin/bans", nil)
+	req.RemoteAddr = "127.0.0.1:40000"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var resp BansResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode bans: %v", err)
+	}
+	if len(resp.Bans) != 1 || resp.Bans[0].Addr != "10.0.0.1" || resp.Bans[0].Reason != "invalid pow" {
+		t.Fatalf("unexpected bans: %+v", resp.Bans)
+	}
+	if resp.Scores["10.0.0.2"] != scorePoolOverwrite+scoreOversized || resp.Scores["10.0.0.3"] != 0 {
+		t.Fatalf("unexpected scores: %v", resp.Scores)
+	}
+
+	if code := do(http.MethodDelete, "/admin/bans?addr=10.0.0.1", "127.0.0.1", nil); code != http.StatusNoContent {
+		t.Fatalf("unban: status %d", code)
+	}
+	if code := do(http.MethodGet, "/status", "10.0.0.1", nil); code != http.StatusOK {
+		t.Fatalf("unbanned peer: status %d", code)
+	}
+}
+
+// TestMisbehaviorScope 只为可证明无效的区块计分：时间戳超前与本地读写错误不计分；被封禁主机上的 peer 不参与同步
+func TestMisbehaviorScope(t *testing.T) {
+	chain := buildChain(nil, 2, "alice")
+	store := mustStore(t, t.TempDir(), "scope")
+	for _, b := range chain {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+	future := buildChain(chain, 1, "bob")[2]
+	future.Header.Timestamp = time.Now().Add(time.Hour).Unix()
+	err := validateAndPersistBlock(store, future, core.DefaultChainParams())
+	if score, _ := blockMisbehavior(err); err == nil || score != 0 {
+		t.Fatalf("future timestamp should be rejected without score, got %v score %d", err, score)
+	}
+	if score, _ := blockMisbehavior(fs.ErrPermission); score != 0 {
+		t.Fatalf("local io error should not be scored")
+	}
+	overpaid := core.MineBlock(chain[1], []*core.Transaction{core.NewCoinbaseTx("mallory", core.BlockSubsidy+1, 2)}, 0)
+	overpaid.Header.Timestamp = chain[1].Header.Timestamp + 1
+	err = validateAndPersistBlock(store, overpaid, core.DefaultChainParams())
+	if score, _ := blockMisbehavior(err); score != scoreInvalidBlock {
+		t.Fatalf("overpaying coinbase should be scored, got %v score %d", err, score)
+	}
+
+	bans, err := NewBanManager(nil, time.Hour)
+	if err != nil {
+		t.Fatalf("new ban manager: %v", err)
+	}
+	bans.Misbehaving("10.0.0.9", banThreshold, "test")
+	m := NewPeerManager([]string{"http://10.0.0.9:8080"})
+	m.Bans = bans
+	m.Add("http://10.0.0.9:8081")
+	m.RecordSuccess("http://10.0.0.9:8080", 5, nil, time.Millisecond)
+	if len(m.Available()) != 0 {
+		t.Fatalf("banned peers should not be available")
+	}
+	if _, ok := m.Best(); ok {
+		t.Fatalf("banned peer should not be best")
+	}
+	m.Maintain(NewAddrBook("", nil), 0, "")
+	if urls := m.URLs(); len(urls) != 1 || urls[0] != "http://10.0.0.9:8080" {
+		t.Fatalf("banned discovered peer should be dropped, pinned kept: %v", urls)
+	}
+}
@@ -1,6 +1,9 @@
 package network
 
 import (
+	"bytes"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,9 +15,9 @@ func TestRejectFutureTimestamp(t *testing.T) {
 	base := t.TempDir()
 	store := mustStore(t, base, "ts")
 
-	block := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("miner", 50)}, 0)
+	block := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("miner", 50, 0)}, 0)
 	block.Header.Timestamp = time.Now().Add(5 * time.Minute).Unix()
-	if err := validateAndPersistBlock(store, block); err == nil {
+	if err := validateAndPersistBlock(store, block, core.DefaultChainParams()); err == nil {
 		t.Fatalf("expected future timestamp block to be rejected")
 	}
 }
@@ -24,22 +27,80 @@ func TestGenesisMismatchReorg(t *testing.T) {
 	base := t.TempDir()
 	store := mustStore(t, base, "genesis")
 
-	localGenesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50)}, 0)
+	localGenesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50, 0)}, 0)
 	if err := store.SaveBlock(localGenesis); err != nil {
 		t.Fatalf("save local genesis: %v", err)
 	}
 
-	peerGenesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerB", 50)}, 0)
-	peerBlock1 := core.MineBlock(peerGenesis, []*core.Transaction{core.NewCoinbaseTx("minerB", 50)}, 0)
-	peerBlocks := []*core.Block{peerGenesis, peerBlock1}
+	peerGenesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerB", 50, 0)}, 0)
+	peerBlock1 := core.MineBlock(peerGenesis, []*core.Transaction{core.NewCoinbaseTx("minerB", 50, 1)}, 0)
+	peer := serveChain(t, "peer", []*core.Block{peerGenesis, peerBlock1})
 
-	s := NewSyncer("peer")
-	s.fetchBlockFn = func(height uint64) (*core.Block, error) {
-		return peerBlocks[height], nil
+	if err := NewSyncer(peer.URL).SyncBlocks(store); err == nil {
+		t.Fatalf("expected reorg to fail due to genesis mismatch")
 	}
+	assertSameChain(t, store, []*core.Block{localGenesis})
+}
 
-	if err := s.reorgFromPeer(store, 1); err == nil {
-		t.Fatalf("expected reorg to fail due to genesis mismatch")
+// TestRejectInvalidForkReorg 对端分叉更长但含无效区块体（coinbase 超额）时不重组，本地链保持不变
+func TestRejectInvalidForkReorg(t *testing.T) {
+	store := mustStore(t, t.TempDir(), "fork")
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50, 0)}, 0)
+	local1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("minerA", 50, 1)}, 0)
+	for _, b := range []*core.Block{genesis, local1} {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save local block: %v", err)
+		}
+	}
+
+	peer1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("mallory", 50, 1)}, 0)
+	peer2 := core.MineBlock(peer1, []*core.Transaction{core.NewCoinbaseTx("mallory", core.BlockSubsidy+1, 2)}, 0)
+	peer := serveChain(t, "peer", []*core.Block{genesis, peer1, peer2})
+	if err := NewSyncer(peer.URL).SyncBlocks(store); err == nil {
+		t.Fatalf("expected reorg to an invalid fork to fail")
 	}
+	assertSameChain(t, store, []*core.Block{genesis, local1})
 }
 
+// TestRejectCoinbaseHeightMismatch coinbase 承诺高度与区块高度不一致时拒绝
+func TestRejectCoinbaseHeightMismatch(t *testing.T) {
+	base := t.TempDir()
+	store := mustStore(t, base, "cbheight")
+
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("miner", 50, 0)}, 0)
+	if err := validateAndPersistBlock(store, genesis, core.DefaultChainParams()); err != nil {
+		t.Fatalf("persist genesis: %v", err)
+	}
+	// 复用高度 0 的承诺挖高度 1 的区块（难度 0，直接调整时间戳不影响 POW）
+	block1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("miner", 50, 0)}, 0)
+	block1.Header.Timestamp = genesis.Header.Timestamp + 1
+	err := validateAndPersistBlock(store, block1, core.DefaultChainParams())
+	if err == nil || !strings.Contains(err.Error(), "coinbase") {
+		t.Fatalf("expected coinbase height mismatch to be rejected, got %v", err)
+	}
+}
+
+// TestRejectMutatedBlock 重复尾交易的变形区块与原区块 Merkle 根相同，应被拒绝且不影响随后接收原区块
+func TestRejectMutatedBlock(t *testing.T) {
+	store := mustStore(t, t.TempDir(), "mutated")
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("miner", 50, 0)}, 0)
+	if err := validateAndPersistBlock(store, genesis, core.DefaultChainParams()); err != nil {
+		t.Fatalf("persist genesis: %v", err)
+	}
+	// 无输入、零金额的交易无需签名即可通过校验
+	x := &core.Transaction{Outputs: []core.TxOutput{{Value: 0, ScriptPubKey: "x"}}}
+	y := &core.Transaction{Outputs: []core.TxOutput{{Value: 0, ScriptPubKey: "y"}}}
+	block1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("miner", 50, 1), x, y}, 0)
+	block1.Header.Timestamp = genesis.Header.Timestamp + 1
+
+	mutated := &core.Block{Header: block1.Header, Transactions: append(append([]*core.Transaction(nil), block1.Transactions...), y)}
+	if !bytes.Equal(core.ComputeMerkleRoot(mutated.Transactions), block1.Header.MerkleRoot) {
+		t.Fatalf("mutated block should share the merkle root")
+	}
+	if err := validateAndPersistBlock(store, mutated, core.DefaultChainParams()); !errors.Is(err, core.ErrMerkleMutated) {
+		t.Fatalf("expected ErrMerkleMutated, got %v", err)
+	}
+	if err := validateAndPersistBlock(store, block1, core.DefaultChainParams()); err != nil {
+		t.Fatalf("original block should still be accepted: %v", err)
+	}
+}
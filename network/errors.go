@@ -3,3 +3,22 @@ package network
 import "errors"
 
 var errConflictBlock = errors.New("block conflict at height")
+
+// 区块校验失败的类别，用于给发送方记录不当行为分值
+var (
+	errInvalidPOW = errors.New("pow invalid")
+	errBadMerkle  = errors.New("invalid block transactions")
+	errInvalidTx  = errors.New("tx invalid")
+	// errInvalidBlock 其他可证明无效的区块：coinbase、时间戳不递增、创世块前序哈希非空
+	errInvalidBlock = errors.New("block invalid")
+)
+
+// 线路协议帧错误，收到时断开连接并记录不当行为分值
+var (
+	errBadMagic     = errors.New("wire: bad magic")
+	errBadChecksum  = errors.New("wire: bad checksum")
+	errBadCommand   = errors.New("wire: bad command")
+	errWireTooLarge = errors.New("wire: message too large")
+	errHandshake    = errors.New("wire: handshake failed")
+	errBadPayload   = errors.New("wire: bad payload")
+)
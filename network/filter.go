@@ -0,0 +1,104 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// handleFilter GET /filter?height=H 返回该高度区块的紧凑过滤器；高度不存在返回 404
+func (s *NodeServer) handleFilter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	height, err := strconv.ParseUint(r.URL.Query().Get("height"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid height", http.StatusBadRequest)
+		return
+	}
+	entries, err := s.Store.BlockFilters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if height >= uint64(len(entries)) {
+		http.Error(w, "filter not found", http.StatusNotFound)
+		return
+	}
+	e := entries[height]
+	resp := FilterResponse{Height: e.Height, BlockHash: e.BlockHash, Filter: e.Filter, Header: e.Header}
+	if height > 0 {
+		resp.PrevHeader = entries[height-1].Header
+	}
+	writeJSON(w, resp)
+}
+
+// handleFilterHeaders GET /filterheaders?from=H&count=N 返回自高度 H 起最多 N 个过滤器头（from 默认 0，上限同 /headers）
+func (s *NodeServer) handleFilterHeaders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	var from uint64
+	if raw := q.Get("from"); raw != "" {
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+		from = n
+	}
+	count := maxHeadersPerRequest
+	if raw := q.Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid count", http.StatusBadRequest)
+			return
+		}
+		if n < count {
+			count = n
+		}
+	}
+	entries, err := s.Store.BlockFilters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := FilterHeadersResponse{Headers: []FilterHeaderEntry{}}
+	for h := from; h < uint64(len(entries)) && len(resp.Headers) < count; h++ {
+		e := entries[h]
+		resp.Headers = append(resp.Headers, FilterHeaderEntry{Height: e.Height, BlockHash: e.BlockHash, Header: e.Header})
+	}
+	writeJSON(w, resp)
+}
+
+// FetchFilter 向 peer 请求指定高度的紧凑过滤器（不做校验）
+func (s *Syncer) FetchFilter(height uint64) (*FilterResponse, error) {
+	resp, err := s.get(fmt.Sprintf("/filter?height=%d", height))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out FilterResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FetchFilterHeaders 向 peer 请求自 from 起最多 count 个过滤器头
+func (s *Syncer) FetchFilterHeaders(from uint64, count int) ([]FilterHeaderEntry, error) {
+	resp, err := s.get(fmt.Sprintf("/filterheaders?from=%d&count=%d", from, count))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out FilterHeadersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out.Headers, nil
+}
@@ -0,0 +1,85 @@
+package network
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+)
+
+// TestFilterEndpoints /filter 与 /filterheaders 返回的过滤器可本地匹配并串成过滤器头链；替换区块后过滤器随之更新
+func TestFilterEndpoints(t *testing.T) {
+	base := t.TempDir()
+	store := mustStore(t, base, "filter")
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 0)}, 0)
+	pay := &core.Transaction{
+		Inputs:  []core.TxInput{{TxID: core.ComputeTxID(genesis.Transactions[0]), Vout: 0}},
+		Outputs: []core.TxOutput{{Value: 50, ScriptPubKey: "addr2"}},
+	}
+	block1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 1), pay}, 0)
+	for _, b := range []*core.Block{genesis, block1} {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+	srv := httptest.NewServer((&NodeServer{NodeID: "filter", Store: store}).Handler())
+	t.Cleanup(func() { srv.Close() })
+	syncer := NewSyncer(srv.URL)
+
+	headers, err := syncer.FetchFilterHeaders(0, 10)
+	if err != nil || len(headers) != 2 {
+		t.Fatalf("filter headers: %v %v", headers, err)
+	}
+	var prev []byte
+	for _, h := range headers {
+		f, err := syncer.FetchFilter(h.Height)
+		if err != nil {
+			t.Fatalf("fetch filter %d: %v", h.Height, err)
+		}
+		if !bytes.Equal(f.PrevHeader, prev) || !bytes.Equal(core.FilterHeader(f.Filter, prev), h.Header) {
+			t.Fatalf("filter %d does not chain to its header", h.Height)
+		}
+		prev = h.Header
+	}
+	f, err := syncer.FetchFilter(1)
+	if err != nil {
+		t.Fatalf("fetch filter: %v", err)
+	}
+	spent := core.FilterOutpointElement(core.ComputeTxID(genesis.Transactions[0]), 0)
+	for _, item := range [][]byte{core.FilterScriptElement("addr2"), spent} {
+		if ok, err := core.FilterMatchAny(f.Filter, f.BlockHash, [][]byte{item}); err != nil || !ok {
+			t.Fatalf("block 1 filter should match %q: %v", item, err)
+		}
+	}
+	if _, err := syncer.FetchFilter(2); err == nil {
+		t.Fatalf("filter above tip should be 404")
+	}
+
+	// 同高度换成另一区块：过滤器与过滤器头都随之更新
+	replaced := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("addr3", 50, 1)}, 0)
+	if err := store.SaveBlock(replaced); err != nil {
+		t.Fatalf("replace block: %v", err)
+	}
+	f, err = syncer.FetchFilter(1)
+	if err != nil {
+		t.Fatalf("fetch replaced filter: %v", err)
+	}
+	if ok, _ := core.FilterMatchAny(f.Filter, f.BlockHash, [][]byte{core.FilterScriptElement("addr3")}); !ok {
+		t.Fatalf("replaced filter should match the new coinbase")
+	}
+	if bytes.Equal(f.Header, headers[1].Header) {
+		t.Fatalf("filter header should change with the block")
+	}
+
+	// 过滤器文件丢失时从区块重建
+	if err := os.Remove(filepath.Join(base, "filter", "index", "blocks.log")); err != nil {
+		t.Fatalf("remove filters: %v", err)
+	}
+	rebuilt, err := syncer.FetchFilterHeaders(1, 1)
+	if err != nil || len(rebuilt) != 1 || !bytes.Equal(rebuilt[0].Header, f.Header) {
+		t.Fatalf("rebuilt filter headers: %v %v", rebuilt, err)
+	}
+}
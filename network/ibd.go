@@ -0,0 +1,434 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"sync"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+const (
+	// defaultDownloadWindow 区块下载滑动窗口：最多领先待接入高度这么多个区块
+	defaultDownloadWindow = 64
+	// workersPerPeer 每个 peer 并发拉取区块的连接数
+	workersPerPeer = 4
+	// medianTimeWindow 与 core.MedianTimePast 一致，只需保留最近 11 个区块
+	medianTimeWindow = 11
+)
+
+// 同步阶段
+const (
+	SyncStageIdle    = "idle"
+	SyncStageHeaders = "headers"
+	SyncStageBlocks  = "blocks"
+)
+
+// SyncStatus 同步进度快照，由 /status 返回
+type SyncStatus struct {
+	Stage        string `json:"stage"`
+	Peer         string `json:"peer,omitempty"`
+	Peers        int    `json:"peers"`
+	HeaderHeight uint64 `json:"header_height"`
+	BlockHeight  uint64 `json:"block_height"`
+	TargetHeight uint64 `json:"target_height"`
+}
+
+// SyncProgress 在下载过程与 HTTP 服务之间共享同步进度，可为 nil
+type SyncProgress struct {
+	mu     sync.Mutex
+	status SyncStatus
+}
+
+// Snapshot 返回当前进度
+func (p *SyncProgress) Snapshot() SyncStatus {
+	if p == nil {
+		return SyncStatus{Stage: SyncStageIdle}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.status.Stage == "" {
+		return SyncStatus{Stage: SyncStageIdle}
+	}
+	return p.status
+}
+
+func (p *SyncProgress) update(fn func(*SyncStatus)) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(&p.status)
+}
+
+// BlockDownload 头优先同步：先从累计工作量最大的 peer 下载并校验区块头链，再从全部 peer 并行拉取区块体，
+// 按高度顺序接入；区块体须与已验证区块头一致，交易以内存中增量维护的 UTXO 集校验
+type BlockDownload struct {
+	Peers    []*Syncer
+	Window   int           // 滑动窗口，<=0 时取默认值
+	Progress *SyncProgress // 可为 nil
+	// OnPeerError 某个 peer 的区块拉取失败或区块与区块头不符时回调，可为 nil
+	OnPeerError func(peer string, err error)
+	// Params 共识参数，nil 时取默认值
+	Params *core.ChainParams
+	// Bans 非 nil 时跳过被封禁主机上的 peer，区块头有效但区块体无效的 peer 计入不当行为分值
+	Bans *BanManager
+
+	// chainMu 非 nil 时接入每个区块与重组都持有该锁，与节点的其他写入方互斥；区块拉取不持锁
+	chainMu *sync.Mutex
+}
+
+// fetchedBlock 下载结果；err 非空时该 worker 已退出
+type fetchedBlock struct {
+	height uint64
+	block  *core.Block
+	peer   string
+	err    error
+}
+
+// Run 同步到 peer 中累计工作量最大的链；本地链与其分叉时回退到分叉点后切换
+func (d *BlockDownload) Run(store *storage.FileStorage) error {
+	defer d.Progress.update(func(st *SyncStatus) { st.Stage = SyncStageIdle })
+
+	best, tip, work, err := d.bestPeer()
+	if err != nil {
+		return err
+	}
+	local, err := store.ChainWork()
+	if err != nil {
+		return err
+	}
+	if work.Cmp(local) <= 0 {
+		return nil // 无需同步
+	}
+	blocks, err := loadAllBlocks(store)
+	if err != nil {
+		return err
+	}
+
+	d.Progress.update(func(st *SyncStatus) {
+		*st = SyncStatus{Stage: SyncStageHeaders, Peer: best.Peer, Peers: len(d.Peers), TargetHeight: tip}
+		if len(blocks) > 0 {
+			st.HeaderHeight = uint64(len(blocks) - 1)
+			st.BlockHeight = st.HeaderHeight
+		}
+	})
+	fork, headers, err := d.downloadHeaders(best, blocks, tip)
+	if err != nil {
+		return err
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	d.Progress.update(func(st *SyncStatus) { st.Stage = SyncStageBlocks })
+	if len(blocks) > 0 && fork < uint64(len(blocks)-1) {
+		return d.reorg(store, blocks, fork, headers)
+	}
+	return d.downloadBlocks(store, blocks, headers)
+}
+
+// bestPeer 返回声明累计工作量最大的可达 peer 及其高度与工作量；声明由随后下载的区块头链校验
+func (d *BlockDownload) bestPeer() (*Syncer, uint64, *big.Int, error) {
+	var best *Syncer
+	var tip uint64
+	var work *big.Int
+	var lastErr error
+	for _, p := range d.Peers {
+		if d.Bans.IsBanned(peerHost(p.Peer)) {
+			continue
+		}
+		status, err := p.fetchStatus()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if w := statusWork(status); best == nil || w.Cmp(work) > 0 {
+			best, tip, work = p, status.Height, w
+		}
+	}
+	if best == nil {
+		if lastErr == nil {
+			lastErr = errors.New("no peers")
+		}
+		return nil, 0, nil, lastErr
+	}
+	return best, tip, work, nil
+}
+
+// downloadHeaders 找到本地链与 peer 的分叉点，自分叉点起拉取 peer 的区块头并逐个校验链接、时间戳、难度下限与工作量证明，
+// 返回分叉点与其后的区块头；本地无区块时以 peer 的创世区块头为锚点，返回全部区块头
+// peer 链分叉后的累计工作量不高于本地时返回错误，不切换
+func (d *BlockDownload) downloadHeaders(peer *Syncer, local []*core.Block, tip uint64) (uint64, []core.BlockHeader, error) {
+	minDifficulty := chainParams(d.Params).MinDifficulty
+	var chain, candidate *core.HeaderChain
+	var fork, from uint64
+	if len(local) > 0 {
+		var err error
+		if chain, err = core.NewHeaderChain(local[0].Header, minDifficulty); err != nil {
+			return 0, nil, err
+		}
+		for _, b := range local[1:] {
+			if err := chain.Append(b.Header); err != nil {
+				return 0, nil, fmt.Errorf("local header %d: %w", b.Header.Height, err)
+			}
+		}
+		if fork, err = findFork(peer, chain, chain.Height()); err != nil {
+			return 0, nil, err
+		}
+		// 在副本上回退到分叉点并接入 peer 的区块头，全部校验通过且工作量更大才切换
+		candidate = chain.Clone()
+		candidate.Truncate(fork)
+		from = fork + 1
+	}
+	for from <= tip {
+		headers, err := peer.FetchHeaders(from, maxHeadersPerRequest)
+		if err != nil {
+			return 0, nil, fmt.Errorf("fetch headers from %d: %w", from, err)
+		}
+		if len(headers) == 0 {
+			break
+		}
+		for _, h := range headers {
+			if candidate == nil {
+				if h.Difficulty < minDifficulty {
+					return 0, nil, fmt.Errorf("genesis difficulty %d below minimum %d", h.Difficulty, minDifficulty)
+				}
+				if candidate, err = core.NewHeaderChain(h, minDifficulty); err != nil {
+					return 0, nil, err
+				}
+				continue
+			}
+			if err := candidate.Append(h); err != nil {
+				return 0, nil, err
+			}
+		}
+		from += uint64(len(headers))
+		height := candidate.Height()
+		d.Progress.update(func(st *SyncStatus) { st.HeaderHeight = height })
+	}
+	if candidate == nil {
+		return 0, nil, errors.New("peer returned no headers")
+	}
+	all := candidate.Headers()
+	if chain == nil {
+		return 0, all, nil
+	}
+	if fork == chain.Height() && candidate.Height() == fork {
+		return fork, nil, nil // peer 在本地链尖之后没有新区块
+	}
+	if candidate.Work().Cmp(chain.Work()) <= 0 {
+		return 0, nil, fmt.Errorf("peer chain (height %d, work %s) does not exceed local chain (height %d, work %s)",
+			candidate.Height(), candidate.Work(), chain.Height(), chain.Work())
+	}
+	return fork, all[fork+1:], nil
+}
+
+// downloadBlocks 并行拉取 headers 对应的区块，按高度顺序校验后逐块接入本地链
+func (d *BlockDownload) downloadBlocks(store *storage.FileStorage, local []*core.Block, headers []core.BlockHeader) error {
+	utxos := core.BuildUTXOSet(local)
+	recent := local
+	if len(recent) > medianTimeWindow {
+		recent = append([]*core.Block(nil), recent[len(recent)-medianTimeWindow:]...)
+	}
+	return d.fetchBlocks(headers, func(b *core.Block) error {
+		if err := d.connectBlock(store, b, utxos, recent); err != nil {
+			return err
+		}
+		if recent = append(recent, b); len(recent) > medianTimeWindow {
+			recent = recent[1:]
+		}
+		return nil
+	})
+}
+
+// reorg 切换到分叉点之后累计工作量更大的 peer 链：并行拉取分叉点之上的区块，以分叉点处的 UTXO 集逐块完整校验，
+// 全部通过后在链锁内回退本地链到分叉点并写入新区块；写入失败时恢复原区块，无效的分叉不会改动本地链
+func (d *BlockDownload) reorg(store *storage.FileStorage, local []*core.Block, fork uint64, headers []core.BlockHeader) error {
+	params := chainParams(d.Params)
+	base := local[:fork+1]
+	utxos := core.BuildUTXOSet(base)
+	chain := append([]*core.Block(nil), base...)
+	spent := make([]map[storage.Outpoint]core.TxOutput, 0, len(headers))
+	d.Progress.update(func(st *SyncStatus) { st.BlockHeight = fork })
+	err := d.fetchBlocks(headers, func(b *core.Block) error {
+		// 被花费输出须在校验把区块应用到 utxos 之前取出，供地址索引使用
+		s := storage.SpentOutputs(b, utxos)
+		if err := validateBlockBody(b, utxos, core.MedianTimePast(chain), params); err != nil {
+			return fmt.Errorf("validate block %d: %w", b.Header.Height, err)
+		}
+		chain = append(chain, b)
+		spent = append(spent, s)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	unlock := lockChain(d.chainMu)
+	defer unlock()
+	// 拉取期间本地链可能已被推送的区块改变，此时放弃，由下一轮同步重新比较
+	tip := local[len(local)-1]
+	if heights, err := store.ListBlockHeights(); err != nil {
+		return err
+	} else if len(heights) != len(local) || heights[len(heights)-1] != tip.Header.Height {
+		return fmt.Errorf("%w: local chain changed during reorg", errConflictBlock)
+	}
+	if current, err := store.LoadBlock(tip.Header.Height); err != nil {
+		return err
+	} else if !bytes.Equal(core.HashBlockHeader(&current.Header), core.HashBlockHeader(&tip.Header)) {
+		return fmt.Errorf("%w: local chain changed during reorg", errConflictBlock)
+	}
+	if err := switchChain(store, fork, local[fork+1:], chain[fork+1:], spent); err != nil {
+		return fmt.Errorf("reorg at fork %d: %w", fork, err)
+	}
+	// 被回滚的区块奖励等可能使池中交易失效，按新链重新校验
+	revalidateTxPool(store, chain, params)
+	return nil
+}
+
+// switchChain 把本地链回退到分叉点并写入新区块，任何一步失败都回退到分叉点并重新写入原区块
+func switchChain(store *storage.FileStorage, fork uint64, old, blocks []*core.Block, spent []map[storage.Outpoint]core.TxOutput) error {
+	err := store.DisconnectBlocks(fork)
+	for i := 0; err == nil && i < len(blocks); i++ {
+		err = store.ConnectBlock(blocks[i], spent[i])
+	}
+	if err == nil {
+		return nil
+	}
+	rerr := store.DisconnectBlocks(fork)
+	for i := 0; rerr == nil && i < len(old); i++ {
+		// 回滚少见，被花费输出直接取自已恢复部分的地址索引
+		rerr = store.SaveBlock(old[i])
+	}
+	if rerr != nil {
+		return fmt.Errorf("%w (restore local chain: %v)", err, rerr)
+	}
+	return err
+}
+
+// fetchBlocks 以滑动窗口把区块高度分派给各 peer 的 worker 并行拉取，按高度顺序交给 accept；accept 出错时中止
+// 某个 worker 拉取失败或返回与区块头不符的区块时退出，其高度交给其他 worker 重试；区块体无效的 peer 计入不当行为分值
+func (d *BlockDownload) fetchBlocks(headers []core.BlockHeader, accept func(b *core.Block) error) error {
+	window := d.Window
+	if window <= 0 {
+		window = defaultDownloadWindow
+	}
+	first := headers[0].Height
+	last := headers[len(headers)-1].Height
+
+	jobs := make(chan uint64)
+	results := make(chan fetchedBlock)
+	done := make(chan struct{})
+	defer close(done)
+	workers := 0
+	for _, p := range d.Peers {
+		for i := 0; i < workersPerPeer; i++ {
+			workers++
+			go func(p *Syncer) {
+				for h := range jobs {
+					var b *core.Block
+					var err error
+					if d.Bans.IsBanned(peerHost(p.Peer)) {
+						err = errors.New("peer banned")
+					} else {
+						b, err = p.fetchBlockInternal(h)
+					}
+					if err == nil && !bytes.Equal(core.HashBlockHeader(&b.Header), core.HashBlockHeader(&headers[h-first])) {
+						err = fmt.Errorf("block %d does not match its header", h)
+					}
+					select {
+					case results <- fetchedBlock{height: h, block: b, peer: p.Peer, err: err}:
+					case <-done:
+						return
+					}
+					if err != nil {
+						return
+					}
+				}
+			}(p)
+		}
+	}
+	defer close(jobs)
+
+	pending := make(map[uint64]fetchedBlock)
+	var retry []uint64
+	next, dispatch := first, first
+	var lastErr error
+	for next <= last {
+		var send chan uint64
+		var job uint64
+		if len(retry) > 0 {
+			send, job = jobs, retry[0]
+		} else if dispatch <= last && dispatch < next+uint64(window) {
+			send, job = jobs, dispatch
+		}
+		select {
+		case send <- job:
+			if len(retry) > 0 {
+				retry = retry[1:]
+			} else {
+				dispatch++
+			}
+		case r := <-results:
+			if r.err != nil {
+				lastErr = fmt.Errorf("peer %s: %w", r.peer, r.err)
+				if d.OnPeerError != nil {
+					d.OnPeerError(r.peer, r.err)
+				}
+				retry = append(retry, r.height)
+				if workers--; workers == 0 {
+					return fmt.Errorf("block download stalled at %d: %w", next, lastErr)
+				}
+				continue
+			}
+			pending[r.height] = r
+			for f, ok := pending[next]; ok; f, ok = pending[next] {
+				delete(pending, next)
+				if err := accept(f.block); err != nil {
+					if score, reason := blockMisbehavior(err); score > 0 {
+						d.Bans.Misbehaving(peerHost(f.peer), score, reason)
+					}
+					return err
+				}
+				height := next
+				d.Progress.update(func(st *SyncStatus) { st.BlockHeight = height })
+				next++
+			}
+		}
+	}
+	return nil
+}
+
+// connectBlock 校验区块并接入本地链，更新 utxos；下载期间其他写入方（推送的区块）已接入同一区块时只更新 utxos，
+// 接入了不同区块时返回 errConflictBlock，由下一轮同步处理分叉
+func (d *BlockDownload) connectBlock(store *storage.FileStorage, b *core.Block, utxos map[string][]core.UTXO, recent []*core.Block) error {
+	unlock := lockChain(d.chainMu)
+	defer unlock()
+	height := b.Header.Height
+	existing, err := store.LoadBlock(height)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if existing != nil && !bytes.Equal(core.HashBlockHeader(&existing.Header), core.HashBlockHeader(&b.Header)) {
+		return fmt.Errorf("%w: local block %d changed during download", errConflictBlock, height)
+	}
+	// 被花费输出须在校验把区块应用到 utxos 之前取出，供地址索引使用
+	spent := storage.SpentOutputs(b, utxos)
+	if err := validateBlockBody(b, utxos, core.MedianTimePast(recent), chainParams(d.Params)); err != nil {
+		return fmt.Errorf("validate block %d: %w", height, err)
+	}
+	if existing != nil {
+		return nil
+	}
+	if err := store.ConnectBlock(b, spent); err != nil {
+		return err
+	}
+	pruneTxPool(store, b.Transactions)
+	return nil
+}
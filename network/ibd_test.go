@@ -0,0 +1,212 @@
+package network
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// buildChain 在 base 之后接 n 个难度 0 的区块，时间戳取过去的时间以免超出未来时间容忍
+func buildChain(base []*core.Block, n int, miner string) []*core.Block {
+	chain := append([]*core.Block(nil), base...)
+	start := time.Now().Unix() - 100000
+	for i := 0; i < n; i++ {
+		var prev *core.Block
+		height := uint64(len(chain))
+		if len(chain) > 0 {
+			prev = chain[len(chain)-1]
+		}
+		b := core.MineBlock(prev, []*core.Transaction{core.NewCoinbaseTx(miner, 50, height)}, 0)
+		b.Header.Timestamp = start + int64(height)
+		chain = append(chain, b)
+	}
+	return chain
+}
+
+func serveChain(t *testing.T, name string, blocks []*core.Block) *httptest.Server {
+	t.Helper()
+	store := mustStore(t, t.TempDir(), name)
+	for _, b := range blocks {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save %s block: %v", name, err)
+		}
+	}
+	srv := httptest.NewServer((&NodeServer{NodeID: name, Store: store}).Handler())
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func assertSameChain(t *testing.T, store *storage.FileStorage, want []*core.Block) {
+	t.Helper()
+	heights, err := store.ListBlockHeights()
+	if err != nil || len(heights) != len(want) {
+		t.Fatalf("expect %d blocks, got %d (%v)", len(want), len(heights), err)
+	}
+	for _, b := range want {
+		got, err := store.LoadBlock(b.Header.Height)
+		if err != nil || string(core.HashBlockHeader(&got.Header)) != string(core.HashBlockHeader(&b.Header)) {
+			t.Fatalf("block %d differs after sync", b.Header.Height)
+		}
+	}
+}
+
+// TestHeadersFirstDownload 从多个 peer 并行下载区块；返回错误区块或缺块的 peer 被弃用，进度随之更新；本地分叉时整链重组
+func TestHeadersFirstDownload(t *testing.T) {
+	longest := buildChain(nil, 150, "minerA")
+	fork := buildChain(longest[:1], 10, "minerB")
+	good1 := serveChain(t, "good1", longest)
+	good2 := serveChain(t, "good2", longest)
+	bad := serveChain(t, "bad", fork)
+
+	store := mustStore(t, t.TempDir(), "ibd")
+	progress := &SyncProgress{}
+	download := &BlockDownload{
+		Peers:    []*Syncer{NewSyncer(bad.URL), NewSyncer(good1.URL), NewSyncer(good2.URL)},
+		Window:   8,
+		Progress: progress,
+	}
+	if err := download.Run(store); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	assertSameChain(t, store, longest)
+	st := progress.Snapshot()
+	if st.Stage != SyncStageIdle || st.HeaderHeight != 149 || st.BlockHeight != 149 || st.TargetHeight != 149 || st.Peers != 3 {
+		t.Fatalf("progress after sync: %+v", st)
+	}
+
+	// 已同步时不再下载
+	if err := download.Run(store); err != nil {
+		t.Fatalf("resync: %v", err)
+	}
+
+	// 本地位于较短分叉：区块头不相连，退回整链重组
+	forked := mustStore(t, t.TempDir(), "forked")
+	for _, b := range fork {
+		if err := forked.SaveBlock(b); err != nil {
+			t.Fatalf("save fork block: %v", err)
+		}
+	}
+	if err := NewSyncer(good1.URL).SyncBlocks(forked); err != nil {
+		t.Fatalf("sync from fork: %v", err)
+	}
+	assertSameChain(t, forked, longest)
+
+	// 唯一的 peer 区块头来自主链、区块体来自分叉：区块与区块头不符，下载失败且不落盘
+	headerStore := mustStore(t, t.TempDir(), "headers")
+	bodyStore := mustStore(t, t.TempDir(), "bodies")
+	for i, b := range longest[:11] {
+		if err := headerStore.SaveBlock(b); err != nil {
+			t.Fatalf("save header block: %v", err)
+		}
+		if err := bodyStore.SaveBlock(fork[i]); err != nil {
+			t.Fatalf("save body block: %v", err)
+		}
+	}
+	headerSrv := &NodeServer{NodeID: "headers", Store: headerStore}
+	bodySrv := &NodeServer{NodeID: "bodies", Store: bodyStore}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", headerSrv.handleStatus)
+	mux.HandleFunc("/headers", headerSrv.handleHeaders)
+	mux.HandleFunc("/block", bodySrv.handleBlock)
+	liar := httptest.NewServer(mux)
+	t.Cleanup(func() { liar.Close() })
+
+	victim := mustStore(t, t.TempDir(), "victim")
+	if err := victim.SaveBlock(longest[0]); err != nil {
+		t.Fatalf("save genesis: %v", err)
+	}
+	if err := NewSyncer(liar.URL).SyncBlocks(victim); err == nil {
+		t.Fatalf("blocks that do not match their headers should be rejected")
+	}
+	assertSameChain(t, victim, longest[:1])
+}
+
+// TestDownloadConcurrentWriter 下载期间推送的区块已接入同一高度时不重复写入，接入了不同区块时中止并保留本地区块
+func TestDownloadConcurrentWriter(t *testing.T) {
+	chain := buildChain(nil, 4, "minerA")
+	fork := buildChain(chain[:3], 1, "minerB")
+	store := mustStore(t, t.TempDir(), "writer")
+	for _, b := range chain[:3] {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+	var mu sync.Mutex
+	d := &BlockDownload{chainMu: &mu}
+	utxos := core.BuildUTXOSet(chain[:2])
+	if err := d.connectBlock(store, chain[2], utxos, chain[:2]); err != nil {
+		t.Fatalf("block already connected by another writer: %v", err)
+	}
+	if _, ok := utxos[crypto.HexEncode(core.ComputeTxID(chain[2].Transactions[0]))]; !ok {
+		t.Fatalf("utxos should include the already connected block")
+	}
+
+	if err := store.SaveBlock(fork[3]); err != nil {
+		t.Fatalf("save fork block: %v", err)
+	}
+	if err := d.connectBlock(store, chain[3], utxos, chain[:3]); !errors.Is(err, errConflictBlock) {
+		t.Fatalf("expected errConflictBlock, got %v", err)
+	}
+	assertSameChain(t, store, fork)
+}
+
+// mineChain 在 base 之后按给定难度挖 n 个区块，时间戳取当前时间并保持递增
+func mineChain(base []*core.Block, n int, miner string, difficulty uint32) []*core.Block {
+	chain := append([]*core.Block(nil), base...)
+	for i := 0; i < n; i++ {
+		var prev *core.Block
+		height := uint64(len(chain))
+		if len(chain) > 0 {
+			prev = chain[len(chain)-1]
+		}
+		chain = append(chain, core.MineBlock(prev, []*core.Transaction{core.NewCoinbaseTx(miner, 50, height)}, difficulty))
+	}
+	return chain
+}
+
+// TestSyncByChainWork 选累计工作量最大而非最高的 peer 同步；低于难度下限的区块头与区块被拒绝
+func TestSyncByChainWork(t *testing.T) {
+	genesis := mineChain(nil, 1, "genesis", 4)
+	light := mineChain(genesis, 8, "minerA", 0) // 高度 8，工作量 16+8
+	heavy := mineChain(genesis, 2, "minerB", 4) // 高度 2，工作量 16+32
+	lightSrv := serveChain(t, "light", light)
+	heavySrv := serveChain(t, "heavy", heavy)
+
+	status, err := NewSyncer(heavySrv.URL).fetchStatus()
+	if err != nil || status.Height != 2 || status.Work != "48" {
+		t.Fatalf("status should report chain work: %+v %v", status, err)
+	}
+
+	store := mustStore(t, t.TempDir(), "work")
+	d := &BlockDownload{Peers: []*Syncer{NewSyncer(lightSrv.URL), NewSyncer(heavySrv.URL)}}
+	if err := d.Run(store); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	assertSameChain(t, store, heavy)
+	if work, err := store.ChainWork(); err != nil || work.Int64() != 48 {
+		t.Fatalf("chain work after sync: %v %v", work, err)
+	}
+
+	params := core.ChainParams{CoinbaseMaturity: core.DefaultCoinbaseMaturity, MinDifficulty: 4}
+	strict := mustStore(t, t.TempDir(), "strict")
+	d = &BlockDownload{Peers: []*Syncer{NewSyncer(lightSrv.URL)}, Params: &params}
+	if err := d.Run(strict); err == nil {
+		t.Fatalf("headers below the minimum difficulty should be rejected")
+	}
+	if heights, _ := strict.ListBlockHeights(); len(heights) != 0 {
+		t.Fatalf("no blocks should be written from a chain below the minimum difficulty: %v", heights)
+	}
+	if err := strict.SaveBlock(genesis[0]); err != nil {
+		t.Fatalf("save genesis: %v", err)
+	}
+	if err := validateAndPersistBlock(strict, light[1], params); !errors.Is(err, errInvalidBlock) {
+		t.Fatalf("pushed block below the minimum difficulty should be invalid, got %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/wallet"
+)
+
+// maxHeadersPerRequest 单次 /headers 返回的区块头上限
+const maxHeadersPerRequest = 2000
+
+// handleHeaders GET /headers?from=H&count=N 返回自高度 H 起最多 N 个区块头（默认与上限均为 2000）
+func (s *NodeServer) handleHeaders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	from, err := strconv.ParseUint(q.Get("from"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid from", http.StatusBadRequest)
+		return
+	}
+	count := maxHeadersPerRequest
+	if raw := q.Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid count", http.StatusBadRequest)
+			return
+		}
+		if n < count {
+			count = n
+		}
+	}
+	tip, err := latestHeight(s.Store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := HeadersResponse{Headers: []core.BlockHeader{}}
+	for h := from; h <= tip && len(resp.Headers) < count; h++ {
+		block, err := s.Store.LoadBlock(h)
+		if err != nil {
+			break
+		}
+		resp.Headers = append(resp.Headers, block.Header)
+	}
+	writeJSON(w, resp)
+}
+
+// FetchHeaders 向 peer 请求自 from 起最多 count 个区块头
+func (s *Syncer) FetchHeaders(from uint64, count int) ([]core.BlockHeader, error) {
+	resp, err := s.get(fmt.Sprintf("/headers?from=%d&count=%d", from, count))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out HeadersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out.Headers, nil
+}
+
+// FetchTx 向 peer 查询交易及其状态
+func (s *Syncer) FetchTx(txid string) (*TxStatusResponse, error) {
+	resp, err := s.get("/tx?id=" + url.QueryEscape(txid))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out TxStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LightClient SPV 轻节点：只同步并校验区块头，以紧凑过滤器找出与钱包相关的区块，
+// 对照已验证的区块头确认区块或交易。全节点可以隐瞒交易，但无法伪造
+type LightClient struct {
+	Chain *core.HeaderChain
+	Peer  *Syncer
+	// Params 共识参数，nil 时取默认值；决定钱包余额中 coinbase 的成熟度
+	Params *core.ChainParams
+}
+
+// NewLightClient 以已验证的区块头链和单个全节点创建轻节点
+func NewLightClient(peer string, chain *core.HeaderChain) *LightClient {
+	return &LightClient{Chain: chain, Peer: NewSyncer(peer)}
+}
+
+// SyncHeaders 从 peer 拉取并校验新区块头，返回新增数量
+// peer 链更长且与本地分叉时，在副本上回退到共同祖先并接入 peer 的区块头，全部校验通过才切换
+func (c *LightClient) SyncHeaders() (int, error) {
+	status, err := c.Peer.fetchStatus()
+	if err != nil {
+		return 0, err
+	}
+	local := c.Chain.Height()
+	if status.Height <= local {
+		return 0, nil
+	}
+	fork, err := findFork(c.Peer, c.Chain, local)
+	if err != nil {
+		return 0, err
+	}
+	candidate := c.Chain.Clone()
+	candidate.Truncate(fork)
+	for from := fork + 1; from <= status.Height; {
+		headers, err := c.Peer.FetchHeaders(from, maxHeadersPerRequest)
+		if err != nil {
+			return 0, err
+		}
+		if len(headers) == 0 {
+			break
+		}
+		for _, h := range headers {
+			if err := candidate.Append(h); err != nil {
+				return 0, err
+			}
+		}
+		from += uint64(len(headers))
+	}
+	if candidate.Height() <= local {
+		return 0, fmt.Errorf("peer chain (height %d) is not longer than local headers (height %d)", candidate.Height(), local)
+	}
+	c.Chain = candidate
+	return int(candidate.Height() - fork), nil
+}
+
+// findFork 返回本地区块头链与 peer 的最高共同高度（不高于 local），全节点同步与轻节点共用
+func findFork(peer *Syncer, chain *core.HeaderChain, local uint64) (uint64, error) {
+	// 常见情况：peer 只是在本地链尖之后延伸
+	if hs, err := peer.FetchHeaders(local, 1); err != nil {
+		return 0, err
+	} else if tip, _ := chain.Header(local); len(hs) == 1 && bytes.Equal(core.HashBlockHeader(&tip), core.HashBlockHeader(&hs[0])) {
+		return local, nil
+	}
+	end := local
+	for {
+		start := uint64(0)
+		if end+1 > maxHeadersPerRequest {
+			start = end + 1 - maxHeadersPerRequest
+		}
+		headers, err := peer.FetchHeaders(start, int(end-start+1))
+		if err != nil {
+			return 0, err
+		}
+		for i := len(headers) - 1; i >= 0; i-- {
+			h := headers[i]
+			if h.Height > end {
+				continue
+			}
+			ours, ok := chain.Header(h.Height)
+			if ok && bytes.Equal(core.HashBlockHeader(&ours), core.HashBlockHeader(&h)) {
+				return h.Height, nil
+			}
+		}
+		if start == 0 {
+			return 0, errors.New("peer chain does not share our genesis header")
+		}
+		end = start - 1
+	}
+}
+
+// VerifiedTx 向 peer 取回已上链交易及其包含证明，校验交易 ID 与证明相符、证明对照已验证区块头成立
+// 返回交易、所在高度与区块内序号
+func (c *LightClient) VerifiedTx(txid string) (*core.Transaction, uint64, int, error) {
+	st, err := c.Peer.FetchTx(txid)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if st.Status != TxStatusConfirmed || st.Transaction == nil {
+		return nil, 0, 0, fmt.Errorf("transaction %s is %s", txid, st.Status)
+	}
+	proof, err := c.Peer.FetchProof(txid)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	id := core.ComputeTxID(st.Transaction)
+	if crypto.HexEncode(id) != txid || !bytes.Equal(proof.Proof.TxID, id) {
+		return nil, 0, 0, fmt.Errorf("transaction %s does not match its proof", txid)
+	}
+	if _, err := c.Chain.VerifyTx(proof.Header, proof.Proof); err != nil {
+		return nil, 0, 0, fmt.Errorf("proof for %s: %w", txid, err)
+	}
+	return st.Transaction, proof.Header.Height, proof.Proof.Index, nil
+}
+
+// ScanWallet 以紧凑过滤器在本地匹配钱包地址与已收到的输出，只下载命中的区块，按区块头顺序交给钱包跟踪
+// 过滤器须与 peer 的过滤器头链相符、区块须与已验证区块头及其 Merkle 根相符；peer 仍可隐瞒交易，但不会得知钱包地址
+// 返回钱包与下载的区块数
+func (c *LightClient) ScanWallet(addresses []string) (*wallet.Wallet, int, error) {
+	var filterHeaders []FilterHeaderEntry
+	for uint64(len(filterHeaders)) <= c.Chain.Height() {
+		page, err := c.Peer.FetchFilterHeaders(uint64(len(filterHeaders)), maxHeadersPerRequest)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(page) == 0 {
+			return nil, 0, fmt.Errorf("peer has filter headers up to %d, need %d", len(filterHeaders), c.Chain.Height()+1)
+		}
+		filterHeaders = append(filterHeaders, page...)
+	}
+
+	owned := make(map[string]bool, len(addresses))
+	queries := make([][]byte, 0, len(addresses))
+	for _, a := range addresses {
+		owned[a] = true
+		queries = append(queries, core.FilterScriptElement(a))
+	}
+	w := wallet.New(addresses)
+	w.CoinbaseMaturity = chainParams(c.Params).CoinbaseMaturity
+	fetched := 0
+	var prevFilterHeader []byte
+	for _, h := range c.Chain.Headers() {
+		hash := core.HashBlockHeader(&h)
+		fh := filterHeaders[h.Height]
+		if fh.Height != h.Height || !bytes.Equal(fh.BlockHash, hash) {
+			return nil, 0, fmt.Errorf("filter header %d does not match the verified block header", h.Height)
+		}
+		f, err := c.Peer.FetchFilter(h.Height)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !bytes.Equal(core.FilterHeader(f.Filter, prevFilterHeader), fh.Header) {
+			return nil, 0, fmt.Errorf("filter %d does not match its filter header", h.Height)
+		}
+		prevFilterHeader = fh.Header
+		match, err := core.FilterMatchAny(f.Filter, hash, queries)
+		if err != nil {
+			return nil, 0, fmt.Errorf("filter %d: %w", h.Height, err)
+		}
+
+		block := &core.Block{Header: h}
+		if match {
+			if block, err = c.fetchVerifiedBlock(h); err != nil {
+				return nil, 0, err
+			}
+			fetched++
+			// 之后花费这些输出的交易不一定再付款给钱包地址，需按输出引用匹配
+			for _, tx := range block.Transactions {
+				id := core.ComputeTxID(tx)
+				for vout, out := range tx.Outputs {
+					if owned[out.ScriptPubKey] {
+						queries = append(queries, core.FilterOutpointElement(id, vout))
+					}
+				}
+			}
+		}
+		if err := w.ConnectBlock(block); err != nil {
+			return nil, 0, err
+		}
+	}
+	return w, fetched, nil
+}
+
+// fetchVerifiedBlock 下载与已验证区块头对应的完整区块，校验区块头一致且交易列表与 Merkle 根相符
+func (c *LightClient) fetchVerifiedBlock(header core.BlockHeader) (*core.Block, error) {
+	block, err := c.Peer.fetchBlockInternal(header.Height)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(core.HashBlockHeader(&block.Header), core.HashBlockHeader(&header)) {
+		return nil, fmt.Errorf("block %d does not match the verified header", header.Height)
+	}
+	if err := core.CheckBlockMerkle(block); err != nil {
+		return nil, fmt.Errorf("block %d: %w", header.Height, err)
+	}
+	return block, nil
+}
@@ -0,0 +1,78 @@
+package network
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestLightClient 轻节点同步并校验区块头，以紧凑过滤器只下载钱包相关区块，peer 链更长时切换到分叉链
+func TestLightClient(t *testing.T) {
+	full := mustStore(t, t.TempDir(), "full")
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 0)}, 0)
+	pay := &core.Transaction{
+		Inputs:  []core.TxInput{{TxID: core.ComputeTxID(genesis.Transactions[0]), Vout: 0}},
+		Outputs: []core.TxOutput{{Value: 30, ScriptPubKey: "addr2"}, {Value: 20, ScriptPubKey: "addr1"}},
+	}
+	block1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 1), pay}, 0)
+	block2 := core.MineBlock(block1, []*core.Transaction{core.NewCoinbaseTx("addr3", 50, 2)}, 0)
+	for _, b := range []*core.Block{genesis, block1, block2} {
+		if err := full.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+	srv := httptest.NewServer((&NodeServer{NodeID: "full", Store: full}).Handler())
+	t.Cleanup(func() { srv.Close() })
+
+	chain, err := core.NewHeaderChain(genesis.Header, 0)
+	if err != nil {
+		t.Fatalf("header chain: %v", err)
+	}
+	client := NewLightClient(srv.URL, chain)
+	if added, err := client.SyncHeaders(); err != nil || added != 2 || client.Chain.Height() != 2 {
+		t.Fatalf("sync headers: added=%d err=%v", added, err)
+	}
+	if added, err := client.SyncHeaders(); err != nil || added != 0 {
+		t.Fatalf("resync: added=%d err=%v", added, err)
+	}
+
+	w, fetched, err := client.ScanWallet([]string{"addr2"})
+	if err != nil {
+		t.Fatalf("scan wallet: %v", err)
+	}
+	if fetched != 1 || w.Balance(3).Confirmed != 30 {
+		t.Fatalf("addr2: fetched=%d balance=%+v", fetched, w.Balance(3))
+	}
+
+	// 全节点切换到更长的分叉链：轻节点回退到创世后接入新链，旧链上的付款不再计入
+	fork := mustStore(t, t.TempDir(), "fork")
+	f1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("addr9", 50, 1)}, 0)
+	f2 := core.MineBlock(f1, []*core.Transaction{core.NewCoinbaseTx("addr9", 50, 2)}, 0)
+	f3 := core.MineBlock(f2, []*core.Transaction{core.NewCoinbaseTx("addr2", 50, 3)}, 0)
+	for _, b := range []*core.Block{genesis, f1, f2, f3} {
+		if err := fork.SaveBlock(b); err != nil {
+			t.Fatalf("save fork block: %v", err)
+		}
+	}
+	forkSrv := httptest.NewServer((&NodeServer{NodeID: "fork", Store: fork}).Handler())
+	t.Cleanup(func() { forkSrv.Close() })
+	client.Peer = NewSyncer(forkSrv.URL)
+	if added, err := client.SyncHeaders(); err != nil || added != 3 || client.Chain.Height() != 3 {
+		t.Fatalf("switch to fork: added=%d err=%v", added, err)
+	}
+	w, _, err = client.ScanWallet([]string{"addr2"})
+	if err != nil {
+		t.Fatalf("scan fork: %v", err)
+	}
+	if b := w.Balance(4); b.Confirmed != 50 {
+		t.Fatalf("addr2 on fork: %+v", b)
+	}
+
+	// 旧链上的交易证明对照新区块头链无效
+	client.Peer = NewSyncer(srv.URL)
+	if _, _, _, err := client.VerifiedTx(crypto.HexEncode(core.ComputeTxID(pay))); err == nil {
+		t.Fatalf("proof from the abandoned chain should not verify")
+	}
+}
@@ -1,11 +1,17 @@
 package network
 
-import "github.com/yiqi-017/blockchain/core"
+import (
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/storage"
+	"github.com/yiqi-017/blockchain/wallet"
+)
 
 // StatusResponse 返回节点基础状态
 type StatusResponse struct {
-	NodeID string `json:"node_id"`
-	Height uint64 `json:"height"`
+	NodeID string      `json:"node_id"`
+	Height uint64      `json:"height"`
+	Work   string      `json:"work,omitempty"` // 自创世起的累计工作量（十进制），peer 按它而非高度排序
+	Sync   *SyncStatus `json:"sync,omitempty"`
 }
 
 // BlockResponse 用于传输单个区块
@@ -23,3 +29,128 @@ type BalanceResponse struct {
 	Address string `json:"address"`
 	Balance int64  `json:"balance"`
 }
+
+// BalancesRequest POST /balances 的请求体
+type BalancesRequest struct {
+	Addresses []string `json:"addresses"`
+}
+
+// AddressBalance 单个地址的余额分类
+type AddressBalance struct {
+	Address string `json:"address"`
+	wallet.Balance
+}
+
+// BalancesResponse 一组地址的余额（含待确认转入）与合计
+type BalancesResponse struct {
+	Height    uint64           `json:"height"`
+	Addresses []AddressBalance `json:"addresses"`
+	Total     wallet.Balance   `json:"total"`
+}
+
+// 交易查询状态
+const (
+	TxStatusPending   = "pending"
+	TxStatusConfirmed = "confirmed"
+	TxStatusUnknown   = "unknown"
+)
+
+// TxStatusResponse GET /tx?id= 的返回：交易及其确认状态；已确认时给出所在区块与确认数
+type TxStatusResponse struct {
+	TxID          string            `json:"txid"`
+	Status        string            `json:"status"`
+	BlockHeight   *uint64           `json:"block_height,omitempty"`
+	BlockIndex    *int              `json:"block_index,omitempty"`
+	Confirmations uint64            `json:"confirmations"`
+	Transaction   *core.Transaction `json:"transaction,omitempty"`
+}
+
+// AddressTxsResponse GET /address/{addr}/txs 的一页结果；NextCursor 为空表示没有更多
+type AddressTxsResponse struct {
+	Address    string              `json:"address"`
+	Height     uint64              `json:"height"`
+	Txs        []storage.AddressTx `json:"txs"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// AddressUTXOsResponse GET /address/{addr}/utxos 的结果
+type AddressUTXOsResponse struct {
+	Address string                `json:"address"`
+	Height  uint64                `json:"height"`
+	UTXOs   []storage.AddressUTXO `json:"utxos"`
+	Total   int64                 `json:"total"`
+}
+
+// ProofResponse GET /proof?txid= 的返回：交易所在区块头与 Merkle 包含证明
+type ProofResponse struct {
+	Header core.BlockHeader  `json:"header"`
+	Proof  *core.MerkleProof `json:"proof"`
+}
+
+// HeadersResponse GET /headers 的返回：自 from 起按高度升序的区块头
+type HeadersResponse struct {
+	Headers []core.BlockHeader `json:"headers"`
+}
+
+// FilterResponse GET /filter?height= 的返回：区块紧凑过滤器、前一过滤器头与本过滤器头
+type FilterResponse struct {
+	Height     uint64 `json:"height"`
+	BlockHash  []byte `json:"block_hash"`
+	Filter     []byte `json:"filter"`
+	PrevHeader []byte `json:"prev_header"`
+	Header     []byte `json:"header"`
+}
+
+// FilterHeaderEntry 单个高度的过滤器头
+type FilterHeaderEntry struct {
+	Height    uint64 `json:"height"`
+	BlockHash []byte `json:"block_hash"`
+	Header    []byte `json:"header"`
+}
+
+// FilterHeadersResponse GET /filterheaders 的返回：自 from 起按高度升序的过滤器头
+type FilterHeadersResponse struct {
+	Headers []FilterHeaderEntry `json:"headers"`
+}
+
+// PeersResponse GET /peers 的返回
+type PeersResponse struct {
+	Peers []PeerState    `json:"peers"`
+	Wire  []WirePeerInfo `json:"wire,omitempty"`
+}
+
+// AddrMessage GET /getaddr 的返回与 POST /addr 的请求体：peer 地址列表
+type AddrMessage struct {
+	Addrs []string `json:"addrs"`
+}
+
+// AddrAckResponse POST /addr 的返回：新加入地址簿的数量
+type AddrAckResponse struct {
+	Added int `json:"added"`
+}
+
+// BansResponse /admin/bans 返回的封禁列表与各地址当前不当行为分值
+type BansResponse struct {
+	Bans   []storage.BanRecord `json:"bans"`
+	Scores map[string]int      `json:"scores"`
+}
+
+// VersionMessage 线路协议握手消息：协议版本、节点 ID、创世区块哈希（无区块时为空）与当前高度
+// Nonce 为本节点启动时随机生成，用于识别连到自己的连接
+type VersionMessage struct {
+	Version     uint32 `json:"version"`
+	NodeID      string `json:"node_id"`
+	GenesisHash string `json:"genesis_hash,omitempty"`
+	Height      uint64 `json:"height"`
+	Nonce       uint64 `json:"nonce"`
+}
+
+// PingMessage ping/pong 保活消息，pong 原样带回 ping 的 Nonce
+type PingMessage struct {
+	Nonce uint64 `json:"nonce"`
+}
+
+// GetBlockMessage 线路协议 getblock 请求；对端没有该区块时以同样内容回复 notfound
+type GetBlockMessage struct {
+	Height uint64 `json:"height"`
+}
@@ -22,7 +22,7 @@ func TestNetworkBlockAndTxSync(t *testing.T) {
 	}
 
 	// 准备节点 A：写入创世块与交易池
-	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50)}, 4)
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50, 0)}, 4)
 	if err := storeA.SaveBlock(genesis); err != nil {
 		t.Fatalf("save genesis A: %v", err)
 	}
@@ -79,6 +79,7 @@ func startNodeServerSimple(t *testing.T, store *storage.FileStorage) *httptest.S
 	mux := http.NewServeMux()
 	mux.HandleFunc("/status", ns.handleStatus)
 	mux.HandleFunc("/block", ns.handleBlock)
+	mux.HandleFunc("/headers", ns.handleHeaders)
 	mux.HandleFunc("/txpool", ns.handleTxPool)
 	mux.HandleFunc("/tx", ns.handleSubmitTx)
 	srv := httptest.NewServer(mux)
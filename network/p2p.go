@@ -3,14 +3,20 @@ package network
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
 	"github.com/yiqi-017/blockchain/storage"
+	"github.com/yiqi-017/blockchain/wallet"
 )
 
 // NodeServer 提供最小 HTTP 接口用于同步区块和交易池
@@ -19,20 +25,66 @@ type NodeServer struct {
 	Store  *storage.FileStorage
 	Addr   string // 监听地址，例 ":8080"
 	Peers  []string
+	// Progress 后台同步进度，非 nil 时随 /status 返回
+	Progress *SyncProgress
+	// PeerManager 非 nil 时 /peers 返回各 peer 的状态，交易广播发往其当前连接
+	PeerManager *PeerManager
+	// AddrBook 非 nil 时 /getaddr 分享其中的地址，/addr 收到的地址写入其中
+	AddrBook *AddrBook
+	// Bans 非 nil 时为不当行为的 peer 计分并拒绝被封禁地址的请求
+	Bans *BanManager
+	// Wire 非 nil 时经 HTTP 收到的区块与交易也推送给线路协议连接
+	Wire *WireNode
+	// Params 共识参数，nil 时取默认值
+	Params *core.ChainParams
+
+	// chainMu 串行化区块链与交易池的读改写：HTTP 与线路协议收到的区块和交易、后台同步、重组、交易池合并
+	chainMu sync.Mutex
+}
+
+// lockChain 持有 mu（可为 nil，此时不加锁），返回解锁函数
+func lockChain(mu *sync.Mutex) func() {
+	if mu == nil {
+		return func() {}
+	}
+	mu.Lock()
+	return mu.Unlock
+}
+
+// chainParams 返回 p 指向的共识参数，nil 时取默认值
+func chainParams(p *core.ChainParams) core.ChainParams {
+	if p == nil {
+		return core.DefaultChainParams()
+	}
+	return *p
 }
 
 // Start 启动 HTTP 服务（阻塞）
 func (s *NodeServer) Start() error {
+	log.Printf("P2P HTTP server listening on %s", s.Addr)
+	return http.ListenAndServe(s.Addr, s.Handler())
+}
+
+// Handler 返回注册了全部接口的路由，便于嵌入其他服务或测试
+func (s *NodeServer) Handler() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/status", s.handleStatus)
 	mux.HandleFunc("/block", s.handleBlock)
 	mux.HandleFunc("/txpool", s.handleTxPool)
-	mux.HandleFunc("/tx", s.handleSubmitTx)
+	mux.HandleFunc("/tx", s.handleTx)
 	mux.HandleFunc("/balance", s.handleBalance)
-
-	log.Printf("P2P HTTP server listening on %s", s.Addr)
-	return http.ListenAndServe(s.Addr, mux)
+	mux.HandleFunc("/balances", s.handleBalances)
+	mux.HandleFunc("/address/", s.handleAddress)
+	mux.HandleFunc("/proof", s.handleProof)
+	mux.HandleFunc("/headers", s.handleHeaders)
+	mux.HandleFunc("/filter", s.handleFilter)
+	mux.HandleFunc("/filterheaders", s.handleFilterHeaders)
+	mux.HandleFunc("/peers", s.handlePeers)
+	mux.HandleFunc("/getaddr", s.handleGetAddr)
+	mux.HandleFunc("/addr", s.handleAddr)
+	mux.HandleFunc("/admin/bans", s.handleAdminBans)
+	return s.withBans(mux)
 }
 
 // handleStatus 返回节点高度
@@ -48,7 +100,17 @@ func (s *NodeServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp := StatusResponse{NodeID: s.NodeID, Height: height}
+	work, err := s.Store.ChainWork()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := StatusResponse{NodeID: s.NodeID, Height: height, Work: work.String()}
+	if s.Progress != nil {
+		st := s.Progress.Snapshot()
+		resp.Sync = &st
+	}
 	writeJSON(w, resp)
 }
 
@@ -72,25 +134,33 @@ func (s *NodeServer) handleBlock(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, BlockResponse{Block: block})
 	case http.MethodPost:
 		var payload BlockResponse
-		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBlockMessageBytes)).Decode(&payload); err != nil {
+			s.rejectBody(w, r, err)
 			return
 		}
 		if payload.Block == nil {
+			s.misbehaving(r, scoreBadMessage, "bad message")
 			http.Error(w, "block is nil", http.StatusBadRequest)
 			return
 		}
-		if err := validateAndPersistBlock(s.Store, payload.Block); err != nil {
+		s.chainMu.Lock()
+		err := validateAndPersistBlock(s.Store, payload.Block, chainParams(s.Params))
+		s.chainMu.Unlock()
+		if err != nil {
+			if score, reason := blockMisbehavior(err); score > 0 {
+				s.misbehaving(r, score, reason)
+			}
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
+		s.Wire.BroadcastBlock(payload.Block, nil)
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-// handleTxPool GET 返回交易池；POST 覆盖交易池
+// handleTxPool GET 返回交易池；POST 覆盖交易池（仅限本机，远程请求计为不当行为）
 func (s *NodeServer) handleTxPool(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -101,16 +171,24 @@ func (s *NodeServer) handleTxPool(w http.ResponseWriter, r *http.Request) {
 		}
 		writeJSON(w, TxPoolResponse{Entries: pool.Snapshot()})
 	case http.MethodPost:
+		if !isLoopback(r) {
+			s.misbehaving(r, scorePoolOverwrite, "remote txpool overwrite")
+			http.Error(w, "txpool overwrite is loopback only", http.StatusForbidden)
+			return
+		}
 		var payload TxPoolResponse
-		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBlockMessageBytes)).Decode(&payload); err != nil {
+			s.rejectBody(w, r, err)
 			return
 		}
 		pool := core.NewTxPool()
 		if payload.Entries != nil {
 			pool.LoadSnapshot(payload.Entries)
 		}
-		if err := s.Store.SaveTxPool(pool); err != nil {
+		s.chainMu.Lock()
+		err := s.Store.SaveTxPool(pool)
+		s.chainMu.Unlock()
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -120,7 +198,7 @@ func (s *NodeServer) handleTxPool(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleBalance 返回某地址的余额（基于全链 UTXO 扫描）
+// handleBalance 返回某地址的余额（由地址索引中该地址的未花费输出求和）
 func (s *NodeServer) handleBalance(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -131,21 +209,161 @@ func (s *NodeServer) handleBalance(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "addr is required", http.StatusBadRequest)
 		return
 	}
-	blocks, err := loadAllBlocks(s.Store)
+	// 由地址索引求和，无需重建 UTXO 集
+	utxos, err := s.Store.AddressUTXOs(addr)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	utxos := core.BuildUTXOSet(blocks)
 	var balance int64
-	for _, list := range utxos {
-		for _, u := range list {
-			if u.Output.ScriptPubKey == addr {
-				balance += u.Output.Value
+	for _, u := range utxos {
+		balance += u.Value
+	}
+	writeJSON(w, BalanceResponse{Address: addr, Balance: balance})
+}
+
+// maxBalanceAddresses 单次 /balances 查询的地址上限
+const maxBalanceAddresses = 1000
+
+// handleBalances 一次查询一组地址的余额（已确认、未成熟、占用、待确认转入）及合计
+// GET /balances?addr=a&addr=b（也可逗号分隔）或 POST {"addresses":[...]}
+// 已确认部分取自地址索引，不扫描全链；待确认部分取自交易池
+func (s *NodeServer) handleBalances(w http.ResponseWriter, r *http.Request) {
+	var addrs []string
+	switch r.Method {
+	case http.MethodGet:
+		for _, v := range r.URL.Query()["addr"] {
+			for _, a := range strings.Split(v, ",") {
+				if a = strings.TrimSpace(a); a != "" {
+					addrs = append(addrs, a)
+				}
 			}
 		}
+	case http.MethodPost:
+		var req BalancesRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTxMessageBytes)).Decode(&req); err != nil {
+			s.rejectBody(w, r, err)
+			return
+		}
+		addrs = req.Addresses
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	writeJSON(w, BalanceResponse{Address: addr, Balance: balance})
+	if len(addrs) == 0 {
+		http.Error(w, "addr is required", http.StatusBadRequest)
+		return
+	}
+	if len(addrs) > maxBalanceAddresses {
+		http.Error(w, fmt.Sprintf("too many addresses (max %d)", maxBalanceAddresses), http.StatusBadRequest)
+		return
+	}
+
+	heights, err := s.Store.ListBlockHeights()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pool, err := s.Store.LoadTxPool()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	spendHeight := uint64(len(heights))
+	balances, err := indexedBalances(s.Store, addrs, pool.Pending(), spendHeight, chainParams(s.Params).CoinbaseMaturity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := BalancesResponse{Addresses: make([]AddressBalance, 0, len(balances))}
+	if spendHeight > 0 {
+		resp.Height = spendHeight - 1
+	}
+	sorted := make([]string, 0, len(balances))
+	for a := range balances {
+		sorted = append(sorted, a)
+	}
+	sort.Strings(sorted)
+	for _, a := range sorted {
+		resp.Addresses = append(resp.Addresses, AddressBalance{Address: a, Balance: balances[a]})
+		resp.Total.Add(balances[a])
+	}
+	writeJSON(w, resp)
+}
+
+// indexedBalances 由地址索引中的未花费输出与交易池计算各地址余额，口径同 wallet.Wallet.AddressBalances
+// spendHeight 为下一个区块高度，用于判断 coinbase 成熟度
+func indexedBalances(store *storage.FileStorage, addrs []string, pending []*core.Transaction, spendHeight, maturity uint64) (map[string]wallet.Balance, error) {
+	out := make(map[string]wallet.Balance, len(addrs))
+	for _, a := range addrs {
+		out[a] = wallet.Balance{}
+	}
+
+	// 交易池中尚未上链的交易：占用的输出与转入地址的输出
+	locked := make(map[storage.Outpoint]struct{})
+	incoming := make(map[storage.Outpoint]core.TxOutput)
+	for _, tx := range pending {
+		if tx == nil || tx.IsCoinbase {
+			continue
+		}
+		id := crypto.HexEncode(core.ComputeTxID(tx))
+		confirmed, err := store.TxConfirmed(id)
+		if err != nil {
+			return nil, err
+		}
+		if confirmed {
+			continue
+		}
+		for _, in := range tx.Inputs {
+			locked[storage.Outpoint{TxID: crypto.HexEncode(in.TxID), Vout: in.Vout}] = struct{}{}
+		}
+		for i, o := range tx.Outputs {
+			if _, ok := out[o.ScriptPubKey]; ok {
+				incoming[storage.Outpoint{TxID: id, Vout: i}] = o
+			}
+		}
+	}
+	// 被其他待确认交易再次花费的转入不计入
+	for op, o := range incoming {
+		if _, spent := locked[op]; spent {
+			continue
+		}
+		b := out[o.ScriptPubKey]
+		b.Pending += o.Value
+		out[o.ScriptPubKey] = b
+	}
+
+	for a := range out {
+		utxos, err := store.AddressUTXOs(a)
+		if err != nil {
+			return nil, err
+		}
+		b := out[a]
+		for _, u := range utxos {
+			b.Confirmed += u.Value
+			_, isLocked := locked[storage.Outpoint{TxID: u.TxID, Vout: u.Vout}]
+			switch {
+			case isLocked:
+				b.Locked += u.Value
+			case !(core.UTXO{Height: u.Height, Coinbase: u.Coinbase}).Mature(spendHeight, maturity):
+				b.Immature += u.Value
+			default:
+				b.Spendable += u.Value
+			}
+		}
+		out[a] = b
+	}
+	return out, nil
+}
+
+// handleTx GET /tx?id= 查询交易状态；POST /tx 提交交易
+func (s *NodeServer) handleTx(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.handleGetTx(w, r)
+		return
+	}
+	s.handleSubmitTx(w, r)
 }
 
 // handleSubmitTx 接收外部提交的简单交易并写入交易池
@@ -156,41 +374,104 @@ func (s *NodeServer) handleSubmitTx(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var tx core.Transaction
-	if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
-		http.Error(w, "bad json", http.StatusBadRequest)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTxMessageBytes)).Decode(&tx); err != nil {
+		s.rejectBody(w, r, err)
 		return
 	}
 
-	blocks, err := loadAllBlocks(s.Store)
+	held, err := s.acceptTx(&tx)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("load blocks: %v", err), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, errInvalidTx):
+			score, reason := txMisbehavior(err)
+			s.misbehaving(r, score, reason)
+			status = http.StatusBadRequest
+		case isPolicyReject(err):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
-	utxos := core.BuildUTXOSet(blocks)
-	if len(tx.ID) == 0 {
-		tx.ID = core.ComputeTxID(&tx)
+	if held {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusCreated)
 	}
-	if err := core.ValidateTransaction(&tx, utxos); err != nil {
-		http.Error(w, fmt.Sprintf("tx invalid: %v", err), http.StatusBadRequest)
-		return
+
+	// 广播给 peers，防止风暴：若来自转发请求则不再转发
+	if r.Header.Get("X-No-Relay") == "" {
+		s.broadcastTx(&tx)
 	}
+}
 
+// acceptTx 校验交易并写入交易池，返回是否因时间锁未到期暂不打包
+// 无效交易返回包装 errInvalidTx 的错误，与池中交易冲突时返回交易池的替换策略错误
+func (s *NodeServer) acceptTx(tx *core.Transaction) (bool, error) {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+	chain, err := loadChainView(s.Store)
+	if err != nil {
+		return false, err
+	}
 	pool, err := s.Store.LoadTxPool()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return false, err
+	}
+	held, _, err := admitTx(tx, pool, chain, pool.UTXOView(chain.utxos, chain.height, chain.medianTime), chainParams(s.Params))
+	if err != nil {
+		return false, err
 	}
-	pool.Add(fmt.Sprintf("%x", tx.ID), &tx)
 	if err := s.Store.SaveTxPool(pool); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return false, err
 	}
-	w.WriteHeader(http.StatusCreated)
+	return held, nil
+}
 
-	// 广播给 peers，防止风暴：若来自转发请求则不再转发
-	if r.Header.Get("X-No-Relay") == "" {
-		s.broadcastTx(&tx)
+// chainView 交易入池校验所需的本地链状态
+type chainView struct {
+	utxos      map[string][]core.UTXO // 已确认的 UTXO 集
+	height     uint64                 // 下一个区块的高度
+	medianTime int64                  // 下一个区块的前序中位时间
+}
+
+// loadChainView 由本地全链构建入池校验所需的链状态，调用方须持有链锁
+func loadChainView(store *storage.FileStorage) (*chainView, error) {
+	blocks, err := loadAllBlocks(store)
+	if err != nil {
+		return nil, fmt.Errorf("load blocks: %w", err)
 	}
+	chain := &chainView{utxos: core.BuildUTXOSet(blocks), medianTime: core.MedianTimePast(blocks)}
+	if len(blocks) > 0 {
+		chain.height = blocks[len(blocks)-1].Header.Height + 1
+	}
+	return chain, nil
+}
+
+// admitTx 以“下一个区块”为基准校验交易并提交到内存中的 pool，不落盘；view 为 pool.UTXOView 的结果，
+// 可花费池内未确认交易的输出（子交易为父交易付费）；时间锁未到期的交易仍入池，但不会进入区块模板
+// 返回是否时间锁未到期、是否驱逐了池内交易（此时 view 中仍留有被驱逐交易的输出，调用方应重建）
+func admitTx(tx *core.Transaction, pool *core.TxPool, chain *chainView, view map[string][]core.UTXO, params core.ChainParams) (bool, bool, error) {
+	if len(tx.ID) == 0 {
+		tx.ID = core.ComputeTxID(tx)
+	}
+	held := false
+	if err := core.ValidateTransaction(tx, view, chain.height, chain.medianTime, params); err != nil {
+		if !errors.Is(err, core.ErrTxNotFinal) {
+			return false, false, fmt.Errorf("%w: %w", errInvalidTx, err)
+		}
+		held = true
+	}
+
+	// 与池中交易冲突时按替换策略处理：允许替换且手续费足够则驱逐原交易及其后代，否则拒绝
+	evicted, err := pool.Submit(tx, chain.utxos)
+	if err != nil {
+		return false, false, err
+	}
+	if len(evicted) > 0 {
+		log.Printf("交易 %x 替换了 %d 笔池内交易", tx.ID, len(evicted))
+	}
+	return held, len(evicted) > 0, nil
 }
 
 // latestHeight 获取本地区块最高高度，若无区块返回 0
@@ -213,7 +494,7 @@ func writeJSON(w http.ResponseWriter, v any) {
 }
 
 // validateAndPersistBlock 对从网络收到的区块进行基本校验并落盘
-func validateAndPersistBlock(store *storage.FileStorage, block *core.Block) error {
+func validateAndPersistBlock(store *storage.FileStorage, block *core.Block, params core.ChainParams) error {
 	if block == nil {
 		return fmt.Errorf("block is nil")
 	}
@@ -238,48 +519,81 @@ func validateAndPersistBlock(store *storage.FileStorage, block *core.Block) erro
 			return fmt.Errorf("%w: prev hash mismatch at height %d", errConflictBlock, block.Header.Height)
 		}
 		if block.Header.Timestamp <= prev.Header.Timestamp {
-			return fmt.Errorf("block timestamp not increasing")
+			return fmt.Errorf("%w: timestamp not increasing", errInvalidBlock)
 		}
 	} else {
 		// 创世块要求 prev 为空
 		if len(block.Header.PrevHash) != 0 {
-			return fmt.Errorf("genesis prev hash must be empty")
+			return fmt.Errorf("%w: genesis prev hash must be empty", errInvalidBlock)
 		}
 	}
 
+	// 以本地全链构建 UTXO 集校验区块体
+	existingBlocks, err := loadAllBlocks(store)
+	if err != nil {
+		return err
+	}
+	utxos := core.BuildUTXOSet(existingBlocks)
+	spent := storage.SpentOutputs(block, utxos)
+	// 时间锁以本区块高度和前序区块的中位时间为准（BIP113）
+	if err := validateBlockBody(block, utxos, core.MedianTimePast(existingBlocks), params); err != nil {
+		return err
+	}
+
+	if err := store.ConnectBlock(block, spent); err != nil {
+		return err
+	}
+	// 收到新区块后移除已上链交易
+	pruneTxPool(store, block.Transactions)
+	return nil
+}
+
+// validateBlockBody 校验区块时间不过于超前、交易列表、coinbase、难度下限与 POW，并对 utxos 逐笔验证交易
+// 校验过程中把交易应用到 utxos，调用方在失败时应丢弃该集合
+func validateBlockBody(block *core.Block, utxos map[string][]core.UTXO, medianTime int64, params core.ChainParams) error {
 	now := time.Now().Unix()
 	const maxFutureDrift = int64(120) // 2 分钟容忍
 	if block.Header.Timestamp > now+maxFutureDrift {
 		return fmt.Errorf("block timestamp too far in future")
 	}
 
-	merkle := core.ComputeMerkleRoot(block.Transactions)
-	if !bytes.Equal(merkle, block.Header.MerkleRoot) {
-		return fmt.Errorf("invalid merkle root")
+	// 交易 ID、重复交易、重复叶子变形与 Merkle 根
+	if err := core.CheckBlockMerkle(block); err != nil {
+		return fmt.Errorf("%w: %w", errBadMerkle, err)
+	}
+	if err := core.ValidateCoinbase(block); err != nil {
+		return fmt.Errorf("%w: invalid coinbase: %w", errInvalidBlock, err)
+	}
+	if block.Header.Difficulty < params.MinDifficulty {
+		return fmt.Errorf("%w: difficulty %d below minimum %d", errInvalidBlock, block.Header.Difficulty, params.MinDifficulty)
 	}
 	if !core.ValidateBlockPOW(block) {
-		return fmt.Errorf("pow invalid")
+		return errInvalidPOW
 	}
 
 	// 校验交易（签名、余额）
-	existingBlocks, err := loadAllBlocks(store)
-	if err != nil {
-		return err
-	}
-	utxos := core.BuildUTXOSet(existingBlocks)
+	var fees int64
 	for _, tx := range block.Transactions {
-		if err := core.ValidateTransaction(tx, utxos); err != nil {
-			return fmt.Errorf("tx invalid: %w", err)
+		if err := core.ValidateTransaction(tx, utxos, block.Header.Height, medianTime, params); err != nil {
+			return fmt.Errorf("%w: %w", errInvalidTx, err)
+		}
+		fee, err := core.TxFee(tx, utxos)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidTx, err)
+		}
+		if fee < 0 {
+			return fmt.Errorf("%w: outputs exceed inputs", errInvalidTx)
+		}
+		if fees, err = core.AddValue(fees, fee); err != nil {
+			return fmt.Errorf("%w: block fees: %w", errInvalidBlock, err)
 		}
 		// 应用花费到 utxo 集以避免同块内双花
-		utxos = applyTxToUTXO(tx, utxos)
+		core.ApplyTxToUTXO(utxos, tx, block.Header.Height, medianTime)
 	}
-
-	if err := store.SaveBlock(block); err != nil {
-		return err
+	// 矿工最多领取出块补贴加区块内手续费
+	if err := core.CheckCoinbaseValue(block, fees); err != nil {
+		return fmt.Errorf("%w: invalid coinbase: %w", errInvalidBlock, err)
 	}
-	// 收到新区块后移除已上链交易
-	pruneTxPool(store, block.Transactions)
 	return nil
 }
 
@@ -300,41 +614,6 @@ func loadAllBlocks(store *storage.FileStorage) ([]*core.Block, error) {
 	return blocks, nil
 }
 
-// applyTxToUTXO 将交易对 utxo 集的消费和新增应用（用于同块内依赖）
-func applyTxToUTXO(tx *core.Transaction, utxos map[string][]core.UTXO) map[string][]core.UTXO {
-	if tx == nil {
-		return utxos
-	}
-	if !tx.IsCoinbase {
-		for _, in := range tx.Inputs {
-			key := fmt.Sprintf("%x", in.TxID)
-			list := utxos[key]
-			for i, u := range list {
-				if bytes.Equal(u.TxID, in.TxID) && u.Index == in.Vout {
-					// remove
-					list = append(list[:i], list[i+1:]...)
-					break
-				}
-			}
-			if len(list) == 0 {
-				delete(utxos, key)
-			} else {
-				utxos[key] = list
-			}
-		}
-	}
-	txID := core.ComputeTxID(tx)
-	key := fmt.Sprintf("%x", txID)
-	for idx, out := range tx.Outputs {
-		utxos[key] = append(utxos[key], core.UTXO{
-			TxID:   txID,
-			Index:  idx,
-			Output: out,
-		})
-	}
-	return utxos
-}
-
 // pruneTxPool 移除交易池中已被区块包含的交易
 func pruneTxPool(store *storage.FileStorage, txs []*core.Transaction) {
 	if len(txs) == 0 {
@@ -368,16 +647,18 @@ func pruneTxPool(store *storage.FileStorage, txs []*core.Transaction) {
 	_ = store.SaveTxPool(pool)
 }
 
-// broadcastTx 将交易推送到 peers 的 /tx 接口
+// broadcastTx 将交易推送到 peers 的 /tx 接口及线路协议连接
 func (s *NodeServer) broadcastTx(tx *core.Transaction) {
-	if tx == nil || len(s.Peers) == 0 {
+	s.Wire.BroadcastTx(tx, nil)
+	peers := s.peerURLs()
+	if tx == nil || len(peers) == 0 {
 		return
 	}
 	body, err := json.Marshal(tx)
 	if err != nil {
 		return
 	}
-	for _, peer := range s.Peers {
+	for _, peer := range peers {
 		req, err := http.NewRequest(http.MethodPost, peer+"/tx", bytes.NewReader(body))
 		if err != nil {
 			continue
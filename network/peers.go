@@ -0,0 +1,442 @@
+package network
+
+import (
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+const (
+	// peerBackoffBase 首次失败后的退避时长，之后每次失败翻倍
+	peerBackoffBase = 2 * time.Second
+	// peerBackoffMax 退避上限
+	peerBackoffMax = 5 * time.Minute
+)
+
+// PeerState /peers 返回的单个 peer 状态；时间为 Unix 秒，延迟为最近一次 /status 往返毫秒数
+type PeerState struct {
+	URL       string `json:"url"`
+	Height    uint64 `json:"height"`
+	Work      string `json:"work,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	Failures  int    `json:"failures"`
+	LastSeen  int64  `json:"last_seen,omitempty"`
+	RetryAt   int64  `json:"retry_at,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	Best      bool   `json:"best,omitempty"`
+}
+
+type peerEntry struct {
+	syncer    *Syncer
+	pinned    bool // 启动参数配置的 peer，失败也不断开
+	exchanged bool // 已与其交换过地址
+	height    uint64
+	work      *big.Int // peer 在 /status 中声明的累计工作量，实际同步前由区块头链校验
+	latency   time.Duration
+	failures  int
+	lastSeen  time.Time
+	retryAt   time.Time
+	lastErr   string
+}
+
+// PeerManager 跟踪各 peer 的高度、累计工作量、延迟与失败次数，从最优 peer 同步；连续失败的 peer 按指数退避暂停使用
+// 本链以累计工作量最大的链为准：各区块难度可以不同，更高的链未必工作量更多
+type PeerManager struct {
+	mu    sync.Mutex
+	peers map[string]*peerEntry
+	order []string
+	// Params 共识参数，nil 时取默认值
+	Params *core.ChainParams
+	// Bans 非 nil 时被封禁主机上的 peer 不参与同步，非手动配置的随之断开
+	Bans *BanManager
+	// now 注入便于测试
+	now func() time.Time
+}
+
+// NewPeerManager 以手动配置的 peer 列表创建管理器，这些 peer 不会因失败被断开
+func NewPeerManager(urls []string) *PeerManager {
+	m := &PeerManager{peers: make(map[string]*peerEntry), now: time.Now}
+	for _, u := range urls {
+		m.add(u, true)
+	}
+	return m
+}
+
+// Add 加入新发现的 peer，已存在时忽略；返回是否新加入
+func (m *PeerManager) Add(url string) bool {
+	return m.add(url, false)
+}
+
+func (m *PeerManager) add(url string, pinned bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.peers[url]; ok || url == "" {
+		return false
+	}
+	m.peers[url] = &peerEntry{syncer: NewSyncer(url), pinned: pinned}
+	m.order = append(m.order, url)
+	return true
+}
+
+// Remove 断开 peer
+func (m *PeerManager) Remove(url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.peers[url]; !ok {
+		return
+	}
+	delete(m.peers, url)
+	for i, u := range m.order {
+		if u == url {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (m *PeerManager) pinned(url string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.peers[url]
+	return ok && e.pinned
+}
+
+// beginExchange 标记即将与 peer 交换地址，已交换过时返回 false
+func (m *PeerManager) beginExchange(url string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.peers[url]
+	if !ok || e.exchanged {
+		return false
+	}
+	e.exchanged = true
+	return true
+}
+
+// endExchange 交换失败时清除标记，待下次重试
+func (m *PeerManager) endExchange(url string, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, found := m.peers[url]; found && !ok {
+		e.exchanged = false
+	}
+}
+
+// URLs 返回全部 peer 地址（按加入顺序）
+func (m *PeerManager) URLs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.order...)
+}
+
+// Peers 返回各 peer 的状态快照，并标记当前最优 peer
+func (m *PeerManager) Peers() []PeerState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	best := m.bestLocked()
+	out := make([]PeerState, 0, len(m.order))
+	for _, u := range m.order {
+		e := m.peers[u]
+		st := PeerState{
+			URL:       u,
+			Height:    e.height,
+			LatencyMs: e.latency.Milliseconds(),
+			Failures:  e.failures,
+			LastError: e.lastErr,
+			Best:      u == best,
+		}
+		if e.work != nil {
+			st.Work = e.work.String()
+		}
+		if !e.lastSeen.IsZero() {
+			st.LastSeen = e.lastSeen.Unix()
+		}
+		if e.retryAt.After(m.now()) {
+			st.RetryAt = e.retryAt.Unix()
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// RecordSuccess 记录一次成功的状态查询，清除失败计数；work 为 nil 时视为 0
+func (m *PeerManager) RecordSuccess(url string, height uint64, work *big.Int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.peers[url]
+	if !ok {
+		return
+	}
+	if work == nil {
+		work = new(big.Int)
+	}
+	e.height = height
+	e.work = work
+	e.latency = latency
+	e.failures = 0
+	e.lastSeen = m.now()
+	e.retryAt = time.Time{}
+	e.lastErr = ""
+}
+
+// RecordFailure 记录一次失败，按 2s、4s、8s…（上限 5 分钟）推迟下次使用
+func (m *PeerManager) RecordFailure(url string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.peers[url]
+	if !ok {
+		return
+	}
+	e.failures++
+	backoff := peerBackoffMax
+	if e.failures <= 20 {
+		if d := peerBackoffBase << (e.failures - 1); d < backoff {
+			backoff = d
+		}
+	}
+	e.retryAt = m.now().Add(backoff)
+	if err != nil {
+		e.lastErr = err.Error()
+	}
+}
+
+// banned 判断 peer 所在主机是否被封禁
+func (m *PeerManager) banned(url string) bool {
+	return m.Bans.IsBanned(peerHost(url))
+}
+
+// Available 返回不在退避期内且未被封禁的 peer
+func (m *PeerManager) Available() []*Syncer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var out []*Syncer
+	for _, u := range m.order {
+		if e := m.peers[u]; !e.retryAt.After(now) && !m.banned(u) {
+			out = append(out, e.syncer)
+		}
+	}
+	return out
+}
+
+// Best 返回最优 peer：不在退避期内、未被封禁、最近查询成功，累计工作量最大，相同时取延迟最低
+func (m *PeerManager) Best() (*Syncer, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	best := m.bestLocked()
+	if best == "" {
+		return nil, false
+	}
+	return m.peers[best].syncer, true
+}
+
+func (m *PeerManager) bestLocked() string {
+	now := m.now()
+	best := ""
+	for _, u := range m.order {
+		e := m.peers[u]
+		if e.lastSeen.IsZero() || e.failures > 0 || e.retryAt.After(now) || m.banned(u) {
+			continue
+		}
+		if best == "" {
+			best = u
+			continue
+		}
+		b := m.peers[best]
+		if c := e.work.Cmp(b.work); c > 0 || c == 0 && e.latency < b.latency {
+			best = u
+		}
+	}
+	return best
+}
+
+// Poll 并发查询所有可用 peer 的 /status，单个 peer 慢或不可达不会拖住其他 peer
+func (m *PeerManager) Poll() {
+	var wg sync.WaitGroup
+	for _, s := range m.Available() {
+		wg.Add(1)
+		go func(s *Syncer) {
+			defer wg.Done()
+			start := time.Now()
+			status, err := s.fetchStatus()
+			if err != nil {
+				m.RecordFailure(s.Peer, err)
+				return
+			}
+			m.RecordSuccess(s.Peer, status.Height, statusWork(status), time.Since(start))
+		}(s)
+	}
+	wg.Wait()
+}
+
+// Sync 一轮同步：查询各 peer 状态，从最优 peer 开始头优先下载区块（其余可用 peer 分担区块体），
+// 再把各可用 peer 交易池中本地没有的交易并入本地交易池；写入本地链与交易池时持有 server 的链锁
+func (m *PeerManager) Sync(server *NodeServer) error {
+	m.Poll()
+	best, ok := m.Best()
+	if !ok {
+		return nil
+	}
+	store := server.Store
+	local, err := store.ChainWork()
+	if err != nil {
+		return err
+	}
+	var syncErr error
+	if target := m.work(best.Peer); target.Cmp(local) > 0 {
+		// 工作量不低于最优 peer 的可用 peer 通常持有同一条链，一起分担区块体；不符的区块会被区块头校验拒绝
+		peers := []*Syncer{best}
+		for _, s := range m.Available() {
+			if s != best && m.work(s.Peer).Cmp(target) >= 0 {
+				peers = append(peers, s)
+			}
+		}
+		download := &BlockDownload{Peers: peers, Progress: server.Progress, OnPeerError: m.RecordFailure, Params: m.Params, Bans: m.Bans, chainMu: &server.chainMu}
+		if syncErr = download.Run(store); syncErr != nil {
+			m.RecordFailure(best.Peer, syncErr)
+		}
+	}
+	for _, s := range m.Available() {
+		if err := s.MergeTxPool(server); err != nil {
+			m.RecordFailure(s.Peer, err)
+		}
+	}
+	return syncErr
+}
+
+func (m *PeerManager) work(url string) *big.Int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.peers[url]; ok && e.work != nil {
+		return e.work
+	}
+	return new(big.Int)
+}
+
+// statusWork 解析 /status 声明的累计工作量，缺失或无效时视为 0
+func statusWork(status *StatusResponse) *big.Int {
+	work, ok := new(big.Int).SetString(status.Work, 10)
+	if !ok || work.Sign() < 0 {
+		return new(big.Int)
+	}
+	return work
+}
+
+// MergeTxPool 把 peer 交易池中本地没有的交易逐笔按 /tx 的规则校验后并入 server 的交易池，不覆盖本地已有交易
+// 响应体以 maxBlockMessageBytes 为上限；整轮合并持有链锁，链状态与池内输出视图只构建一次，入池的交易增量并入视图
+// 依赖池内父交易的子交易在父交易入池后重试；最终仍无效的交易按 txMisbehavior 计入该 peer 的不当行为分值
+func (s *Syncer) MergeTxPool(server *NodeServer) error {
+	resp, err := s.get("/txpool")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var payload TxPoolResponse
+	if err := decodeResponse(resp, maxBlockMessageBytes, &payload); err != nil {
+		return err
+	}
+	if len(payload.Entries) == 0 {
+		return nil
+	}
+
+	server.chainMu.Lock()
+	defer server.chainMu.Unlock()
+	pool, err := server.Store.LoadTxPool()
+	if err != nil {
+		return err
+	}
+	// 以交易内容计算的 ID 为准，不信任 peer 给出的池键
+	remaining := make(map[string]*core.Transaction, len(payload.Entries))
+	for _, tx := range payload.Entries {
+		if tx == nil {
+			continue
+		}
+		id := crypto.HexEncode(core.ComputeTxID(tx))
+		if _, ok := pool.Get(id); !ok {
+			remaining[id] = tx
+		}
+	}
+	if len(remaining) == 0 {
+		return nil
+	}
+	chain, err := loadChainView(server.Store)
+	if err != nil {
+		return err
+	}
+	params := chainParams(server.Params)
+	view := pool.UTXOView(chain.utxos, chain.height, chain.medianTime)
+	invalid := make(map[string]error)
+	accepted := false
+	for progress := true; progress && len(remaining) > 0; {
+		progress = false
+		ids := make([]string, 0, len(remaining))
+		for id := range remaining {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			tx := remaining[id]
+			tx.ID = nil // 不信任 peer 填写的 ID 字段，由 admitTx 重新计算
+			_, evicted, err := admitTx(tx, pool, chain, view, params)
+			if errors.Is(err, errInvalidTx) {
+				invalid[id] = err
+				continue
+			}
+			if err != nil && !isPolicyReject(err) {
+				return err
+			}
+			delete(remaining, id)
+			delete(invalid, id)
+			if err != nil {
+				continue
+			}
+			accepted, progress = true, true
+			if evicted {
+				// 被替换交易的输出不可再花费
+				view = pool.UTXOView(chain.utxos, chain.height, chain.medianTime)
+			} else {
+				core.AddToUTXOView(view, tx, chain.height, chain.medianTime)
+			}
+		}
+	}
+	if accepted {
+		if err := server.Store.SaveTxPool(pool); err != nil {
+			return err
+		}
+	}
+	for id := range remaining {
+		score, reason := txMisbehavior(invalid[id])
+		server.Bans.Misbehaving(peerHost(s.Peer), score, reason)
+		log.Printf("[sync] peer %s txpool entry %s rejected: %v", s.Peer, id, invalid[id])
+	}
+	return nil
+}
+
+// isPolicyReject 交易有效但按交易池策略（冲突、替换手续费、包大小）未入池，不算不当行为
+func isPolicyReject(err error) bool {
+	return errors.Is(err, core.ErrTxConflict) || errors.Is(err, core.ErrReplacementFee) || errors.Is(err, core.ErrPackageLimit)
+}
+
+// handlePeers GET /peers 返回各 peer 的高度、延迟、失败次数与退避状态
+func (s *NodeServer) handlePeers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp := PeersResponse{Peers: []PeerState{}, Wire: s.Wire.PeerInfos()}
+	if s.PeerManager != nil {
+		resp.Peers = s.PeerManager.Peers()
+	} else {
+		for _, p := range s.Peers {
+			resp.Peers = append(resp.Peers, PeerState{URL: p})
+		}
+	}
+	writeJSON(w, resp)
+}
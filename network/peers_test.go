@@ -0,0 +1,190 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestPeerBackoff 按累计工作量选最优 peer；连续失败按 2s、4s、8s 退避直至上限，退避期内不可用，成功后清零
+func TestPeerBackoff(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := NewPeerManager([]string{"http://a", "http://b"})
+	m.now = func() time.Time { return now }
+
+	m.RecordSuccess("http://a", 5, big.NewInt(32), 30*time.Millisecond)
+	m.RecordSuccess("http://b", 5, big.NewInt(32), 10*time.Millisecond)
+	if best, ok := m.Best(); !ok || best.Peer != "http://b" {
+		t.Fatalf("same work should prefer lower latency, got %v", best)
+	}
+	m.RecordSuccess("http://a", 6, big.NewInt(64), 30*time.Millisecond)
+	if best, _ := m.Best(); best.Peer != "http://a" {
+		t.Fatalf("peer with more work should be best, got %s", best.Peer)
+	}
+	m.RecordSuccess("http://b", 9, big.NewInt(40), 10*time.Millisecond)
+	if best, _ := m.Best(); best.Peer != "http://a" {
+		t.Fatalf("higher but lighter chain should not be best, got %s", best.Peer)
+	}
+	if st := m.Peers()[1]; st.Height != 9 || st.Work != "40" {
+		t.Fatalf("peer state should report height and work: %+v", st)
+	}
+
+	for i, want := range []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		m.RecordFailure("http://a", errors.New("down"))
+		st := m.Peers()[0]
+		if st.Failures != i+1 || st.RetryAt != now.Add(want).Unix() || st.LastError != "down" {
+			t.Fatalf("failure %d: %+v", i+1, st)
+		}
+	}
+	if best, _ := m.Best(); best.Peer != "http://b" {
+		t.Fatalf("failing peer should not be best")
+	}
+	if avail := m.Available(); len(avail) != 1 || avail[0].Peer != "http://b" {
+		t.Fatalf("peer in backoff should be unavailable: %v", avail)
+	}
+	now = now.Add(9 * time.Second)
+	if len(m.Available()) != 2 {
+		t.Fatalf("peer should be retried after backoff")
+	}
+	for i := 0; i < 30; i++ {
+		m.RecordFailure("http://a", nil)
+	}
+	if st := m.Peers()[0]; st.RetryAt != now.Add(peerBackoffMax).Unix() {
+		t.Fatalf("backoff should be capped: %+v", st)
+	}
+	m.RecordSuccess("http://a", 7, big.NewInt(128), time.Millisecond)
+	if st := m.Peers()[0]; st.Failures != 0 || st.RetryAt != 0 || st.LastError != "" || !st.Best {
+		t.Fatalf("success should reset state: %+v", st)
+	}
+	if m.Add("http://a") || !m.Add("http://c") || len(m.URLs()) != 3 {
+		t.Fatalf("add should skip known peers")
+	}
+}
+
+// TestPeerManagerSync 不可达 peer 不影响从最优 peer 同步区块，各 peer 交易池合并而非覆盖；/peers 返回各 peer 状态
+func TestPeerManagerSync(t *testing.T) {
+	chain := buildChain(nil, 6, "miner")
+	short := serveChain(t, "short", chain[:3])
+
+	longStore := mustStore(t, t.TempDir(), "long")
+	for _, b := range chain {
+		if err := longStore.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+	// 无输入、零金额的交易无需签名即可通过校验；凭空产生金额的交易无效，不应并入且计入该 peer 分值
+	valid := &core.Transaction{Outputs: []core.TxOutput{{Value: 0, ScriptPubKey: "alice"}}}
+	forged := &core.Transaction{Outputs: []core.TxOutput{{Value: 1, ScriptPubKey: "mallory"}}}
+	pool := core.NewTxPool()
+	pool.Add("tx-long", valid)
+	pool.Add("tx-forged", forged)
+	if err := longStore.SaveTxPool(pool); err != nil {
+		t.Fatalf("save pool: %v", err)
+	}
+	long := httptest.NewServer((&NodeServer{NodeID: "long", Store: longStore}).Handler())
+	t.Cleanup(func() { long.Close() })
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	store := mustStore(t, t.TempDir(), "local")
+	localPool := core.NewTxPool()
+	localPool.Add("tx-local", &core.Transaction{Outputs: []core.TxOutput{{Value: 2, ScriptPubKey: "bob"}}})
+	if err := store.SaveTxPool(localPool); err != nil {
+		t.Fatalf("save local pool: %v", err)
+	}
+
+	m := NewPeerManager([]string{dead.URL, short.URL, long.URL})
+	bans, err := NewBanManager(nil, 0)
+	if err != nil {
+		t.Fatalf("ban manager: %v", err)
+	}
+	if err := m.Sync(&NodeServer{NodeID: "local", Store: store, Bans: bans}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	assertSameChain(t, store, chain)
+	merged, err := store.LoadTxPool()
+	if err != nil {
+		t.Fatalf("load pool: %v", err)
+	}
+	for _, id := range []string{"tx-local", crypto.HexEncode(core.ComputeTxID(valid))} {
+		if _, ok := merged.Get(id); !ok {
+			t.Fatalf("pool should keep %s", id)
+		}
+	}
+	if merged.Size() != 2 {
+		t.Fatalf("invalid peer tx should not be merged, pool size %d", merged.Size())
+	}
+	if _, scores := bans.Bans(); scores["127.0.0.1"] != scoreInvalidTx {
+		t.Fatalf("peer relaying an invalid tx should be scored, got %v", scores)
+	}
+
+	srv := httptest.NewServer((&NodeServer{NodeID: "local", Store: store, PeerManager: m}).Handler())
+	t.Cleanup(func() { srv.Close() })
+	resp, err := http.Get(srv.URL + "/peers")
+	if err != nil {
+		t.Fatalf("get peers: %v", err)
+	}
+	defer resp.Body.Close()
+	var out PeersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode peers: %v", err)
+	}
+	if len(out.Peers) != 3 {
+		t.Fatalf("expect 3 peers, got %+v", out.Peers)
+	}
+	if p := out.Peers[0]; p.Failures != 1 || p.RetryAt == 0 || p.LastError == "" {
+		t.Fatalf("dead peer state: %+v", p)
+	}
+	if p := out.Peers[1]; p.Height != 2 || p.Failures != 0 || p.Best {
+		t.Fatalf("short peer state: %+v", p)
+	}
+	if p := out.Peers[2]; p.Height != 5 || !p.Best || p.LastSeen == 0 {
+		t.Fatalf("long peer state: %+v", p)
+	}
+}
+
+// TestMergeTxPoolLimits 超过上限的 /txpool 响应不再读取、不改动本地交易池；正常响应的多笔交易一轮并入
+func TestMergeTxPoolLimits(t *testing.T) {
+	store := mustStore(t, t.TempDir(), "local")
+	server := &NodeServer{NodeID: "local", Store: store}
+
+	huge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"entries":{"x":{"outputs":[{"value":0,"script_pub_key":"`))
+		w.Write(bytes.Repeat([]byte("a"), maxBlockMessageBytes))
+		w.Write([]byte(`"}]}}}`))
+	}))
+	t.Cleanup(huge.Close)
+	if err := NewSyncer(huge.URL).MergeTxPool(server); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("oversized txpool response should be rejected, got %v", err)
+	}
+	if pool, err := store.LoadTxPool(); err != nil || pool.Size() != 0 {
+		t.Fatalf("oversized response must not touch the pool: %v", err)
+	}
+
+	peerStore := mustStore(t, t.TempDir(), "peer")
+	pool := core.NewTxPool()
+	for i, owner := range []string{"alice", "bob", "carol"} {
+		pool.Add(fmt.Sprintf("tx-%d", i), &core.Transaction{Outputs: []core.TxOutput{{Value: 0, ScriptPubKey: owner}}})
+	}
+	if err := peerStore.SaveTxPool(pool); err != nil {
+		t.Fatalf("save pool: %v", err)
+	}
+	peer := httptest.NewServer((&NodeServer{NodeID: "peer", Store: peerStore}).Handler())
+	t.Cleanup(peer.Close)
+	if err := NewSyncer(peer.URL).MergeTxPool(server); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if merged, err := store.LoadTxPool(); err != nil || merged.Size() != 3 {
+		t.Fatalf("all peer txs should be merged: %v", err)
+	}
+}
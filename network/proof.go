@@ -0,0 +1,41 @@
+package network
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/yiqi-017/blockchain/core"
+)
+
+// handleProof GET /proof?txid=<txid> 返回已上链交易的区块头与 Merkle 包含证明；未上链返回 404
+func (s *NodeServer) handleProof(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	txid := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("txid")))
+	if txid == "" {
+		http.Error(w, "missing txid", http.StatusBadRequest)
+		return
+	}
+	_, loc, ok, err := s.Store.LookupTx(txid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "transaction not confirmed", http.StatusNotFound)
+		return
+	}
+	block, err := s.Store.LoadBlock(loc.Height)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proof, err := core.BuildMerkleProof(block.Transactions, loc.Index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, ProofResponse{Header: block.Header, Proof: proof})
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestProofEndpoint /proof 返回的区块头与包含证明可由轻节点的区块头链验证
+func TestProofEndpoint(t *testing.T) {
+	store := mustStore(t, t.TempDir(), "proof")
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 0)}, 0)
+	pay := &core.Transaction{
+		Inputs:  []core.TxInput{{TxID: core.ComputeTxID(genesis.Transactions[0]), Vout: 0}},
+		Outputs: []core.TxOutput{{Value: 50, ScriptPubKey: "addr2"}},
+	}
+	block1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 1), pay}, 0)
+	block1.Header.Timestamp = genesis.Header.Timestamp + 1
+	for _, b := range []*core.Block{genesis, block1} {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+
+	ns := &NodeServer{NodeID: "proof", Store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/proof", ns.handleProof)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() { srv.Close() })
+
+	syncer := NewSyncer(srv.URL)
+	resp, err := syncer.FetchProof(crypto.HexEncode(core.ComputeTxID(pay)))
+	if err != nil {
+		t.Fatalf("fetch proof: %v", err)
+	}
+	chain, err := core.NewHeaderChain(genesis.Header, 0)
+	if err != nil {
+		t.Fatalf("header chain: %v", err)
+	}
+	if err := chain.Append(block1.Header); err != nil {
+		t.Fatalf("append header: %v", err)
+	}
+	if confs, err := chain.VerifyTx(resp.Header, resp.Proof); err != nil || confs != 1 {
+		t.Fatalf("verify proof: confs=%d err=%v", confs, err)
+	}
+	if _, err := syncer.FetchProof("00ff"); err == nil {
+		t.Fatalf("unknown tx should have no proof")
+	}
+}
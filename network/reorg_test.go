@@ -1,52 +1,122 @@
 package network
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
 )
 
-// TestReorgFromPeer 本地短链，对端更长链，触发重组
+// TestReorgFromPeer 本地链与对端分叉且对端累计工作量更大时，只拉取分叉点之上的区块，回退到分叉点后切换；
+// 更低但工作量更大的分叉同样胜出，本地多出的高度与其索引被删除；写入新区块失败时恢复原链
 func TestReorgFromPeer(t *testing.T) {
-	base := t.TempDir()
-	store := mustStore(t, base, "local")
+	shared := mineChain(nil, 2, "shared", 0)
+	local := mineChain(shared, 3, "minerA", 0) // 高度 4，工作量 2+3
+	short := mineChain(shared, 2, "minerB", 2) // 高度 3，工作量 2+8
+	tall := mineChain(shared, 4, "minerC", 2)  // 高度 5，工作量 2+16
 
-	// 本地链：仅创世
-	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50)}, 4)
-	if err := store.SaveBlock(genesis); err != nil {
-		t.Fatalf("save local genesis: %v", err)
+	peerStore := mustStore(t, t.TempDir(), "peer")
+	for _, b := range short {
+		if err := peerStore.SaveBlock(b); err != nil {
+			t.Fatalf("save peer block: %v", err)
+		}
 	}
+	var mu sync.Mutex
+	var fetched []string
+	handler := (&NodeServer{NodeID: "peer", Store: peerStore}).Handler()
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			mu.Lock()
+			fetched = append(fetched, r.URL.Query().Get("height"))
+			mu.Unlock()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(peer.Close)
 
-	// 构造对端更长链：创世 + 区块1
-	peerBlocks := []*core.Block{genesis}
-	block1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("minerB", 50)}, 4)
-	peerBlocks = append(peerBlocks, block1)
-
-	// fake syncer，直接调用 reorgFromPeer
-	s := NewSyncer("peer")
-	fetches := 0
-	s.fetchBlockFn = func(height uint64) (*core.Block, error) {
-		fetches++
-		return peerBlocks[height], nil
-	}
-	// 调用重组
-	if err := s.reorgFromPeer(store, uint64(len(peerBlocks)-1)); err != nil {
+	base := t.TempDir()
+	store := mustStore(t, base, "local")
+	for _, b := range local {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save local block: %v", err)
+		}
+	}
+	if err := NewSyncer(peer.URL).SyncBlocks(store); err != nil {
 		t.Fatalf("reorg failed: %v", err)
 	}
-	if fetches != len(peerBlocks) {
-		t.Fatalf("expected fetch %d blocks, got %d", len(peerBlocks), fetches)
+	if len(fetched) != 2 || fetched[0] == fetched[1] || fetched[0] != "2" && fetched[0] != "3" || fetched[1] != "2" && fetched[1] != "3" {
+		t.Fatalf("only blocks above the fork point should be fetched, got %v", fetched)
+	}
+	assertSameChain(t, store, short)
+	if work, err := store.ChainWork(); err != nil || work.Int64() != 10 {
+		t.Fatalf("chain work after reorg: %v %v", work, err)
+	}
+	oldID := crypto.HexEncode(core.ComputeTxID(local[4].Transactions[0]))
+	if _, _, ok, err := store.LookupTx(oldID); err != nil || ok {
+		t.Fatalf("tx of a disconnected block should leave the index: %v %v", ok, err)
+	}
+	if utxos, err := store.AddressUTXOs("minerA"); err != nil || len(utxos) != 0 {
+		t.Fatalf("disconnected coinbases should leave the address index: %+v %v", utxos, err)
+	}
+	if utxos, err := store.AddressUTXOs("minerB"); err != nil || len(utxos) != 2 {
+		t.Fatalf("new coinbases should be indexed: %+v %v", utxos, err)
+	}
+	if err := NewSyncer(peer.URL).SyncBlocks(store); err != nil || len(fetched) != 2 {
+		t.Fatalf("synced chain should not fetch again: %v %v", fetched, err)
 	}
 
-	heights, err := store.ListBlockHeights()
-	if err != nil {
-		t.Fatalf("list heights: %v", err)
+	// 新链第 5 个区块无法写入：回退并恢复原链
+	restored := mustStore(t, base, "restored")
+	for _, b := range local {
+		if err := restored.SaveBlock(b); err != nil {
+			t.Fatalf("save local block: %v", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(base, "restored", "blocks", "5.json", "x"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := NewSyncer(serveChain(t, "tall", tall).URL).SyncBlocks(restored); err == nil {
+		t.Fatalf("reorg should fail when a new block cannot be written")
 	}
-	if len(heights) != 2 {
-		t.Fatalf("expect 2 blocks after reorg, got %d", len(heights))
+	assertSameChain(t, restored, local)
+	if work, err := restored.ChainWork(); err != nil || work.Int64() != 5 {
+		t.Fatalf("chain work after rollback: %v %v", work, err)
 	}
-	b1, _ := store.LoadBlock(1)
-	if string(core.HashBlockHeader(&b1.Header)) != string(core.HashBlockHeader(&block1.Header)) {
-		t.Fatalf("block1 hash mismatch after reorg")
+	if _, loc, ok, err := restored.LookupTx(oldID); err != nil || !ok || loc.Height != 4 {
+		t.Fatalf("restored block should be indexed again: %+v %v %v", loc, ok, err)
+	}
+	if utxos, err := restored.AddressUTXOs("minerC"); err != nil || len(utxos) != 0 {
+		t.Fatalf("blocks of the abandoned reorg should not stay indexed: %+v %v", utxos, err)
 	}
 }
 
+// TestDisconnectBlocks 回退删除的高度在索引日志中以删除记录保留，重放日志与内存索引一致
+func TestDisconnectBlocks(t *testing.T) {
+	base := t.TempDir()
+	store := mustStore(t, base, "disc")
+	chain := buildChain(nil, 4, "minerA")
+	for _, b := range chain {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+	if err := store.DisconnectBlocks(1); err != nil {
+		t.Fatalf("disconnect: %v", err)
+	}
+	reopened, err := storage.NewFileStorage(base, "disc")
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	for _, s := range []*storage.FileStorage{store, reopened} {
+		assertSameChain(t, s, chain[:2])
+		if filters, err := s.BlockFilters(); err != nil || len(filters) != 2 {
+			t.Fatalf("filters after disconnect: %d %v", len(filters), err)
+		}
+	}
+}
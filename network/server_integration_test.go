@@ -22,7 +22,7 @@ func TestServerThreeNodesSync(t *testing.T) {
 	storeC := mustStore(t, base, "nC")
 
 	// 节点 A 写入创世块和一笔交易
-	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50)}, 4)
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50, 0)}, 4)
 	if err := storeA.SaveBlock(genesis); err != nil {
 		t.Fatalf("save genesis A: %v", err)
 	}
@@ -141,6 +141,7 @@ func startNodeServerForTest(t *testing.T, nodeID string, store *storage.FileStor
 	mux := http.NewServeMux()
 	mux.HandleFunc("/status", ns.handleStatus)
 	mux.HandleFunc("/block", ns.handleBlock)
+	mux.HandleFunc("/headers", ns.handleHeaders)
 	mux.HandleFunc("/txpool", ns.handleTxPool)
 	srv := httptest.NewServer(mux)
 	t.Cleanup(func() { srv.Close() })
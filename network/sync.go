@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/yiqi-017/blockchain/core"
@@ -16,6 +18,8 @@ import (
 type Syncer struct {
 	Peer   string // 例如 http://127.0.0.1:8081
 	Client *http.Client
+	// Params 共识参数，nil 时取默认值
+	Params *core.ChainParams
 	// fetchBlockFn 注入便于测试；生产使用默认 HTTP 拉取
 	fetchBlockFn func(height uint64) (*core.Block, error)
 }
@@ -30,121 +34,50 @@ func NewSyncer(peer string) *Syncer {
 	}
 }
 
-// SyncBlocks 拉取缺失区块并落盘
+// SyncBlocks 以头优先方式从该 peer 拉取缺失区块并落盘，分叉且对端累计工作量更大时回退到分叉点后切换
 func (s *Syncer) SyncBlocks(store *storage.FileStorage) error {
-	status, err := s.fetchStatus()
-	if err != nil {
-		return err
-	}
-
-	localHeights, err := store.ListBlockHeights()
-	if err != nil {
-		return err
-	}
-	// 若本地为空，则从 0 开始拉取（含创世）
-	var start uint64
-	if len(localHeights) == 0 {
-		start = 0
-	} else {
-		start = localHeights[len(localHeights)-1] + 1
-	}
-
-	if status.Height+1 <= start {
-		return nil // 无需同步
-	}
-
-	// 尝试增量同步；遇到冲突时进行重组
-	for h := start; h <= status.Height; h++ {
-		block, err := s.fetchBlockInternal(h)
-		if err != nil {
-			return fmt.Errorf("fetch block %d: %w", h, err)
-		}
-		if err := validateAndPersistBlock(store, block); err != nil {
-			if errors.Is(err, errConflictBlock) {
-				return s.reorgFromPeer(store, status.Height)
-			}
-			return fmt.Errorf("validate block %d: %w", h, err)
-		}
-	}
-	return nil
+	return (&BlockDownload{Peers: []*Syncer{s}, Params: s.Params}).Run(store)
 }
 
-// reorgFromPeer 拉取对端全链并替换（当对端更长时）
-func (s *Syncer) reorgFromPeer(store *storage.FileStorage, peerTip uint64) error {
-	// 仅当对端链更长时才重组
-	localHeights, err := store.ListBlockHeights()
-	if err != nil {
-		return err
-	}
-	if uint64(len(localHeights)) >= peerTip+1 {
-		return fmt.Errorf("peer conflict but not longer chain")
-	}
-
-	var genesisHash []byte
-	if len(localHeights) > 0 {
-		g, err := store.LoadBlock(0)
-		if err != nil {
-			return err
+// revalidateTxPool 依据新链剔除池中已失效的交易（时间锁未到期的仍保留）
+func revalidateTxPool(store *storage.FileStorage, blocks []*core.Block, params core.ChainParams) {
+	pool, err := store.LoadTxPool()
+	if err != nil || pool.Size() == 0 {
+		return
+	}
+	utxos := core.BuildUTXOSet(blocks)
+	var height uint64
+	if len(blocks) > 0 {
+		height = blocks[len(blocks)-1].Header.Height + 1
+	}
+	medianTime := core.MedianTimePast(blocks)
+
+	// 多轮扫描以接纳依赖池内父交易的子交易
+	remaining := pool.Snapshot()
+	for {
+		progress := false
+		for id, tx := range remaining {
+			err := core.ValidateTransaction(tx, utxos, height, medianTime, params)
+			if err != nil && !errors.Is(err, core.ErrTxNotFinal) {
+				continue
+			}
+			core.ApplyTxToUTXO(utxos, tx, height, medianTime)
+			delete(remaining, id)
+			progress = true
 		}
-		genesisHash = core.HashBlockHeader(&g.Header)
-	}
-
-	blocks := make([]*core.Block, 0, peerTip+1)
-	for h := uint64(0); h <= peerTip; h++ {
-		b, err := s.fetchBlockInternal(h)
-		if err != nil {
-			return fmt.Errorf("fetch block %d during reorg: %w", h, err)
+		if !progress || len(remaining) == 0 {
+			break
 		}
-		blocks = append(blocks, b)
 	}
-	if err := validateChainWithGenesis(blocks, genesisHash); err != nil {
-		return fmt.Errorf("peer chain invalid: %w", err)
+	if len(remaining) == 0 {
+		return
 	}
-	if err := store.ClearBlocks(); err != nil {
-		return fmt.Errorf("clear local blocks: %w", err)
+	ids := make([]string, 0, len(remaining))
+	for id := range remaining {
+		ids = append(ids, id)
 	}
-	for _, b := range blocks {
-		if err := store.SaveBlock(b); err != nil {
-			return fmt.Errorf("rewrite block %d: %w", b.Header.Height, err)
-		}
-	}
-	return nil
-}
-
-// validateChainWithGenesis 校验链的连续性、Merkle 和 POW，并在提供时校验创世哈希
-func validateChainWithGenesis(blocks []*core.Block, expectGenesis []byte) error {
-	var prevHash []byte
-	for i, b := range blocks {
-		if b == nil {
-			return fmt.Errorf("nil block at %d", i)
-		}
-		if b.Header.Height != uint64(i) {
-			return fmt.Errorf("height mismatch at %d", i)
-		}
-		if i == 0 {
-			if len(b.Header.PrevHash) != 0 {
-				return fmt.Errorf("genesis prev hash not empty")
-			}
-			if len(expectGenesis) > 0 {
-				if !bytes.Equal(core.HashBlockHeader(&b.Header), expectGenesis) {
-					return fmt.Errorf("genesis hash mismatch")
-				}
-			}
-		} else {
-			if !bytes.Equal(prevHash, b.Header.PrevHash) {
-				return fmt.Errorf("prev hash mismatch at %d", i)
-			}
-		}
-		merkle := core.ComputeMerkleRoot(b.Transactions)
-		if !bytes.Equal(merkle, b.Header.MerkleRoot) {
-			return fmt.Errorf("merkle mismatch at %d", i)
-		}
-		if !core.ValidateBlockPOW(b) {
-			return fmt.Errorf("pow invalid at %d", i)
-		}
-		prevHash = core.HashBlockHeader(&b.Header)
-	}
-	return nil
+	pool.RemoveMany(ids)
+	_ = store.SaveTxPool(pool)
 }
 
 // SyncTxPool 直接覆盖本地交易池
@@ -156,7 +89,7 @@ func (s *Syncer) SyncTxPool(store *storage.FileStorage) error {
 	defer resp.Body.Close()
 
 	var payload TxPoolResponse
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+	if err := decodeResponse(resp, maxBlockMessageBytes, &payload); err != nil {
 		return err
 	}
 
@@ -167,6 +100,29 @@ func (s *Syncer) SyncTxPool(store *storage.FileStorage) error {
 	return store.SaveTxPool(pool)
 }
 
+// SubmitTx 将已签名交易提交到 peer 的 /tx 接口；返回 held 表示交易有效但时间锁未到期，暂存于交易池
+func (s *Syncer) SubmitTx(tx *core.Transaction) (bool, error) {
+	body, err := json.Marshal(tx)
+	if err != nil {
+		return false, err
+	}
+	url := s.Peer + "/tx"
+	resp, err := s.Client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return false, nil
+	case http.StatusAccepted:
+		return true, nil
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("POST %s status %d: %s", url, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+}
+
 // fetchStatus 获取对端高度
 func (s *Syncer) fetchStatus() (*StatusResponse, error) {
 	resp, err := s.get("/status")
@@ -203,6 +159,35 @@ func (s *Syncer) fetchBlockInternal(height uint64) (*core.Block, error) {
 	return payload.Block, nil
 }
 
+// FetchProof 向 peer 请求已上链交易的区块头与 Merkle 包含证明（不做校验，由调用方对照区块头链验证）
+func (s *Syncer) FetchProof(txid string) (*ProofResponse, error) {
+	resp, err := s.get("/proof?txid=" + url.QueryEscape(txid))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out ProofResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if out.Proof == nil {
+		return nil, errors.New("proof missing in response")
+	}
+	return &out, nil
+}
+
+// decodeResponse 解析 peer 的响应体，超过 limit 字节时停止读取并返回错误
+func decodeResponse(resp *http.Response, limit int64, v any) error {
+	body := &io.LimitedReader{R: resp.Body, N: limit + 1}
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		if body.N <= 0 {
+			return fmt.Errorf("response from %s exceeds %d bytes", resp.Request.URL, limit)
+		}
+		return err
+	}
+	return nil
+}
+
 func (s *Syncer) get(path string) (*http.Response, error) {
 	url := s.Peer + path
 	resp, err := s.Client.Get(url)
@@ -31,7 +31,7 @@ func TestTxBroadcast(t *testing.T) {
 	t.Cleanup(func() { srvA.Close() })
 
 	// 构造交易并 POST 到 A
-	tx := core.NewCoinbaseTx("alice", 5) // coinbase 免输入校验，便于测试
+	tx := core.NewCoinbaseTx("alice", 5, 0) // coinbase 免输入校验，便于测试
 	body, _ := json.Marshal(tx)
 	resp, err := http.Post(srvA.URL+"/tx", "application/json", bytes.NewReader(body))
 	if err != nil {
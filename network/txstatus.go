@@ -0,0 +1,73 @@
+package network
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// LookupTx 查询交易状态：先查链上索引，再查交易池；都没有时状态为 unknown
+func LookupTx(store *storage.FileStorage, txid string) (*TxStatusResponse, error) {
+	txid = strings.ToLower(strings.TrimSpace(txid))
+	resp := &TxStatusResponse{TxID: txid, Status: TxStatusUnknown}
+
+	tx, loc, ok, err := store.LookupTx(txid)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		tip, err := latestHeight(store)
+		if err != nil {
+			return nil, err
+		}
+		resp.Status = TxStatusConfirmed
+		resp.Transaction = tx
+		resp.BlockHeight = &loc.Height
+		resp.BlockIndex = &loc.Index
+		if tip >= loc.Height {
+			resp.Confirmations = tip - loc.Height + 1
+		}
+		return resp, nil
+	}
+
+	pool, err := store.LoadTxPool()
+	if err != nil {
+		return nil, err
+	}
+	if tx, ok := pool.Get(txid); ok && crypto.HexEncode(core.ComputeTxID(tx)) == txid {
+		resp.Status = TxStatusPending
+		resp.Transaction = tx
+		return resp, nil
+	}
+	// 池键不一定是交易 ID，退回逐笔比对
+	for _, tx := range pool.Pending() {
+		if crypto.HexEncode(core.ComputeTxID(tx)) == txid {
+			resp.Status = TxStatusPending
+			resp.Transaction = tx
+			return resp, nil
+		}
+	}
+	return resp, nil
+}
+
+// handleGetTx GET /tx?id=<txid> 返回交易及其状态；未知交易返回 404（仍带 JSON 状态）
+func (s *NodeServer) handleGetTx(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	resp, err := LookupTx(s.Store, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if resp.Status == TxStatusUnknown {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,82 @@
+package network
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestGetTxStatus GET /tx?id= 返回已确认交易的位置与确认数、池内交易为 pending、未知交易 404
+func TestGetTxStatus(t *testing.T) {
+	store := mustStore(t, t.TempDir(), "txs")
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 0)}, 0)
+	spend := &core.Transaction{
+		Inputs:  []core.TxInput{{TxID: core.ComputeTxID(genesis.Transactions[0]), Vout: 0}},
+		Outputs: []core.TxOutput{{Value: 50, ScriptPubKey: "addr2"}},
+	}
+	block1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 1), spend}, 0)
+	block2 := core.MineBlock(block1, []*core.Transaction{core.NewCoinbaseTx("addr1", 50, 2)}, 0)
+	for _, b := range []*core.Block{genesis, block1, block2} {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+	pending := &core.Transaction{
+		Inputs:  []core.TxInput{{TxID: core.ComputeTxID(spend), Vout: 0}},
+		Outputs: []core.TxOutput{{Value: 49, ScriptPubKey: "addr3"}},
+	}
+	pool := core.NewTxPool()
+	pool.Add("custom-key", pending)
+	if err := store.SaveTxPool(pool); err != nil {
+		t.Fatalf("save pool: %v", err)
+	}
+
+	ns := &NodeServer{NodeID: "txs", Store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tx", ns.handleTx)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() { srv.Close() })
+	get := func(id string) (int, TxStatusResponse) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/tx?id=" + id)
+		if err != nil {
+			t.Fatalf("get tx: %v", err)
+		}
+		defer resp.Body.Close()
+		var out TxStatusResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.StatusCode, out
+	}
+
+	code, out := get(crypto.HexEncode(core.ComputeTxID(spend)))
+	if code != http.StatusOK || out.Status != TxStatusConfirmed || out.BlockHeight == nil || *out.BlockHeight != 1 || *out.BlockIndex != 1 || out.Confirmations != 2 {
+		t.Fatalf("confirmed tx: %d %+v", code, out)
+	}
+	if out.Transaction == nil || out.Transaction.Outputs[0].ScriptPubKey != "addr2" {
+		t.Fatalf("confirmed tx body missing")
+	}
+	if code, out = get(crypto.HexEncode(core.ComputeTxID(pending))); code != http.StatusOK || out.Status != TxStatusPending || out.Confirmations != 0 {
+		t.Fatalf("pending tx: %d %+v", code, out)
+	}
+	if code, out = get("00ff"); code != http.StatusNotFound || out.Status != TxStatusUnknown {
+		t.Fatalf("unknown tx: %d %+v", code, out)
+	}
+
+	// 重组覆盖高度 1 后，旧交易不再可查
+	fork := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("addr9", 50, 1)}, 0)
+	if err := store.SaveBlock(fork); err != nil {
+		t.Fatalf("save fork: %v", err)
+	}
+	if code, out = get(crypto.HexEncode(core.ComputeTxID(spend))); code != http.StatusNotFound {
+		t.Fatalf("reorged-out tx should be unknown: %d %+v", code, out)
+	}
+	if code, out = get(crypto.HexEncode(core.ComputeTxID(fork.Transactions[0]))); out.Status != TxStatusConfirmed || out.Confirmations != 2 {
+		t.Fatalf("fork coinbase: %d %+v", code, out)
+	}
+}
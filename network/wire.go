@@ -0,0 +1,117 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// 线路协议：TCP 长连接上的定长帧头 + JSON 负载
+//
+//	magic(4) | command(12，ASCII，右补 0) | length(4，小端) | checksum(4，负载双 SHA-256 的前 4 字节) | payload
+const (
+	// ProtocolVersion 当前线路协议版本
+	ProtocolVersion uint32 = 1
+	// minProtocolVersion 可接受的最低对端协议版本
+	minProtocolVersion uint32 = 1
+
+	wireHeaderSize  = 24
+	wireCommandSize = 12
+	// maxWirePayload 单条消息负载上限，与 HTTP 区块请求体上限一致
+	maxWirePayload = maxBlockMessageBytes
+)
+
+// WireMagic 帧起始魔数，区分本网络与其他协议的数据
+var WireMagic = [4]byte{0x59, 0x51, 0x42, 0x43}
+
+// 线路协议命令
+const (
+	CmdVersion   = "version"
+	CmdVerack    = "verack"
+	CmdPing      = "ping"
+	CmdPong      = "pong"
+	CmdGetStatus = "getstatus"
+	CmdStatus    = "status"
+	CmdGetBlock  = "getblock"
+	CmdBlock     = "block"
+	CmdNotFound  = "notfound"
+	CmdTx        = "tx"
+)
+
+// WriteMessage 把命令与负载编码为一帧写出
+func WriteMessage(w io.Writer, command string, payload []byte) error {
+	if len(command) == 0 || len(command) > wireCommandSize {
+		return fmt.Errorf("%w: %q", errBadCommand, command)
+	}
+	if len(payload) > maxWirePayload {
+		return errWireTooLarge
+	}
+	var header [wireHeaderSize]byte
+	copy(header[0:4], WireMagic[:])
+	copy(header[4:16], command)
+	binary.LittleEndian.PutUint32(header[16:20], uint32(len(payload)))
+	copy(header[20:24], crypto.DoubleHash256(payload)[:4])
+	// 帧头与负载一次写出，避免多个 goroutine 交错时拆帧
+	_, err := w.Write(append(header[:], payload...))
+	return err
+}
+
+// ReadMessage 读取一帧，校验魔数、命令、长度上限与校验和
+func ReadMessage(r io.Reader) (string, []byte, error) {
+	var header [wireHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", nil, err
+	}
+	if !bytes.Equal(header[0:4], WireMagic[:]) {
+		return "", nil, errBadMagic
+	}
+	command, err := parseCommand(header[4:16])
+	if err != nil {
+		return "", nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[16:20])
+	if length > maxWirePayload {
+		return command, nil, fmt.Errorf("%w: %s %d bytes", errWireTooLarge, command, length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return command, nil, err
+	}
+	if !bytes.Equal(crypto.DoubleHash256(payload)[:4], header[20:24]) {
+		return command, nil, errBadChecksum
+	}
+	return command, payload, nil
+}
+
+// parseCommand 命令须为可打印 ASCII，其后只能是补位的 0
+func parseCommand(raw []byte) (string, error) {
+	n := bytes.IndexByte(raw, 0)
+	if n < 0 {
+		n = len(raw)
+	}
+	if n == 0 {
+		return "", errBadCommand
+	}
+	for i, c := range raw {
+		if i < n && (c < 0x21 || c > 0x7e) || i >= n && c != 0 {
+			return "", errBadCommand
+		}
+	}
+	return string(raw[:n]), nil
+}
+
+// writeJSONMessage 以 JSON 编码负载后写出一帧
+func writeJSONMessage(w io.Writer, command string, v any) error {
+	payload := []byte("{}")
+	if v != nil {
+		var err error
+		if payload, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	return WriteMessage(w, command, payload)
+}
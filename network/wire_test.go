@@ -0,0 +1,234 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestWireFraming 帧编码往返；魔数、校验和、命令错误与超长负载被拒绝
+func TestWireFraming(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, CmdPing, []byte(`{"nonce":7}`)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	frame := append([]byte(nil), buf.Bytes()...)
+	command, payload, err := ReadMessage(bytes.NewReader(frame))
+	if err != nil || command != CmdPing || string(payload) != `{"nonce":7}` {
+		t.Fatalf("round trip: %q %q %v", command, payload, err)
+	}
+
+	corrupt := func(i int, b byte) []byte {
+		f := append([]byte(nil), frame...)
+		f[i] = b
+		return f
+	}
+	for name, tc := range map[string]struct {
+		frame []byte
+		want  error
+	}{
+		"magic":    {corrupt(0, 0), errBadMagic},
+		"checksum": {corrupt(len(frame)-1, '8'), errBadChecksum},
+		"command":  {corrupt(5, 0), errBadCommand},
+	} {
+		if _, _, err := ReadMessage(bytes.NewReader(tc.frame)); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expect %v, got %v", name, tc.want, err)
+		}
+	}
+
+	huge := corrupt(0, WireMagic[0])
+	binary.LittleEndian.PutUint32(huge[16:20], maxWirePayload+1)
+	if _, _, err := ReadMessage(bytes.NewReader(huge)); !errors.Is(err, errWireTooLarge) {
+		t.Fatalf("oversized: %v", err)
+	}
+	if err := WriteMessage(&buf, "commandtoolong", nil); !errors.Is(err, errBadCommand) {
+		t.Fatalf("long command: %v", err)
+	}
+}
+
+func newWireNode(t *testing.T, name string, blocks []*core.Block) *WireNode {
+	t.Helper()
+	store := mustStore(t, t.TempDir(), name)
+	for _, b := range blocks {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save %s block: %v", name, err)
+		}
+	}
+	n := NewWireNode(&NodeServer{NodeID: name, Store: store})
+	t.Cleanup(n.Close)
+	return n
+}
+
+func listenWire(t *testing.T, n *WireNode) string {
+	t.Helper()
+	l, err := n.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return l.Addr().String()
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func tipHeight(store *storage.FileStorage) uint64 {
+	h, _ := latestHeight(store)
+	return h
+}
+
+// TestWireSync 握手交换创世哈希与高度，拒绝不同创世链与连到自身；落后节点经 getblock 补齐，新区块主动推送并继续转发
+func TestWireSync(t *testing.T) {
+	chain := buildChain(nil, 5, "alice")
+	a := newWireNode(t, "a", chain)
+	b := newWireNode(t, "b", chain[:1])
+	c := newWireNode(t, "c", nil)
+	addrA := listenWire(t, a)
+	addrB := listenWire(t, b)
+
+	p, err := b.Connect(addrA)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	v := p.Version()
+	if v.NodeID != "a" || v.Height != 4 || v.Version != ProtocolVersion || v.GenesisHash != crypto.HexEncode(core.HashBlockHeader(&chain[0].Header)) {
+		t.Fatalf("unexpected version: %+v", v)
+	}
+	b.Maintain([]string{addrA})
+	if len(b.Peers()) != 1 {
+		t.Fatalf("maintain should reuse the connection, got %d peers", len(b.Peers()))
+	}
+	assertSameChain(t, b.Server.Store, chain)
+
+	// c 尚无区块，连上 b 后补齐；此后 a 推送的新区块经 b 转发到 c
+	if _, err := c.Connect(addrB); err != nil {
+		t.Fatalf("connect c: %v", err)
+	}
+	c.Maintain(nil)
+	assertSameChain(t, c.Server.Store, chain)
+
+	next := buildChain(chain, 1, "alice")
+	if err := a.Server.Store.SaveBlock(next[5]); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	a.BroadcastBlock(next[5], nil)
+	waitFor(t, "block relay", func() bool { return tipHeight(c.Server.Store) == 5 })
+	assertSameChain(t, b.Server.Store, next)
+
+	other := newWireNode(t, "other", buildChain(nil, 1, "mallory"))
+	if _, err := other.Connect(addrA); !errors.Is(err, errHandshake) {
+		t.Fatalf("genesis mismatch should fail handshake, got %v", err)
+	}
+	if _, err := a.Connect(addrA); !errors.Is(err, errHandshake) {
+		t.Fatalf("self connection should fail handshake, got %v", err)
+	}
+}
+
+// rawHandshake 以裸 TCP 连接完成握手，返回连接与读取器
+func rawHandshake(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	r := bufio.NewReader(conn)
+	if err := writeJSONMessage(conn, CmdVersion, VersionMessage{Version: ProtocolVersion, NodeID: "raw", Nonce: 1}); err != nil {
+		t.Fatalf("send version: %v", err)
+	}
+	for _, want := range []string{CmdVersion, CmdVerack} {
+		command, _, err := ReadMessage(r)
+		if err != nil || command != want {
+			t.Fatalf("expect %s, got %q %v", want, command, err)
+		}
+	}
+	if err := writeJSONMessage(conn, CmdVerack, nil); err != nil {
+		t.Fatalf("send verack: %v", err)
+	}
+	return conn, r
+}
+
+// TestWireKeepalive 正常连接以 ping/pong 保活并测得延迟；不回 pong 的连接被断开；帧错误断开连接并计入不当行为分值
+func TestWireKeepalive(t *testing.T) {
+	chain := buildChain(nil, 2, "alice")
+	a := newWireNode(t, "a", chain)
+	bans, err := NewBanManager(nil, time.Hour)
+	if err != nil {
+		t.Fatalf("ban manager: %v", err)
+	}
+	a.Server.Bans = bans
+	a.PingInterval = 50 * time.Millisecond
+	addr := listenWire(t, a)
+
+	b := newWireNode(t, "b", chain)
+	b.PingInterval = 50 * time.Millisecond
+	p, err := b.Connect(addr)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	waitFor(t, "pong", func() bool { return p.Info().LastPong != 0 })
+	if st, err := p.Status(); err != nil || st.Height != 1 || st.NodeID != "a" {
+		t.Fatalf("status: %+v %v", st, err)
+	}
+
+	// 只完成握手、从不回 pong 的连接在 2 个保活间隔后被断开
+	_, r := rawHandshake(t, addr)
+	pinged := false
+	for {
+		command, _, err := ReadMessage(r)
+		if err != nil {
+			break
+		}
+		pinged = pinged || command == CmdPing
+	}
+	if !pinged {
+		t.Fatalf("expect ping before disconnect")
+	}
+	waitFor(t, "silent peer removal", func() bool { return len(a.Peers()) == 1 })
+
+	conn, r := rawHandshake(t, addr)
+	if _, err := conn.Write(bytes.Repeat([]byte{0xff}, wireHeaderSize)); err != nil {
+		t.Fatalf("write garbage: %v", err)
+	}
+	for {
+		if _, _, err := ReadMessage(r); err != nil {
+			break
+		}
+	}
+	if _, scores := bans.Bans(); scores["127.0.0.1"] != scoreBadMessage {
+		t.Fatalf("bad frame should be scored, got %v", scores)
+	}
+
+	// 推送的无效交易同样计分，有效连接不受影响；引用缺失输出的交易可能来自诚实转发，不计分
+	orphan := &core.Transaction{Inputs: []core.TxInput{{TxID: []byte("missing")}}}
+	bad := &core.Transaction{Outputs: []core.TxOutput{{Value: 1, ScriptPubKey: "mallory"}}}
+	for _, tx := range []*core.Transaction{orphan, bad} {
+		if err := p.send(CmdTx, tx); err != nil {
+			t.Fatalf("send tx: %v", err)
+		}
+	}
+	waitFor(t, "invalid tx score", func() bool {
+		_, scores := bans.Bans()
+		return scores["127.0.0.1"] == scoreBadMessage+scoreInvalidTx
+	})
+	select {
+	case <-p.Done():
+		t.Fatalf("peer should stay connected")
+	default:
+	}
+}
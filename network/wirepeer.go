@@ -0,0 +1,763 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+const (
+	// defaultPingInterval 保活 ping 间隔；超过 2 个间隔未收到 pong、或 3 个间隔无任何消息即断开
+	defaultPingInterval = 30 * time.Second
+	// defaultHandshakeTimeout 建立连接与 version/verack 握手的超时
+	defaultHandshakeTimeout = 10 * time.Second
+	// wireRequestTimeout getstatus、getblock 等待应答及单次写出的超时
+	wireRequestTimeout = 10 * time.Second
+	// maxSeenTxs 已处理交易 ID 的缓存上限，用于抑制交易在节点间来回转发
+	maxSeenTxs = 10000
+)
+
+// WirePeerInfo /peers 返回的线路协议连接状态
+type WirePeerInfo struct {
+	Addr      string `json:"addr"`
+	NodeID    string `json:"node_id"`
+	Version   uint32 `json:"version"`
+	Inbound   bool   `json:"inbound"`
+	Height    uint64 `json:"height"`
+	LatencyMs int64  `json:"latency_ms"`
+	LastPong  int64  `json:"last_pong,omitempty"`
+}
+
+// WireNode 线路协议节点：监听并发起 TCP 长连接，握手后收发区块、交易与状态消息，新区块、新交易主动推送给其他连接
+// 区块与交易的校验、落盘复用 Server 的存储与交易池逻辑，不当行为计入 Server.Bans
+type WireNode struct {
+	Server           *NodeServer
+	PingInterval     time.Duration
+	HandshakeTimeout time.Duration
+
+	nonce uint64
+
+	mu        sync.Mutex
+	peers     map[*WirePeer]struct{}
+	listeners []net.Listener
+	seenTxs   map[string]struct{}
+}
+
+// NewWireNode 以 HTTP 节点的存储、封禁管理器创建线路协议节点
+func NewWireNode(server *NodeServer) *WireNode {
+	return &WireNode{
+		Server:           server,
+		PingInterval:     defaultPingInterval,
+		HandshakeTimeout: defaultHandshakeTimeout,
+		nonce:            randomNonce(),
+		peers:            make(map[*WirePeer]struct{}),
+		seenTxs:          make(map[string]struct{}),
+	}
+}
+
+// Listen 在 addr 上监听入站连接（后台接受），返回监听器以便获取实际地址
+func (n *WireNode) Listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := n.Serve(l); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("[wire] accept on %s: %v", l.Addr(), err)
+		}
+	}()
+	return l, nil
+}
+
+// Serve 在监听器上接受入站连接直到其关闭；被封禁地址的连接直接断开
+func (n *WireNode) Serve(l net.Listener) error {
+	n.mu.Lock()
+	n.listeners = append(n.listeners, l)
+	n.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if _, err := n.setup(conn, ""); err != nil {
+				log.Printf("[wire] inbound %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// Connect 连接 addr（host:port）并完成握手
+func (n *WireNode) Connect(addr string) (*WirePeer, error) {
+	conn, err := net.DialTimeout("tcp", addr, n.HandshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	p, err := n.setup(conn, addr)
+	if err != nil {
+		return nil, fmt.Errorf("connect %s: %w", addr, err)
+	}
+	return p, nil
+}
+
+// setup 握手成功后登记连接并启动读取、保活循环；握手失败断开，帧错误计入不当行为
+// dialAddr 为主动连接的目标地址，入站连接为空
+func (n *WireNode) setup(conn net.Conn, dialAddr string) (*WirePeer, error) {
+	p := &WirePeer{
+		node:     n,
+		conn:     conn,
+		r:        bufio.NewReader(conn),
+		inbound:  dialAddr == "",
+		host:     hostOf(conn.RemoteAddr()),
+		dialAddr: dialAddr,
+		blocks:   make(map[uint64][]chan *core.Block),
+		closed:   make(chan struct{}),
+	}
+	if n.Server.Bans.IsBanned(p.host) {
+		conn.Close()
+		return nil, errors.New("banned")
+	}
+	if err := p.handshake(); err != nil {
+		if score, reason := wireMisbehavior(err); score > 0 {
+			n.Server.Bans.Misbehaving(p.host, score, reason)
+		}
+		conn.Close()
+		return nil, err
+	}
+	n.mu.Lock()
+	n.peers[p] = struct{}{}
+	n.mu.Unlock()
+	go p.readLoop()
+	go p.pingLoop()
+	return p, nil
+}
+
+// Peers 返回已握手的连接
+func (n *WireNode) Peers() []*WirePeer {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := make([]*WirePeer, 0, len(n.peers))
+	for p := range n.peers {
+		out = append(out, p)
+	}
+	return out
+}
+
+// PeerInfos 返回各连接的状态快照
+func (n *WireNode) PeerInfos() []WirePeerInfo {
+	if n == nil {
+		return nil
+	}
+	var out []WirePeerInfo
+	for _, p := range n.Peers() {
+		out = append(out, p.Info())
+	}
+	return out
+}
+
+// Close 停止监听并断开全部连接
+func (n *WireNode) Close() {
+	n.mu.Lock()
+	listeners := n.listeners
+	n.listeners = nil
+	n.mu.Unlock()
+	for _, l := range listeners {
+		l.Close()
+	}
+	for _, p := range n.Peers() {
+		p.Close()
+	}
+}
+
+// BroadcastBlock 把区块推送给除 except 外的全部连接
+func (n *WireNode) BroadcastBlock(b *core.Block, except *WirePeer) {
+	if n == nil || b == nil {
+		return
+	}
+	n.broadcast(CmdBlock, BlockResponse{Block: b}, except)
+}
+
+// BroadcastTx 把交易推送给除 except 外的全部连接
+func (n *WireNode) BroadcastTx(tx *core.Transaction, except *WirePeer) {
+	if n == nil || tx == nil {
+		return
+	}
+	n.markSeenTx(tx)
+	n.broadcast(CmdTx, tx, except)
+}
+
+func (n *WireNode) broadcast(command string, v any, except *WirePeer) {
+	for _, p := range n.Peers() {
+		if p == except {
+			continue
+		}
+		// 慢连接不拖住其他连接，写出失败时断开
+		go func(p *WirePeer) {
+			if err := p.send(command, v); err != nil {
+				p.fail(err)
+			}
+		}(p)
+	}
+}
+
+// Maintain 连接 addrs 中尚未连接的节点，刷新各连接的高度，并从高于本地的连接补齐区块
+func (n *WireNode) Maintain(addrs []string) {
+	connected := make(map[string]bool)
+	for _, p := range n.Peers() {
+		if p.dialAddr != "" {
+			connected[p.dialAddr] = true
+		}
+	}
+	for _, a := range addrs {
+		if connected[a] {
+			continue
+		}
+		if _, err := n.Connect(a); err != nil {
+			log.Printf("[wire] %v", err)
+		}
+	}
+	for _, p := range n.Peers() {
+		if _, err := p.Status(); err != nil {
+			continue
+		}
+		if _, err := n.SyncFrom(p); err != nil {
+			log.Printf("[wire] sync from %s: %v", p.conn.RemoteAddr(), err)
+		}
+	}
+}
+
+// SyncFrom 按高度逐个向 peer 请求本地尚缺的区块并校验接入，返回接入数量
+// 与本地链分叉时返回 errConflictBlock，整链重组仍由 HTTP 同步完成
+func (n *WireNode) SyncFrom(p *WirePeer) (int, error) {
+	if !p.beginSync() {
+		return 0, nil
+	}
+	defer p.endSync()
+	added := 0
+	for {
+		heights, err := n.Server.Store.ListBlockHeights()
+		if err != nil {
+			return added, err
+		}
+		var next uint64
+		if len(heights) > 0 {
+			next = heights[len(heights)-1] + 1
+		}
+		if len(heights) > 0 && next > p.Height() {
+			return added, nil
+		}
+		b, err := p.FetchBlock(next)
+		if err != nil {
+			return added, err
+		}
+		if b == nil {
+			return added, nil // 对端尚无该区块
+		}
+		n.Server.chainMu.Lock()
+		err = validateAndPersistBlock(n.Server.Store, b, chainParams(n.Server.Params))
+		n.Server.chainMu.Unlock()
+		if err != nil {
+			if score, reason := blockMisbehavior(err); score > 0 {
+				p.misbehaving(score, reason)
+			}
+			return added, fmt.Errorf("block %d: %w", next, err)
+		}
+		added++
+	}
+}
+
+// acceptBlock 处理对端推送的区块：新区块校验接入后转发给其他连接；缺少前序区块时向其补齐
+func (n *WireNode) acceptBlock(from *WirePeer, b *core.Block) {
+	from.noteHeight(b.Header.Height)
+	n.Server.chainMu.Lock()
+	known := false
+	if existing, err := n.Server.Store.LoadBlock(b.Header.Height); err == nil && existing != nil {
+		known = bytes.Equal(core.HashBlockHeader(&existing.Header), core.HashBlockHeader(&b.Header))
+	}
+	var err error
+	if !known {
+		err = validateAndPersistBlock(n.Server.Store, b, chainParams(n.Server.Params))
+	}
+	n.Server.chainMu.Unlock()
+	switch {
+	case known:
+	case errors.Is(err, errConflictBlock):
+		go func() {
+			if _, err := n.SyncFrom(from); err != nil {
+				log.Printf("[wire] sync from %s: %v", from.conn.RemoteAddr(), err)
+			}
+		}()
+	case err != nil:
+		from.misbehaving(blockMisbehavior(err))
+	default:
+		n.BroadcastBlock(b, from)
+	}
+}
+
+// acceptTx 处理对端推送的交易：已处理过的忽略，新交易入池后转发给其他连接
+func (n *WireNode) acceptTx(from *WirePeer, tx *core.Transaction) {
+	if !n.markSeenTx(tx) {
+		return
+	}
+	if _, err := n.Server.acceptTx(tx); err != nil {
+		from.misbehaving(txMisbehavior(err))
+		return
+	}
+	n.broadcast(CmdTx, tx, from)
+}
+
+// markSeenTx 记录交易已处理，首次出现返回 true
+func (n *WireNode) markSeenTx(tx *core.Transaction) bool {
+	id := crypto.HexEncode(core.ComputeTxID(tx))
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.seenTxs[id]; ok {
+		return false
+	}
+	if len(n.seenTxs) >= maxSeenTxs {
+		n.seenTxs = make(map[string]struct{})
+	}
+	n.seenTxs[id] = struct{}{}
+	return true
+}
+
+// localVersion 本节点握手信息
+func (n *WireNode) localVersion() (VersionMessage, error) {
+	v := VersionMessage{Version: ProtocolVersion, NodeID: n.Server.NodeID, Nonce: n.nonce}
+	heights, err := n.Server.Store.ListBlockHeights()
+	if err != nil {
+		return v, err
+	}
+	if len(heights) == 0 {
+		return v, nil
+	}
+	v.Height = heights[len(heights)-1]
+	genesis, err := n.Server.Store.LoadBlock(0)
+	if err != nil {
+		return v, err
+	}
+	v.GenesisHash = crypto.HexEncode(core.HashBlockHeader(&genesis.Header))
+	return v, nil
+}
+
+func (n *WireNode) remove(p *WirePeer) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.peers, p)
+}
+
+// WirePeer 一条已握手的线路协议连接
+type WirePeer struct {
+	node     *WireNode
+	conn     net.Conn
+	r        *bufio.Reader
+	inbound  bool
+	host     string
+	dialAddr string
+	version  VersionMessage // 对端握手信息，握手后只读
+
+	wmu sync.Mutex // 串行化写出
+
+	mu        sync.Mutex
+	height    uint64
+	latency   time.Duration
+	lastPong  time.Time
+	pingNonce uint64
+	pingSent  time.Time
+	syncing   bool
+	blocks    map[uint64][]chan *core.Block
+	statuses  []chan StatusResponse
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Version 对端握手信息
+func (p *WirePeer) Version() VersionMessage {
+	return p.version
+}
+
+// Height 对端已知最高高度（握手、status 与区块推送更新）
+func (p *WirePeer) Height() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.height
+}
+
+// Info 连接状态快照
+func (p *WirePeer) Info() WirePeerInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info := WirePeerInfo{
+		Addr:      p.conn.RemoteAddr().String(),
+		NodeID:    p.version.NodeID,
+		Version:   p.version.Version,
+		Inbound:   p.inbound,
+		Height:    p.height,
+		LatencyMs: p.latency.Milliseconds(),
+	}
+	if !p.lastPong.IsZero() {
+		info.LastPong = p.lastPong.Unix()
+	}
+	return info
+}
+
+// Done 连接断开后关闭
+func (p *WirePeer) Done() <-chan struct{} {
+	return p.closed
+}
+
+// Close 断开连接
+func (p *WirePeer) Close() {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		p.conn.Close()
+		p.node.remove(p)
+	})
+}
+
+func (p *WirePeer) fail(err error) {
+	select {
+	case <-p.closed:
+	default:
+		log.Printf("[wire] disconnect %s: %v", p.conn.RemoteAddr(), err)
+		p.Close()
+	}
+}
+
+// Status 请求对端状态并更新其高度
+func (p *WirePeer) Status() (*StatusResponse, error) {
+	ch := make(chan StatusResponse, 1)
+	p.mu.Lock()
+	p.statuses = append(p.statuses, ch)
+	p.mu.Unlock()
+	if err := p.send(CmdGetStatus, nil); err != nil {
+		return nil, err
+	}
+	select {
+	case st := <-ch:
+		return &st, nil
+	case <-p.closed:
+		return nil, errors.New("connection closed")
+	case <-time.After(wireRequestTimeout):
+		return nil, errors.New("getstatus timeout")
+	}
+}
+
+// FetchBlock 请求指定高度的区块；对端没有时返回 nil
+func (p *WirePeer) FetchBlock(height uint64) (*core.Block, error) {
+	ch := make(chan *core.Block, 1)
+	p.mu.Lock()
+	p.blocks[height] = append(p.blocks[height], ch)
+	p.mu.Unlock()
+	if err := p.send(CmdGetBlock, GetBlockMessage{Height: height}); err != nil {
+		return nil, err
+	}
+	select {
+	case b := <-ch:
+		return b, nil
+	case <-p.closed:
+		return nil, errors.New("connection closed")
+	case <-time.After(wireRequestTimeout):
+		// 只撤下自己的等待者，同一高度的其他请求仍等待回复
+		p.mu.Lock()
+		waiters := p.blocks[height]
+		for i, w := range waiters {
+			if w == ch {
+				waiters = append(waiters[:i:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(waiters) == 0 {
+			delete(p.blocks, height)
+		} else {
+			p.blocks[height] = waiters
+		}
+		p.mu.Unlock()
+		return nil, fmt.Errorf("getblock %d timeout", height)
+	}
+}
+
+func (p *WirePeer) send(command string, v any) error {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	p.conn.SetWriteDeadline(time.Now().Add(wireRequestTimeout))
+	return writeJSONMessage(p.conn, command, v)
+}
+
+// handshake 双方各自先发 version，收到对端 version 校验后回 verack；收齐对端 version 与 verack 即完成
+func (p *WirePeer) handshake() error {
+	p.conn.SetDeadline(time.Now().Add(p.node.HandshakeTimeout))
+	defer p.conn.SetDeadline(time.Time{})
+	local, err := p.node.localVersion()
+	if err != nil {
+		return err
+	}
+	if err := p.send(CmdVersion, local); err != nil {
+		return err
+	}
+	gotVersion, gotVerack := false, false
+	for !gotVersion || !gotVerack {
+		command, payload, err := ReadMessage(p.r)
+		if err != nil {
+			return err
+		}
+		switch {
+		case command == CmdVersion && !gotVersion:
+			var v VersionMessage
+			if err := json.Unmarshal(payload, &v); err != nil {
+				return fmt.Errorf("%w: %w: %v", errHandshake, errBadCommand, err)
+			}
+			if err := checkVersion(local, v); err != nil {
+				return err
+			}
+			p.version, p.height = v, v.Height
+			if err := p.send(CmdVerack, nil); err != nil {
+				return err
+			}
+			gotVersion = true
+		case command == CmdVerack && !gotVerack:
+			gotVerack = true
+		default:
+			return fmt.Errorf("%w: %w: unexpected %q", errHandshake, errBadCommand, command)
+		}
+	}
+	return nil
+}
+
+// checkVersion 拒绝过旧的协议版本、连到自身的连接与创世区块不同的链（任一方尚无区块时不比较）
+func checkVersion(local, remote VersionMessage) error {
+	switch {
+	case remote.Version < minProtocolVersion:
+		return fmt.Errorf("%w: protocol version %d too old", errHandshake, remote.Version)
+	case remote.Nonce == local.Nonce:
+		return fmt.Errorf("%w: connected to self", errHandshake)
+	case local.GenesisHash != "" && remote.GenesisHash != "" && local.GenesisHash != remote.GenesisHash:
+		return fmt.Errorf("%w: genesis mismatch %s", errHandshake, remote.GenesisHash)
+	}
+	return nil
+}
+
+// readLoop 读取并处理消息直到连接断开；3 个保活间隔内无任何消息视为断开
+func (p *WirePeer) readLoop() {
+	defer p.Close()
+	for {
+		p.conn.SetReadDeadline(time.Now().Add(3 * p.node.PingInterval))
+		command, payload, err := ReadMessage(p.r)
+		if err != nil {
+			if score, reason := wireMisbehavior(err); score > 0 {
+				p.misbehaving(score, reason)
+			}
+			p.fail(err)
+			return
+		}
+		if err := p.handle(command, payload); err != nil {
+			// 只有无法解析的负载是对端的过错，本端发送失败等不计分
+			if errors.Is(err, errBadPayload) {
+				p.misbehaving(scoreBadMessage, "bad message")
+			}
+			p.fail(fmt.Errorf("%s: %w", command, err))
+			return
+		}
+	}
+}
+
+// handle 处理一条消息；负载无法解析时返回错误
+func (p *WirePeer) handle(command string, payload []byte) error {
+	switch command {
+	case CmdPing:
+		var ping PingMessage
+		if err := decodePayload(payload, &ping); err != nil {
+			return err
+		}
+		return p.send(CmdPong, ping)
+	case CmdPong:
+		var pong PingMessage
+		if err := decodePayload(payload, &pong); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		if !p.pingSent.IsZero() && pong.Nonce == p.pingNonce {
+			p.latency = time.Since(p.pingSent)
+			p.lastPong = time.Now()
+			p.pingSent = time.Time{}
+		}
+		p.mu.Unlock()
+	case CmdGetStatus:
+		height, err := latestHeight(p.node.Server.Store)
+		if err != nil {
+			return nil
+		}
+		return p.send(CmdStatus, StatusResponse{NodeID: p.node.Server.NodeID, Height: height})
+	case CmdStatus:
+		var st StatusResponse
+		if err := decodePayload(payload, &st); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.height = st.Height
+		waiters := p.statuses
+		p.statuses = nil
+		p.mu.Unlock()
+		for _, ch := range waiters {
+			ch <- st
+		}
+	case CmdGetBlock:
+		var req GetBlockMessage
+		if err := decodePayload(payload, &req); err != nil {
+			return err
+		}
+		b, err := p.node.Server.Store.LoadBlock(req.Height)
+		if err != nil || b == nil {
+			return p.send(CmdNotFound, req)
+		}
+		return p.send(CmdBlock, BlockResponse{Block: b})
+	case CmdBlock:
+		var msg BlockResponse
+		if err := decodePayload(payload, &msg); err != nil {
+			return err
+		}
+		if msg.Block == nil {
+			return fmt.Errorf("%w: block is nil", errBadPayload)
+		}
+		if !p.deliverBlock(msg.Block.Header.Height, msg.Block) {
+			p.node.acceptBlock(p, msg.Block)
+		}
+	case CmdNotFound:
+		var req GetBlockMessage
+		if err := decodePayload(payload, &req); err != nil {
+			return err
+		}
+		p.deliverBlock(req.Height, nil)
+	case CmdTx:
+		var tx core.Transaction
+		if err := decodePayload(payload, &tx); err != nil {
+			return err
+		}
+		p.node.acceptTx(p, &tx)
+	case CmdVersion, CmdVerack:
+		p.misbehaving(scoreBadMessage, "duplicate handshake")
+	default:
+		// 未知命令忽略，便于协议向后兼容
+	}
+	return nil
+}
+
+// decodePayload 解析消息负载，失败时返回包装 errBadPayload 的错误
+func decodePayload(payload []byte, v any) error {
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("%w: %w", errBadPayload, err)
+	}
+	return nil
+}
+
+// deliverBlock 把 getblock 的应答交给等待者，没有等待者时返回 false（视为主动推送）
+func (p *WirePeer) deliverBlock(height uint64, b *core.Block) bool {
+	p.mu.Lock()
+	waiters := p.blocks[height]
+	delete(p.blocks, height)
+	p.mu.Unlock()
+	for _, ch := range waiters {
+		ch <- b
+	}
+	return len(waiters) > 0
+}
+
+// pingLoop 定期发送 ping；上一个 ping 超过 2 个间隔仍未收到 pong 时断开
+func (p *WirePeer) pingLoop() {
+	interval := p.node.PingInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.closed:
+			return
+		case <-ticker.C:
+		}
+		p.mu.Lock()
+		if !p.pingSent.IsZero() {
+			expired := time.Since(p.pingSent) > 2*interval
+			p.mu.Unlock()
+			if expired {
+				p.fail(errors.New("ping timeout"))
+				return
+			}
+			continue
+		}
+		p.pingNonce, p.pingSent = randomNonce(), time.Now()
+		ping := PingMessage{Nonce: p.pingNonce}
+		p.mu.Unlock()
+		if err := p.send(CmdPing, ping); err != nil {
+			p.fail(err)
+			return
+		}
+	}
+}
+
+func (p *WirePeer) noteHeight(h uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h > p.height {
+		p.height = h
+	}
+}
+
+func (p *WirePeer) beginSync() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.syncing {
+		return false
+	}
+	p.syncing = true
+	return true
+}
+
+func (p *WirePeer) endSync() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.syncing = false
+}
+
+// misbehaving 为对端记录不当行为，因此被封禁时断开
+func (p *WirePeer) misbehaving(score int, reason string) {
+	if p.node.Server.Bans.Misbehaving(p.host, score, reason) {
+		p.fail(fmt.Errorf("banned: %s", reason))
+	}
+}
+
+// wireMisbehavior 帧错误对应的分值
+func wireMisbehavior(err error) (int, string) {
+	switch {
+	case errors.Is(err, errWireTooLarge):
+		return scoreOversized, "oversized message"
+	case errors.Is(err, errBadMagic), errors.Is(err, errBadChecksum), errors.Is(err, errBadCommand):
+		return scoreBadMessage, "bad message"
+	}
+	return 0, ""
+}
+
+func hostOf(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func randomNonce() uint64 {
+	var b [8]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		return uint64(time.Now().UnixNano())
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
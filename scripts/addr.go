@@ -1,20 +1,41 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/yiqi-017/blockchain/crypto"
 	"github.com/yiqi-017/blockchain/storage"
 )
 
 // 简单工具：打印钱包地址（公钥 hex），若钱包不存在则生成
+// 已存在的钱包直接读取地址字段，加密钱包无需口令
 func main() {
 	walletPath := flag.String("wallet", "data/n1/wallet.json", "钱包文件路径")
+	schemeName := flag.String("scheme", "p256", "新建钱包的签名方案：p256 | secp256k1 | ed25519")
+	passphraseFile := flag.String("passphrase-file", "", "新建钱包的口令文件（未指定时读取环境变量或终端输入）")
+	noEncrypt := flag.Bool("no-encrypt", false, "未提供口令时以明文保存新钱包（不推荐）")
 	flag.Parse()
 
-	w, err := storage.LoadOrCreateWallet(*walletPath)
+	if address, err := storage.WalletAddress(*walletPath); err == nil {
+		fmt.Println(address)
+		return
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("load wallet failed: %v", err)
+	}
+
+	scheme, err := crypto.ParseScheme(*schemeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	passphrase, err := storage.ResolvePassphrase(*passphraseFile, *noEncrypt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w, err := storage.LoadOrCreateWalletWithScheme(*walletPath, scheme, passphrase)
 	if err != nil {
 		log.Fatalf("load wallet failed: %v", err)
 	}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// Outpoint 交易输出引用
+type Outpoint struct {
+	TxID string `json:"txid"`
+	Vout int    `json:"vout"`
+}
+
+// AddressOutput 地址在某笔交易中收到的输出
+type AddressOutput struct {
+	Vout  int   `json:"vout"`
+	Value int64 `json:"value"`
+}
+
+// AddressTx 地址索引条目：一笔向该地址付款（Outputs）或花费其输出（Spends）的已上链交易
+type AddressTx struct {
+	TxID     string          `json:"txid"`
+	Height   uint64          `json:"height"`
+	Index    int             `json:"index"`
+	Coinbase bool            `json:"coinbase,omitempty"`
+	Received int64           `json:"received"`
+	Sent     int64           `json:"sent"`
+	Outputs  []AddressOutput `json:"outputs,omitempty"`
+	Spends   []Outpoint      `json:"spends,omitempty"`
+}
+
+// AddressUTXO 地址的未花费输出
+type AddressUTXO struct {
+	TxID     string `json:"txid"`
+	Vout     int    `json:"vout"`
+	Value    int64  `json:"value"`
+	Height   uint64 `json:"height"`
+	Coinbase bool   `json:"coinbase,omitempty"`
+}
+
+// AddressTxs 返回地址相关的已上链交易，按链上顺序（高度、区块内序号）升序
+func (s *FileStorage) AddressTxs(addr string) ([]AddressTx, error) {
+	var out []AddressTx
+	err := s.viewChainIndex(func(index *chainIndex) {
+		list := index.addresses[addr]
+		out = list[:len(list):len(list)]
+	})
+	return out, err
+}
+
+// AddressUTXOs 由地址索引计算地址的未花费输出（含未成熟的 coinbase），按链上顺序排列
+func (s *FileStorage) AddressUTXOs(addr string) ([]AddressUTXO, error) {
+	entries, err := s.AddressTxs(addr)
+	if err != nil {
+		return nil, err
+	}
+	spent := make(map[Outpoint]struct{})
+	for _, e := range entries {
+		for _, op := range e.Spends {
+			spent[op] = struct{}{}
+		}
+	}
+	var out []AddressUTXO
+	for _, e := range entries {
+		for _, o := range e.Outputs {
+			if _, ok := spent[Outpoint{TxID: e.TxID, Vout: o.Vout}]; ok {
+				continue
+			}
+			out = append(out, AddressUTXO{TxID: e.TxID, Vout: o.Vout, Value: o.Value, Height: e.Height, Coinbase: e.Coinbase})
+		}
+	}
+	return out, nil
+}
+
+// indexAddressBlock 将区块内每笔交易记入其输出地址与被花费输出所属地址的条目
+func indexAddressBlock(index map[string][]AddressTx, block *core.Block, prevOut func(Outpoint) (core.TxOutput, bool)) {
+	for i, tx := range block.Transactions {
+		id := crypto.HexEncode(core.ComputeTxID(tx))
+		touched := make(map[string]*AddressTx)
+		var order []string
+		entry := func(addr string) *AddressTx {
+			if e, ok := touched[addr]; ok {
+				return e
+			}
+			e := &AddressTx{TxID: id, Height: block.Header.Height, Index: i, Coinbase: tx.IsCoinbase}
+			touched[addr] = e
+			order = append(order, addr)
+			return e
+		}
+		if !tx.IsCoinbase {
+			for _, in := range tx.Inputs {
+				op := Outpoint{TxID: crypto.HexEncode(in.TxID), Vout: in.Vout}
+				out, ok := prevOut(op)
+				if !ok {
+					continue
+				}
+				e := entry(out.ScriptPubKey)
+				e.Sent += out.Value
+				e.Spends = append(e.Spends, op)
+			}
+		}
+		for vout, out := range tx.Outputs {
+			e := entry(out.ScriptPubKey)
+			e.Received += out.Value
+			e.Outputs = append(e.Outputs, AddressOutput{Vout: vout, Value: out.Value})
+		}
+		for _, addr := range order {
+			index[addr] = insertAddressTx(index[addr], *touched[addr])
+		}
+	}
+}
+
+// insertAddressTx 按（高度、序号）有序插入，保持链上顺序
+func insertAddressTx(list []AddressTx, e AddressTx) []AddressTx {
+	pos := sort.Search(len(list), func(i int) bool {
+		return list[i].Height > e.Height || list[i].Height == e.Height && list[i].Index > e.Index
+	})
+	list = append(list, AddressTx{})
+	copy(list[pos+1:], list[pos:])
+	list[pos] = e
+	return list
+}
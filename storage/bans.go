@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const bansFile = "bans.json"
+
+// BanRecord 被封禁的 peer：地址（IP）、解封时间（Unix 秒）与原因
+type BanRecord struct {
+	Addr   string `json:"addr"`
+	Until  int64  `json:"until"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// SaveBans 保存封禁列表
+func (s *FileStorage) SaveBans(records []BanRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.rootDir, bansFile), data, 0o644)
+}
+
+// LoadBans 读取封禁列表，不存在时返回 nil
+func (s *FileStorage) LoadBans() ([]BanRecord, error) {
+	data, err := os.ReadFile(filepath.Join(s.rootDir, bansFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var records []BanRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
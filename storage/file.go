@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/yiqi-017/blockchain/core"
 )
@@ -19,9 +20,14 @@ type FileStorage struct {
 	rootDir   string
 	blocksDir string
 	poolDir   string
+	indexDir  string
+
+	indexMu   sync.Mutex
+	index     *chainIndex // 内存中的链索引：首次查询时由日志重放，随 ConnectBlock / DisconnectBlocks / ClearBlocks 更新
+	indexSize int64       // index 对应的索引日志长度，用于发现其他进程对日志的改动
 }
 
-// NewFileStorage 创建存储实例，目录结构：baseDir/nodeID/{blocks,txpool}
+// NewFileStorage 创建存储实例，目录结构：baseDir/nodeID/{blocks,txpool,index}
 func NewFileStorage(baseDir, nodeID string) (*FileStorage, error) {
 	if nodeID == "" {
 		return nil, errors.New("nodeID is required")
@@ -30,8 +36,9 @@ func NewFileStorage(baseDir, nodeID string) (*FileStorage, error) {
 	root := filepath.Join(baseDir, nodeID)
 	blocks := filepath.Join(root, "blocks")
 	pool := filepath.Join(root, "txpool")
+	index := filepath.Join(root, "index")
 
-	for _, dir := range []string{blocks, pool} {
+	for _, dir := range []string{blocks, pool, index} {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return nil, err
 		}
@@ -41,23 +48,79 @@ func NewFileStorage(baseDir, nodeID string) (*FileStorage, error) {
 		rootDir:   root,
 		blocksDir: blocks,
 		poolDir:   pool,
+		indexDir:  index,
 	}, nil
 }
 
-// SaveBlock 将区块序列化为 JSON 按高度存储
+// SaveBlock 将区块序列化为 JSON 按高度存储，并向索引日志追加该区块的交易、地址与过滤器记录（覆盖同高度旧区块时以新记录为准）
+// 被花费输出从已有地址索引中查找，需要时遍历整份地址索引；逐块同步时应改用 ConnectBlock
 func (s *FileStorage) SaveBlock(block *core.Block) error {
 	if block == nil {
 		return errors.New("block is nil")
 	}
+	var outputs map[Outpoint]core.TxOutput
+	return s.saveBlock(block, func(op Outpoint) (core.TxOutput, bool) {
+		if outputs == nil {
+			outputs = make(map[Outpoint]core.TxOutput)
+			_ = s.viewChainIndex(func(index *chainIndex) {
+				for addr, entries := range index.addresses {
+					for _, e := range entries {
+						for _, o := range e.Outputs {
+							outputs[Outpoint{TxID: e.TxID, Vout: o.Vout}] = core.TxOutput{Value: o.Value, ScriptPubKey: addr}
+						}
+					}
+				}
+			})
+		}
+		out, ok := outputs[op]
+		return out, ok
+	})
+}
 
-	filename := fmt.Sprintf("%d.json", block.Header.Height)
-	path := filepath.Join(s.blocksDir, filename)
+// ConnectBlock 同 SaveBlock，被花费输出取自调用方以区块前 UTXO 集解析的 spent（见 SpentOutputs），不读取已有索引
+func (s *FileStorage) ConnectBlock(block *core.Block, spent map[Outpoint]core.TxOutput) error {
+	if block == nil {
+		return errors.New("block is nil")
+	}
+	return s.saveBlock(block, func(op Outpoint) (core.TxOutput, bool) {
+		out, ok := spent[op]
+		return out, ok
+	})
+}
 
+// saveBlock 先把区块写入临时文件，追加索引记录后再改名为正式文件；改名失败时撤销索引记录，
+// 区块文件与索引要么都更新，要么都不变；成功后把记录并入内存中的索引
+func (s *FileStorage) saveBlock(block *core.Block, prevOut func(Outpoint) (core.TxOutput, bool)) error {
 	data, err := json.MarshalIndent(block, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	rec := newIndexRecord(block, prevOut)
+
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	path := filepath.Join(s.blocksDir, fmt.Sprintf("%d.json", block.Header.Height))
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	before, after, err := s.appendIndexRecord(rec)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("index block %d: %w", block.Header.Height, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		if terr := s.truncateIndexLog(before); terr != nil {
+			return fmt.Errorf("%w (undo index: %v)", err, terr)
+		}
+		return err
+	}
+	// 内存索引与追加前的日志一致时增量更新，否则留待下次查询重放
+	if s.index != nil && s.indexSize == before {
+		s.index, s.indexSize = s.index.connect(rec), after
+	}
+	return nil
 }
 
 // LoadBlock 按高度读取区块
@@ -101,8 +164,48 @@ func (s *FileStorage) ListBlockHeights() ([]uint64, error) {
 	return heights, nil
 }
 
-// ClearBlocks 删除所有区块文件（用于重组覆盖）
+// DisconnectBlocks 自最高处起逐块删除高于 height 的区块（重组回退到分叉点），删除前向索引日志追加该高度的删除记录
+// 中途失败时已删除的区块不恢复，由调用方重新写入
+func (s *FileStorage) DisconnectBlocks(height uint64) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	index, err := s.loadChainIndex()
+	if err != nil {
+		return err
+	}
+	heights, err := s.ListBlockHeights()
+	if err != nil {
+		return err
+	}
+	// 内存索引在全部删除（或中途失败）后一次性汇总，期间其他进程改动日志时留待下次查询重放
+	start := s.indexSize
+	size, kept := start, height
+	defer func() {
+		if size != start && s.indexSize == start {
+			s.index, s.indexSize = index.disconnect(kept), size
+		}
+	}()
+	for i := len(heights) - 1; i >= 0 && heights[i] > height; i-- {
+		h := heights[i]
+		before, after, err := s.appendIndexRecord(indexRecord{Height: h, Removed: true})
+		if err != nil {
+			return fmt.Errorf("index removal of block %d: %w", h, err)
+		}
+		if err := os.Remove(filepath.Join(s.blocksDir, fmt.Sprintf("%d.json", h))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			if terr := s.truncateIndexLog(before); terr != nil {
+				return fmt.Errorf("%w (undo index: %v)", err, terr)
+			}
+			return err
+		}
+		size, kept = after, h-1
+	}
+	return nil
+}
+
+// ClearBlocks 删除所有区块文件
 func (s *FileStorage) ClearBlocks() error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
 	entries, err := os.ReadDir(s.blocksDir)
 	if err != nil {
 		return err
@@ -118,7 +221,7 @@ func (s *FileStorage) ClearBlocks() error {
 			return err
 		}
 	}
-	return nil
+	return s.removeIndex()
 }
 
 type txPoolPersist struct {
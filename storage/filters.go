@@ -0,0 +1,36 @@
+package storage
+
+// FilterEntry 区块的紧凑过滤器及其过滤器头，按高度连续存放
+type FilterEntry struct {
+	Height    uint64 `json:"height"`
+	BlockHash []byte `json:"block_hash"`
+	Filter    []byte `json:"filter"`
+	Header    []byte `json:"header"`
+}
+
+// BlockFilters 返回自创世起连续高度的区块过滤器
+func (s *FileStorage) BlockFilters() ([]FilterEntry, error) {
+	var out []FilterEntry
+	// 已有条目不会被改写，限定容量后新追加的条目不会写入调用方持有的切片
+	err := s.viewChainIndex(func(index *chainIndex) {
+		out = index.filters[:len(index.filters):len(index.filters)]
+	})
+	return out, err
+}
+
+// BlockFilter 返回指定高度的过滤器
+func (s *FileStorage) BlockFilter(height uint64) (*FilterEntry, bool, error) {
+	var (
+		entry FilterEntry
+		ok    bool
+	)
+	err := s.viewChainIndex(func(index *chainIndex) {
+		if height < uint64(len(index.filters)) {
+			entry, ok = index.filters[height], true
+		}
+	})
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return &entry, true, nil
+}
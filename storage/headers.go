@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/yiqi-017/blockchain/core"
+)
+
+const headersFile = "headers.json"
+
+// SaveHeaders 保存轻节点已验证的区块头链（按高度升序，从创世开始）
+func (s *FileStorage) SaveHeaders(headers []core.BlockHeader) error {
+	data, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.rootDir, headersFile), data, 0o644)
+}
+
+// LoadHeaders 读取轻节点保存的区块头链，不存在时返回 nil
+func (s *FileStorage) LoadHeaders() ([]core.BlockHeader, error) {
+	data, err := os.ReadFile(filepath.Join(s.rootDir, headersFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var headers []core.BlockHeader
+	if err := json.Unmarshal(data, &headers); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// indexLogFile 交易、地址与过滤器索引共用的追加式日志：每写入一个区块追加一行该区块的记录
+const indexLogFile = "blocks.log"
+
+// legacyIndexFiles 旧版本每个区块整体重写的索引文件，重建索引时删除
+var legacyIndexFiles = []string{"txindex.json", "addrindex.json", "filters.json"}
+
+// indexRecord 单个区块的索引记录；同一高度以日志中最后一条为准（重组覆盖同高度区块时追加新记录，回退删除区块时追加 Removed 记录）
+type indexRecord struct {
+	Height    uint64                 `json:"height"`
+	BlockHash []byte                 `json:"block_hash"`
+	TxIDs     []string               `json:"txids"`
+	Addresses map[string][]AddressTx `json:"addresses,omitempty"`
+	Filter    []byte                 `json:"filter"`
+	// Difficulty 区块难度，用于累计链工作量；旧版本日志没有该字段，读到时从区块重建
+	Difficulty *uint32 `json:"difficulty"`
+	Removed    bool    `json:"removed,omitempty"`
+}
+
+// chainIndex 重放日志得到的索引；过滤器头在重放时按高度顺序链接
+// records 保留各高度的记录，写入新区块时据此增量更新，覆盖已有高度时在内存中重新汇总
+type chainIndex struct {
+	records   map[uint64]indexRecord
+	next      uint64 // 已记录的最高高度 + 1，空链为 0
+	txs       map[string]TxLocation
+	addresses map[string][]AddressTx
+	filters   []FilterEntry
+	work      *big.Int // 自创世起连续高度的累计工作量
+}
+
+// SpentOutputs 由区块之前的 UTXO 集解析区块内各输入引用的已确认输出，供 ConnectBlock 更新地址索引
+// 须在把区块应用到 utxos 之前调用；花费同块前序交易输出的输入由 ConnectBlock 自行解析
+func SpentOutputs(block *core.Block, utxos map[string][]core.UTXO) map[Outpoint]core.TxOutput {
+	spent := make(map[Outpoint]core.TxOutput)
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase {
+			continue
+		}
+		for _, in := range tx.Inputs {
+			id := crypto.HexEncode(in.TxID)
+			for _, u := range utxos[id] {
+				if u.Index == in.Vout && bytes.Equal(u.TxID, in.TxID) {
+					spent[Outpoint{TxID: id, Vout: in.Vout}] = u.Output
+					break
+				}
+			}
+		}
+	}
+	return spent
+}
+
+// newIndexRecord 生成区块的索引记录；prevOut 解析已确认的被花费输出，同块内前序交易的输出直接取自区块
+func newIndexRecord(block *core.Block, prevOut func(Outpoint) (core.TxOutput, bool)) indexRecord {
+	difficulty := block.Header.Difficulty
+	rec := indexRecord{
+		Height:     block.Header.Height,
+		BlockHash:  core.HashBlockHeader(&block.Header),
+		TxIDs:      make([]string, 0, len(block.Transactions)),
+		Addresses:  make(map[string][]AddressTx),
+		Filter:     core.BuildBlockFilter(block),
+		Difficulty: &difficulty,
+	}
+	own := make(map[string][]core.TxOutput, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		id := crypto.HexEncode(core.ComputeTxID(tx))
+		rec.TxIDs = append(rec.TxIDs, id)
+		own[id] = tx.Outputs
+	}
+	indexAddressBlock(rec.Addresses, block, func(op Outpoint) (core.TxOutput, bool) {
+		if outs, ok := own[op.TxID]; ok {
+			if op.Vout < 0 || op.Vout >= len(outs) {
+				return core.TxOutput{}, false
+			}
+			return outs[op.Vout], true
+		}
+		return prevOut(op)
+	})
+	return rec
+}
+
+// ChainWork 返回本地链自创世起连续高度的累计工作量
+func (s *FileStorage) ChainWork() (*big.Int, error) {
+	var work *big.Int
+	// 追加区块时替换而非原地修改 work，返回的值不会被改写
+	err := s.viewChainIndex(func(index *chainIndex) {
+		work = index.work
+	})
+	return work, err
+}
+
+// viewChainIndex 在持有索引锁时以内存中的索引调用 fn；fn 不得保留索引中的 map 或修改其内容
+func (s *FileStorage) viewChainIndex(fn func(index *chainIndex)) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	index, err := s.loadChainIndex()
+	if err != nil {
+		return err
+	}
+	fn(index)
+	return nil
+}
+
+// loadChainIndex 返回内存中的索引，调用方须持有 indexMu
+// 仅在首次使用（启动）或日志长度与内存索引不符（其他进程写入或删除了日志）时重放日志；
+// 日志缺失、损坏或与区块文件不符（旧数据目录、外部改写区块、写入中断）时从区块重建
+func (s *FileStorage) loadChainIndex() (*chainIndex, error) {
+	size, err := s.indexLogSize()
+	if err != nil {
+		return nil, err
+	}
+	if s.index != nil && size == s.indexSize {
+		return s.index, nil
+	}
+	records, err := s.readIndexLog()
+	if err != nil {
+		return nil, err
+	}
+	if records != nil && s.indexCurrent(records) {
+		s.index, s.indexSize = buildChainIndex(records), size
+		return s.index, nil
+	}
+	return s.rebuildIndex()
+}
+
+// indexLogSize 返回索引日志长度，日志不存在时为 0
+func (s *FileStorage) indexLogSize() (int64, error) {
+	info, err := os.Stat(filepath.Join(s.indexDir, indexLogFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// RebuildIndex 扫描全部区块重写索引日志
+func (s *FileStorage) RebuildIndex() error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	_, err := s.rebuildIndex()
+	return err
+}
+
+// rebuildIndex 扫描全部区块重写索引日志并替换内存中的索引，调用方须持有 indexMu
+func (s *FileStorage) rebuildIndex() (*chainIndex, error) {
+	heights, err := s.ListBlockHeights()
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[uint64]indexRecord, len(heights))
+	outputs := make(map[string][]core.TxOutput)
+	var buf bytes.Buffer
+	for _, h := range heights {
+		block, err := s.LoadBlock(h)
+		if err != nil {
+			return nil, err
+		}
+		rec := newIndexRecord(block, func(op Outpoint) (core.TxOutput, bool) {
+			outs, ok := outputs[op.TxID]
+			if !ok || op.Vout < 0 || op.Vout >= len(outs) {
+				return core.TxOutput{}, false
+			}
+			return outs[op.Vout], true
+		})
+		for i, tx := range block.Transactions {
+			outputs[rec.TxIDs[i]] = tx.Outputs
+		}
+		records[h] = rec
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	// 先写临时文件再改名，重建中途失败不会留下半份日志
+	path := filepath.Join(s.indexDir, indexLogFile)
+	if err := os.WriteFile(path+".tmp", buf.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, err
+	}
+	for _, name := range legacyIndexFiles {
+		_ = os.Remove(filepath.Join(s.indexDir, name))
+	}
+	s.index, s.indexSize = buildChainIndex(records), int64(buf.Len())
+	return s.index, nil
+}
+
+// appendIndexRecord 向日志追加一行，返回追加前后的日志长度，追加前长度供调用方撤销；写入失败时截断回原长度，不留半行
+func (s *FileStorage) appendIndexRecord(rec indexRecord) (int64, int64, error) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return 0, 0, err
+	}
+	f, err := os.OpenFile(filepath.Join(s.indexDir, indexLogFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	line = append(line, '\n')
+	if _, err := f.Write(line); err != nil {
+		_ = f.Truncate(info.Size())
+		return 0, 0, err
+	}
+	return info.Size(), info.Size() + int64(len(line)), nil
+}
+
+// truncateIndexLog 撤销 appendIndexRecord 追加的记录
+func (s *FileStorage) truncateIndexLog(size int64) error {
+	return os.Truncate(filepath.Join(s.indexDir, indexLogFile), size)
+}
+
+// readIndexLog 读取日志中每个高度的最后一条记录；日志不存在或存在损坏的完整行时返回 nil，末尾未写完的半行忽略
+func (s *FileStorage) readIndexLog() (map[uint64]indexRecord, error) {
+	f, err := os.Open(filepath.Join(s.indexDir, indexLogFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	records := make(map[uint64]indexRecord)
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var rec indexRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, nil
+		}
+		if rec.Removed {
+			delete(records, rec.Height)
+			continue
+		}
+		records[rec.Height] = rec
+	}
+}
+
+// indexCurrent 抽查日志与区块文件是否一致：高度集合相同、各记录带有难度且链尖区块哈希相同
+func (s *FileStorage) indexCurrent(records map[uint64]indexRecord) bool {
+	heights, err := s.ListBlockHeights()
+	if err != nil || len(heights) != len(records) {
+		return false
+	}
+	for _, h := range heights {
+		if rec, ok := records[h]; !ok || rec.Difficulty == nil {
+			return false
+		}
+	}
+	if len(heights) == 0 {
+		return true
+	}
+	tip, err := s.LoadBlock(heights[len(heights)-1])
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(records[tip.Header.Height].BlockHash, core.HashBlockHeader(&tip.Header))
+}
+
+// buildChainIndex 按高度顺序汇总各区块记录；过滤器只取自创世起连续的高度
+func buildChainIndex(records map[uint64]indexRecord) *chainIndex {
+	heights := make([]uint64, 0, len(records))
+	for h := range records {
+		heights = append(heights, h)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	index := &chainIndex{
+		records:   make(map[uint64]indexRecord, len(records)),
+		txs:       make(map[string]TxLocation),
+		addresses: make(map[string][]AddressTx),
+		filters:   []FilterEntry{},
+		work:      new(big.Int),
+	}
+	for _, h := range heights {
+		index.append(records[h])
+	}
+	return index
+}
+
+// append 并入高于现有全部高度的区块记录
+func (index *chainIndex) append(rec indexRecord) {
+	h := rec.Height
+	contiguous := uint64(len(index.records)) == h && uint64(len(index.filters)) == h
+	index.records[h] = rec
+	index.next = h + 1
+	for j, id := range rec.TxIDs {
+		index.txs[id] = TxLocation{Height: h, Index: j}
+	}
+	// 同一记录内的条目已按区块内序号排列，按高度顺序追加即保持链上顺序
+	for addr, entries := range rec.Addresses {
+		index.addresses[addr] = append(index.addresses[addr], entries...)
+	}
+	if contiguous {
+		var prevHeader []byte
+		if len(index.filters) > 0 {
+			prevHeader = index.filters[len(index.filters)-1].Header
+		}
+		header := core.FilterHeader(rec.Filter, prevHeader)
+		index.filters = append(index.filters, FilterEntry{Height: h, BlockHash: rec.BlockHash, Filter: rec.Filter, Header: header})
+		if rec.Difficulty != nil {
+			index.work = new(big.Int).Add(index.work, core.BlockWork(*rec.Difficulty))
+		}
+	}
+}
+
+// connect 并入新写入区块的记录：高于现有全部高度时增量追加，覆盖已有高度（重组）时由内存中的记录重新汇总
+func (index *chainIndex) connect(rec indexRecord) *chainIndex {
+	if rec.Height >= index.next {
+		index.append(rec)
+		return index
+	}
+	records := make(map[uint64]indexRecord, len(index.records))
+	for h, r := range index.records {
+		records[h] = r
+	}
+	records[rec.Height] = rec
+	return buildChainIndex(records)
+}
+
+// disconnect 移除高于 height 的记录（重组回退到分叉点），由内存中其余记录重新汇总
+func (index *chainIndex) disconnect(height uint64) *chainIndex {
+	records := make(map[uint64]indexRecord, len(index.records))
+	for h, r := range index.records {
+		if h <= height {
+			records[h] = r
+		}
+	}
+	return buildChainIndex(records)
+}
+
+// removeIndex 删除索引日志并清空内存中的索引（区块被整体清除时），调用方须持有 indexMu
+func (s *FileStorage) removeIndex() error {
+	err := os.Remove(filepath.Join(s.indexDir, indexLogFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		s.index = nil
+		return err
+	}
+	s.index, s.indexSize = buildChainIndex(nil), 0
+	return nil
+}
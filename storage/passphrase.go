@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// WalletPassphraseEnv 读取钱包口令的环境变量名
+const WalletPassphraseEnv = "BLOCKCHAIN_WALLET_PASSPHRASE"
+
+// ErrPassphraseRequired 未获得钱包口令且调用方不允许以明文保存钱包
+var ErrPassphraseRequired = errors.New("wallet passphrase required (passphrase file, " + WalletPassphraseEnv + " or terminal input)")
+
+// ResolvePassphrase 按优先级获取钱包口令：口令文件 > 环境变量 > 终端输入（不回显）
+// 口令为空（含非交互环境且均未配置）时返回 ErrPassphraseRequired，除非 allowPlaintext 显式允许明文保存钱包
+func ResolvePassphrase(file string, allowPlaintext bool) (string, error) {
+	pass, err := readPassphrase(file, allowPlaintext)
+	if err != nil {
+		return "", err
+	}
+	if pass == "" && !allowPlaintext {
+		return "", ErrPassphraseRequired
+	}
+	return pass, nil
+}
+
+func readPassphrase(file string, allowPlaintext bool) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("read passphrase file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if pass, ok := os.LookupEnv(WalletPassphraseEnv); ok {
+		return pass, nil
+	}
+	prompt := "钱包口令: "
+	if allowPlaintext {
+		prompt = "钱包口令（留空表示不加密）: "
+	}
+	return readHidden(os.Stdin, prompt)
+}
+
+// readHidden 在终端上关闭回显读取一行；f 不是终端（或系统没有 stty）时返回空串
+// 仅依赖标准库，借助 stty 切换回显，读取结束后恢复原终端设置
+func readHidden(f *os.File, prompt string) (string, error) {
+	state, err := stty(f, "-g")
+	if err != nil {
+		return "", nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	if _, err := stty(f, "-echo"); err != nil {
+		fmt.Fprintln(os.Stderr)
+		return "", fmt.Errorf("disable terminal echo: %w", err)
+	}
+	line, err := bufio.NewReader(f).ReadString('\n')
+	_, restoreErr := stty(f, state)
+	fmt.Fprintln(os.Stderr)
+	if restoreErr != nil {
+		return "", fmt.Errorf("restore terminal: %w", restoreErr)
+	}
+	if err != nil && line == "" {
+		return "", nil
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// stty 对 f 所在终端执行 stty，返回其输出
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const peersFile = "peers.json"
+
+// PeerRecord 地址簿条目：peer 地址、最近一次连通时间（Unix 秒，0 表示从未连通）、连续失败次数与来源
+type PeerRecord struct {
+	URL      string `json:"url"`
+	LastSeen int64  `json:"last_seen,omitempty"`
+	Failures int    `json:"failures,omitempty"`
+	Source   string `json:"source,omitempty"`
+}
+
+// SavePeers 保存 peer 地址簿
+func (s *FileStorage) SavePeers(records []PeerRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.rootDir, peersFile), data, 0o644)
+}
+
+// LoadPeers 读取 peer 地址簿，不存在时返回 nil
+func (s *FileStorage) LoadPeers() ([]PeerRecord, error) {
+	data, err := os.ReadFile(filepath.Join(s.rootDir, peersFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var records []PeerRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
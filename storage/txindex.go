@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TxLocation 交易在链上的位置：所在区块高度与区块内序号
+type TxLocation struct {
+	Height uint64 `json:"height"`
+	Index  int    `json:"index"`
+}
+
+// LookupTx 按交易 ID（hex）查找已上链交易，返回交易及其位置
+// 命中位置与区块文件不符（如外部改写区块）时从区块重建一次索引
+func (s *FileStorage) LookupTx(txid string) (*core.Transaction, TxLocation, bool, error) {
+	var (
+		loc TxLocation
+		ok  bool
+	)
+	if err := s.viewChainIndex(func(index *chainIndex) { loc, ok = index.txs[txid] }); err != nil {
+		return nil, TxLocation{}, false, err
+	}
+	if !ok {
+		return nil, TxLocation{}, false, nil
+	}
+	if tx, err := s.txAt(loc, txid); err != nil || tx != nil {
+		return tx, loc, tx != nil, err
+	}
+	s.indexMu.Lock()
+	index, err := s.rebuildIndex()
+	if err == nil {
+		loc, ok = index.txs[txid]
+	}
+	s.indexMu.Unlock()
+	if err != nil {
+		return nil, TxLocation{}, false, err
+	}
+	if !ok {
+		return nil, TxLocation{}, false, nil
+	}
+	tx, err := s.txAt(loc, txid)
+	return tx, loc, tx != nil, err
+}
+
+// TxConfirmed 仅查询内存中的交易索引判断交易是否已上链，不读取区块文件
+func (s *FileStorage) TxConfirmed(txid string) (bool, error) {
+	var ok bool
+	err := s.viewChainIndex(func(index *chainIndex) { _, ok = index.txs[txid] })
+	return ok, err
+}
+
+// txAt 读取 loc 处的交易，ID 不符或区块不存在时返回 nil
+func (s *FileStorage) txAt(loc TxLocation, txid string) (*core.Transaction, error) {
+	block, err := s.LoadBlock(loc.Height)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if loc.Index < 0 || loc.Index >= len(block.Transactions) {
+		return nil, nil
+	}
+	tx := block.Transactions[loc.Index]
+	if crypto.HexEncode(core.ComputeTxID(tx)) != txid {
+		return nil, nil
+	}
+	return tx, nil
+}
@@ -1,53 +1,482 @@
 package storage
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/wallet"
+)
+
+// walletFileVersion 当前钱包文件格式版本；无 version 字段的旧文件为明文 private_hex
+const walletFileVersion = 2
+
+// 钱包文件类型：walletTypeHD 为分层确定性钱包，walletTypeWatch 为只读钱包
+const (
+	walletTypeHD    = "hd"
+	walletTypeWatch = "watch"
+)
+
+var (
+	// ErrWalletLocked 表示钱包已加密但未提供口令
+	ErrWalletLocked = errors.New("wallet is encrypted, passphrase required")
+	// ErrHDWallet 表示对 HD 钱包文件调用了单密钥接口
+	ErrHDWallet = errors.New("wallet file is an HD wallet")
+	// ErrNotHDWallet 表示对单密钥钱包文件调用了 HD 接口
+	ErrNotHDWallet = errors.New("wallet file is not an HD wallet")
+	// ErrWatchOnly 表示只读钱包没有私钥，不能签名或派生新地址
+	ErrWatchOnly = errors.New("watch-only wallet has no private keys")
 )
 
 type walletPersist struct {
-	PrivateHex string `json:"private_hex"`
+	Version    int                  `json:"version,omitempty"`
+	Type       string               `json:"type,omitempty"`        // "hd" 为 HD 钱包，"watch" 为只读钱包，为空为单密钥钱包
+	Scheme     string               `json:"scheme,omitempty"`      // 为空表示 P-256
+	Address    string               `json:"address,omitempty"`     // 公钥 hex，无需口令即可读取；HD 钱包为最新收款地址
+	PrivateHex string               `json:"private_hex,omitempty"` // 仅未加密钱包（含旧版）使用
+	Mnemonic   string               `json:"mnemonic,omitempty"`    // 仅未加密 HD 钱包使用
+	Encrypted  *crypto.EncryptedBox `json:"encrypted,omitempty"`   // 加密后的私钥（HD 钱包为助记词）
+
+	NextReceive uint32   `json:"next_receive,omitempty"` // HD 已分配收款地址数
+	NextChange  uint32   `json:"next_change,omitempty"`  // HD 已分配找零地址数
+	Addresses   []string `json:"addresses,omitempty"`    // HD 已分配的全部地址，无需口令即可查询余额
+	AccountPub  string   `json:"account_pub,omitempty"`  // HD 账户扩展公钥，无需口令即可派生新地址
+	AccountPubs []string `json:"account_pubs,omitempty"` // 只读钱包导入的账户扩展公钥
 }
 
-// LoadOrCreateWallet 从文件加载钱包，不存在则生成新钱包并保存
-func LoadOrCreateWallet(path string) (*crypto.Wallet, error) {
-	data, err := os.ReadFile(path)
+// LoadOrCreateWallet 从文件加载钱包，不存在则生成新的 P-256 钱包并保存
+// passphrase 为空时新钱包以明文保存；非空时加密，并顺带把明文旧钱包迁移为加密格式
+func LoadOrCreateWallet(path string, passphrase string) (*crypto.Wallet, error) {
+	return LoadOrCreateWalletWithScheme(path, crypto.SchemeP256, passphrase)
+}
+
+// LoadOrCreateWalletWithScheme 从文件加载钱包，不存在则按指定签名方案生成并保存
+// 已存在的钱包始终使用文件中记录的方案
+func LoadOrCreateWalletWithScheme(path string, scheme crypto.Scheme, passphrase string) (*crypto.Wallet, error) {
+	w, err := LoadWallet(path, passphrase)
 	if err == nil {
-		var wp walletPersist
-		if err := json.Unmarshal(data, &wp); err != nil {
-			return nil, err
-		}
-		if wp.PrivateHex == "" {
-			return nil, errors.New("wallet file missing private key")
-		}
-		return crypto.FromPrivateHex(wp.PrivateHex)
+		return w, nil
 	}
 	if !errors.Is(err, os.ErrNotExist) {
 		return nil, err
 	}
 
 	// create new
-	w, err := crypto.GenerateWallet()
+	w, err = crypto.GenerateWalletWithScheme(scheme)
 	if err != nil {
 		return nil, err
 	}
-	if err := SaveWallet(path, w); err != nil {
+	if err := SaveWallet(path, w, passphrase); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// LoadWallet 读取钱包文件；加密钱包需提供口令
+// 未加密钱包在提供口令时会被重写为加密格式（旧版明文文件的迁移路径）
+func LoadWallet(path string, passphrase string) (*crypto.Wallet, error) {
+	wp, err := readWalletFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch wp.Type {
+	case walletTypeHD:
+		return nil, ErrHDWallet
+	case walletTypeWatch:
+		return nil, ErrWatchOnly
+	}
+	scheme, err := crypto.ParseScheme(wp.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	if wp.Encrypted != nil {
+		if passphrase == "" {
+			return nil, ErrWalletLocked
+		}
+		priv, err := crypto.DecryptWithPassphrase(wp.Encrypted, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		w, err := crypto.FromPrivateHexWithScheme(scheme, hex.EncodeToString(priv))
+		if err != nil {
+			return nil, err
+		}
+		if wp.Address != "" && wp.Address != crypto.PublicKeyHex(w.PublicKey) {
+			return nil, errors.New("wallet address does not match decrypted key")
+		}
+		return w, nil
+	}
+
+	if wp.PrivateHex == "" {
+		return nil, errors.New("wallet file missing private key")
+	}
+	w, err := crypto.FromPrivateHexWithScheme(scheme, wp.PrivateHex)
+	if err != nil {
 		return nil, err
 	}
+	if passphrase != "" {
+		if err := SaveWallet(path, w, passphrase); err != nil {
+			return nil, fmt.Errorf("encrypt legacy wallet: %w", err)
+		}
+		log.Printf("钱包 %s 已迁移为加密格式（version %d）", path, walletFileVersion)
+	}
 	return w, nil
 }
 
-// SaveWallet 覆盖保存钱包到文件
-func SaveWallet(path string, w *crypto.Wallet) error {
+// WalletAddress 读取钱包地址（公钥 hex），加密钱包也无需口令；HD 钱包返回最新分配的收款地址
+func WalletAddress(path string) (string, error) {
+	wp, err := readWalletFile(path)
+	if err != nil {
+		return "", err
+	}
+	if wp.Address != "" {
+		return wp.Address, nil
+	}
+	if wp.Type == walletTypeWatch {
+		return "", ErrWatchOnly
+	}
+	if wp.PrivateHex == "" {
+		return "", errors.New("wallet file missing address")
+	}
+	// 旧版文件只有明文私钥，直接推导
+	scheme, err := crypto.ParseScheme(wp.Scheme)
+	if err != nil {
+		return "", err
+	}
+	w, err := crypto.FromPrivateHexWithScheme(scheme, wp.PrivateHex)
+	if err != nil {
+		return "", err
+	}
+	return crypto.PublicKeyHex(w.PublicKey), nil
+}
+
+// SaveWallet 覆盖保存钱包到文件；passphrase 非空时以 scrypt + AES-GCM 加密私钥
+func SaveWallet(path string, w *crypto.Wallet, passphrase string) error {
 	privHex, err := crypto.PrivateKeyHex(w)
 	if err != nil {
 		return err
 	}
-	payload := walletPersist{PrivateHex: privHex}
+	payload := walletPersist{
+		Version: walletFileVersion,
+		Scheme:  w.Scheme().String(),
+		Address: crypto.PublicKeyHex(w.PublicKey),
+	}
+	if passphrase == "" {
+		payload.PrivateHex = privHex
+	} else {
+		payload.Encrypted, err = crypto.EncryptWithPassphrase(w.Signer.PrivateKey(), passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	return writeWalletFile(path, payload)
+}
+
+// IsHDWallet 判断钱包文件是否为 HD 钱包
+func IsHDWallet(path string) (bool, error) {
+	wp, err := readWalletFile(path)
+	if err != nil {
+		return false, err
+	}
+	return wp.Type == walletTypeHD, nil
+}
+
+// LoadHDWallet 读取 HD 钱包文件；加密钱包需提供口令，未加密钱包在提供口令时改写为加密格式
+func LoadHDWallet(path string, passphrase string) (*crypto.HDWallet, error) {
+	wp, err := readWalletFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch wp.Type {
+	case walletTypeHD:
+	case walletTypeWatch:
+		return nil, ErrWatchOnly
+	default:
+		return nil, ErrNotHDWallet
+	}
+
+	mnemonic := wp.Mnemonic
+	if wp.Encrypted != nil {
+		if passphrase == "" {
+			return nil, ErrWalletLocked
+		}
+		plain, err := crypto.DecryptWithPassphrase(wp.Encrypted, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		mnemonic = string(plain)
+	} else if mnemonic == "" {
+		return nil, errors.New("wallet file missing mnemonic")
+	}
+
+	hd, err := crypto.NewHDWallet(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	hd.NextReceive = wp.NextReceive
+	hd.NextChange = wp.NextChange
+
+	if wp.Encrypted == nil && passphrase != "" {
+		if err := SaveHDWallet(path, hd, passphrase); err != nil {
+			return nil, fmt.Errorf("encrypt hd wallet: %w", err)
+		}
+		log.Printf("钱包 %s 已改写为加密格式", path)
+	}
+	return hd, nil
+}
+
+// SaveHDWallet 覆盖保存 HD 钱包（助记词与分配计数）；passphrase 非空时加密助记词
+func SaveHDWallet(path string, hd *crypto.HDWallet, passphrase string) error {
+	payload := walletPersist{
+		Version:     walletFileVersion,
+		Type:        walletTypeHD,
+		Scheme:      crypto.SchemeSecp256k1.String(),
+		NextReceive: hd.NextReceive,
+		NextChange:  hd.NextChange,
+		AccountPub:  hd.AccountPublicKey().String(),
+	}
+	if hd.NextReceive > 0 {
+		w, err := hd.DeriveKey(false, hd.NextReceive-1)
+		if err != nil {
+			return err
+		}
+		payload.Address = crypto.PublicKeyHex(w.PublicKey)
+	}
+	keys, err := hd.Keys()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		payload.Addresses = append(payload.Addresses, crypto.PublicKeyHex(k.PublicKey))
+	}
+	if passphrase == "" {
+		payload.Mnemonic = hd.Mnemonic
+	} else {
+		payload.Encrypted, err = crypto.EncryptWithPassphrase([]byte(hd.Mnemonic), passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	return writeWalletFile(path, payload)
+}
+
+// ReserveHDChangeAddress 不解密助记词，由账户扩展公钥分配一个新的找零地址并写回钱包文件
+// 返回地址及其派生路径（供离线签名方定位私钥）
+func ReserveHDChangeAddress(path string) (string, string, error) {
+	wp, account, err := readHDAccount(path)
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		index := wp.NextChange
+		wp.NextChange++
+		addr, err := crypto.DeriveHDAddress(account, true, index)
+		if errors.Is(err, crypto.ErrInvalidChildKey) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		wp.Addresses = append(wp.Addresses, addr)
+		if err := writeWalletFile(path, *wp); err != nil {
+			return "", "", err
+		}
+		return addr, crypto.HDKeyPath(true, index), nil
+	}
+}
+
+// HDAddressPaths 由账户扩展公钥派生已分配的全部地址，返回地址到派生路径的映射
+func HDAddressPaths(path string) (map[string]string, error) {
+	wp, account, err := readHDAccount(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, wp.NextReceive+wp.NextChange)
+	for _, b := range []struct {
+		change bool
+		n      uint32
+	}{{false, wp.NextReceive}, {true, wp.NextChange}} {
+		for i := uint32(0); i < b.n; i++ {
+			addr, err := crypto.DeriveHDAddress(account, b.change, i)
+			if errors.Is(err, crypto.ErrInvalidChildKey) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			out[addr] = crypto.HDKeyPath(b.change, i)
+		}
+	}
+	return out, nil
+}
+
+// IsWatchOnlyWallet 判断钱包文件是否为只读钱包
+func IsWatchOnlyWallet(path string) (bool, error) {
+	wp, err := readWalletFile(path)
+	if err != nil {
+		return false, err
+	}
+	return wp.Type == walletTypeWatch, nil
+}
+
+// ImportWatchAddresses 向只读钱包导入地址（公钥 hex）和账户扩展公钥，文件不存在时创建；返回新增地址个数
+// 地址须为可解析的公钥；账户扩展公钥仅作记录，其派生地址由调用方一并传入
+func ImportWatchAddresses(path string, addresses []string, accountPubs []string) (int, error) {
+	wp, err := readWalletFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		wp = &walletPersist{Version: walletFileVersion, Type: walletTypeWatch}
+	case err != nil:
+		return 0, err
+	case wp.Type != walletTypeWatch:
+		return 0, fmt.Errorf("%s is not a watch-only wallet, import into a separate file", path)
+	}
+
+	known := make(map[string]struct{}, len(wp.Addresses))
+	for _, a := range wp.Addresses {
+		known[a] = struct{}{}
+	}
+	added := 0
+	for _, a := range addresses {
+		a = strings.ToLower(strings.TrimSpace(a))
+		pub, err := hex.DecodeString(a)
+		if err != nil {
+			return 0, fmt.Errorf("invalid address %q: not hex", a)
+		}
+		if _, err := crypto.ParsePublicKey(pub); err != nil {
+			return 0, fmt.Errorf("invalid address %q: %w", a, err)
+		}
+		if _, ok := known[a]; ok {
+			continue
+		}
+		known[a] = struct{}{}
+		wp.Addresses = append(wp.Addresses, a)
+		added++
+	}
+	for _, xpub := range accountPubs {
+		if _, err := crypto.ParseExtendedPublicKey(xpub); err != nil {
+			return 0, err
+		}
+		dup := false
+		for _, have := range wp.AccountPubs {
+			dup = dup || have == xpub
+		}
+		if !dup {
+			wp.AccountPubs = append(wp.AccountPubs, xpub)
+		}
+	}
+	if err := writeWalletFile(path, *wp); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// WatchAccountPubs 返回只读钱包导入的账户扩展公钥
+func WatchAccountPubs(path string) ([]*crypto.ExtendedPublicKey, error) {
+	wp, err := readWalletFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if wp.Type != walletTypeWatch {
+		return nil, fmt.Errorf("%s is not a watch-only wallet", path)
+	}
+	out := make([]*crypto.ExtendedPublicKey, 0, len(wp.AccountPubs))
+	for _, xpub := range wp.AccountPubs {
+		account, err := crypto.ParseExtendedPublicKey(xpub)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, account)
+	}
+	return out, nil
+}
+
+// WatchAddressPaths 由只读钱包导入的账户扩展公钥 account 推出其已跟踪地址的派生路径，返回地址到派生路径的映射
+// 每条分支从索引 0 起派生，连续 HDGapLimit 个地址不在跟踪集合内即停止；account 须已导入该钱包
+func WatchAddressPaths(path string, account *crypto.ExtendedPublicKey) (map[string]string, error) {
+	wp, err := readWalletFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if wp.Type != walletTypeWatch {
+		return nil, fmt.Errorf("%s is not a watch-only wallet", path)
+	}
+	imported := false
+	for _, xpub := range wp.AccountPubs {
+		imported = imported || xpub == account.String()
+	}
+	if !imported {
+		return nil, errors.New("account public key is not imported into this wallet")
+	}
+	tracked := make(map[string]struct{}, len(wp.Addresses))
+	for _, a := range wp.Addresses {
+		tracked[a] = struct{}{}
+	}
+	out := make(map[string]string)
+	for _, change := range []bool{false, true} {
+		for i, gap := uint32(0), 0; gap < crypto.HDGapLimit; i++ {
+			addr, err := crypto.DeriveHDAddress(account, change, i)
+			if errors.Is(err, crypto.ErrInvalidChildKey) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := tracked[addr]; !ok {
+				gap++
+				continue
+			}
+			gap = 0
+			out[addr] = crypto.HDKeyPath(change, i)
+		}
+	}
+	return out, nil
+}
+
+// readHDAccount 读取 HD 钱包文件及其账户扩展公钥
+func readHDAccount(path string) (*walletPersist, *crypto.ExtendedPublicKey, error) {
+	wp, err := readWalletFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if wp.Type != walletTypeHD {
+		return nil, nil, ErrNotHDWallet
+	}
+	if wp.AccountPub == "" {
+		return nil, nil, errors.New("wallet file lacks account public key, load it once with its passphrase (e.g. -mode newaddr) to upgrade")
+	}
+	account, err := crypto.ParseExtendedPublicKey(wp.AccountPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wp, account, nil
+}
+
+// readWalletFile 读取并解析钱包文件，拒绝未知的新版本格式
+func readWalletFile(path string) (*walletPersist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var wp walletPersist
+	if err := json.Unmarshal(data, &wp); err != nil {
+		return nil, err
+	}
+	if wp.Version > walletFileVersion {
+		return nil, fmt.Errorf("unsupported wallet file version %d", wp.Version)
+	}
+	return &wp, nil
+}
+
+// writeWalletFile 以仅所有者可读写的权限写入钱包文件
+func writeWalletFile(path string, payload walletPersist) error {
 	data, err := json.MarshalIndent(payload, "", "  ")
 	if err != nil {
 		return err
@@ -58,3 +487,82 @@ func SaveWallet(path string, w *crypto.Wallet) error {
 	return os.WriteFile(path, data, 0o600)
 }
 
+// WalletAddresses 返回钱包跟踪的全部地址，无需口令：单密钥钱包为其地址，HD 钱包为已分配的收款与找零地址，
+// 只读钱包为导入的地址
+func WalletAddresses(path string) ([]string, error) {
+	wp, err := readWalletFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if wp.Type == walletTypeHD || wp.Type == walletTypeWatch {
+		return append([]string(nil), wp.Addresses...), nil
+	}
+	addr, err := WalletAddress(path)
+	if err != nil {
+		return nil, err
+	}
+	return []string{addr}, nil
+}
+
+// WalletStatePath 返回钱包跟踪状态文件路径（与钱包文件同目录）
+func WalletStatePath(walletPath string) string {
+	return strings.TrimSuffix(walletPath, ".json") + ".state.json"
+}
+
+// SaveWalletState 保存钱包 UTXO/历史跟踪状态
+func SaveWalletState(path string, st *wallet.State) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadWalletState 读取钱包跟踪状态，不存在时返回 nil
+func LoadWalletState(path string) (*wallet.State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var st wallet.State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// SavePartialTx 保存部分签名交易文件（不含私钥，可在联网与离线机器间拷贝）
+func SavePartialTx(path string, p *wallet.PartialTx) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadPartialTx 读取并校验部分签名交易文件
+func LoadPartialTx(path string) (*wallet.PartialTx, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p wallet.PartialTx
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse partial tx: %w", err)
+	}
+	if err := p.Check(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
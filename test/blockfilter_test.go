@@ -0,0 +1,84 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestSipHash24 SipHash-2-4 参考向量：密钥 00..0f，消息 00..0e
+func TestSipHash24(t *testing.T) {
+	msg := make([]byte, 15)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	if got := crypto.SipHash24(0x0706050403020100, 0x0f0e0d0c0b0a0908, msg); got != 0xa129ca6149be45e5 {
+		t.Fatalf("siphash = %x", got)
+	}
+}
+
+// TestBlockFilter 过滤器命中区块内全部输出脚本与被花费输出，不命中无关元素；编码损坏时报错
+func TestBlockFilter(t *testing.T) {
+	prevID := crypto.Hash256([]byte("prev"))
+	spend := &core.Transaction{
+		Inputs:  []core.TxInput{{TxID: prevID, Vout: 3}},
+		Outputs: []core.TxOutput{{Value: 5, ScriptPubKey: "bob"}},
+	}
+	// 固定区块头，使过滤器密钥与误报结果可复现
+	txs := append(dummyTxs(4), spend)
+	block := &core.Block{
+		Header:       core.BlockHeader{Version: 1, MerkleRoot: core.ComputeMerkleRoot(txs), Timestamp: 1700000000, Height: 1},
+		Transactions: txs,
+	}
+	hash := core.HashBlockHeader(&block.Header)
+	filter := core.BuildBlockFilter(block)
+
+	for _, item := range [][]byte{
+		core.FilterScriptElement("miner"),
+		core.FilterScriptElement("alice"),
+		core.FilterScriptElement("bob"),
+		core.FilterOutpointElement(prevID, 3),
+	} {
+		ok, err := core.FilterMatchAny(filter, hash, [][]byte{item})
+		if err != nil || !ok {
+			t.Fatalf("filter should match %q: %v", item, err)
+		}
+	}
+	var misses [][]byte
+	for i := 0; i < 1000; i++ {
+		misses = append(misses, core.FilterScriptElement(fmt.Sprintf("stranger-%d", i)))
+	}
+	misses = append(misses, core.FilterOutpointElement(prevID, 4))
+	if ok, err := core.FilterMatchAny(filter, hash, misses); err != nil || ok {
+		t.Fatalf("unrelated elements matched: %v", err)
+	}
+	// 过滤器以区块哈希为密钥，换用其他哈希查询不再命中
+	other := crypto.Hash256([]byte("other block"))
+	if ok, _ := core.FilterMatchAny(filter, other, [][]byte{core.FilterScriptElement("bob")}); ok {
+		t.Fatalf("filter keyed by block hash should not match under another key")
+	}
+
+	if _, err := core.FilterMatchAny(filter[:len(filter)-3], hash, misses); !errors.Is(err, core.ErrMalformedFilter) {
+		t.Fatalf("truncated filter: %v", err)
+	}
+	// 声明的元素数远超数据长度时直接拒绝，不按其分配内存
+	if _, err := core.FilterMatchAny([]byte{0xff, 0xff, 0xff, 0xff, 0x0f}, hash, misses); !errors.Is(err, core.ErrMalformedFilter) {
+		t.Fatalf("oversized element count: %v", err)
+	}
+	empty := core.BuildBlockFilter(&core.Block{Header: block.Header})
+	if ok, err := core.FilterMatchAny(empty, hash, misses); err != nil || ok {
+		t.Fatalf("empty filter: ok=%v err=%v", ok, err)
+	}
+
+	h0 := core.FilterHeader(filter, nil)
+	if !bytes.Equal(h0, core.FilterHeader(filter, make([]byte, 32))) {
+		t.Fatalf("first filter header should chain from 32 zero bytes")
+	}
+	if bytes.Equal(core.FilterHeader(empty, h0), core.FilterHeader(filter, h0)) {
+		t.Fatalf("filter header should commit to the filter")
+	}
+}
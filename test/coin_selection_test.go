@@ -0,0 +1,115 @@
+package test
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/wallet"
+)
+
+func coinsOf(values ...int64) []core.UTXO {
+	out := make([]core.UTXO, len(values))
+	for i, v := range values {
+		out[i] = core.UTXO{TxID: []byte{byte(i)}, Index: 0, Output: core.TxOutput{Value: v, ScriptPubKey: "me"}}
+	}
+	return out
+}
+
+func selectedValues(sel *wallet.Selection) []int64 {
+	var out []int64
+	for _, u := range sel.Coins {
+		out = append(out, u.Output.Value)
+	}
+	return out
+}
+
+// TestCoinSelectionStrategies 各策略在固定输入下结果确定
+func TestCoinSelectionStrategies(t *testing.T) {
+	coins := coinsOf(1, 2, 5, 10, 20, 50)
+	target := wallet.Target{Value: 12, Outputs: 1}
+
+	cases := []struct {
+		selector wallet.CoinSelector
+		want     []int64
+		change   int64
+	}{
+		{wallet.LargestFirst{}, []int64{50}, 38},
+		{wallet.SmallestFirst{}, []int64{1, 2, 5, 10}, 6},
+		{wallet.BranchAndBound{}, []int64{10, 2}, 0}, // 精确匹配，无需找零
+	}
+	for _, c := range cases {
+		sel, err := wallet.SelectCoins(c.selector, coins, target)
+		if err != nil {
+			t.Fatalf("%s: %v", c.selector.Name(), err)
+		}
+		if got := selectedValues(sel); !reflect.DeepEqual(got, c.want) || sel.Change != c.change || sel.Fee != 0 {
+			t.Fatalf("%s: got %v change=%d fee=%d", c.selector.Name(), got, sel.Change, sel.Fee)
+		}
+	}
+}
+
+// TestCoinSelectionFees 按输入计费；找零低于粉尘阈值并入手续费
+func TestCoinSelectionFees(t *testing.T) {
+	coins := coinsOf(1, 2, 5, 10, 20, 50)
+	policy := wallet.FeePolicy{FeePerInput: 1, DustLimit: 3}
+	target := wallet.Target{Value: 12, Outputs: 1, Policy: policy}
+
+	// 有效价值（金额-1）中 9+4=13 落在 [12, 15]，剩余 1 低于粉尘阈值并入手续费
+	sel, err := wallet.SelectCoins(wallet.BranchAndBound{}, coins, target)
+	if err != nil {
+		t.Fatalf("bnb: %v", err)
+	}
+	if got := selectedValues(sel); !reflect.DeepEqual(got, []int64{10, 5}) || sel.Change != 0 || sel.Fee != 3 {
+		t.Fatalf("bnb with fees: got %v change=%d fee=%d", got, sel.Change, sel.Fee)
+	}
+
+	// 找零 13-10-1=2 < 3，不创建粉尘找零
+	sel, err = wallet.SelectCoins(wallet.LargestFirst{}, coinsOf(13), wallet.Target{Value: 10, Outputs: 1, Policy: policy})
+	if err != nil || sel.Change != 0 || sel.Fee != 3 {
+		t.Fatalf("dust change should go to fee: %+v, %v", sel, err)
+	}
+
+	// 无精确匹配时回退到 largest-first
+	sel, err = wallet.SelectCoins(wallet.BranchAndBound{}, coinsOf(50), target)
+	if err != nil || sel.Change != 37 || sel.Fee != 1 {
+		t.Fatalf("bnb fallback: %+v, %v", sel, err)
+	}
+
+	if _, err := wallet.SelectCoins(wallet.SmallestFirst{}, coinsOf(3, 4), target); !errors.Is(err, wallet.ErrInsufficientFunds) {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+}
+
+// TestRandomImproveDeterministic 相同随机种子得到相同选择，结果覆盖金额
+func TestRandomImproveDeterministic(t *testing.T) {
+	coins := coinsOf(3, 4, 6, 7, 8, 9, 11, 15, 30)
+	target := wallet.Target{Value: 10, Outputs: 1}
+	var prev []int64
+	for i := 0; i < 2; i++ {
+		sel, err := wallet.ParseCoinSelector("random", rand.New(rand.NewSource(42)))
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		got, err := wallet.SelectCoins(sel, coins, target)
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		var total int64
+		for _, v := range selectedValues(got) {
+			total += v
+		}
+		if total < target.Value {
+			t.Fatalf("unexpected total %d", total)
+		}
+		if prev != nil && !reflect.DeepEqual(prev, selectedValues(got)) {
+			t.Fatalf("same seed should give same selection: %v vs %v", prev, selectedValues(got))
+		}
+		prev = selectedValues(got)
+	}
+	if _, err := wallet.ParseCoinSelector("nope", nil); err == nil {
+		t.Fatalf("unknown strategy should fail")
+	}
+}
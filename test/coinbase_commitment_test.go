@@ -0,0 +1,50 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+)
+
+// TestCoinbaseHeightCommitment 同一矿工在不同高度的 coinbase ID 不同，且承诺高度需与区块头一致
+func TestCoinbaseHeightCommitment(t *testing.T) {
+	cb0 := core.NewCoinbaseTx("miner", 50, 0)
+	cb1 := core.NewCoinbaseTx("miner", 50, 1)
+	if bytes.Equal(core.ComputeTxID(cb0), core.ComputeTxID(cb1)) {
+		t.Fatalf("coinbase txids at different heights should differ")
+	}
+
+	tagged := core.NewCoinbaseTxWithExtra("miner", 50, 300, []byte("pool-a"))
+	h, err := core.CoinbaseHeight(tagged)
+	if err != nil || h != 300 {
+		t.Fatalf("expect committed height 300, got %d err=%v", h, err)
+	}
+
+	genesis := core.MineBlock(nil, []*core.Transaction{cb0}, 0)
+	if err := core.ValidateCoinbase(genesis); err != nil {
+		t.Fatalf("genesis coinbase should be valid: %v", err)
+	}
+	// 两个区块奖励同一地址，UTXO 不会相互覆盖
+	block1 := core.MineBlock(genesis, []*core.Transaction{cb1}, 0)
+	utxos := core.BuildUTXOSet([]*core.Block{genesis, block1})
+	if len(utxos) != 2 {
+		t.Fatalf("expect 2 distinct coinbase utxo entries, got %d", len(utxos))
+	}
+
+	// 承诺高度与区块头不符
+	wrong := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("miner", 50, 0)}, 0)
+	if err := core.ValidateCoinbase(wrong); err == nil {
+		t.Fatalf("mismatched coinbase height should be rejected")
+	}
+	// 缺少高度承诺
+	bare := &core.Transaction{Outputs: []core.TxOutput{{Value: 50, ScriptPubKey: "miner"}}, IsCoinbase: true}
+	if err := core.ValidateCoinbase(core.MineBlock(nil, []*core.Transaction{bare}, 0)); err == nil {
+		t.Fatalf("coinbase without height commitment should be rejected")
+	}
+	// coinbase 必须位于首位且唯一
+	dup := core.MineBlock(genesis, []*core.Transaction{cb1, core.NewCoinbaseTx("other", 50, 1)}, 0)
+	if err := core.ValidateCoinbase(dup); err == nil {
+		t.Fatalf("multiple coinbases should be rejected")
+	}
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestCoinbaseMaturity coinbase 输出需经过共识参数规定的区块数才能花费
+func TestCoinbaseMaturity(t *testing.T) {
+	params := maturityParams(3)
+
+	w, err := crypto.GenerateWallet()
+	if err != nil {
+		t.Fatalf("wallet: %v", err)
+	}
+	addr := crypto.PublicKeyHex(w.PublicKey)
+
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx(addr, 50, 0)}, 0)
+	blocks := []*core.Block{genesis}
+	utxos := core.BuildUTXOSet(blocks)
+	coinbaseID := core.ComputeTxID(genesis.Transactions[0])
+
+	spend := signedSpend(t, w, coinbaseID, 0, core.SequenceFinal, "alice", 50)
+	if err := core.ValidateTransaction(spend, utxos, 2, 0, params); !errors.Is(err, core.ErrImmatureCoinbase) {
+		t.Fatalf("expect ErrImmatureCoinbase at height 2, got %v", err)
+	}
+	if err := core.ValidateTransaction(spend, utxos, 3, 0, params); err != nil {
+		t.Fatalf("coinbase should be mature at height 3: %v", err)
+	}
+
+	// 模板构建同样遵守成熟度
+	if got := core.SelectTransactions([]*core.Transaction{spend}, utxos, 1, 0, params); len(got) != 0 {
+		t.Fatalf("immature spend should not enter template, got %d", len(got))
+	}
+
+	// 普通交易输出不受成熟度约束
+	if !(core.UTXO{Height: 5}).Mature(5, params.CoinbaseMaturity) {
+		t.Fatalf("non-coinbase output should always be mature")
+	}
+}
+
+// maturityParams 返回 coinbase 成熟深度为 depth 的共识参数
+func maturityParams(depth uint64) core.ChainParams {
+	return core.ChainParams{CoinbaseMaturity: depth}
+}
@@ -74,7 +74,7 @@ func initChainLocal(store *storage.FileStorage, miner string, difficulty uint32)
 	if len(heights) > 0 {
 		return nil
 	}
-	genesisTx := core.NewCoinbaseTx(miner, 50)
+	genesisTx := core.NewCoinbaseTx(miner, 50, 0)
 	block := core.MineBlock(nil, []*core.Transaction{genesisTx}, difficulty)
 	if err := store.SaveBlock(block); err != nil {
 		return err
@@ -165,7 +165,11 @@ func mineOnceLocal(store *storage.FileStorage, miner string, difficulty uint32)
 		return err
 	}
 	pending := pool.Pending()
-	coinbase := core.NewCoinbaseTx(miner, 50)
+	var height uint64
+	if tip != nil {
+		height = tip.Header.Height + 1
+	}
+	coinbase := core.NewCoinbaseTx(miner, 50, height)
 	var baseTxs []*core.Transaction
 	baseTxs = append(baseTxs, coinbase)
 	baseTxs = append(baseTxs, pending...)
@@ -0,0 +1,123 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestChildPaysForParent 区块模板按祖先包手续费率挑选：高手续费子交易带动低手续费父交易优先打包
+func TestChildPaysForParent(t *testing.T) {
+	params := maturityParams(0)
+
+	w, err := crypto.GenerateWallet()
+	if err != nil {
+		t.Fatalf("wallet: %v", err)
+	}
+	addr := crypto.PublicKeyHex(w.PublicKey)
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx(addr, 50, 0)}, 0)
+	second := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx(addr, 50, 1)}, 0)
+	utxos := core.BuildUTXOSet([]*core.Block{genesis, second})
+	key := func(tx *core.Transaction) string { return crypto.HexEncode(core.ComputeTxID(tx)) }
+
+	// 父交易手续费 1，另一笔无关交易手续费 3
+	parent := signedSpend(t, w, core.ComputeTxID(genesis.Transactions[0]), 0, core.SequenceFinal, addr, 49)
+	other := signedSpend(t, w, core.ComputeTxID(second.Transactions[0]), 0, core.SequenceFinal, "bob", 47)
+	pool := core.NewTxPool()
+	for _, tx := range []*core.Transaction{parent, other} {
+		if _, err := pool.Submit(tx, utxos); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	oneTx := core.TxSize(parent)
+	if s := core.TxSize(other); s > oneTx {
+		oneTx = s
+	}
+	tmpl := core.BuildTemplate(pool.Pending(), utxos, 2, 0, oneTx, params)
+	if len(tmpl.Transactions) != 1 || key(tmpl.Transactions[0]) != key(other) || tmpl.Fees != 3 {
+		t.Fatalf("higher fee rate tx should win the only slot, got %d txs fees %d", len(tmpl.Transactions), tmpl.Fees)
+	}
+
+	// 子交易花费未确认父交易的输出：只能在包含池内输出的视图上校验通过
+	child := signedSpend(t, w, core.ComputeTxID(parent), 0, core.SequenceFinal, "alice", 39)
+	if err := core.ValidateTransaction(child, utxos, 2, 0, params); err == nil {
+		t.Fatalf("child should not validate against confirmed utxos only")
+	}
+	if err := core.ValidateTransaction(child, pool.UTXOView(utxos, 2, 0), 2, 0, params); err != nil {
+		t.Fatalf("child should validate against pool view: %v", err)
+	}
+	if _, err := pool.Submit(child, utxos); err != nil {
+		t.Fatalf("submit child: %v", err)
+	}
+	if got := pool.Ancestors(key(child)); len(got) != 1 || got[0] != key(parent) {
+		t.Fatalf("child ancestors: %v", got)
+	}
+	pkg, err := pool.AncestorPackage(key(child), utxos)
+	if err != nil || pkg.Count != 2 || pkg.Fee != 11 || pkg.Size != core.TxSize(parent)+core.TxSize(child) {
+		t.Fatalf("ancestor package: %+v, %v", pkg, err)
+	}
+	if desc, err := pool.DescendantPackage(key(parent), utxos); err != nil || desc.Count != 2 || desc.Fee != 11 {
+		t.Fatalf("descendant package: %+v, %v", desc, err)
+	}
+
+	// 父子包（11/2 笔）的手续费率高于无关交易（3/1 笔），父交易先于子交易打包
+	tmpl = core.BuildTemplate(pool.Pending(), utxos, 2, 0, pkg.Size, params)
+	if len(tmpl.Transactions) != 2 || key(tmpl.Transactions[0]) != key(parent) || key(tmpl.Transactions[1]) != key(child) {
+		t.Fatalf("parent+child package should be selected in dependency order")
+	}
+	if tmpl.Fees != 11 || tmpl.Size != pkg.Size {
+		t.Fatalf("template totals: fees %d size %d", tmpl.Fees, tmpl.Size)
+	}
+	if got := core.SelectTransactions(pool.Pending(), utxos, 2, 0, params); len(got) != 3 {
+		t.Fatalf("unlimited template should include all, got %d", len(got))
+	}
+
+	// 包内子交易签名无效时整包回滚，父交易仍能单独打包
+	forged := signedSpend(t, w, core.ComputeTxID(parent), 0, core.SequenceFinal, "mallory", 1)
+	forged.Inputs[0].Signature = child.Inputs[0].Signature
+	forged.ID = core.ComputeTxID(forged)
+	if got := core.BuildTemplate([]*core.Transaction{parent, forged}, utxos, 2, 0, 0, params); len(got.Transactions) != 1 || key(got.Transactions[0]) != key(parent) || got.Fees != 1 {
+		t.Fatalf("failed package should be rolled back, got %d txs fees %d", len(got.Transactions), got.Fees)
+	}
+	if len(utxos) != 2 {
+		t.Fatalf("template must not modify the caller's utxo set")
+	}
+
+	// 矿工最多领取出块补贴加手续费
+	block := core.MineBlock(second, append([]*core.Transaction{core.NewCoinbaseTx(addr, core.BlockSubsidy+11, 2)}, tmpl.Transactions...), 0)
+	if err := core.CheckCoinbaseValue(block, tmpl.Fees); err != nil {
+		t.Fatalf("coinbase with fees: %v", err)
+	}
+	if err := core.CheckCoinbaseValue(block, tmpl.Fees-1); err == nil {
+		t.Fatalf("coinbase above subsidy plus fees should be rejected")
+	}
+}
+
+// TestPackageLimit 未确认交易链超过 MaxPackageTxs 时拒绝入池
+func TestPackageLimit(t *testing.T) {
+	w, err := crypto.GenerateWallet()
+	if err != nil {
+		t.Fatalf("wallet: %v", err)
+	}
+	addr := crypto.PublicKeyHex(w.PublicKey)
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx(addr, 50, 0)}, 0)
+	utxos := core.BuildUTXOSet([]*core.Block{genesis})
+
+	pool := core.NewTxPool()
+	prev := core.ComputeTxID(genesis.Transactions[0])
+	value := int64(50)
+	for i := 0; i < core.MaxPackageTxs; i++ {
+		value--
+		tx := signedSpend(t, w, prev, 0, core.SequenceFinal, addr, value)
+		if _, err := pool.Submit(tx, utxos); err != nil {
+			t.Fatalf("submit chain tx %d: %v", i, err)
+		}
+		prev = core.ComputeTxID(tx)
+	}
+	tooLong := signedSpend(t, w, prev, 0, core.SequenceFinal, addr, value-1)
+	if _, err := pool.Submit(tooLong, utxos); !errors.Is(err, core.ErrPackageLimit) {
+		t.Fatalf("expected ErrPackageLimit, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestDeterministicSignatureRFC6979 使用 RFC 6979 A.2.5（P-256, SHA-256, "sample"）向量校验确定性签名
+func TestDeterministicSignatureRFC6979(t *testing.T) {
+	w, err := crypto.FromPrivateHex("c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721")
+	if err != nil {
+		t.Fatalf("from private hex: %v", err)
+	}
+	sig, err := w.Sign([]byte("sample"))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	again, _ := w.Sign([]byte("sample"))
+	if !bytes.Equal(sig, again) {
+		t.Fatalf("signature should be deterministic")
+	}
+
+	var parsed struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &parsed); err != nil {
+		t.Fatalf("parse sig: %v", err)
+	}
+	wantR, _ := new(big.Int).SetString("efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716", 16)
+	wantS, _ := new(big.Int).SetString("f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8", 16)
+	// RFC 给出的 s 为 high-S，本实现归一化为 N - s
+	wantS.Sub(elliptic.P256().Params().N, wantS)
+	if parsed.R.Cmp(wantR) != 0 || parsed.S.Cmp(wantS) != 0 {
+		t.Fatalf("rfc6979 vector mismatch: r=%x s=%x", parsed.R, parsed.S)
+	}
+	if !crypto.Verify(w.PublicKey, []byte("sample"), sig) {
+		t.Fatalf("verify failed for deterministic signature")
+	}
+
+	// high-S 形式虽数学有效，但非规范签名应被拒绝
+	highS, _ := asn1.Marshal(struct{ R, S *big.Int }{parsed.R, new(big.Int).Sub(elliptic.P256().Params().N, parsed.S)})
+	if crypto.Verify(w.PublicKey, []byte("sample"), highS) {
+		t.Fatalf("high-S signature should be rejected")
+	}
+	// 追加多余字节的非严格 DER 应被拒绝
+	if crypto.Verify(w.PublicKey, []byte("sample"), append(append([]byte{}, sig...), 0x00)) {
+		t.Fatalf("signature with trailing bytes should be rejected")
+	}
+}
+
+// TestPrivateKeyEncodingAndCompressedPubKey 私钥定长编码往返，压缩公钥可用于验签
+func TestPrivateKeyEncodingAndCompressedPubKey(t *testing.T) {
+	// 前导字节为 0 的私钥：旧实现会丢失前导零
+	w, err := crypto.FromPrivateHex("00" + strings.Repeat("11", 31))
+	if err != nil {
+		t.Fatalf("from private hex: %v", err)
+	}
+	privHex, err := crypto.PrivateKeyHex(w)
+	if err != nil {
+		t.Fatalf("private hex: %v", err)
+	}
+	if len(privHex) != 2*crypto.PrivateKeySize {
+		t.Fatalf("private key hex should be fixed width, got %d chars", len(privHex))
+	}
+	// 兼容旧版短编码
+	legacy, err := crypto.FromPrivateHex(strings.Repeat("11", 31))
+	if err != nil {
+		t.Fatalf("legacy short key: %v", err)
+	}
+	if !bytes.Equal(legacy.PublicKey, w.PublicKey) {
+		t.Fatalf("short and padded encodings should yield the same key")
+	}
+
+	compressed := w.CompressedPublicKey()
+	if len(compressed) != crypto.CompressedPubKeySize {
+		t.Fatalf("compressed pubkey should be 33 bytes, got %d", len(compressed))
+	}
+	fromFull, err := crypto.CompressPublicKey(w.PublicKey)
+	if err != nil || !bytes.Equal(fromFull, compressed) {
+		t.Fatalf("CompressPublicKey mismatch: %v", err)
+	}
+	msg := []byte("compressed")
+	sig, err := w.Sign(msg)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if !crypto.Verify(compressed, msg, sig) || !crypto.Verify(w.PublicKey, msg, sig) {
+		t.Fatalf("signature should verify with both pubkey forms")
+	}
+}
@@ -9,7 +9,7 @@ import (
 // TestDataStructuresSanity 验证核心数据结构的基础行为
 func TestDataStructuresSanity(t *testing.T) {
 	// 1) 交易 + Merkle 根
-	coinbase := core.NewCoinbaseTx("miner", 50)
+	coinbase := core.NewCoinbaseTx("miner", 50, 0)
 	tx2 := &core.Transaction{
 		Inputs:     nil,
 		Outputs:    []core.TxOutput{{Value: 10, ScriptPubKey: "alice"}},
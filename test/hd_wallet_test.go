@@ -0,0 +1,105 @@
+package test
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestBIP39Vector BIP39 官方向量：全零熵 + 口令 "TREZOR"
+func TestBIP39Vector(t *testing.T) {
+	mnemonic, err := crypto.EntropyToMnemonic(make([]byte, 16))
+	if err != nil {
+		t.Fatalf("entropy to mnemonic: %v", err)
+	}
+	want := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	if mnemonic != want {
+		t.Fatalf("mnemonic mismatch: %s", mnemonic)
+	}
+	seed := crypto.MnemonicToSeed(mnemonic, "TREZOR")
+	wantSeed := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if got := hex.EncodeToString(seed); got != wantSeed {
+		t.Fatalf("seed mismatch: %s", got)
+	}
+	// 改动最后一个词破坏校验和
+	if crypto.ValidateMnemonic(want[:len(want)-len("about")] + "abandon") {
+		t.Fatalf("mnemonic with bad checksum should be invalid")
+	}
+}
+
+// TestBIP32Vector BIP32 测试向量 1：混合硬化/普通派生
+func TestBIP32Vector(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := crypto.NewMasterKey(seed)
+	if err != nil {
+		t.Fatalf("master key: %v", err)
+	}
+	cases := map[string]string{
+		"m":                      "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+		"m/0'":                   "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+		"m/0'/1":                 "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368",
+		"m/0'/1/2'/2/1000000000": "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8",
+	}
+	for path, want := range cases {
+		k, err := master.DerivePath(path)
+		if err != nil {
+			t.Fatalf("derive %s: %v", path, err)
+		}
+		if got := hex.EncodeToString(k.PrivateKey()); got != want {
+			t.Fatalf("%s: got %s want %s", path, got, want)
+		}
+	}
+}
+
+// TestHDWalletRescan 恢复时按缺口限制找回已使用的最高索引
+func TestHDWalletRescan(t *testing.T) {
+	hd, err := crypto.GenerateHDWallet()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	used := map[string]bool{}
+	for _, d := range []struct {
+		change bool
+		index  uint32
+	}{{false, 0}, {false, 7}, {true, 2}} {
+		w, err := hd.DeriveKey(d.change, d.index)
+		if err != nil {
+			t.Fatalf("derive: %v", err)
+		}
+		used[crypto.PublicKeyHex(w.PublicKey)] = true
+	}
+
+	restored, err := crypto.NewHDWallet(hd.Mnemonic)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if err := restored.Rescan(func(a string) bool { return used[a] }); err != nil {
+		t.Fatalf("rescan: %v", err)
+	}
+	if restored.NextReceive != 8 || restored.NextChange != 3 {
+		t.Fatalf("unexpected counters: receive=%d change=%d", restored.NextReceive, restored.NextChange)
+	}
+	keys, err := restored.Keys()
+	if err != nil || len(keys) != 11 {
+		t.Fatalf("expected 11 keys, got %d (%v)", len(keys), err)
+	}
+
+	// 持久化往返：加密保存后按口令加载，计数保留
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	if err := storage.SaveHDWallet(path, restored, "pw"); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := storage.LoadHDWallet(path, "pw")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if loaded.Mnemonic != hd.Mnemonic || loaded.NextReceive != 8 || loaded.NextChange != 3 {
+		t.Fatalf("hd wallet round trip mismatch")
+	}
+	if _, err := storage.LoadWallet(path, "pw"); err != storage.ErrHDWallet {
+		t.Fatalf("expected ErrHDWallet, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+)
+
+// TestMerkleMutation [a,b,c] 与 [a,b,c,c] 共享 Merkle 根：变形、重复交易与伪造交易 ID 的区块均被拒绝
+func TestMerkleMutation(t *testing.T) {
+	txs := dummyTxs(3)
+	block := core.MineBlock(nil, txs, 0)
+	if err := core.CheckBlockMerkle(block); err != nil {
+		t.Fatalf("valid block: %v", err)
+	}
+
+	mutated := &core.Block{Header: block.Header, Transactions: append(append([]*core.Transaction(nil), txs...), txs[2])}
+	if !bytes.Equal(core.ComputeMerkleRoot(mutated.Transactions), block.Header.MerkleRoot) {
+		t.Fatalf("duplicate-leaf mutation should keep the root")
+	}
+	if err := core.CheckBlockMerkle(mutated); !errors.Is(err, core.ErrMerkleMutated) {
+		t.Fatalf("expected ErrMerkleMutated, got %v", err)
+	}
+
+	// 六笔交易时倒数两笔成对重复：第二层出现相同节点
+	six := dummyTxs(6)
+	deep := core.MineBlock(nil, append(append([]*core.Transaction(nil), six...), six[4], six[5]), 0)
+	if err := core.CheckBlockMerkle(deep); !errors.Is(err, core.ErrMerkleMutated) {
+		t.Fatalf("expected ErrMerkleMutated for repeated pair, got %v", err)
+	}
+
+	// 非相邻重复不改变根的结构，但同一交易不能出现两次
+	dup := core.MineBlock(nil, []*core.Transaction{txs[0], txs[1], txs[0]}, 0)
+	if err := core.CheckBlockMerkle(dup); !errors.Is(err, core.ErrDuplicateTx) {
+		t.Fatalf("expected ErrDuplicateTx, got %v", err)
+	}
+
+	// 交易携带的 ID 与内容不符
+	forged := *txs[1]
+	forged.ID = []byte("not-the-real-id")
+	bad := core.MineBlock(nil, []*core.Transaction{txs[0], &forged}, 0)
+	if err := core.CheckBlockMerkle(bad); err == nil {
+		t.Fatalf("tx id mismatch should be rejected")
+	}
+
+	// 包含证明不能借重复兄弟节点伪造
+	proof, err := core.BuildMerkleProof(mutated.Transactions, 2)
+	if err != nil {
+		t.Fatalf("build proof: %v", err)
+	}
+	if core.VerifyMerkleProof(proof, block.Header.MerkleRoot) == nil {
+		t.Fatalf("proof through a duplicated sibling should fail")
+	}
+}
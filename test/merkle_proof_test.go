@@ -0,0 +1,121 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+)
+
+func dummyTxs(n int) []*core.Transaction {
+	txs := []*core.Transaction{core.NewCoinbaseTx("miner", 50, 1)}
+	for i := 1; i < n; i++ {
+		txs = append(txs, &core.Transaction{Outputs: []core.TxOutput{{Value: int64(i), ScriptPubKey: "alice"}}})
+	}
+	return txs
+}
+
+// TestMerkleProof 每种交易数、每个位置的包含证明都能推出 Merkle 根；篡改证明被拒绝
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		txs := dummyTxs(n)
+		root := core.ComputeMerkleRoot(txs)
+		for i := 0; i < n; i++ {
+			proof, err := core.BuildMerkleProof(txs, i)
+			if err != nil {
+				t.Fatalf("build proof n=%d i=%d: %v", n, i, err)
+			}
+			if err := core.VerifyMerkleProof(proof, root); err != nil {
+				t.Fatalf("verify proof n=%d i=%d: %v", n, i, err)
+			}
+		}
+	}
+
+	txs := dummyTxs(5)
+	root := core.ComputeMerkleRoot(txs)
+	proof, err := core.BuildMerkleProof(txs, 2)
+	if err != nil {
+		t.Fatalf("build proof: %v", err)
+	}
+	bad := *proof
+	bad.Index = 3
+	if core.VerifyMerkleProof(&bad, root) == nil {
+		t.Fatalf("wrong index should fail")
+	}
+	bad = *proof
+	bad.Branch = append([][]byte{append([]byte(nil), proof.Branch[0]...)}, proof.Branch[1:]...)
+	bad.Branch[0][0] ^= 1
+	if core.VerifyMerkleProof(&bad, root) == nil {
+		t.Fatalf("tampered branch should fail")
+	}
+	bad = *proof
+	bad.Branch = proof.Branch[:len(proof.Branch)-1]
+	if core.VerifyMerkleProof(&bad, root) == nil {
+		t.Fatalf("short branch should fail")
+	}
+	if _, err := core.BuildMerkleProof(txs, 5); err == nil {
+		t.Fatalf("out of range index should fail")
+	}
+}
+
+// TestHeaderChainSPV 轻节点校验区块头链接与工作量证明，并以已验证的区块头确认交易包含证明
+func TestHeaderChainSPV(t *testing.T) {
+	genesis := core.MineBlock(nil, dummyTxs(1), 4)
+	block1 := core.MineBlock(genesis, dummyTxs(3), 4)
+	block1.Header.Timestamp = genesis.Header.Timestamp + 1
+	block1 = remine(block1)
+	block2 := core.MineBlock(block1, dummyTxs(2), 4)
+	block2.Header.Timestamp = block1.Header.Timestamp + 1
+	block2 = remine(block2)
+
+	chain, err := core.NewHeaderChain(genesis.Header, 4)
+	if err != nil {
+		t.Fatalf("new header chain: %v", err)
+	}
+	if err := chain.Append(block2.Header); err == nil {
+		t.Fatalf("non-consecutive header should be rejected")
+	}
+	weak := block1.Header
+	weak.Difficulty = 24
+	weak.Nonce = 0
+	if err := chain.Append(weak); err == nil {
+		t.Fatalf("header without valid pow should be rejected")
+	}
+	if lax, _ := core.NewHeaderChain(genesis.Header, 8); lax.Append(block1.Header) == nil {
+		t.Fatalf("header below minimum difficulty should be rejected")
+	}
+	for _, b := range []*core.Block{block1, block2} {
+		if err := chain.Append(b.Header); err != nil {
+			t.Fatalf("append header %d: %v", b.Header.Height, err)
+		}
+	}
+
+	proof, err := core.BuildMerkleProof(block1.Transactions, 2)
+	if err != nil {
+		t.Fatalf("build proof: %v", err)
+	}
+	confs, err := chain.VerifyTx(block1.Header, proof)
+	if err != nil || confs != 2 {
+		t.Fatalf("verify tx: confs=%d err=%v", confs, err)
+	}
+	// 证明提供方给出不在已验证链上的区块头
+	forged := block1.Header
+	forged.Nonce++
+	if _, err := chain.VerifyTx(forged, proof); err == nil {
+		t.Fatalf("header off the verified chain should be rejected")
+	}
+	// 证明与区块不符
+	other, _ := core.BuildMerkleProof(block2.Transactions, 1)
+	if _, err := chain.VerifyTx(block1.Header, other); err == nil {
+		t.Fatalf("proof from another block should be rejected")
+	}
+}
+
+// remine 在修改区块头后重新寻找满足难度的 nonce
+func remine(b *core.Block) *core.Block {
+	for nonce := uint64(0); ; nonce++ {
+		b.Header.Nonce = nonce
+		if core.ValidateBlockPOW(b) {
+			return b
+		}
+	}
+}
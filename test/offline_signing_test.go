@@ -0,0 +1,124 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/wallet"
+)
+
+// TestExtendedPublicKeyDerivation 账户扩展公钥派生的地址与私钥派生一致，且可序列化往返
+func TestExtendedPublicKeyDerivation(t *testing.T) {
+	hd, err := crypto.NewHDWallet("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about")
+	if err != nil {
+		t.Fatalf("hd wallet: %v", err)
+	}
+	account, err := crypto.ParseExtendedPublicKey(hd.AccountPublicKey().String())
+	if err != nil {
+		t.Fatalf("parse account key: %v", err)
+	}
+	for _, change := range []bool{false, true} {
+		for i := uint32(0); i < 5; i++ {
+			priv, err := hd.DeriveKey(change, i)
+			if err != nil {
+				t.Fatalf("derive key: %v", err)
+			}
+			pub, err := crypto.DeriveHDAddress(account, change, i)
+			if err != nil {
+				t.Fatalf("derive address: %v", err)
+			}
+			if pub != crypto.PublicKeyHex(priv.PublicKey) {
+				t.Fatalf("public derivation mismatch at change=%v index=%d", change, i)
+			}
+			byPath, err := hd.KeyAtPath(crypto.HDKeyPath(change, i))
+			if err != nil || crypto.PublicKeyHex(byPath.PublicKey) != pub {
+				t.Fatalf("key at path mismatch: %v", err)
+			}
+		}
+	}
+	if _, err := hd.KeyAtPath("m/44'/0'/1'/0/0"); err == nil {
+		t.Fatalf("path outside the account should be rejected")
+	}
+}
+
+// TestPartialTxMultiSigner 两个钱包分别签名各自的输入，全部签名后才能定稿；签名后篡改输出被拒绝
+func TestPartialTxMultiSigner(t *testing.T) {
+	a, _ := crypto.GenerateWalletWithScheme(crypto.SchemeSecp256k1)
+	b, _ := crypto.GenerateWallet()
+	addrA, addrB := crypto.PublicKeyHex(a.PublicKey), crypto.PublicKeyHex(b.PublicKey)
+	coins := []core.UTXO{
+		{TxID: []byte{1}, Index: 0, Output: core.TxOutput{Value: 30, ScriptPubKey: addrA}},
+		{TxID: []byte{2}, Index: 1, Output: core.TxOutput{Value: 20, ScriptPubKey: addrB}},
+	}
+	p, err := wallet.NewPartialTx(coins, []core.TxOutput{{Value: 45, ScriptPubKey: "alice"}}, 0, core.SequenceFinal, nil)
+	if err != nil {
+		t.Fatalf("new partial tx: %v", err)
+	}
+	if p.Fee() != 5 {
+		t.Fatalf("fee = %d, want 5", p.Fee())
+	}
+	only := func(w *crypto.Wallet) func(wallet.PartialInput) (*crypto.Wallet, error) {
+		return func(in wallet.PartialInput) (*crypto.Wallet, error) {
+			if in.Address == crypto.PublicKeyHex(w.PublicKey) {
+				return w, nil
+			}
+			return nil, nil
+		}
+	}
+
+	if n, err := p.Sign(only(a)); err != nil || n != 1 {
+		t.Fatalf("sign by a: n=%d err=%v", n, err)
+	}
+	if _, err := p.Finalize(); !errors.Is(err, wallet.ErrTxIncomplete) {
+		t.Fatalf("expected incomplete, got %v", err)
+	}
+	if n, err := p.Sign(only(b)); err != nil || n != 1 {
+		t.Fatalf("sign by b: n=%d err=%v", n, err)
+	}
+	tx, err := p.Finalize()
+	if err != nil {
+		t.Fatalf("finalize: %v", err)
+	}
+	if len(tx.ID) == 0 {
+		t.Fatalf("finalized tx should carry an id")
+	}
+
+	p.Tx.Outputs[0].Value = 49
+	if _, err := p.Finalize(); err == nil {
+		t.Fatalf("tampered outputs should invalidate signatures")
+	}
+}
+
+// TestPartialTxVerifyInputs 输入金额须与附带的前序交易一致，缺失或被替换的前序交易被拒绝
+func TestPartialTxVerifyInputs(t *testing.T) {
+	a, _ := crypto.GenerateWalletWithScheme(crypto.SchemeSecp256k1)
+	addrA := crypto.PublicKeyHex(a.PublicKey)
+	prev := core.NewCoinbaseTx(addrA, 50, 1)
+	prev.ID = core.ComputeTxID(prev)
+	coins := []core.UTXO{{TxID: prev.ID, Index: 0, Output: prev.Outputs[0]}}
+	p, err := wallet.NewPartialTx(coins, []core.TxOutput{{Value: 45, ScriptPubKey: "alice"}}, 0, core.SequenceFinal, nil)
+	if err != nil {
+		t.Fatalf("new partial tx: %v", err)
+	}
+	if err := p.VerifyInputs(); err == nil {
+		t.Fatalf("missing previous tx should fail verification")
+	}
+	p.Inputs[0].PrevTx = prev
+	if err := p.VerifyInputs(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	p.Inputs[0].Value = 500
+	if err := p.VerifyInputs(); err == nil {
+		t.Fatalf("inflated input value should fail verification")
+	}
+	// 同时改写前序交易金额会改变其哈希
+	forged := *prev
+	forged.Outputs = []core.TxOutput{{Value: 500, ScriptPubKey: addrA}}
+	p.Inputs[0].PrevTx = &forged
+	if err := p.VerifyInputs(); err == nil {
+		t.Fatalf("forged previous tx should fail verification")
+	}
+}
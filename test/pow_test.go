@@ -8,7 +8,7 @@ import (
 
 // TestPOWValidation 通过小难度挖块并校验 POW；篡改后应失败
 func TestPOWValidation(t *testing.T) {
-	txs := []*core.Transaction{core.NewCoinbaseTx("miner", 50)}
+	txs := []*core.Transaction{core.NewCoinbaseTx("miner", 50, 0)}
 	block := core.MineBlock(nil, txs, 4) // 小难度，快速出块
 	if !core.ValidateBlockPOW(block) {
 		t.Fatalf("valid block should pass POW validation")
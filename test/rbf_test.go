@@ -0,0 +1,69 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestReplaceByFee 未选择加入替换的冲突交易被拒绝；选择加入时更高手续费的替换驱逐原交易及其后代
+func TestReplaceByFee(t *testing.T) {
+	w, err := crypto.GenerateWallet()
+	if err != nil {
+		t.Fatalf("wallet: %v", err)
+	}
+	addr := crypto.PublicKeyHex(w.PublicKey)
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx(addr, 50, 0)}, 0)
+	utxos := core.BuildUTXOSet([]*core.Block{genesis})
+	coinbaseID := core.ComputeTxID(genesis.Transactions[0])
+	key := func(tx *core.Transaction) string { return crypto.HexEncode(core.ComputeTxID(tx)) }
+
+	// 不可替换：同一输出的第二笔花费被拒绝
+	pool := core.NewTxPool()
+	final := signedSpend(t, w, coinbaseID, 0, core.SequenceFinal, addr, 49)
+	if _, err := pool.Submit(final, utxos); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	conflict := signedSpend(t, w, coinbaseID, 0, core.SequenceFinal, addr, 40)
+	if _, err := pool.Submit(conflict, utxos); !errors.Is(err, core.ErrTxConflict) {
+		t.Fatalf("expected ErrTxConflict, got %v", err)
+	}
+	if pool.Size() != 1 {
+		t.Fatalf("conflicting tx should not be accepted alongside")
+	}
+
+	// 可替换：原交易手续费 1，子交易再付 1
+	pool = core.NewTxPool()
+	parent := signedSpend(t, w, coinbaseID, 0, core.SequenceMaxReplaceable, addr, 49)
+	child := signedSpend(t, w, core.ComputeTxID(parent), 0, core.SequenceFinal, "alice", 48)
+	for _, tx := range []*core.Transaction{parent, child} {
+		if _, err := pool.Submit(tx, utxos); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	if fee, err := pool.TxFee(child, utxos); err != nil || fee != 1 {
+		t.Fatalf("child fee from pool parent: %d, %v", fee, err)
+	}
+
+	// 手续费 2 不超过被驱逐交易手续费之和（1+1）
+	low := signedSpend(t, w, coinbaseID, 0, core.SequenceMaxReplaceable, "bob", 48)
+	if _, err := pool.Submit(low, utxos); !errors.Is(err, core.ErrReplacementFee) {
+		t.Fatalf("expected ErrReplacementFee, got %v", err)
+	}
+	bump := signedSpend(t, w, coinbaseID, 0, core.SequenceMaxReplaceable, "bob", 47)
+	evicted, err := pool.Submit(bump, utxos)
+	if err != nil {
+		t.Fatalf("replacement: %v", err)
+	}
+	if len(evicted) != 2 || pool.Size() != 1 {
+		t.Fatalf("expected parent and child evicted, got %v (pool %d)", evicted, pool.Size())
+	}
+	if _, ok := pool.Get(key(bump)); !ok {
+		t.Fatalf("replacement should be in pool")
+	}
+	if _, ok := pool.Get(key(child)); ok {
+		t.Fatalf("descendant of replaced tx should be evicted")
+	}
+}
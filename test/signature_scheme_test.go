@@ -0,0 +1,81 @@
+package test
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestSecp256k1Vector 私钥 1 对 "Satoshi Nakamoto" 的确定性签名（常见 RFC 6979 secp256k1 向量）
+func TestSecp256k1Vector(t *testing.T) {
+	w, err := crypto.FromPrivateHexWithScheme(crypto.SchemeSecp256k1, "0000000000000000000000000000000000000000000000000000000000000001")
+	if err != nil {
+		t.Fatalf("from private hex: %v", err)
+	}
+	// 公钥为生成元 G 的压缩形式，带 secp256k1 方案前缀
+	if got := crypto.PublicKeyHex(w.PublicKey); got != "110279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798" {
+		t.Fatalf("unexpected secp256k1 pubkey %s", got)
+	}
+	sig, err := w.Sign([]byte("Satoshi Nakamoto"))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	want := "3045" +
+		"022100934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8" +
+		"02202442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5"
+	if hex.EncodeToString(sig) != want {
+		t.Fatalf("secp256k1 signature mismatch: %x", sig)
+	}
+}
+
+// TestSignatureSchemesInTransactions 各签名方案的钱包均可签名交易，ValidateTransaction 按方案分派验签
+func TestSignatureSchemesInTransactions(t *testing.T) {
+	params := maturityParams(0)
+
+	for _, scheme := range []crypto.Scheme{crypto.SchemeP256, crypto.SchemeSecp256k1, crypto.SchemeEd25519} {
+		t.Run(scheme.String(), func(t *testing.T) {
+			w, err := crypto.GenerateWalletWithScheme(scheme)
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			if got, err := crypto.PublicKeyScheme(w.PublicKey); err != nil || got != scheme {
+				t.Fatalf("pubkey should carry scheme %s, got %s err=%v", scheme, got, err)
+			}
+
+			addr := crypto.PublicKeyHex(w.PublicKey)
+			genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx(addr, 50, 0)}, 0)
+			utxos := core.BuildUTXOSet([]*core.Block{genesis})
+			tx := signedSpend(t, w, core.ComputeTxID(genesis.Transactions[0]), 0, core.SequenceFinal, "alice", 50)
+			if err := core.ValidateTransaction(tx, utxos, 1, 0, params); err != nil {
+				t.Fatalf("validate %s tx: %v", scheme, err)
+			}
+
+			// 篡改输出后签名失效
+			tx.Outputs[0].Value = 49
+			if err := core.ValidateTransaction(tx, utxos, 1, 0, params); err == nil {
+				t.Fatalf("tampered %s tx should fail", scheme)
+			}
+
+			// 钱包文件记录方案，重新加载得到同一地址
+			path := filepath.Join(t.TempDir(), "wallet.json")
+			if err := storage.SaveWallet(path, w, ""); err != nil {
+				t.Fatalf("save wallet: %v", err)
+			}
+			loaded, err := storage.LoadOrCreateWallet(path, "")
+			if err != nil {
+				t.Fatalf("load wallet: %v", err)
+			}
+			if loaded.Scheme() != scheme || crypto.PublicKeyHex(loaded.PublicKey) != addr {
+				t.Fatalf("reloaded wallet mismatch for %s", scheme)
+			}
+		})
+	}
+
+	if _, err := crypto.ParsePublicKey([]byte{0x7f, 1, 2, 3}); err == nil {
+		t.Fatalf("unknown scheme prefix should be rejected")
+	}
+}
@@ -24,7 +24,7 @@ func TestStorageIsolationAndConsistency(t *testing.T) {
 	}
 
 	// 节点 A 写创世块与空池
-	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50)}, 4)
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50, 0)}, 4)
 	if err := store1.SaveBlock(genesis); err != nil {
 		t.Fatalf("save genesis A: %v", err)
 	}
@@ -33,7 +33,7 @@ func TestStorageIsolationAndConsistency(t *testing.T) {
 	}
 
 	// 节点 B 写自己的创世块，与 A 不同矿工
-	genesisB := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerB", 50)}, 4)
+	genesisB := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerB", 50, 0)}, 4)
 	if err := store2.SaveBlock(genesisB); err != nil {
 		t.Fatalf("save genesis B: %v", err)
 	}
@@ -69,7 +69,7 @@ func TestStorageIsolationAndConsistency(t *testing.T) {
 
 	// TxPool 隔离：A 写入一笔交易，不影响 B
 	poolA := core.NewTxPool()
-	tx := core.NewCoinbaseTx("someone", 1)
+	tx := core.NewCoinbaseTx("someone", 1, 0)
 	poolA.Add("tx1", tx)
 	if err := store1.SaveTxPool(poolA); err != nil {
 		t.Fatalf("save txpool A: %v", err)
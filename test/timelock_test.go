@@ -0,0 +1,95 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestAbsoluteLockTime 覆盖高度型/时间型 LockTime 与 SequenceFinal 豁免
+func TestAbsoluteLockTime(t *testing.T) {
+	tx := &core.Transaction{
+		Inputs:   []core.TxInput{{Sequence: 0}},
+		LockTime: 10,
+	}
+	if core.IsFinalTx(tx, 10, 0) {
+		t.Fatalf("height lock 10 should not be final at height 10")
+	}
+	if !core.IsFinalTx(tx, 11, 0) {
+		t.Fatalf("height lock 10 should be final at height 11")
+	}
+
+	tx.LockTime = core.LockTimeThreshold + 1000
+	if core.IsFinalTx(tx, 1<<40, int64(core.LockTimeThreshold)+1000) {
+		t.Fatalf("time lock should compare median time, not height")
+	}
+	if !core.IsFinalTx(tx, 0, int64(core.LockTimeThreshold)+1001) {
+		t.Fatalf("time lock should be final once median time passes")
+	}
+
+	// 所有输入均为 SequenceFinal 时 LockTime 失效
+	tx.Inputs[0].Sequence = core.SequenceFinal
+	if !core.IsFinalTx(tx, 0, 0) {
+		t.Fatalf("locktime should be ignored when all inputs are final")
+	}
+}
+
+// TestSequenceLockAndTemplate 相对时间锁未到期时校验返回 ErrTxNotFinal，且不会进入区块模板
+func TestSequenceLockAndTemplate(t *testing.T) {
+	params := maturityParams(0)
+
+	w, err := crypto.GenerateWallet()
+	if err != nil {
+		t.Fatalf("wallet: %v", err)
+	}
+	addr := crypto.PublicKeyHex(w.PublicKey)
+
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx(addr, 50, 0)}, 0)
+	blocks := []*core.Block{genesis}
+	utxos := core.BuildUTXOSet(blocks)
+	coinbaseID := core.ComputeTxID(genesis.Transactions[0])
+
+	// 输入要求距离创世至少 3 个区块
+	locked := signedSpend(t, w, coinbaseID, 0, 3, "alice", 50)
+	if err := core.ValidateTransaction(locked, utxos, 1, core.MedianTimePast(blocks), params); !errors.Is(err, core.ErrTxNotFinal) {
+		t.Fatalf("expect ErrTxNotFinal at height 1, got %v", err)
+	}
+	if err := core.ValidateTransaction(locked, utxos, 3, core.MedianTimePast(blocks), params); err != nil {
+		t.Fatalf("sequence lock should be satisfied at height 3: %v", err)
+	}
+
+	selected := core.SelectTransactions([]*core.Transaction{locked}, utxos, 1, core.MedianTimePast(blocks), params)
+	if len(selected) != 0 {
+		t.Fatalf("locked tx should be held out of template, got %d", len(selected))
+	}
+
+	// 禁用相对锁后可立即打包
+	ready := signedSpend(t, w, coinbaseID, 0, core.SequenceLockTimeDisableFlag, "alice", 50)
+	selected = core.SelectTransactions([]*core.Transaction{locked, ready}, utxos, 1, core.MedianTimePast(blocks), params)
+	if len(selected) != 1 || selected[0] != ready {
+		t.Fatalf("expect only the unlocked tx in template, got %d", len(selected))
+	}
+}
+
+// signedSpend 构造花费单个输出的签名交易
+func signedSpend(t *testing.T, w *crypto.Wallet, txid []byte, vout int, sequence uint32, to string, value int64) *core.Transaction {
+	t.Helper()
+	tx := &core.Transaction{
+		Inputs: []core.TxInput{{
+			TxID:     txid,
+			Vout:     vout,
+			PubKey:   w.PublicKey,
+			Sequence: sequence,
+		}},
+		Outputs: []core.TxOutput{{Value: value, ScriptPubKey: to}},
+	}
+	sig, err := w.Sign(core.TxSigningHash(tx))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	tx.Inputs[0].Signature = sig
+	tx.ID = core.ComputeTxID(tx)
+	return tx
+}
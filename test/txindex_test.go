@@ -0,0 +1,157 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestTxIndex 交易索引随区块写入与清除更新，索引日志缺失时从区块重建，写区块失败时不留下索引记录
+func TestTxIndex(t *testing.T) {
+	base := t.TempDir()
+	store, err := storage.NewFileStorage(base, "idx")
+	if err != nil {
+		t.Fatalf("new storage: %v", err)
+	}
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50, 0)}, 0)
+	block1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("minerA", 50, 1)}, 0)
+	for _, b := range []*core.Block{genesis, block1} {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+	id := crypto.HexEncode(core.ComputeTxID(block1.Transactions[0]))
+	tx, loc, ok, err := store.LookupTx(id)
+	if err != nil || !ok || loc.Height != 1 || loc.Index != 0 || tx.Outputs[0].ScriptPubKey != "minerA" {
+		t.Fatalf("lookup: %+v %v %v", loc, ok, err)
+	}
+
+	// 旧数据目录没有索引文件：首次查询时重建
+	if err := os.Remove(filepath.Join(base, "idx", "index", "blocks.log")); err != nil {
+		t.Fatalf("remove index: %v", err)
+	}
+	if _, loc, ok, err = store.LookupTx(id); err != nil || !ok || loc.Height != 1 {
+		t.Fatalf("lookup after rebuild: %+v %v %v", loc, ok, err)
+	}
+
+	// 旧版本日志不带区块难度：同样从区块重建，累计工作量按各区块难度计算
+	oldLog, err := os.ReadFile(filepath.Join(base, "idx", "index", "blocks.log"))
+	if err != nil {
+		t.Fatalf("read index log: %v", err)
+	}
+	oldLog = bytes.ReplaceAll(oldLog, []byte(`,"difficulty":0`), nil)
+	if err := os.WriteFile(filepath.Join(base, "idx", "index", "blocks.log"), oldLog, 0o644); err != nil {
+		t.Fatalf("write old index log: %v", err)
+	}
+	if work, err := store.ChainWork(); err != nil || work.Int64() != 2 {
+		t.Fatalf("chain work after rebuilding an old log: %v %v", work, err)
+	}
+
+	// 区块文件改名失败时撤销已追加的索引记录；日志末尾未写完的半行被忽略
+	logPath := filepath.Join(base, "idx", "index", "blocks.log")
+	before, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read index log: %v", err)
+	}
+	block2 := core.MineBlock(block1, []*core.Transaction{core.NewCoinbaseTx("minerB", 50, 2)}, 0)
+	occupied := filepath.Join(base, "idx", "blocks", "2.json")
+	if err := os.MkdirAll(filepath.Join(occupied, "x"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := store.SaveBlock(block2); err == nil {
+		t.Fatalf("save over a directory should fail")
+	}
+	if after, _ := os.ReadFile(logPath); !bytes.Equal(before, after) {
+		t.Fatalf("failed save must not leave an index record")
+	}
+	if err := os.RemoveAll(occupied); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if err := os.WriteFile(logPath, append(before, `{"height":2,"txi`...), 0o644); err != nil {
+		t.Fatalf("tear log: %v", err)
+	}
+	if _, loc, ok, err = store.LookupTx(id); err != nil || !ok || loc.Height != 1 {
+		t.Fatalf("lookup with torn log tail: %+v %v %v", loc, ok, err)
+	}
+
+	if err := store.ClearBlocks(); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if _, _, ok, err = store.LookupTx(id); err != nil || ok {
+		t.Fatalf("cleared chain should not contain tx: %v %v", ok, err)
+	}
+}
+
+// TestChainIndexCache 查询直接使用内存中的索引，不再逐次重放日志；新区块增量并入，其他实例写入的区块在日志变化后可见
+func TestChainIndexCache(t *testing.T) {
+	base := t.TempDir()
+	store, err := storage.NewFileStorage(base, "idx")
+	if err != nil {
+		t.Fatalf("new storage: %v", err)
+	}
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx("minerA", 50, 0)}, 0)
+	block1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("minerA", 50, 1)}, 0)
+	for _, b := range []*core.Block{genesis, block1} {
+		if err := store.SaveBlock(b); err != nil {
+			t.Fatalf("save block: %v", err)
+		}
+	}
+	if utxos, err := store.AddressUTXOs("minerA"); err != nil || len(utxos) != 2 {
+		t.Fatalf("address utxos: %+v %v", utxos, err)
+	}
+
+	// 日志长度不变时不重读：同长度的乱码不影响查询
+	logPath := filepath.Join(base, "idx", "index", "blocks.log")
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read index log: %v", err)
+	}
+	if err := os.WriteFile(logPath, bytes.Repeat([]byte("x"), len(data)), 0o644); err != nil {
+		t.Fatalf("scribble log: %v", err)
+	}
+	if filters, err := store.BlockFilters(); err != nil || len(filters) != 2 {
+		t.Fatalf("filters should be served from memory: %d %v", len(filters), err)
+	}
+	if err := os.WriteFile(logPath, data, 0o644); err != nil {
+		t.Fatalf("restore log: %v", err)
+	}
+
+	// 另一实例（如另一进程）写入区块后日志变长，本实例下次查询时重放
+	other, err := storage.NewFileStorage(base, "idx")
+	if err != nil {
+		t.Fatalf("new storage: %v", err)
+	}
+	block2 := core.MineBlock(block1, []*core.Transaction{core.NewCoinbaseTx("minerB", 50, 2)}, 0)
+	if err := other.SaveBlock(block2); err != nil {
+		t.Fatalf("save block: %v", err)
+	}
+	id := crypto.HexEncode(core.ComputeTxID(block2.Transactions[0]))
+	if _, loc, ok, err := store.LookupTx(id); err != nil || !ok || loc.Height != 2 {
+		t.Fatalf("lookup block written by another instance: %+v %v %v", loc, ok, err)
+	}
+
+	// 本实例写入的区块增量并入，过滤器头仍按高度链接
+	block3 := core.MineBlock(block2, []*core.Transaction{core.NewCoinbaseTx("minerB", 50, 3)}, 0)
+	if err := store.SaveBlock(block3); err != nil {
+		t.Fatalf("save block: %v", err)
+	}
+	filters, err := store.BlockFilters()
+	if err != nil || len(filters) != 4 {
+		t.Fatalf("filters after connect: %d %v", len(filters), err)
+	}
+	if err := store.RebuildIndex(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	rebuilt, err := store.BlockFilters()
+	if err != nil || !bytes.Equal(rebuilt[3].Header, filters[3].Header) {
+		t.Fatalf("incremental filter header differs from rebuilt one: %v", err)
+	}
+	if utxos, err := store.AddressUTXOs("minerB"); err != nil || len(utxos) != 2 {
+		t.Fatalf("address utxos after connect: %+v %v", utxos, err)
+	}
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// TestValueOverflow 交易输出、手续费与 coinbase 金额合计溢出 int64 时被拒绝，而不是回绕成小额通过校验
+func TestValueOverflow(t *testing.T) {
+	params := maturityParams(0)
+	w, err := crypto.GenerateWallet()
+	if err != nil {
+		t.Fatalf("wallet: %v", err)
+	}
+	addr := crypto.PublicKeyHex(w.PublicKey)
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx(addr, 50, 0)}, 0)
+	utxos := core.BuildUTXOSet([]*core.Block{genesis})
+
+	// 输出合计回绕为负数时曾绕过"输入不足"检查
+	spend := signedSpend(t, w, core.ComputeTxID(genesis.Transactions[0]), 0, core.SequenceFinal, "alice", math.MaxInt64)
+	spend.Outputs = append(spend.Outputs, core.TxOutput{Value: 2, ScriptPubKey: "bob"})
+	sig, _ := w.Sign(core.TxSigningHash(spend))
+	spend.Inputs[0].Signature = sig
+	if err := core.ValidateTransaction(spend, utxos, 1, 0, params); !errors.Is(err, core.ErrValueOverflow) {
+		t.Fatalf("overflowing outputs should be rejected, got %v", err)
+	}
+	if _, err := core.TxFee(spend, utxos); !errors.Is(err, core.ErrValueOverflow) {
+		t.Fatalf("fee of overflowing outputs should fail, got %v", err)
+	}
+
+	block := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx("miner", 60, 1)}, 0)
+	if err := core.CheckCoinbaseValue(block, 10); err != nil {
+		t.Fatalf("subsidy plus fees should cover coinbase: %v", err)
+	}
+	if err := core.CheckCoinbaseValue(block, math.MaxInt64-10); !errors.Is(err, core.ErrValueOverflow) {
+		t.Fatalf("subsidy plus fees overflow should be rejected, got %v", err)
+	}
+	block.Transactions[0].Outputs = append(block.Transactions[0].Outputs, core.TxOutput{Value: math.MaxInt64, ScriptPubKey: "miner"})
+	if err := core.CheckCoinbaseValue(block, 10); !errors.Is(err, core.ErrValueOverflow) {
+		t.Fatalf("coinbase outputs overflow should be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package test
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/storage"
+)
+
+// TestScryptVector RFC 7914 第 12 节向量：P="password", S="NaCl", N=1024, r=8, p=16
+func TestScryptVector(t *testing.T) {
+	dk, err := crypto.Scrypt([]byte("password"), []byte("NaCl"), 1024, 8, 16, 64)
+	if err != nil {
+		t.Fatalf("scrypt: %v", err)
+	}
+	want := "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b373162" +
+		"2eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"
+	if got := hex.EncodeToString(dk); got != want {
+		t.Fatalf("scrypt mismatch:\n got %s\nwant %s", got, want)
+	}
+}
+
+// TestEncryptedWalletRoundTrip 加密钱包文件不含明文私钥，口令正确可恢复，错误口令被拒绝
+func TestEncryptedWalletRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	w, err := storage.LoadOrCreateWalletWithScheme(path, crypto.SchemeSecp256k1, "correct horse")
+	if err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+	privHex, _ := crypto.PrivateKeyHex(w)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read wallet: %v", err)
+	}
+	if strings.Contains(string(data), privHex) || strings.Contains(string(data), "private_hex") {
+		t.Fatalf("encrypted wallet file leaks private key: %s", data)
+	}
+
+	loaded, err := storage.LoadWallet(path, "correct horse")
+	if err != nil {
+		t.Fatalf("load wallet: %v", err)
+	}
+	if got, _ := crypto.PrivateKeyHex(loaded); got != privHex {
+		t.Fatalf("private key mismatch after decrypt")
+	}
+
+	if _, err := storage.LoadWallet(path, "wrong"); !errors.Is(err, crypto.ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if _, err := storage.LoadWallet(path, ""); !errors.Is(err, storage.ErrWalletLocked) {
+		t.Fatalf("expected ErrWalletLocked, got %v", err)
+	}
+
+	// 地址无需口令即可读取
+	addr, err := storage.WalletAddress(path)
+	if err != nil {
+		t.Fatalf("wallet address: %v", err)
+	}
+	if addr != crypto.PublicKeyHex(w.PublicKey) {
+		t.Fatalf("address mismatch")
+	}
+}
+
+// TestLegacyWalletMigration 旧版明文钱包在提供口令时迁移为加密格式，地址保持不变
+func TestLegacyWalletMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	w, err := crypto.GenerateWallet()
+	if err != nil {
+		t.Fatalf("generate wallet: %v", err)
+	}
+	privHex, _ := crypto.PrivateKeyHex(w)
+	legacy := `{"private_hex":"` + privHex + `"}`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatalf("write legacy wallet: %v", err)
+	}
+
+	addr, err := storage.WalletAddress(path)
+	if err != nil || addr != crypto.PublicKeyHex(w.PublicKey) {
+		t.Fatalf("legacy address: %s, %v", addr, err)
+	}
+
+	if _, err := storage.LoadWallet(path, "secret"); err != nil {
+		t.Fatalf("migrate legacy wallet: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), privHex) {
+		t.Fatalf("migrated wallet still contains plaintext key")
+	}
+	loaded, err := storage.LoadWallet(path, "secret")
+	if err != nil {
+		t.Fatalf("load migrated wallet: %v", err)
+	}
+	if crypto.PublicKeyHex(loaded.PublicKey) != addr {
+		t.Fatalf("address changed after migration")
+	}
+}
+
+// TestScryptParamLimits 钱包文件中被篡改为超大值的 scrypt 参数在派生前即被拒绝
+func TestScryptParamLimits(t *testing.T) {
+	box, err := crypto.EncryptWithPassphrase([]byte("secret"), "pw")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	for _, tamper := range []func(b *crypto.EncryptedBox){
+		func(b *crypto.EncryptedBox) { b.N = 1 << 30 },
+		func(b *crypto.EncryptedBox) { b.R = 1 << 20 },
+		func(b *crypto.EncryptedBox) { b.P = 1 << 20 },
+		func(b *crypto.EncryptedBox) { b.N, b.R = crypto.MaxScryptN, crypto.MaxScryptR },
+	} {
+		bad := *box
+		tamper(&bad)
+		if _, err := crypto.DecryptWithPassphrase(&bad, "pw"); err == nil || errors.Is(err, crypto.ErrWrongPassphrase) {
+			t.Fatalf("oversized scrypt parameters N=%d r=%d p=%d should be rejected, got %v", bad.N, bad.R, bad.P, err)
+		}
+	}
+	if plain, err := crypto.DecryptWithPassphrase(box, "pw"); err != nil || string(plain) != "secret" {
+		t.Fatalf("default parameters should decrypt: %q %v", plain, err)
+	}
+}
+
+// TestResolvePassphrase 口令为空时报错，除非显式允许明文保存
+func TestResolvePassphrase(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	full := filepath.Join(dir, "full")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatalf("write passphrase file: %v", err)
+	}
+	if err := os.WriteFile(full, []byte("pw\n"), 0o600); err != nil {
+		t.Fatalf("write passphrase file: %v", err)
+	}
+	if pass, err := storage.ResolvePassphrase(full, false); err != nil || pass != "pw" {
+		t.Fatalf("passphrase file: %q %v", pass, err)
+	}
+	if _, err := storage.ResolvePassphrase(empty, false); !errors.Is(err, storage.ErrPassphraseRequired) {
+		t.Fatalf("empty passphrase should be rejected, got %v", err)
+	}
+	if pass, err := storage.ResolvePassphrase(empty, true); err != nil || pass != "" {
+		t.Fatalf("empty passphrase should be allowed with plaintext opt-out: %q %v", pass, err)
+	}
+	t.Setenv(storage.WalletPassphraseEnv, "")
+	if _, err := storage.ResolvePassphrase("", false); !errors.Is(err, storage.ErrPassphraseRequired) {
+		t.Fatalf("empty env passphrase should be rejected, got %v", err)
+	}
+}
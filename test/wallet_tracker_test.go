@@ -0,0 +1,82 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+	"github.com/yiqi-017/blockchain/wallet"
+)
+
+// TestWalletTrackerConnectDisconnect 钱包随区块连接/断开更新 UTXO 与历史，待确认交易锁定输出
+func TestWalletTrackerConnectDisconnect(t *testing.T) {
+	key, err := crypto.GenerateWallet()
+	if err != nil {
+		t.Fatalf("wallet: %v", err)
+	}
+	addr := crypto.PublicKeyHex(key.PublicKey)
+
+	genesis := core.MineBlock(nil, []*core.Transaction{core.NewCoinbaseTx(addr, 50, 0)}, 0)
+	b1 := core.MineBlock(genesis, []*core.Transaction{core.NewCoinbaseTx(addr, 50, 1)}, 0)
+	tracker := wallet.New([]string{addr})
+	tracker.CoinbaseMaturity = 1
+	if err := tracker.SyncChain([]*core.Block{genesis, b1}); err != nil {
+		t.Fatalf("sync chain: %v", err)
+	}
+	// 高度 1 的 coinbase 在下一个区块（高度 2）已成熟，深度 1
+	if b := tracker.Balance(1); b.Confirmed != 100 || b.Immature != 50 || b.Spendable != 50 {
+		t.Fatalf("unexpected balance before spend: %+v", b)
+	}
+
+	spend := signedSpend(t, key, core.ComputeTxID(genesis.Transactions[0]), 0, core.SequenceFinal, "alice", 50)
+	tracker.SyncPending([]*core.Transaction{spend})
+	if b := tracker.Balance(2); b.Locked != 50 || b.Spendable != 50 {
+		t.Fatalf("pending spend should lock its input: %+v", b)
+	}
+	if coins := tracker.SpendableCoins(2); len(coins) != 1 || coins[0].Height != 1 {
+		t.Fatalf("locked coin must not be selectable: %+v", coins)
+	}
+	if h := tracker.History(); len(h) != 3 || h[0].Confirmed || h[0].Net() != -50 {
+		t.Fatalf("pending entry should come first: %+v", h)
+	}
+
+	b2 := core.MineBlock(b1, []*core.Transaction{core.NewCoinbaseTx("bob", 50, 2), spend}, 0)
+	if err := tracker.ConnectBlock(b2); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	tracker.SyncPending(nil)
+	if b := tracker.Balance(3); b.Confirmed != 50 || b.Locked != 0 {
+		t.Fatalf("unexpected balance after confirm: %+v", b)
+	}
+
+	// 快照往返，地址集合不同则丢弃快照
+	data, err := json.Marshal(tracker.Snapshot())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var st wallet.State
+	if err := json.Unmarshal(data, &st); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	restored := wallet.New([]string{addr, "fresh-address"})
+	restored.CoinbaseMaturity = 1
+	if !restored.LoadSnapshot(&st) || restored.Balance(3) != tracker.Balance(3) {
+		t.Fatalf("snapshot round trip mismatch")
+	}
+	if wallet.New([]string{"other"}).LoadSnapshot(&st) {
+		t.Fatalf("snapshot with untracked addresses should be discarded")
+	}
+
+	// 重组：高度 2 被不含该花费的区块替换，输出恢复
+	b2alt := core.MineBlock(b1, []*core.Transaction{core.NewCoinbaseTxWithExtra("carol", 50, 2, []byte("alt"))}, 0)
+	if err := restored.SyncChain([]*core.Block{genesis, b1, b2alt}); err != nil {
+		t.Fatalf("reorg sync: %v", err)
+	}
+	if b := restored.Balance(3); b.Confirmed != 100 {
+		t.Fatalf("spent coin should be restored after reorg: %+v", b)
+	}
+	if h := restored.History(); len(h) != 2 {
+		t.Fatalf("disconnected tx should leave history, got %d entries", len(h))
+	}
+}
@@ -0,0 +1,269 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/yiqi-017/blockchain/core"
+)
+
+// ErrInsufficientFunds 表示可用输出不足以支付金额和手续费
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// bnbMaxTries 分支定界的最大搜索步数，超出后回退到 largest-first
+const bnbMaxTries = 100000
+
+// FeePolicy 手续费与找零规则；输入携带公钥和签名，是交易体积的主要来源，因此按输入计费
+type FeePolicy struct {
+	BaseFee      int64 // 每笔交易的固定手续费
+	FeePerInput  int64 // 每个输入的手续费
+	FeePerOutput int64 // 每个输出的手续费（含找零输出）
+	DustLimit    int64 // 找零低于该值时并入手续费，不创建找零输出
+}
+
+// Fee 计算给定输入、输出个数的手续费
+func (p FeePolicy) Fee(inputs, outputs int) int64 {
+	return p.BaseFee + int64(inputs)*p.FeePerInput + int64(outputs)*p.FeePerOutput
+}
+
+// Target 选币目标：支付金额与不含找零的输出个数
+type Target struct {
+	Value   int64
+	Outputs int
+	Policy  FeePolicy
+}
+
+// covers 判断 n 个输入、合计 total 时能否支付金额和（不含找零的）手续费
+func (t Target) covers(n int, total int64) bool {
+	return total >= t.Value+t.Policy.Fee(n, t.Outputs)
+}
+
+// Selection 选币结果
+type Selection struct {
+	Coins  []core.UTXO
+	Fee    int64
+	Change int64 // 0 表示不创建找零输出
+}
+
+// CoinSelector 选币策略
+type CoinSelector interface {
+	Name() string
+	// Select 从 coins 中选出足以覆盖 target 的输入
+	Select(coins []core.UTXO, target Target) ([]core.UTXO, error)
+}
+
+// ParseCoinSelector 按名称创建选币策略；random-improve 使用 rng 保证可复现
+func ParseCoinSelector(name string, rng *rand.Rand) (CoinSelector, error) {
+	switch name {
+	case "largest", "largest-first":
+		return LargestFirst{}, nil
+	case "smallest", "smallest-first":
+		return SmallestFirst{}, nil
+	case "bnb", "branch-and-bound":
+		return BranchAndBound{}, nil
+	case "random", "random-improve":
+		if rng == nil {
+			return nil, errors.New("random-improve requires a random source")
+		}
+		return &RandomImprove{Rand: rng}, nil
+	default:
+		return nil, fmt.Errorf("unknown coin selection strategy: %s", name)
+	}
+}
+
+// SelectCoins 执行选币并计算手续费和找零；找零低于粉尘阈值时并入手续费
+func SelectCoins(sel CoinSelector, coins []core.UTXO, target Target) (*Selection, error) {
+	if target.Value <= 0 {
+		return nil, errors.New("value must be positive")
+	}
+	chosen, err := sel.Select(coins, target)
+	if err != nil {
+		return nil, err
+	}
+	total := sumValues(chosen)
+	if !target.covers(len(chosen), total) {
+		return nil, ErrInsufficientFunds
+	}
+	withChange := total - target.Value - target.Policy.Fee(len(chosen), target.Outputs+1)
+	if withChange > 0 && withChange >= target.Policy.DustLimit {
+		return &Selection{Coins: chosen, Fee: target.Policy.Fee(len(chosen), target.Outputs+1), Change: withChange}, nil
+	}
+	return &Selection{Coins: chosen, Fee: total - target.Value}, nil
+}
+
+// LargestFirst 按金额从大到小累加，输入个数最少
+type LargestFirst struct{}
+
+func (LargestFirst) Name() string { return "largest-first" }
+
+func (LargestFirst) Select(coins []core.UTXO, target Target) ([]core.UTXO, error) {
+	sorted := sortedByValue(coins, true)
+	return accumulate(sorted, target)
+}
+
+// SmallestFirst 按金额从小到大累加，顺带合并零碎输出
+type SmallestFirst struct{}
+
+func (SmallestFirst) Name() string { return "smallest-first" }
+
+func (SmallestFirst) Select(coins []core.UTXO, target Target) ([]core.UTXO, error) {
+	sorted := sortedByValue(coins, false)
+	return accumulate(sorted, target)
+}
+
+// BranchAndBound 深度优先搜索无需找零的组合：合计落在 [金额+手续费, 金额+手续费+找零成本] 内，
+// 多出部分并入手续费；找不到时回退到 largest-first
+type BranchAndBound struct{}
+
+func (BranchAndBound) Name() string { return "branch-and-bound" }
+
+func (BranchAndBound) Select(coins []core.UTXO, target Target) ([]core.UTXO, error) {
+	sorted := sortedByValue(coins, true)
+	p := target.Policy
+	// 每个输入的有效价值 = 金额 - 该输入的手续费；有效价值非正的输入只会增加成本
+	var pool []core.UTXO
+	var effective []int64
+	for _, u := range sorted {
+		if v := u.Output.Value - p.FeePerInput; v > 0 {
+			pool = append(pool, u)
+			effective = append(effective, v)
+		}
+	}
+	low := target.Value + p.Fee(0, target.Outputs)
+	// 找零成本：多一个输出的手续费加上不值得找零的粉尘
+	high := low + p.FeePerOutput + p.DustLimit
+
+	remaining := make([]int64, len(effective)+1)
+	for i := len(effective) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + effective[i]
+	}
+	if remaining[0] < low {
+		return nil, ErrInsufficientFunds
+	}
+
+	var (
+		picked []int
+		best   []int
+		tries  int
+	)
+	var search func(i int, sum int64) bool
+	search = func(i int, sum int64) bool {
+		tries++
+		if tries > bnbMaxTries {
+			return false
+		}
+		if sum >= low {
+			if sum <= high {
+				best = append([]int(nil), picked...)
+				return true
+			}
+			return false // 超出上界，回溯
+		}
+		if i >= len(effective) || sum+remaining[i] < low {
+			return false
+		}
+		// 先尝试包含当前输入，再尝试跳过
+		picked = append(picked, i)
+		if search(i+1, sum+effective[i]) {
+			return true
+		}
+		picked = picked[:len(picked)-1]
+		// 跳过与上一个被跳过输入金额相同的分支，避免重复搜索
+		j := i + 1
+		for j < len(effective) && effective[j] == effective[i] {
+			j++
+		}
+		return search(j, sum)
+	}
+	if search(0, 0) {
+		out := make([]core.UTXO, len(best))
+		for k, idx := range best {
+			out[k] = pool[idx]
+		}
+		return out, nil
+	}
+	return LargestFirst{}.Select(coins, target)
+}
+
+// RandomImprove 随机选币后改进：先随机选到覆盖金额，再随机追加输入使找零接近支付金额
+// （找零大小与支付相当，后续交易更容易精确匹配，也避免产生粉尘）
+type RandomImprove struct {
+	Rand *rand.Rand
+}
+
+func (*RandomImprove) Name() string { return "random-improve" }
+
+func (r *RandomImprove) Select(coins []core.UTXO, target Target) ([]core.UTXO, error) {
+	order := r.Rand.Perm(len(coins))
+	var chosen []core.UTXO
+	var total int64
+	next := 0
+	for ; next < len(order) && !target.covers(len(chosen), total); next++ {
+		u := coins[order[next]]
+		chosen = append(chosen, u)
+		total += u.Output.Value
+	}
+	if !target.covers(len(chosen), total) {
+		return nil, ErrInsufficientFunds
+	}
+
+	// 改进阶段：理想合计为 2 倍金额，追加后更接近理想值且不超过 3 倍金额才采纳
+	ideal := 2*target.Value + target.Policy.Fee(len(chosen), target.Outputs+1)
+	limit := 3 * target.Value
+	for ; next < len(order); next++ {
+		u := coins[order[next]]
+		// 多一个输入，理想合计相应增加一份输入手续费
+		candidate := total + u.Output.Value
+		nextIdeal := ideal + target.Policy.FeePerInput
+		if candidate > limit || abs64(nextIdeal-candidate) >= abs64(ideal-total) {
+			continue
+		}
+		chosen = append(chosen, u)
+		total = candidate
+		ideal = nextIdeal
+	}
+	return chosen, nil
+}
+
+// accumulate 按顺序累加直到覆盖目标
+func accumulate(sorted []core.UTXO, target Target) ([]core.UTXO, error) {
+	var chosen []core.UTXO
+	var total int64
+	for _, u := range sorted {
+		chosen = append(chosen, u)
+		total += u.Output.Value
+		if target.covers(len(chosen), total) {
+			return chosen, nil
+		}
+	}
+	return nil, ErrInsufficientFunds
+}
+
+// sortedByValue 按金额排序的副本；金额相同时保持原顺序，结果可复现
+func sortedByValue(coins []core.UTXO, desc bool) []core.UTXO {
+	out := append([]core.UTXO(nil), coins...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if desc {
+			return out[i].Output.Value > out[j].Output.Value
+		}
+		return out[i].Output.Value < out[j].Output.Value
+	})
+	return out
+}
+
+func sumValues(coins []core.UTXO) int64 {
+	var total int64
+	for _, u := range coins {
+		total += u.Output.Value
+	}
+	return total
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
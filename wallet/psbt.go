@@ -0,0 +1,174 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// PartialTxVersion 部分签名交易文件格式版本
+const PartialTxVersion = 1
+
+// ErrTxIncomplete 表示仍有输入未签名
+var ErrTxIncomplete = errors.New("transaction is not fully signed")
+
+// PartialInput 部分签名交易中每个输入的附加信息，供离线签名方核对金额与定位私钥
+type PartialInput struct {
+	Address string `json:"address"`        // 被花费输出的地址（公钥 hex）
+	Value   int64  `json:"value"`          // 被花费输出的金额
+	Path    string `json:"path,omitempty"` // HD 钱包派生路径，单密钥钱包为空
+
+	PrevTx *core.Transaction `json:"prev_tx,omitempty"` // 被花费输出所在的完整交易，离线签名方据此核对金额
+}
+
+// PartialTx 部分签名交易：未签名（或部分签名）的交易及其输入信息
+// 由只读节点 create、离线机器 sign、联网节点 broadcast
+type PartialTx struct {
+	Version int               `json:"version"`
+	Tx      *core.Transaction `json:"tx"`
+	Inputs  []PartialInput    `json:"inputs"`
+}
+
+// NewPartialTx 由选中的输出构造未签名交易；输入公钥取自被花费输出的地址，签名为空
+func NewPartialTx(coins []core.UTXO, outputs []core.TxOutput, lockTime uint64, sequence uint32, path func(address string) string) (*PartialTx, error) {
+	p := &PartialTx{
+		Version: PartialTxVersion,
+		Tx:      &core.Transaction{Outputs: append([]core.TxOutput(nil), outputs...), LockTime: lockTime},
+	}
+	for _, u := range coins {
+		pub, err := crypto.HexDecode(u.Output.ScriptPubKey)
+		if err != nil {
+			return nil, fmt.Errorf("output %x:%d is not a key address: %w", u.TxID, u.Index, err)
+		}
+		p.Tx.Inputs = append(p.Tx.Inputs, core.TxInput{TxID: u.TxID, Vout: u.Index, PubKey: pub, Sequence: sequence})
+		in := PartialInput{Address: u.Output.ScriptPubKey, Value: u.Output.Value}
+		if path != nil {
+			in.Path = path(u.Output.ScriptPubKey)
+		}
+		p.Inputs = append(p.Inputs, in)
+	}
+	return p, nil
+}
+
+// Check 校验文件结构：版本、输入个数以及输入公钥与地址一致
+func (p *PartialTx) Check() error {
+	if p.Version != PartialTxVersion {
+		return fmt.Errorf("unsupported partial tx version %d", p.Version)
+	}
+	if p.Tx == nil || p.Tx.IsCoinbase {
+		return errors.New("partial tx has no spendable transaction")
+	}
+	if len(p.Tx.Inputs) == 0 || len(p.Tx.Inputs) != len(p.Inputs) {
+		return errors.New("partial tx input metadata does not match transaction")
+	}
+	for i, in := range p.Tx.Inputs {
+		if crypto.PublicKeyHex(in.PubKey) != p.Inputs[i].Address {
+			return fmt.Errorf("input %d public key does not match address", i)
+		}
+	}
+	return nil
+}
+
+// VerifyInputs 用每个输入附带的前序交易核对金额与地址：前序交易哈希须等于输入引用的交易 ID，
+// 被花费输出须与输入信息一致；未通过核对的文件中 Value 由创建方任意填写，不能据此显示手续费
+func (p *PartialTx) VerifyInputs() error {
+	if err := p.Check(); err != nil {
+		return err
+	}
+	for i, in := range p.Tx.Inputs {
+		prev := p.Inputs[i].PrevTx
+		if prev == nil {
+			return fmt.Errorf("input %d: missing previous transaction", i)
+		}
+		if !bytes.Equal(core.ComputeTxID(prev), in.TxID) {
+			return fmt.Errorf("input %d: previous transaction does not match txid %x", i, in.TxID)
+		}
+		if in.Vout < 0 || in.Vout >= len(prev.Outputs) {
+			return fmt.Errorf("input %d: previous transaction has no output %d", i, in.Vout)
+		}
+		out := prev.Outputs[in.Vout]
+		if out.Value != p.Inputs[i].Value || out.ScriptPubKey != p.Inputs[i].Address {
+			return fmt.Errorf("input %d: value or address does not match previous transaction", i)
+		}
+	}
+	return nil
+}
+
+// Fee 按输入信息计算手续费（输入合计 - 输出合计）；输入金额未经 VerifyInputs 核对时不可信
+func (p *PartialTx) Fee() int64 {
+	var fee int64
+	for _, in := range p.Inputs {
+		fee += in.Value
+	}
+	for _, out := range p.Tx.Outputs {
+		fee -= out.Value
+	}
+	return fee
+}
+
+// Sign 为 key 返回非空密钥的输入签名，返回本次签名的输入个数；已签名的输入保持不变
+// key 返回 nil 表示该输入不属于本签名方
+func (p *PartialTx) Sign(key func(in PartialInput) (*crypto.Wallet, error)) (int, error) {
+	if err := p.Check(); err != nil {
+		return 0, err
+	}
+	if p.Fee() < 0 {
+		return 0, errors.New("outputs exceed inputs")
+	}
+	signHash := core.TxSigningHash(p.Tx)
+	signed := 0
+	for i, in := range p.Inputs {
+		if len(p.Tx.Inputs[i].Signature) > 0 {
+			continue
+		}
+		k, err := key(in)
+		if err != nil {
+			return signed, fmt.Errorf("input %d: %w", i, err)
+		}
+		if k == nil {
+			continue
+		}
+		if crypto.PublicKeyHex(k.PublicKey) != in.Address {
+			return signed, fmt.Errorf("input %d: key does not match address %s", i, in.Address)
+		}
+		sig, err := k.Sign(signHash)
+		if err != nil {
+			return signed, err
+		}
+		p.Tx.Inputs[i].Signature = sig
+		signed++
+	}
+	return signed, nil
+}
+
+// Complete 判断全部输入是否已签名
+func (p *PartialTx) Complete() bool {
+	for _, in := range p.Tx.Inputs {
+		if len(in.Signature) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Finalize 验证全部签名并返回带 ID 的可广播交易
+func (p *PartialTx) Finalize() (*core.Transaction, error) {
+	if err := p.Check(); err != nil {
+		return nil, err
+	}
+	if !p.Complete() {
+		return nil, ErrTxIncomplete
+	}
+	signHash := core.TxSigningHash(p.Tx)
+	for i, in := range p.Tx.Inputs {
+		if !crypto.Verify(in.PubKey, signHash, in.Signature) {
+			return nil, fmt.Errorf("input %d: invalid signature", i)
+		}
+	}
+	tx := *p.Tx
+	tx.ID = core.ComputeTxID(&tx)
+	return &tx, nil
+}
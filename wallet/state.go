@@ -0,0 +1,68 @@
+package wallet
+
+import (
+	"sort"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// State 钱包已确认状态的可序列化快照；待确认状态每次从交易池重建，不持久化
+type State struct {
+	Addresses []string       `json:"addresses"`
+	Records   []blockRecord  `json:"records"`
+	Coins     []core.UTXO    `json:"coins"`
+	History   []HistoryEntry `json:"history"`
+}
+
+// Snapshot 导出已确认状态
+func (w *Wallet) Snapshot() *State {
+	st := &State{
+		Addresses: w.Addresses(),
+		Records:   append([]blockRecord(nil), w.records...),
+		Coins:     make([]core.UTXO, 0, len(w.coins)),
+		History:   make([]HistoryEntry, 0, len(w.history)),
+	}
+	for _, u := range w.coins {
+		st.Coins = append(st.Coins, u)
+	}
+	sort.Slice(st.Coins, func(i, j int) bool {
+		a, b := crypto.HexEncode(st.Coins[i].TxID), crypto.HexEncode(st.Coins[j].TxID)
+		if a != b {
+			return a < b
+		}
+		return st.Coins[i].Index < st.Coins[j].Index
+	})
+	for _, e := range w.history {
+		st.History = append(st.History, e)
+	}
+	sort.Slice(st.History, func(i, j int) bool { return st.History[i].TxID < st.History[j].TxID })
+	return st
+}
+
+// LoadSnapshot 恢复已确认状态，返回是否采用了快照
+// 钱包新增的地址视为未使用（HD 新分配地址），快照仍然有效；
+// 快照中有钱包已不跟踪的地址时丢弃快照，随后的 SyncChain 从创世重新扫描
+func (w *Wallet) LoadSnapshot(st *State) bool {
+	w.reset()
+	if st == nil || !w.coversAddresses(st.Addresses) {
+		return false
+	}
+	w.records = append([]blockRecord(nil), st.Records...)
+	for _, u := range st.Coins {
+		w.coins[Outpoint{TxID: crypto.HexEncode(u.TxID), Vout: u.Index}] = u
+	}
+	for _, e := range st.History {
+		w.history[e.TxID] = e
+	}
+	return true
+}
+
+func (w *Wallet) coversAddresses(addresses []string) bool {
+	for _, a := range addresses {
+		if !w.Owns(a) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,382 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/yiqi-017/blockchain/core"
+	"github.com/yiqi-017/blockchain/crypto"
+)
+
+// ErrBlockNotConnected 表示区块不能接在钱包当前跟踪的链尖之后
+var ErrBlockNotConnected = errors.New("block does not connect to wallet tip")
+
+// Outpoint 标识一个交易输出
+type Outpoint struct {
+	TxID string `json:"txid"` // 交易 ID hex
+	Vout int    `json:"vout"`
+}
+
+func (o Outpoint) String() string {
+	return fmt.Sprintf("%s:%d", o.TxID, o.Vout)
+}
+
+// HistoryEntry 一笔与钱包相关的交易
+type HistoryEntry struct {
+	TxID      string `json:"txid"`
+	Confirmed bool   `json:"confirmed"`
+	Height    uint64 `json:"height"`   // 所在区块高度（未确认为 0）
+	Time      int64  `json:"time"`     // 所在区块时间戳（未确认为 0）
+	Coinbase  bool   `json:"coinbase"` // 是否为挖矿奖励
+	Received  int64  `json:"received"` // 转入钱包地址的金额（含找零）
+	Sent      int64  `json:"sent"`     // 花费的钱包输出金额
+}
+
+// Net 返回该交易对钱包余额的净影响
+func (e HistoryEntry) Net() int64 {
+	return e.Received - e.Sent
+}
+
+// Balance 钱包余额分类
+type Balance struct {
+	Confirmed int64 `json:"confirmed"` // 已确认的钱包输出总额（含未成熟、已锁定）
+	Immature  int64 `json:"immature"`  // 其中尚未成熟的 coinbase 输出
+	Locked    int64 `json:"locked"`    // 其中被待确认交易占用的输出
+	Pending   int64 `json:"pending"`   // 待确认交易转入钱包且未被再次花费的金额
+	Spendable int64 `json:"spendable"` // 可立即用于新交易的金额
+}
+
+// blockRecord 已连接区块对钱包的影响，断开区块时据此撤销
+type blockRecord struct {
+	Height  uint64      `json:"height"`
+	Hash    string      `json:"hash"`
+	Created []Outpoint  `json:"created"` // 本块新增的钱包输出
+	Spent   []core.UTXO `json:"spent"`   // 本块花费的钱包输出（撤销时恢复）
+	History []string    `json:"history"` // 本块中与钱包相关的交易
+}
+
+// Wallet 跟踪一组地址拥有的 UTXO 和交易历史，随区块连接/断开增量更新
+type Wallet struct {
+	// CoinbaseMaturity coinbase 输出可花费前需经过的区块数，New 时取默认值，须与链的共识参数一致
+	CoinbaseMaturity uint64
+
+	addresses map[string]struct{}
+	coins     map[Outpoint]core.UTXO
+	records   []blockRecord // 按高度连续，records[i].Height == i
+	history   map[string]HistoryEntry
+
+	// 待确认交易：占用的已确认输出、转入钱包的输出
+	pending       map[string]HistoryEntry
+	locked        map[Outpoint]string // 输出 -> 占用它的待确认交易
+	pendingOutput map[Outpoint]core.UTXO
+}
+
+// New 创建跟踪指定地址（公钥 hex）的空钱包
+func New(addresses []string) *Wallet {
+	w := &Wallet{CoinbaseMaturity: core.DefaultCoinbaseMaturity, addresses: make(map[string]struct{}, len(addresses))}
+	for _, a := range addresses {
+		w.addresses[a] = struct{}{}
+	}
+	w.reset()
+	return w
+}
+
+func (w *Wallet) reset() {
+	w.coins = make(map[Outpoint]core.UTXO)
+	w.records = nil
+	w.history = make(map[string]HistoryEntry)
+	w.pending = make(map[string]HistoryEntry)
+	w.locked = make(map[Outpoint]string)
+	w.pendingOutput = make(map[Outpoint]core.UTXO)
+}
+
+// Addresses 返回跟踪的地址（升序）
+func (w *Wallet) Addresses() []string {
+	out := make([]string, 0, len(w.addresses))
+	for a := range w.addresses {
+		out = append(out, a)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Owns 判断地址是否属于钱包
+func (w *Wallet) Owns(address string) bool {
+	_, ok := w.addresses[address]
+	return ok
+}
+
+// TipHeight 返回已连接的最高区块高度，ok=false 表示尚未连接任何区块
+func (w *Wallet) TipHeight() (height uint64, ok bool) {
+	if len(w.records) == 0 {
+		return 0, false
+	}
+	return w.records[len(w.records)-1].Height, true
+}
+
+// ConnectBlock 将下一个区块的花费和新增输出应用到钱包
+func (w *Wallet) ConnectBlock(b *core.Block) error {
+	if b == nil {
+		return errors.New("block is nil")
+	}
+	if b.Header.Height != uint64(len(w.records)) {
+		return fmt.Errorf("%w: height %d, wallet has %d blocks", ErrBlockNotConnected, b.Header.Height, len(w.records))
+	}
+	if n := len(w.records); n > 0 && crypto.HexEncode(b.Header.PrevHash) != w.records[n-1].Hash {
+		return fmt.Errorf("%w: prev hash mismatch at height %d", ErrBlockNotConnected, b.Header.Height)
+	}
+
+	rec := blockRecord{
+		Height: b.Header.Height,
+		Hash:   crypto.HexEncode(core.HashBlockHeader(&b.Header)),
+	}
+	for _, tx := range b.Transactions {
+		txID := core.ComputeTxID(tx)
+		txIDHex := crypto.HexEncode(txID)
+		entry := HistoryEntry{
+			TxID:      txIDHex,
+			Confirmed: true,
+			Height:    b.Header.Height,
+			Time:      b.Header.Timestamp,
+			Coinbase:  tx.IsCoinbase,
+		}
+		if !tx.IsCoinbase {
+			for _, in := range tx.Inputs {
+				op := Outpoint{TxID: crypto.HexEncode(in.TxID), Vout: in.Vout}
+				if u, ok := w.coins[op]; ok {
+					entry.Sent += u.Output.Value
+					rec.Spent = append(rec.Spent, u)
+					delete(w.coins, op)
+				}
+			}
+		}
+		for i, out := range tx.Outputs {
+			if !w.Owns(out.ScriptPubKey) {
+				continue
+			}
+			op := Outpoint{TxID: txIDHex, Vout: i}
+			// 时间型相对锁只由共识层检查，钱包不跟踪输出的中位时间
+			w.coins[op] = core.UTXO{
+				TxID:     txID,
+				Index:    i,
+				Output:   out,
+				Height:   b.Header.Height,
+				Coinbase: tx.IsCoinbase,
+			}
+			entry.Received += out.Value
+			rec.Created = append(rec.Created, op)
+		}
+		if entry.Sent > 0 || entry.Received > 0 {
+			w.history[txIDHex] = entry
+			rec.History = append(rec.History, txIDHex)
+		}
+	}
+	w.records = append(w.records, rec)
+	return nil
+}
+
+// DisconnectBlock 撤销链尖区块对钱包的影响（重组时使用）
+func (w *Wallet) DisconnectBlock() error {
+	n := len(w.records)
+	if n == 0 {
+		return errors.New("no block to disconnect")
+	}
+	rec := w.records[n-1]
+	for _, op := range rec.Created {
+		delete(w.coins, op)
+	}
+	for _, u := range rec.Spent {
+		w.coins[Outpoint{TxID: crypto.HexEncode(u.TxID), Vout: u.Index}] = u
+	}
+	for _, id := range rec.History {
+		delete(w.history, id)
+	}
+	w.records = w.records[:n-1]
+	return nil
+}
+
+// SyncChain 使钱包跟随给定的链（按高度升序，从创世开始）：
+// 断开与链不一致的已连接区块，再连接新区块
+func (w *Wallet) SyncChain(blocks []*core.Block) error {
+	fork := 0
+	for fork < len(w.records) && fork < len(blocks) {
+		if crypto.HexEncode(core.HashBlockHeader(&blocks[fork].Header)) != w.records[fork].Hash {
+			break
+		}
+		fork++
+	}
+	for len(w.records) > fork {
+		if err := w.DisconnectBlock(); err != nil {
+			return err
+		}
+	}
+	for _, b := range blocks[fork:] {
+		if err := w.ConnectBlock(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncPending 用交易池内容重建待确认状态：锁定被占用的钱包输出，记录转入钱包的待确认输出
+// 交易与钱包相关的判定：任一输入公钥属于钱包，或任一输出发往钱包地址
+func (w *Wallet) SyncPending(txs []*core.Transaction) {
+	w.pending = make(map[string]HistoryEntry)
+	w.locked = make(map[Outpoint]string)
+	w.pendingOutput = make(map[Outpoint]core.UTXO)
+
+	relevant := make([]*core.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if tx == nil || tx.IsCoinbase {
+			continue
+		}
+		if _, ok := w.history[crypto.HexEncode(core.ComputeTxID(tx))]; ok {
+			continue // 已确认
+		}
+		if w.involves(tx) {
+			relevant = append(relevant, tx)
+		}
+	}
+	sort.Slice(relevant, func(i, j int) bool {
+		return bytes.Compare(core.ComputeTxID(relevant[i]), core.ComputeTxID(relevant[j])) < 0
+	})
+
+	// 先登记所有待确认输出，再处理花费，链式待确认交易与池中顺序无关
+	for _, tx := range relevant {
+		txID := core.ComputeTxID(tx)
+		for i, out := range tx.Outputs {
+			if w.Owns(out.ScriptPubKey) {
+				w.pendingOutput[Outpoint{TxID: crypto.HexEncode(txID), Vout: i}] = core.UTXO{TxID: txID, Index: i, Output: out}
+			}
+		}
+	}
+	spentPending := make(map[Outpoint]struct{})
+	for _, tx := range relevant {
+		txIDHex := crypto.HexEncode(core.ComputeTxID(tx))
+		entry := HistoryEntry{TxID: txIDHex}
+		for _, in := range tx.Inputs {
+			op := Outpoint{TxID: crypto.HexEncode(in.TxID), Vout: in.Vout}
+			if u, ok := w.coins[op]; ok {
+				entry.Sent += u.Output.Value
+				w.locked[op] = txIDHex
+			} else if u, ok := w.pendingOutput[op]; ok {
+				entry.Sent += u.Output.Value
+				spentPending[op] = struct{}{}
+			}
+		}
+		for _, out := range tx.Outputs {
+			if w.Owns(out.ScriptPubKey) {
+				entry.Received += out.Value
+			}
+		}
+		w.pending[txIDHex] = entry
+	}
+	for op := range spentPending {
+		delete(w.pendingOutput, op)
+	}
+}
+
+// involves 判断交易是否与钱包相关
+func (w *Wallet) involves(tx *core.Transaction) bool {
+	for _, in := range tx.Inputs {
+		if w.Owns(crypto.PublicKeyHex(in.PubKey)) {
+			return true
+		}
+	}
+	for _, out := range tx.Outputs {
+		if w.Owns(out.ScriptPubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// Balance 计算余额；spendHeight 为下一个区块高度，用于判断 coinbase 成熟度
+func (w *Wallet) Balance(spendHeight uint64) Balance {
+	var total Balance
+	for _, b := range w.AddressBalances(spendHeight) {
+		total.Add(b)
+	}
+	return total
+}
+
+// AddressBalances 按地址分别计算余额，跟踪的每个地址都有一项（无资金时为零值）
+func (w *Wallet) AddressBalances(spendHeight uint64) map[string]Balance {
+	out := make(map[string]Balance, len(w.addresses))
+	for a := range w.addresses {
+		out[a] = Balance{}
+	}
+	for op, u := range w.coins {
+		b := out[u.Output.ScriptPubKey]
+		b.Confirmed += u.Output.Value
+		_, locked := w.locked[op]
+		switch {
+		case locked:
+			b.Locked += u.Output.Value
+		case !u.Mature(spendHeight, w.CoinbaseMaturity):
+			b.Immature += u.Output.Value
+		default:
+			b.Spendable += u.Output.Value
+		}
+		out[u.Output.ScriptPubKey] = b
+	}
+	for _, u := range w.pendingOutput {
+		b := out[u.Output.ScriptPubKey]
+		b.Pending += u.Output.Value
+		out[u.Output.ScriptPubKey] = b
+	}
+	return out
+}
+
+// Add 累加另一组余额
+func (b *Balance) Add(o Balance) {
+	b.Confirmed += o.Confirmed
+	b.Immature += o.Immature
+	b.Locked += o.Locked
+	b.Pending += o.Pending
+	b.Spendable += o.Spendable
+}
+
+// SpendableCoins 返回已确认、已成熟且未被待确认交易占用的输出，按高度、交易 ID、序号排序
+func (w *Wallet) SpendableCoins(spendHeight uint64) []core.UTXO {
+	out := make([]core.UTXO, 0, len(w.coins))
+	for op, u := range w.coins {
+		if _, locked := w.locked[op]; locked || !u.Mature(spendHeight, w.CoinbaseMaturity) {
+			continue
+		}
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Height != out[j].Height {
+			return out[i].Height < out[j].Height
+		}
+		if c := bytes.Compare(out[i].TxID, out[j].TxID); c != 0 {
+			return c < 0
+		}
+		return out[i].Index < out[j].Index
+	})
+	return out
+}
+
+// History 返回交易历史：待确认在前，已确认按高度从新到旧
+func (w *Wallet) History() []HistoryEntry {
+	out := make([]HistoryEntry, 0, len(w.pending)+len(w.history))
+	for _, e := range w.pending {
+		out = append(out, e)
+	}
+	for _, e := range w.history {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Confirmed != out[j].Confirmed {
+			return !out[i].Confirmed
+		}
+		if out[i].Height != out[j].Height {
+			return out[i].Height > out[j].Height
+		}
+		return out[i].TxID < out[j].TxID
+	})
+	return out
+}